@@ -1,16 +1,32 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"github.com/gofrs/uuid"
 	"github.com/linkly-id/auth/internal/conf"
 	"github.com/linkly-id/auth/internal/models"
 	"github.com/linkly-id/auth/internal/storage"
+	"github.com/linkly-id/auth/internal/userexport"
+	"github.com/linkly-id/auth/internal/userimport"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var autoconfirm, isAdmin bool
 var audience string
+var importFormat, importOnConflict, importReport string
+var importBatchSize int
+var importDryRun bool
+var exportOutput, exportCreatedAfter, exportProvider string
+var exportIncludeSecrets bool
 
 func getAudience(c *conf.GlobalConfiguration) string {
 	if audience == "" {
@@ -25,12 +41,23 @@ func adminCmd() *cobra.Command {
 		Use: "admin",
 	}
 
-	adminCmd.AddCommand(&adminCreateUserCmd, &adminDeleteUserCmd)
+	adminCmd.AddCommand(&adminCreateUserCmd, &adminDeleteUserCmd, &adminImportCmd, &adminExportCmd)
 	adminCmd.PersistentFlags().StringVarP(&audience, "aud", "a", "", "Set the new user's audience")
 
 	adminCreateUserCmd.Flags().BoolVar(&autoconfirm, "confirm", false, "Automatically confirm user without sending an email")
 	adminCreateUserCmd.Flags().BoolVar(&isAdmin, "admin", false, "Create user with admin privileges")
 
+	adminImportCmd.Flags().StringVar(&importFormat, "format", "", "Format of the import file, ndjson or csv (defaults to csv for .csv files, otherwise ndjson)")
+	adminImportCmd.Flags().StringVar(&importOnConflict, "on-conflict", userimport.OnConflictSkip, "What to do with users that already exist, skip or update")
+	adminImportCmd.Flags().IntVar(&importBatchSize, "batch-size", userimport.DefaultBatchSize, "Number of users imported per transaction")
+	adminImportCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Validate and look up every user without importing them")
+	adminImportCmd.Flags().StringVar(&importReport, "report", "", "Write the NDJSON result of every record to this file instead of stdout")
+
+	adminExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write the NDJSON export to this file instead of stdout")
+	adminExportCmd.Flags().StringVar(&exportCreatedAfter, "created-after", "", "Only export users created after this RFC 3339 timestamp")
+	adminExportCmd.Flags().StringVar(&exportProvider, "provider", "", "Only export users with an identity of this provider")
+	adminExportCmd.Flags().BoolVar(&exportIncludeSecrets, "include-secrets", false, "Export password hashes and TOTP secrets as stored, encrypted when database encryption is enabled")
+
 	return adminCmd
 }
 
@@ -58,6 +85,138 @@ var adminDeleteUserCmd = cobra.Command{
 	},
 }
 
+var adminImportCmd = cobra.Command{
+	Use:   "import [file]",
+	Short: "Import users from an NDJSON or CSV file",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			logrus.Fatal("Not enough arguments to import command. Expected the file to import")
+			return
+		}
+
+		execWithConfigAndArgs(cmd, adminImport, args)
+	},
+}
+
+var adminExportCmd = cobra.Command{
+	Use:   "export",
+	Short: "Export users to an NDJSON file that can be imported",
+	Run: func(cmd *cobra.Command, args []string) {
+		execWithConfigAndArgs(cmd, adminExport, args)
+	},
+}
+
+func adminImport(config *conf.GlobalConfiguration, args []string) {
+	format := importFormat
+	if format == "" {
+		format = userimport.FormatNDJSON
+		if strings.EqualFold(filepath.Ext(args[0]), ".csv") {
+			format = userimport.FormatCSV
+		}
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		logrus.Fatalf("Error opening import file: %+v", err)
+	}
+	defer file.Close()
+
+	var report io.Writer = os.Stdout
+	if importReport != "" {
+		reportFile, err := os.Create(importReport)
+		if err != nil {
+			logrus.Fatalf("Error creating report file: %+v", err)
+		}
+		defer reportFile.Close()
+		report = reportFile
+	}
+
+	db, err := storage.Dial(config)
+	if err != nil {
+		logrus.Fatalf("Error opening database: %+v", err)
+	}
+	defer db.Close()
+
+	importer, err := userimport.NewImporter(db, userimport.Options{
+		Aud:        getAudience(config),
+		Role:       config.JWT.DefaultGroupName,
+		OnConflict: importOnConflict,
+		BatchSize:  importBatchSize,
+		DryRun:     importDryRun,
+		Encryption: config.Security.DBEncryption,
+	})
+	if err != nil {
+		logrus.Fatalf("Error importing users: %+v", err)
+	}
+
+	reader, err := userimport.NewReader(file, format)
+	if err != nil {
+		logrus.Fatalf("Error reading import file: %+v", err)
+	}
+
+	encoder := json.NewEncoder(report)
+	summary, err := importer.Import(context.Background(), reader, func(result userimport.Result) error {
+		return encoder.Encode(result)
+	})
+	fields := logrus.Fields{
+		"created": summary.Created,
+		"updated": summary.Updated,
+		"skipped": summary.Skipped,
+		"failed":  summary.Failed,
+		"dry_run": summary.DryRun,
+	}
+	if err != nil {
+		// the report accounts for the records up to the failure
+		fields["unprocessed"] = summary.Unprocessed
+		logrus.WithFields(fields).Fatalf("Error importing users: %+v", err)
+	}
+
+	logrus.WithFields(fields).Infof("Imported users from %s", args[0])
+}
+
+func adminExport(config *conf.GlobalConfiguration, args []string) {
+	opts := userexport.Options{
+		Aud:            getAudience(config),
+		Provider:       exportProvider,
+		IncludeSecrets: exportIncludeSecrets,
+	}
+
+	if exportCreatedAfter != "" {
+		createdAfter, err := time.Parse(time.RFC3339, exportCreatedAfter)
+		if err != nil {
+			logrus.Fatalf("Error parsing --created-after: %+v", err)
+		}
+		opts.CreatedAfter = &createdAfter
+	}
+
+	var output io.Writer = os.Stdout
+	if exportOutput != "" {
+		outputFile, err := os.Create(exportOutput)
+		if err != nil {
+			logrus.Fatalf("Error creating export file: %+v", err)
+		}
+		defer outputFile.Close()
+		output = outputFile
+	}
+
+	db, err := storage.Dial(config)
+	if err != nil {
+		logrus.Fatalf("Error opening database: %+v", err)
+	}
+	defer db.Close()
+
+	buffered := bufio.NewWriter(output)
+	count, err := userexport.Export(context.Background(), db, opts, buffered)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		logrus.Fatalf("Error exporting users: %+v", err)
+	}
+
+	logrus.Infof("Exported %d users", count)
+}
+
 func adminCreateUser(config *conf.GlobalConfiguration, args []string) {
 	db, err := storage.Dial(config)
 	if err != nil {
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+
+	"github.com/linkly-id/auth/internal/utilities"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var hibpHash string
+var hibpFalsePositives float64
+
+func hibpCmd() *cobra.Command {
+	var hibpCmd = &cobra.Command{
+		Use: "hibp",
+	}
+
+	hibpCmd.AddCommand(&hibpBuildFilterCmd)
+
+	hibpBuildFilterCmd.Flags().StringVar(&hibpHash, "hash", utilities.HIBPHashSHA1, "Hash of the dataset, sha1 or ntlm")
+	hibpBuildFilterCmd.Flags().Float64Var(&hibpFalsePositives, "false-positives", 0.0000099, "False positive rate of the filter")
+
+	return hibpCmd
+}
+
+var hibpBuildFilterCmd = cobra.Command{
+	Use:   "build-filter",
+	Short: "Build a compact filter from a Pwned Passwords dataset",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			logrus.Fatal("Not enough arguments to build-filter command. Expected dataset and filter paths")
+			return
+		}
+
+		hibpBuildFilter(args[0], args[1])
+	},
+}
+
+func hibpBuildFilter(datasetPath, filterPath string) {
+	dataset, err := os.Open(datasetPath) // #nosec G304 -- path is from the command line
+	if err != nil {
+		logrus.Fatalf("Error opening dataset: %+v", err)
+	}
+	defer dataset.Close()
+
+	filter, err := os.Create(filterPath) // #nosec G304 -- path is from the command line
+	if err != nil {
+		logrus.Fatalf("Error creating filter: %+v", err)
+	}
+	defer filter.Close()
+
+	w := bufio.NewWriter(filter)
+
+	count, err := utilities.BuildHIBPDatasetFilter(dataset, hibpHash, w, hibpFalsePositives)
+	if err != nil {
+		logrus.Fatalf("Error building filter: %+v", err)
+	}
+
+	if err := w.Flush(); err != nil {
+		logrus.Fatalf("Error writing filter: %+v", err)
+	}
+
+	logrus.Infof("Built filter of %d pwned passwords", count)
+}
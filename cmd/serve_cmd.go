@@ -12,6 +12,7 @@ import (
 
 	"github.com/linkly-id/auth/internal/api"
 	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/events"
 	"github.com/linkly-id/auth/internal/reloader"
 	"github.com/linkly-id/auth/internal/storage"
 	"github.com/linkly-id/auth/internal/utilities"
@@ -53,7 +54,15 @@ func serve(ctx context.Context) {
 	opts := []api.Option{
 		api.NewLimiterOptions(config),
 	}
-	a := api.NewAPIWithVersion(config, db, utilities.Version, opts...)
+	mdsOpt, err := api.NewWebAuthnMetadataOption(config)
+	if err != nil {
+		logrus.WithError(err).Fatal("unable to load WebAuthn metadata")
+	}
+	hibpOpt, err := api.NewHIBPDatasetOption(config)
+	if err != nil {
+		logrus.WithError(err).Fatal("unable to load pwned passwords dataset")
+	}
+	a := api.NewAPIWithVersion(config, db, utilities.Version, append(opts, mdsOpt, hibpOpt)...)
 	ah := reloader.NewAtomicHandler(a)
 	logrus.WithField("version", a.Version()).Infof("GoTrue API started on: %s", addr)
 
@@ -73,6 +82,14 @@ func serve(ctx context.Context) {
 	var wg sync.WaitGroup
 	defer wg.Wait() // Do not return to caller until this goroutine is done.
 
+	dispatcher := events.NewDispatcher(db, &config.Events)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		dispatcher.Run(ctx)
+	}()
+
 	if watchDir != "" {
 		wg.Add(1)
 		go func() {
@@ -80,9 +97,31 @@ func serve(ctx context.Context) {
 
 			fn := func(latestCfg *conf.GlobalConfiguration) {
 				log.Info("reloading api with new configuration")
+				latestMDSOpt, err := api.NewWebAuthnMetadataOption(latestCfg)
+				if err != nil {
+					log.WithError(err).Error("not reloading api, unable to load WebAuthn metadata")
+					return
+				}
+				latestHIBPOpt := hibpOpt
+				if !hibpOpt.Matches(latestCfg) {
+					latestHIBPOpt, err = api.NewHIBPDatasetOption(latestCfg)
+					if err != nil {
+						log.WithError(err).Error("not reloading api, unable to load pwned passwords dataset")
+						return
+					}
+				}
 				latestAPI := api.NewAPIWithVersion(
-					latestCfg, db, utilities.Version, opts...)
+					latestCfg, db, utilities.Version, append(opts, latestMDSOpt, latestHIBPOpt)...)
 				ah.Store(latestAPI)
+				if latestHIBPOpt != hibpOpt {
+					// requests still served by the previous API check
+					// passwords like the HIBP API was unreachable
+					if err := hibpOpt.Close(); err != nil {
+						log.WithError(err).Error("unable to close previous pwned passwords dataset")
+					}
+					hibpOpt = latestHIBPOpt
+				}
+				dispatcher.SetConfig(&latestCfg.Events)
 			}
 
 			rl := reloader.NewReloader(watchDir)
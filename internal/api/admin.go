@@ -2,7 +2,10 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/fatih/structs"
@@ -10,6 +13,7 @@ import (
 	"github.com/gofrs/uuid"
 	"github.com/linkly-id/auth/internal/api/apierrors"
 	"github.com/linkly-id/auth/internal/api/provider"
+	"github.com/linkly-id/auth/internal/hooks/v0hooks"
 	"github.com/linkly-id/auth/internal/models"
 	"github.com/linkly-id/auth/internal/observability"
 	"github.com/linkly-id/auth/internal/storage"
@@ -32,6 +36,8 @@ type AdminUserParams struct {
 	UserMetaData map[string]interface{} `json:"user_metadata"`
 	AppMetaData  map[string]interface{} `json:"app_metadata"`
 	BanDuration  string                 `json:"ban_duration"`
+
+	MustChangePassword *bool `json:"must_change_password"`
 }
 
 type adminUserDeleteParams struct {
@@ -41,11 +47,15 @@ type adminUserDeleteParams struct {
 type adminUserUpdateFactorParams struct {
 	FriendlyName string `json:"friendly_name"`
 	Phone        string `json:"phone"`
+	Email        string `json:"email"`
 }
 
 type AdminListUsersResponse struct {
 	Users []*models.User `json:"users"`
 	Aud   string         `json:"aud"`
+	// NextCursor is the cursor of the next page with cursor pagination,
+	// empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 func (a *API) loadUser(w http.ResponseWriter, r *http.Request) (context.Context, error) {
@@ -101,6 +111,69 @@ func (a *API) getAdminParams(r *http.Request) (*AdminUserParams, error) {
 	return params, nil
 }
 
+// userFilter returns the filter of a user search from the query parameters
+func userFilter(r *http.Request) (*models.UserFilter, error) {
+	query := r.URL.Query()
+	filter := &models.UserFilter{
+		Query:    query.Get("filter"),
+		Provider: query.Get("provider"),
+	}
+
+	for name, value := range map[string]**bool{
+		"confirmed": &filter.Confirmed,
+		"banned":    &filter.Banned,
+		"anonymous": &filter.Anonymous,
+		"sso_user":  &filter.SSOUser,
+		"has_mfa":   &filter.HasMFA,
+	} {
+		if param := query.Get(name); param != "" {
+			b, err := strconv.ParseBool(param)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a boolean", name)
+			}
+			*value = &b
+		}
+	}
+
+	for name, value := range map[string]**time.Time{
+		"created_after":       &filter.CreatedAfter,
+		"created_before":      &filter.CreatedBefore,
+		"last_sign_in_after":  &filter.LastSignInAfter,
+		"last_sign_in_before": &filter.LastSignInBefore,
+	} {
+		if param := query.Get(name); param != "" {
+			t, err := time.Parse(time.RFC3339, param)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+			}
+			*value = &t
+		}
+	}
+
+	for name := range query {
+		var metaData *map[string]string
+		var path string
+		if p, ok := strings.CutPrefix(name, "app_metadata."); ok {
+			metaData, path = &filter.AppMetaData, p
+		} else if p, ok := strings.CutPrefix(name, "user_metadata."); ok {
+			metaData, path = &filter.UserMetaData, p
+		} else {
+			continue
+		}
+
+		if err := models.ValidateMetaDataPath(path); err != nil {
+			return nil, err
+		}
+
+		if *metaData == nil {
+			*metaData = make(map[string]string)
+		}
+		(*metaData)[path] = query.Get(name)
+	}
+
+	return filter, nil
+}
+
 // adminUsers responds with a list of all users in a given audience
 func (a *API) adminUsers(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
@@ -112,14 +185,42 @@ func (a *API) adminUsers(w http.ResponseWriter, r *http.Request) error {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Bad Pagination Parameters: %v", err).WithInternalError(err)
 	}
 
-	sortParams, err := sort(r, map[string]bool{models.CreatedAt: true}, []models.SortField{{Name: models.CreatedAt, Dir: models.Descending}})
+	sortParams, err := sort(r, map[string]bool{models.CreatedAt: true, models.LastSignInAt: true, models.EmailAddress: true}, []models.SortField{{Name: models.CreatedAt, Dir: models.Descending}})
 	if err != nil {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Bad Sort Parameters: %v", err)
 	}
 
-	filter := r.URL.Query().Get("filter")
+	filter, err := userFilter(r)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Bad Filter Parameters: %v", err)
+	}
+
+	if r.URL.Query().Has("cursor") {
+		if len(sortParams.Fields) != 1 {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Bad Sort Parameters: cursor pagination sorts by a single field")
+		}
 
-	users, err := models.FindUsersInAudience(db, aud, pageParams, sortParams, filter)
+		if pageParams.PerPage == 0 {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Bad Pagination Parameters: per_page must be positive")
+		}
+
+		users, next, err := models.SearchUsersAfter(db, aud, filter, sortParams.Fields[0], r.URL.Query().Get("cursor"), int(pageParams.PerPage)) // #nosec G115
+		if err != nil {
+			if _, ok := err.(models.InvalidCursorError); ok {
+				return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Bad Pagination Parameters: %v", err)
+			}
+			return apierrors.NewInternalServerError("Database error finding users").WithInternalError(err)
+		}
+		addCursorPaginationHeaders(w, r, next)
+
+		return sendJSON(w, http.StatusOK, AdminListUsersResponse{
+			Users:      users,
+			Aud:        aud,
+			NextCursor: next,
+		})
+	}
+
+	users, err := models.SearchUsers(db, aud, filter, pageParams, sortParams)
 	if err != nil {
 		return apierrors.NewInternalServerError("Database error finding users").WithInternalError(err)
 	}
@@ -150,6 +251,17 @@ func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	// signing in as a user of an admin role grants every scope
+	if getAdminAPIKey(ctx) != nil && isStringInSlice(user.Role, config.JWT.AdminRoles) {
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeAdminScopeMissing, "Admin API keys can't update users with admin roles")
+	}
+
+	if params.Role != "" {
+		if err := a.checkAdminRoleChange(ctx, params.Role); err != nil {
+			return err
+		}
+	}
+
 	if params.Email != "" {
 		params.Email, err = a.validateEmail(params.Email)
 		if err != nil {
@@ -176,13 +288,34 @@ func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 		banDuration = &duration
 	}
 
+	update := &v0hooks.UserUpdate{
+		Password:     params.Password != nil,
+		UserMetadata: params.UserMetaData,
+		AppMetadata:  params.AppMetaData,
+	}
+	if params.Email != "" && params.Email != user.GetEmail() {
+		update.Email = params.Email
+	}
+	if params.Phone != "" && params.Phone != user.GetPhone() {
+		update.Phone = params.Phone
+	}
+	if err := a.triggerBeforeUserUpdated(r, db, user, update); err != nil {
+		return err
+	}
+
+	var previousPassword *string
 	if params.Password != nil {
 		password := *params.Password
 
-		if err := a.checkPasswordStrength(ctx, password); err != nil {
+		if err := a.checkPasswordStrength(ctx, password, passwordUserInputs(user.GetEmail(), user.GetPhone(), user.UserMetaData)...); err != nil {
+			return err
+		}
+
+		if err := a.checkPasswordHistory(ctx, db, user, password); err != nil {
 			return err
 		}
 
+		previousPassword = user.EncryptedPassword
 		if err := user.SetPassword(ctx, password, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey); err != nil {
 			return err
 		}
@@ -211,6 +344,23 @@ func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 			if terr := user.UpdatePassword(tx, nil); terr != nil {
 				return terr
 			}
+
+			if terr := models.NewEvent(config.Events, tx, models.UserPasswordChangedEvent, user, nil); terr != nil {
+				return terr
+			}
+
+			if previousPassword != nil {
+				if terr := models.AddPasswordHistory(tx, user.ID, *previousPassword, a.previousPasswordsKept()); terr != nil {
+					return terr
+				}
+			}
+		}
+
+		// set after updating the password, which clears the flag
+		if params.MustChangePassword != nil {
+			if terr := user.SetMustChangePassword(tx, *params.MustChangePassword); terr != nil {
+				return terr
+			}
 		}
 
 		var identities []models.Identity
@@ -342,6 +492,12 @@ func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Cannot create a user without either an email or phone")
 	}
 
+	if params.Role != "" {
+		if err := a.checkAdminRoleChange(ctx, params.Role); err != nil {
+			return err
+		}
+	}
+
 	var providers []string
 	if params.Email != "" {
 		params.Email, err = a.validateEmail(params.Email)
@@ -373,6 +529,17 @@ func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Only a password or a password hash should be provided")
 	}
 
+	if params.Password != nil && *params.Password != "" {
+		if pwned, err := a.checkPwnedPassword(ctx, *params.Password); err != nil {
+			return err
+		} else if pwned {
+			return &WeakPasswordError{
+				Message: pwnedPasswordMessage,
+				Reasons: []string{"pwned"},
+			}
+		}
+	}
+
 	if (params.Password == nil || *params.Password == "") && params.PasswordHash == "" {
 		password, err := password.Generate(64, 10, 0, false, true)
 		if err != nil {
@@ -425,6 +592,10 @@ func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 		banDuration = &duration
 	}
 
+	if params.MustChangePassword != nil {
+		user.MustChangePassword = *params.MustChangePassword
+	}
+
 	err = db.Transaction(func(tx *storage.Connection) error {
 		if terr := tx.Create(user); terr != nil {
 			return terr
@@ -498,6 +669,10 @@ func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 			}
 		}
 
+		if terr := models.NewEvent(config.Events, tx, models.UserCreatedEvent, user, nil); terr != nil {
+			return terr
+		}
+
 		return nil
 	})
 
@@ -551,6 +726,10 @@ func (a *API) adminUserDelete(w http.ResponseWriter, r *http.Request) error {
 			if terr := models.DeleteFactorsByUserId(tx, user.ID); terr != nil {
 				return apierrors.NewInternalServerError("Error deleting user's factors").WithInternalError(terr)
 			}
+			// hard delete all previous password hashes
+			if terr := models.DeletePasswordHistoryByUser(tx, user.ID); terr != nil {
+				return apierrors.NewInternalServerError("Error deleting user's password history").WithInternalError(terr)
+			}
 			// hard delete all associated sessions
 			if terr := models.Logout(tx, user.ID); terr != nil {
 				return apierrors.NewInternalServerError("Error deleting user's sessions").WithInternalError(terr)
@@ -561,6 +740,78 @@ func (a *API) adminUserDelete(w http.ResponseWriter, r *http.Request) error {
 			}
 		}
 
+		// earlier events carry snapshots of the user, which shouldn't
+		// outlive them
+		if terr := models.DeleteEventsByUser(tx, user.ID); terr != nil {
+			return apierrors.NewInternalServerError("Error deleting user's events").WithInternalError(terr)
+		}
+
+		if terr := models.NewEvent(config.Events, tx, models.UserDeletedEvent, user, map[string]interface{}{
+			"soft_delete": params.ShouldSoftDelete,
+		}); terr != nil {
+			return apierrors.NewInternalServerError("Error recording event").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// adminUserUnlock clears all failed sign-in attempts and lockouts recorded
+// for the user and its identifiers
+func (a *API) adminUserUnlock(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	user := getUser(ctx)
+	config := a.config
+	adminUser := getAdminUser(ctx)
+
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, adminUser, models.UserUnlockedAction, "", map[string]interface{}{
+			"user_id":    user.ID,
+			"user_email": user.Email,
+			"user_phone": user.Phone,
+		}); terr != nil {
+			return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		if terr := models.ClearLoginLockouts(tx, userLockoutKeys(user)...); terr != nil {
+			return apierrors.NewInternalServerError("Database error clearing lockout").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, user)
+}
+
+// adminUserDeleteTrustedDevices revokes all devices trusted by the user, so
+// that the next sign-in on any of them requires MFA again
+func (a *API) adminUserDeleteTrustedDevices(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	user := getUser(ctx)
+	config := a.config
+	adminUser := getAdminUser(ctx)
+
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, adminUser, models.RevokeTrustedDeviceAction, "", map[string]interface{}{
+			"user_id":    user.ID,
+			"user_email": user.Email,
+			"user_phone": user.Phone,
+		}); terr != nil {
+			return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		if terr := models.DeleteTrustedDevicesByUser(tx, user.ID); terr != nil {
+			return apierrors.NewInternalServerError("Database error deleting trusted devices").WithInternalError(terr)
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -630,6 +881,16 @@ func (a *API) adminUserUpdateFactor(w http.ResponseWriter, r *http.Request) erro
 			}
 		}
 
+		if params.Email != "" && factor.IsEmailFactor() {
+			email, err := a.validateEmail(params.Email)
+			if err != nil {
+				return err
+			}
+			if terr := factor.UpdateEmail(tx, email); terr != nil {
+				return terr
+			}
+		}
+
 		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, adminUser, models.UpdateFactorAction, "", map[string]interface{}{
 			"user_id":     user.ID,
 			"factor_id":   factor.ID,
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/storage"
+)
+
+// AdminAPIKeyParams are the parameters of a new admin API key
+type AdminAPIKeyParams struct {
+	Name         string     `json:"name"`
+	Scopes       []string   `json:"scopes"`
+	AllowedCIDRs []string   `json:"allowed_cidrs"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+}
+
+// AdminAPIKeyResponse is an admin API key, with the key itself only when
+// it's created
+type AdminAPIKeyResponse struct {
+	*models.AdminAPIKey
+	Scopes       []string `json:"scopes"`
+	AllowedCIDRs []string `json:"allowed_cidrs"`
+	Key          string   `json:"key,omitempty"`
+}
+
+func newAdminAPIKeyResponse(key *models.AdminAPIKey) *AdminAPIKeyResponse {
+	cidrs := []string{}
+	for _, prefix := range key.GetAllowedCIDRs() {
+		cidrs = append(cidrs, prefix.String())
+	}
+
+	return &AdminAPIKeyResponse{
+		AdminAPIKey:  key,
+		Scopes:       key.GetScopes(),
+		AllowedCIDRs: cidrs,
+	}
+}
+
+func (a *API) loadAdminAPIKey(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	keyID, err := uuid.FromString(chi.URLParam(r, "key_id"))
+	if err != nil {
+		return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeAdminAPIKeyNotFound, "Admin API key not found")
+	}
+
+	key, err := models.FindAdminAPIKeyByID(db, keyID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeAdminAPIKeyNotFound, "Admin API key not found")
+		}
+		return nil, apierrors.NewInternalServerError("Database error finding admin API key").WithInternalError(err)
+	}
+
+	return withTargetAdminAPIKey(ctx, key), nil
+}
+
+// adminAPIKeysList lists the admin API keys
+func (a *API) adminAPIKeysList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	keys, err := models.FindAdminAPIKeys(db)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding admin API keys").WithInternalError(err)
+	}
+
+	items := []*AdminAPIKeyResponse{}
+	for _, key := range keys {
+		items = append(items, newAdminAPIKeyResponse(key))
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": items,
+	})
+}
+
+// adminAPIKeysCreate creates an admin API key, returning the key in plain
+// text for the only time. Admin API keys can only create keys with scopes
+// they have themselves, restricted to their IP ranges and expiring no later
+// than they do, which the new key inherits unless it narrows them.
+func (a *API) adminAPIKeysCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.config
+	adminUser := getAdminUser(ctx)
+
+	params := &AdminAPIKeyParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if params.Name == "" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "name is required")
+	}
+
+	if params.ExpiresAt != nil && !params.ExpiresAt.After(time.Now()) {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "expires_at must be in the future")
+	}
+
+	caller := getAdminAPIKey(ctx)
+	if caller != nil {
+		for _, scope := range params.Scopes {
+			if !caller.HasScope(scope) {
+				return apierrors.NewForbiddenError(apierrors.ErrorCodeAdminScopeMissing, "Admin API key can't grant the %s scope it doesn't have", scope)
+			}
+		}
+
+		if len(params.AllowedCIDRs) == 0 {
+			for _, prefix := range caller.GetAllowedCIDRs() {
+				params.AllowedCIDRs = append(params.AllowedCIDRs, prefix.String())
+			}
+		}
+		if params.ExpiresAt == nil {
+			params.ExpiresAt = caller.ExpiresAt
+		}
+	}
+
+	key, plaintext, err := models.NewAdminAPIKey(params.Name, params.Scopes, params.AllowedCIDRs, params.ExpiresAt)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Invalid admin API key: %s", err.Error())
+	}
+
+	if caller != nil {
+		if !caller.GetAllowedCIDRs().Covers(key.GetAllowedCIDRs()) {
+			return apierrors.NewForbiddenError(apierrors.ErrorCodeAdminAPIKeyRestricted, "Admin API key can't grant IP ranges outside its own")
+		}
+		if caller.ExpiresAt != nil && key.ExpiresAt.After(*caller.ExpiresAt) {
+			return apierrors.NewForbiddenError(apierrors.ErrorCodeAdminAPIKeyRestricted, "Admin API key can't create keys expiring after it")
+		}
+	}
+
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Create(key); terr != nil {
+			return apierrors.NewInternalServerError("Database error creating admin API key").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, adminUser, models.AdminAPIKeyCreatedAction, "", map[string]interface{}{
+			"admin_api_key_id":   key.ID,
+			"admin_api_key_name": key.Name,
+			"scopes":             key.GetScopes(),
+		}); terr != nil {
+			return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	res := newAdminAPIKeyResponse(key)
+	res.Key = plaintext
+
+	return sendJSON(w, http.StatusCreated, res)
+}
+
+// adminAPIKeysGet returns an admin API key
+func (a *API) adminAPIKeysGet(w http.ResponseWriter, r *http.Request) error {
+	return sendJSON(w, http.StatusOK, newAdminAPIKeyResponse(getTargetAdminAPIKey(r.Context())))
+}
+
+// adminAPIKeysDelete revokes an admin API key. Admin API keys can only
+// revoke keys with scopes they have themselves.
+func (a *API) adminAPIKeysDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.config
+	adminUser := getAdminUser(ctx)
+	key := getTargetAdminAPIKey(ctx)
+
+	if caller := getAdminAPIKey(ctx); caller != nil {
+		for _, scope := range key.GetScopes() {
+			if !caller.HasScope(scope) {
+				return apierrors.NewForbiddenError(apierrors.ErrorCodeAdminScopeMissing, "Admin API key can't revoke keys with the %s scope it doesn't have", scope)
+			}
+		}
+	}
+
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, adminUser, models.AdminAPIKeyDeletedAction, "", map[string]interface{}{
+			"admin_api_key_id":   key.ID,
+			"admin_api_key_name": key.Name,
+		}); terr != nil {
+			return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		if terr := tx.Destroy(key); terr != nil {
+			return apierrors.NewInternalServerError("Database error deleting admin API key").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, newAdminAPIKeyResponse(key))
+}
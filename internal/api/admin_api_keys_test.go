@@ -0,0 +1,250 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func (ts *AdminTestSuite) adminRequest(method, path, bearer string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(ts.T(), json.NewEncoder(&buf).Encode(body))
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", bearer))
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	ts.API.handler.ServeHTTP(w, req)
+	return w
+}
+
+func (ts *AdminTestSuite) createAdminAPIKey(bearer string, params *AdminAPIKeyParams) *AdminAPIKeyResponse {
+	w := ts.adminRequest(http.MethodPost, "/admin/api_keys", bearer, params)
+	require.Equal(ts.T(), http.StatusCreated, w.Code, w.Body.String())
+
+	res := &AdminAPIKeyResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(res))
+	return res
+}
+
+func (ts *AdminTestSuite) TestAdminAPIKeys() {
+	created := ts.createAdminAPIKey(ts.token, &AdminAPIKeyParams{
+		Name:   "backoffice",
+		Scopes: []string{models.AdminScopeUsersRead, models.AdminScopeAdminAPIKeysWrite},
+	})
+	require.True(ts.T(), models.IsAdminAPIKey(created.Key))
+	require.Equal(ts.T(), []string{models.AdminScopeUsersRead, models.AdminScopeAdminAPIKeysWrite}, created.Scopes)
+
+	// the key is only returned when it's created
+	w := ts.adminRequest(http.MethodGet, "/admin/api_keys/"+created.ID.String(), ts.token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.NotContains(ts.T(), w.Body.String(), created.Key)
+
+	w = ts.adminRequest(http.MethodGet, "/admin/users", created.Key, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	for _, c := range []struct{ method, path string }{
+		{http.MethodPost, "/admin/users"},
+		{http.MethodGet, "/admin/audit"},
+		{http.MethodGet, "/admin/api_keys"},
+		{http.MethodGet, "/admin/sso/providers"},
+	} {
+		w = ts.adminRequest(c.method, c.path, created.Key, map[string]interface{}{})
+		require.Equal(ts.T(), http.StatusForbidden, w.Code, c.path)
+	}
+
+	key, err := models.FindAdminAPIKeyByID(ts.API.db, created.ID)
+	require.NoError(ts.T(), err)
+	require.NotNil(ts.T(), key.LastUsedAt)
+	require.Equal(ts.T(), "192.0.2.1", key.LastUsedIP.String())
+
+	entries := []models.AuditLogEntry{}
+	require.NoError(ts.T(), ts.API.db.Q().All(&entries))
+	used := 0
+	for _, entry := range entries {
+		if entry.Payload["action"] == string(models.AdminAPIKeyUsedAction) {
+			used++
+			require.Equal(ts.T(), created.ID.String(), entry.Payload["actor_id"])
+		}
+	}
+	require.Equal(ts.T(), 5, used)
+
+	// keys can't grant scopes they don't have
+	w = ts.adminRequest(http.MethodPost, "/admin/api_keys", created.Key, &AdminAPIKeyParams{
+		Name:   "escalated",
+		Scopes: []string{models.AdminScopeUsersWrite},
+	})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+	ts.createAdminAPIKey(created.Key, &AdminAPIKeyParams{
+		Name:   "read only",
+		Scopes: []string{models.AdminScopeUsersRead},
+	})
+
+	w = ts.adminRequest(http.MethodDelete, "/admin/api_keys/"+created.ID.String(), ts.token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = ts.adminRequest(http.MethodGet, "/admin/users", created.Key, nil)
+	require.Equal(ts.T(), http.StatusUnauthorized, w.Code)
+}
+
+func (ts *AdminTestSuite) TestAdminAPIKeyRestrictions() {
+	expiresAt := time.Now().Add(time.Hour)
+	restricted := ts.createAdminAPIKey(ts.token, &AdminAPIKeyParams{
+		Name:         "vpn only",
+		Scopes:       []string{models.AdminScopeUsersRead},
+		AllowedCIDRs: []string{"10.0.0.0/8"},
+		ExpiresAt:    &expiresAt,
+	})
+
+	w := ts.adminRequest(http.MethodGet, "/admin/users", restricted.Key, nil)
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+
+	key, err := models.FindAdminAPIKeyByID(ts.API.db, restricted.ID)
+	require.NoError(ts.T(), err)
+	require.Nil(ts.T(), key.LastUsedAt)
+
+	expired := time.Now().Add(-time.Minute)
+	key.ExpiresAt = &expired
+	key.AllowedCIDRs = ""
+	require.NoError(ts.T(), ts.API.db.UpdateOnly(key, "expires_at", "allowed_cidrs"))
+
+	w = ts.adminRequest(http.MethodGet, "/admin/users", restricted.Key, nil)
+	require.Equal(ts.T(), http.StatusUnauthorized, w.Code)
+
+	for _, params := range []*AdminAPIKeyParams{
+		{Scopes: []string{models.AdminScopeUsersRead}},
+		{Name: "no scopes"},
+		{Name: "unknown scope", Scopes: []string{"users:delete"}},
+		{Name: "invalid cidr", Scopes: []string{models.AdminScopeUsersRead}, AllowedCIDRs: []string{"vpn"}},
+		{Name: "expired", Scopes: []string{models.AdminScopeUsersRead}, ExpiresAt: &expired},
+	} {
+		w = ts.adminRequest(http.MethodPost, "/admin/api_keys", ts.token, params)
+		require.Equal(ts.T(), http.StatusBadRequest, w.Code, params.Name)
+	}
+
+	w = ts.adminRequest(http.MethodGet, "/admin/users", models.AdminAPIKeyPrefix+"unknown", nil)
+	require.Equal(ts.T(), http.StatusUnauthorized, w.Code)
+}
+
+func (ts *AdminTestSuite) TestAdminAPIKeyInheritance() {
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	creator := ts.createAdminAPIKey(ts.token, &AdminAPIKeyParams{
+		Name:         "provisioning",
+		Scopes:       []string{models.AdminScopeUsersRead, models.AdminScopeAdminAPIKeysWrite},
+		AllowedCIDRs: []string{"192.0.2.0/24"},
+		ExpiresAt:    &expiresAt,
+	})
+
+	// keys inherit the IP ranges and expiry of the key creating them
+	inherited := ts.createAdminAPIKey(creator.Key, &AdminAPIKeyParams{
+		Name:   "inherited",
+		Scopes: []string{models.AdminScopeUsersRead},
+	})
+	require.Equal(ts.T(), []string{"192.0.2.0/24"}, inherited.AllowedCIDRs)
+	require.NotNil(ts.T(), inherited.ExpiresAt)
+	require.True(ts.T(), inherited.ExpiresAt.Equal(expiresAt))
+
+	narrowed := ts.createAdminAPIKey(creator.Key, &AdminAPIKeyParams{
+		Name:         "narrowed",
+		Scopes:       []string{models.AdminScopeUsersRead},
+		AllowedCIDRs: []string{"192.0.2.1"},
+	})
+	require.Equal(ts.T(), []string{"192.0.2.1/32"}, narrowed.AllowedCIDRs)
+
+	later := expiresAt.Add(time.Hour)
+	for _, params := range []*AdminAPIKeyParams{
+		{Name: "anywhere", Scopes: []string{models.AdminScopeUsersRead}, AllowedCIDRs: []string{"0.0.0.0/0"}},
+		{Name: "later", Scopes: []string{models.AdminScopeUsersRead}, ExpiresAt: &later},
+	} {
+		w := ts.adminRequest(http.MethodPost, "/admin/api_keys", creator.Key, params)
+		require.Equal(ts.T(), http.StatusForbidden, w.Code, params.Name)
+	}
+
+	// keys can't revoke keys with scopes they don't have
+	privileged := ts.createAdminAPIKey(ts.token, &AdminAPIKeyParams{
+		Name:   "privileged",
+		Scopes: []string{models.AdminScopeUsersWrite},
+	})
+	w := ts.adminRequest(http.MethodDelete, "/admin/api_keys/"+privileged.ID.String(), creator.Key, nil)
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+
+	w = ts.adminRequest(http.MethodDelete, "/admin/api_keys/"+narrowed.ID.String(), creator.Key, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+}
+
+func (ts *AdminTestSuite) TestAdminAPIKeyRolesAndSecrets() {
+	writer := ts.createAdminAPIKey(ts.token, &AdminAPIKeyParams{
+		Name:   "provisioning",
+		Scopes: []string{models.AdminScopeUsersRead, models.AdminScopeUsersWrite},
+	})
+
+	u, err := models.NewUser("", "role@example.com", "password", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(u))
+
+	// setting roles needs the users:roles scope
+	w := ts.adminRequest(http.MethodPut, "/admin/users/"+u.ID.String(), writer.Key, map[string]interface{}{
+		"role": "editor",
+	})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code, w.Body.String())
+
+	w = ts.adminRequest(http.MethodPost, "/admin/users", writer.Key, map[string]interface{}{
+		"email": "other@example.com",
+		"role":  "editor",
+	})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code, w.Body.String())
+
+	w = ts.adminRequest(http.MethodGet, "/admin/users/export?include_secrets=true", writer.Key, nil)
+	require.Equal(ts.T(), http.StatusForbidden, w.Code, w.Body.String())
+
+	w = ts.adminRequest(http.MethodGet, "/admin/users/export", writer.Key, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	roles := ts.createAdminAPIKey(ts.token, &AdminAPIKeyParams{
+		Name:   "roles",
+		Scopes: []string{models.AdminScopeUsersWrite, models.AdminScopeUsersRoles},
+	})
+
+	w = ts.adminRequest(http.MethodPut, "/admin/users/"+u.ID.String(), roles.Key, map[string]interface{}{
+		"role": "editor",
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	// admin roles can only be granted with an admin JWT
+	w = ts.adminRequest(http.MethodPut, "/admin/users/"+u.ID.String(), roles.Key, map[string]interface{}{
+		"role": ts.Config.JWT.AdminRoles[0],
+	})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code, w.Body.String())
+
+	w = ts.adminRequest(http.MethodPut, "/admin/users/"+u.ID.String(), ts.token, map[string]interface{}{
+		"role": ts.Config.JWT.AdminRoles[0],
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	// nor can keys update users with them
+	w = ts.adminRequest(http.MethodPut, "/admin/users/"+u.ID.String(), roles.Key, map[string]interface{}{
+		"password": "new-password",
+	})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code, w.Body.String())
+
+	// or import users with them
+	w = ts.adminRequest(http.MethodPost, "/admin/users/import", roles.Key, map[string]interface{}{
+		"email": "imported@example.com",
+		"role":  ts.Config.JWT.AdminRoles[0],
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	var report AdminUsersImportResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&report))
+	require.Equal(ts.T(), 1, report.Failed)
+}
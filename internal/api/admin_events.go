@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/storage"
+)
+
+func (a *API) loadEvent(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	eventID, err := uuid.FromString(chi.URLParam(r, "event_id"))
+	if err != nil {
+		return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeEventNotFound, "Event not found")
+	}
+
+	event, err := models.FindEventByID(db, eventID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeEventNotFound, "Event not found")
+		}
+		return nil, apierrors.NewInternalServerError("Database error finding event").WithInternalError(err)
+	}
+
+	return withTargetEvent(ctx, event), nil
+}
+
+// adminEvents lists the events of the outbox, filtered by the status and
+// type query parameters.
+func (a *API) adminEvents(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	pageParams, err := paginate(r)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Bad Pagination Parameters: %v", err)
+	}
+
+	status := models.EventStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", models.EventPending, models.EventDelivered, models.EventDead:
+	default:
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "status must be one of pending, delivered or dead")
+	}
+
+	events, err := models.FindEvents(db, status, models.EventType(r.URL.Query().Get("type")), pageParams)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding events").WithInternalError(err)
+	}
+
+	addPaginationHeaders(w, r, pageParams)
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"events": events,
+	})
+}
+
+// adminEventGet returns an event of the outbox
+func (a *API) adminEventGet(w http.ResponseWriter, r *http.Request) error {
+	return sendJSON(w, http.StatusOK, getTargetEvent(r.Context()))
+}
+
+// adminEventReplay delivers an event again, whether it was delivered or
+// dead-lettered.
+func (a *API) adminEventReplay(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.config
+	adminUser := getAdminUser(ctx)
+	event := getTargetEvent(ctx)
+
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		if terr := event.Replay(tx); terr != nil {
+			return apierrors.NewInternalServerError("Database error replaying event").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, adminUser, models.EventsReplayedAction, "", map[string]interface{}{
+			"event_id":   event.ID,
+			"event_type": event.Type,
+		}); terr != nil {
+			return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, event)
+}
+
+// adminEventsReplay delivers every dead-lettered event again, or those of
+// the type query parameter.
+func (a *API) adminEventsReplay(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.config
+	adminUser := getAdminUser(ctx)
+	eventType := models.EventType(r.URL.Query().Get("type"))
+
+	var count int
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if count, terr = models.ReplayDeadEvents(tx, eventType); terr != nil {
+			return apierrors.NewInternalServerError("Database error replaying events").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, adminUser, models.EventsReplayedAction, "", map[string]interface{}{
+			"event_type": eventType,
+			"count":      count,
+		}); terr != nil {
+			return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"replayed": count,
+	})
+}
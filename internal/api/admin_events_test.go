@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func (ts *AdminTestSuite) TestAdminEvents() {
+	ts.Config.Events.Enabled = true
+	defer func() {
+		ts.Config.Events.Enabled = false
+	}()
+
+	w := ts.adminRequest(http.MethodPost, "/admin/users", ts.token, map[string]interface{}{
+		"email":    "events@example.com",
+		"password": "test1234",
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	w = ts.adminRequest(http.MethodGet, "/admin/events?type=user.created", ts.token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	res := struct {
+		Events []*models.Event `json:"events"`
+	}{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&res))
+	require.Len(ts.T(), res.Events, 1)
+	require.Equal(ts.T(), models.EventPending, res.Events[0].Status)
+	require.Equal(ts.T(), "events@example.com", res.Events[0].Data["user"].(map[string]interface{})["email"])
+
+	event, err := models.FindEventByID(ts.API.db, res.Events[0].ID)
+	require.NoError(ts.T(), err)
+	event.Status = models.EventDead
+	event.Attempts = 10
+	require.NoError(ts.T(), ts.API.db.UpdateOnly(event, "status", "attempts"))
+
+	w = ts.adminRequest(http.MethodGet, "/admin/events?status=dead", ts.token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Contains(ts.T(), w.Body.String(), event.ID.String())
+
+	w = ts.adminRequest(http.MethodPost, "/admin/events/"+event.ID.String()+"/replay", ts.token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	event, err = models.FindEventByID(ts.API.db, event.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), models.EventPending, event.Status)
+	require.Equal(ts.T(), 0, event.Attempts)
+
+	event.Status = models.EventDead
+	require.NoError(ts.T(), ts.API.db.UpdateOnly(event, "status"))
+
+	w = ts.adminRequest(http.MethodPost, "/admin/events/replay?type=user.created", ts.token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+	require.JSONEq(ts.T(), `{"replayed": 1}`, w.Body.String())
+
+	w = ts.adminRequest(http.MethodGet, "/admin/events?status=unknown", ts.token, nil)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	w = ts.adminRequest(http.MethodPost, "/admin/events/00000000-0000-0000-0000-000000000000/replay", ts.token, nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/observability"
+	"github.com/linkly-id/auth/internal/userexport"
+)
+
+// adminUsersExport streams the users as NDJSON in the format of the import
+// file
+func (a *API) adminUsersExport(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	adminUser := getAdminUser(ctx)
+	query := r.URL.Query()
+
+	opts := userexport.Options{
+		Aud:      a.requestAud(ctx, r),
+		Provider: query.Get("provider"),
+	}
+
+	if createdAfter := query.Get("created_after"); createdAfter != "" {
+		t, err := time.Parse(time.RFC3339, createdAfter)
+		if err != nil {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "created_after must be an RFC 3339 timestamp")
+		}
+		opts.CreatedAfter = &t
+	}
+
+	if includeSecrets := query.Get("include_secrets"); includeSecrets != "" {
+		var err error
+		if opts.IncludeSecrets, err = strconv.ParseBool(includeSecrets); err != nil {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "include_secrets must be a boolean")
+		}
+	}
+
+	if opts.IncludeSecrets {
+		if err := checkAdminScope(ctx, models.AdminScopeUsersSecrets); err != nil {
+			return err
+		}
+	}
+
+	if err := models.NewAuditLogEntry(config.AuditLog, r, db, adminUser, models.UsersExportedAction, "", map[string]interface{}{
+		"created_after":   createdAfter(opts.CreatedAfter),
+		"provider":        opts.Provider,
+		"include_secrets": opts.IncludeSecrets,
+	}); err != nil {
+		return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(err)
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	count, err := userexport.Export(ctx, a.db, opts, w)
+	if err != nil {
+		if count == 0 {
+			return apierrors.NewInternalServerError("Error exporting users").WithInternalError(err)
+		}
+
+		// the response has started, so the export can only be cut short
+		observability.GetLogEntry(r).Entry.WithError(err).WithField("exported", count).Error("error exporting users")
+	}
+
+	return nil
+}
+
+func createdAfter(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/userimport"
+	"github.com/stretchr/testify/require"
+)
+
+func (ts *AdminTestSuite) exportUsers(query string) []*userimport.Record {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/users/export"+query, nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	reader, err := userimport.NewReader(w.Body, userimport.FormatNDJSON)
+	require.NoError(ts.T(), err)
+
+	var records []*userimport.Record
+	for {
+		record, err := reader.Next()
+		if err != nil {
+			break
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func (ts *AdminTestSuite) TestAdminUsersExport() {
+	before := time.Now().Add(-time.Second)
+
+	body := `{"email":"jane@example.com","password_hash":"$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0","user_metadata":{"name":"Jane"},"identities":[{"provider":"google","provider_id":"123"}],"factors":[{"factor_type":"totp","friendly_name":"app","secret":"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"}]}
+{"phone":"15555550100","created_at":"2020-01-02T03:04:05Z"}
+`
+	response := ts.importUsers("", "application/x-ndjson", body)
+	require.Equal(ts.T(), userimport.Summary{Created: 2}, response.Summary)
+
+	records := ts.exportUsers("")
+	require.Len(ts.T(), records, 2)
+	require.Equal(ts.T(), "15555550100", records[0].Phone, "users are exported in order of creation")
+	require.Equal(ts.T(), "jane@example.com", records[1].Email)
+	require.Equal(ts.T(), "Jane", records[1].UserMetaData["name"])
+	require.Len(ts.T(), records[1].Identities, 1)
+	require.Len(ts.T(), records[1].Factors, 1)
+	require.Empty(ts.T(), records[1].PasswordHash)
+	require.Empty(ts.T(), records[1].Factors[0].Secret)
+
+	records = ts.exportUsers("?provider=google&include_secrets=true")
+	require.Len(ts.T(), records, 1)
+	require.NotEmpty(ts.T(), records[0].PasswordHash)
+	require.NoError(ts.T(), records[0].Decrypt(ts.Config.Security.DBEncryption.DecryptionKeys))
+	require.Equal(ts.T(), "$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0", records[0].PasswordHash)
+	require.Equal(ts.T(), "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP", records[0].Factors[0].Secret)
+
+	records = ts.exportUsers("?created_after=" + before.UTC().Format(time.RFC3339))
+	require.Len(ts.T(), records, 1)
+	require.Equal(ts.T(), "jane@example.com", records[0].Email)
+
+	// exports import as the same users
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/users/export?include_secrets=true", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	export := w.Body.String()
+
+	jane, err := models.FindUserByEmailAndAudience(ts.API.db, "jane@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Destroy(jane))
+
+	response = ts.importUsers("", "application/x-ndjson", export)
+	require.Equal(ts.T(), userimport.Summary{Created: 1, Skipped: 1}, response.Summary)
+
+	imported, err := models.FindUserByEmailAndAudience(ts.API.db, "jane@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), jane.ID, imported.ID)
+	require.WithinDuration(ts.T(), jane.CreatedAt, imported.CreatedAt, time.Millisecond)
+	require.Len(ts.T(), imported.Identities, 2)
+	require.Len(ts.T(), imported.Factors, 1)
+	require.Equal(ts.T(), jane.Factors[0].ID, imported.Factors[0].ID)
+	isAuthenticated, _, err := imported.Authenticate(context.Background(), ts.API.db, "test12345", ts.API.config.Security.DBEncryption.DecryptionKeys, ts.API.config.Security.DBEncryption.Encrypt, ts.API.config.Security.DBEncryption.EncryptionKeyID)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), isAuthenticated)
+}
+
+func (ts *AdminTestSuite) TestAdminUsersExportInvalidOptions() {
+	for _, query := range []string{"?created_after=yesterday", "?include_secrets=maybe"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/admin/users/export"+query, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusBadRequest, w.Code, query)
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/storage"
+)
+
+// impersonator identifies the admin making the request in the act claim of
+// impersonation sessions: the admin API key, or the subject of the admin's
+// JWT. Admin JWTs without a subject, such as service role keys, don't say
+// which admin is acting and can't impersonate users.
+func impersonator(ctx context.Context) (string, error) {
+	if key := getAdminAPIKey(ctx); key != nil {
+		return "admin_api_key:" + key.ID.String(), nil
+	}
+
+	if claims := getClaims(ctx); claims != nil && claims.Subject != "" {
+		return claims.Subject, nil
+	}
+
+	return "", apierrors.NewForbiddenError(apierrors.ErrorCodeImpersonationNotAllowed, "Impersonation requires an admin API key or an admin JWT with a subject")
+}
+
+// adminUserImpersonate signs the admin in as the user, in a session that
+// can't be refreshed past the configured maximum duration. Its access
+// tokens carry the admin in the act claim.
+func (a *API) adminUserImpersonate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	user := getUser(ctx)
+	adminUser := getAdminUser(ctx)
+
+	if !config.Impersonation.Enabled {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeImpersonationDisabled, "Impersonation is disabled")
+	}
+
+	if user.IsBanned() {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeUserBanned, "User is banned")
+	}
+
+	if config.Impersonation.Denies(user.Role, user.AppMetaData, config.JWT.AdminRoles) {
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeImpersonationNotAllowed, "User can't be impersonated")
+	}
+
+	actor, err := impersonator(ctx)
+	if err != nil {
+		return err
+	}
+	notAfter := time.Now().Add(config.Impersonation.MaxDuration)
+
+	grantParams := models.GrantParams{
+		SessionNotAfter: &notAfter,
+		Impersonator:    &actor,
+	}
+	grantParams.FillGrantParams(r)
+
+	var token *AccessTokenResponse
+	err = db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		token, terr = a.issueImpersonationToken(r, tx, user, grantParams)
+		if terr != nil {
+			return terr
+		}
+
+		traits := map[string]interface{}{
+			"user_id":      user.ID,
+			"user_email":   user.Email,
+			"user_phone":   user.Phone,
+			"impersonator": actor,
+			"not_after":    notAfter.Unix(),
+		}
+
+		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, adminUser, models.UserImpersonatedAction, "", traits); terr != nil {
+			return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.ImpersonatedLoginAction, "", traits); terr != nil {
+			return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, token)
+}
+
+// issueImpersonationToken issues a session to the admin acting as the user.
+// Unlike issueRefreshToken, it has none of the side effects of the user
+// signing in: last_sign_in_at is left as is, no user.signed_in event is
+// written and trusted devices don't apply.
+func (a *API) issueImpersonationToken(r *http.Request, tx *storage.Connection, user *models.User, grantParams models.GrantParams) (*AccessTokenResponse, error) {
+	refreshToken, err := models.GrantAuthenticatedUser(tx, user, grantParams)
+	if err != nil {
+		return nil, apierrors.NewInternalServerError("Database error granting user").WithInternalError(err)
+	}
+
+	if err := models.AddClaimToSession(tx, *refreshToken.SessionId, models.Impersonation); err != nil {
+		return nil, err
+	}
+
+	tokenString, expiresAt, err := a.generateAccessToken(r, tx, user, refreshToken.SessionId, models.Impersonation)
+	if err != nil {
+		if httpErr, ok := err.(*HTTPError); ok {
+			return nil, httpErr
+		}
+		return nil, apierrors.NewInternalServerError("error generating jwt token").WithInternalError(err)
+	}
+
+	return &AccessTokenResponse{
+		Token:        tokenString,
+		TokenType:    "bearer",
+		ExpiresIn:    a.config.JWT.Exp,
+		ExpiresAt:    expiresAt,
+		RefreshToken: refreshToken.Token,
+		User:         user,
+	}, nil
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func (ts *AdminTestSuite) TestAdminUserImpersonate() {
+	ts.Config.Impersonation.Enabled = true
+	ts.Config.Impersonation.MaxDuration = 15 * time.Minute
+	defer func() {
+		ts.Config.Impersonation.Enabled = false
+	}()
+
+	u, err := models.NewUser("", "impersonated@example.com", "", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(u))
+
+	// an admin JWT without a subject doesn't say which admin is acting
+	w := ts.adminRequest(http.MethodPost, "/admin/users/"+u.ID.String()+"/impersonate", ts.token, nil)
+	require.Equal(ts.T(), http.StatusForbidden, w.Code, w.Body.String())
+	require.Contains(ts.T(), w.Body.String(), string(apierrors.ErrorCodeImpersonationNotAllowed))
+
+	adminID := "6f1c3b2e-8c1d-4f0a-9b7e-2d5a4c3b2a10"
+	adminToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &AccessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: adminID},
+		Role:             "linkly_admin",
+	}).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err)
+
+	w = ts.adminRequest(http.MethodPost, "/admin/users/"+u.ID.String()+"/impersonate", adminToken, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	res := &AccessTokenResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(res))
+	require.NotEmpty(ts.T(), res.RefreshToken)
+
+	claims := &AccessTokenClaims{}
+	_, err = jwt.ParseWithClaims(res.Token, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(ts.Config.JWT.Secret), nil
+	})
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), u.ID.String(), claims.Subject)
+	require.NotNil(ts.T(), claims.Actor)
+	require.Equal(ts.T(), adminID, claims.Actor.Subject)
+	require.Len(ts.T(), claims.AuthenticationMethodReference, 1)
+	require.Equal(ts.T(), models.Impersonation.String(), claims.AuthenticationMethodReference[0].Method)
+
+	_, _, session, err := models.FindUserWithRefreshToken(ts.API.db, res.RefreshToken, false)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), session.IsImpersonation())
+	require.NotNil(ts.T(), session.NotAfter)
+	require.WithinDuration(ts.T(), time.Now().Add(15*time.Minute), *session.NotAfter, time.Minute)
+
+	// impersonating the user isn't the user signing in
+	u, err = models.FindUserByID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+	require.Nil(ts.T(), u.LastSignInAt)
+
+	entries := []models.AuditLogEntry{}
+	require.NoError(ts.T(), ts.API.db.Q().All(&entries))
+	actions := map[string]interface{}{}
+	for _, entry := range entries {
+		actions[entry.Payload["action"].(string)] = entry.Payload["actor_id"]
+	}
+	require.Contains(ts.T(), actions, string(models.UserImpersonatedAction))
+	require.Equal(ts.T(), u.ID.String(), actions[string(models.ImpersonatedLoginAction)])
+
+	// the session can't be refreshed past its maximum duration
+	past := time.Now().Add(-time.Minute)
+	session.NotAfter = &past
+	require.NoError(ts.T(), ts.API.db.UpdateOnly(session, "not_after"))
+
+	w = ts.adminRequest(http.MethodPost, "/token?grant_type=refresh_token", "", map[string]interface{}{
+		"refresh_token": res.RefreshToken,
+	})
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code, w.Body.String())
+	require.Contains(ts.T(), w.Body.String(), string(apierrors.ErrorCodeSessionExpired))
+}
+
+func (ts *AdminTestSuite) TestAdminUserImpersonateDenied() {
+	u, err := models.NewUser("", "admin@example.com", "", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(u))
+	require.NoError(ts.T(), u.SetRole(ts.API.db, "linkly_admin"))
+
+	path := "/admin/users/" + u.ID.String() + "/impersonate"
+
+	w := ts.adminRequest(http.MethodPost, path, ts.token, nil)
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	ts.Config.Impersonation.Enabled = true
+	ts.Config.Impersonation.MaxDuration = time.Hour
+	defer func() {
+		ts.Config.Impersonation.Enabled = false
+	}()
+
+	w = ts.adminRequest(http.MethodPost, path, ts.token, nil)
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+
+	// admin API keys need their own scope to impersonate users
+	key := ts.createAdminAPIKey(ts.token, &AdminAPIKeyParams{
+		Name:   "support",
+		Scopes: []string{models.AdminScopeUsersWrite},
+	})
+	w = ts.adminRequest(http.MethodPost, path, key.Key, nil)
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+
+	sessions, err := models.FindAllSessionsForUser(ts.API.db, u.ID, false)
+	require.NoError(ts.T(), err)
+	require.Empty(ts.T(), sessions)
+}
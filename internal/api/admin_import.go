@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/observability"
+	"github.com/linkly-id/auth/internal/userimport"
+)
+
+// importMaxBodySize caps the size of import request bodies, as the report is
+// held in memory until the import is done. Larger files are imported with
+// the admin import command, which streams its report.
+const importMaxBodySize = 100 << 20
+
+// AdminUsersImportResponse is the report of a bulk user import. Error is set
+// when the import was cut short.
+type AdminUsersImportResponse struct {
+	userimport.Summary
+	Results []userimport.Result `json:"results"`
+	Error   string              `json:"error,omitempty"`
+}
+
+// importFormat returns the format of the import file from the format query
+// parameter or the content type, defaulting to NDJSON.
+func importFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == "text/csv" {
+		return userimport.FormatCSV
+	}
+
+	return userimport.FormatNDJSON
+}
+
+// adminUsersImport imports users from an NDJSON or CSV request body, and
+// reports the result of every record, including when the import fails
+// midway
+func (a *API) adminUsersImport(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	adminUser := getAdminUser(ctx)
+	query := r.URL.Query()
+
+	opts := userimport.Options{
+		Aud:        a.requestAud(ctx, r),
+		Role:       config.JWT.DefaultGroupName,
+		OnConflict: query.Get("on_conflict"),
+		Encryption: config.Security.DBEncryption,
+		CheckRole: func(role string) error {
+			return a.checkAdminRoleChange(ctx, role)
+		},
+	}
+
+	if batchSize := query.Get("batch_size"); batchSize != "" {
+		size, err := strconv.Atoi(batchSize)
+		if err != nil || size <= 0 {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "batch_size must be a positive integer")
+		}
+		opts.BatchSize = size
+	}
+
+	if dryRun := query.Get("dry_run"); dryRun != "" {
+		var err error
+		if opts.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "dry_run must be a boolean")
+		}
+	}
+
+	importer, err := userimport.NewImporter(db, opts)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, err.Error())
+	}
+
+	reader, err := userimport.NewReader(http.MaxBytesReader(w, r.Body, importMaxBodySize), importFormat(r))
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, err.Error())
+	}
+
+	results := []userimport.Result{}
+	summary, err := importer.Import(ctx, reader, func(result userimport.Result) error {
+		results = append(results, result)
+		return nil
+	})
+	importErr := err
+
+	// the records committed before a failure stay imported, so the import
+	// is audited either way
+	if err := models.NewAuditLogEntry(config.AuditLog, r, db, adminUser, models.UsersImportedAction, "", map[string]interface{}{
+		"created":     summary.Created,
+		"updated":     summary.Updated,
+		"skipped":     summary.Skipped,
+		"failed":      summary.Failed,
+		"unprocessed": summary.Unprocessed,
+		"dry_run":     summary.DryRun,
+	}); err != nil {
+		observability.GetLogEntry(r).Entry.WithError(err).Error("error recording audit log entry of user import")
+	}
+
+	response := AdminUsersImportResponse{
+		Summary: *summary,
+		Results: results,
+	}
+
+	if importErr != nil {
+		observability.GetLogEntry(r).Entry.WithError(importErr).WithField("unprocessed", summary.Unprocessed).Error("error importing users")
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(importErr, &maxBytesErr) {
+			response.Error = "Import file is too large, import it with the admin import command instead"
+			return sendJSON(w, http.StatusRequestEntityTooLarge, response)
+		}
+
+		response.Error = "Error importing users"
+		return sendJSON(w, http.StatusInternalServerError, response)
+	}
+
+	return sendJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing/iotest"
+
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/userimport"
+	"github.com/stretchr/testify/require"
+)
+
+func (ts *AdminTestSuite) importUsers(query, contentType, body string) AdminUsersImportResponse {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/import"+query, strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+	req.Header.Set("Content-Type", contentType)
+
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	var response AdminUsersImportResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&response))
+
+	return response
+}
+
+func (ts *AdminTestSuite) TestAdminUsersImport() {
+	existing, err := models.NewUser("", "existing@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(existing))
+
+	body := `{"email":"jane@example.com","password_hash":"$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0","email_confirmed_at":"2024-01-02T03:04:05Z","identities":[{"provider":"google","provider_id":"123","identity_data":{"email":"jane@gmail.com"}}],"factors":[{"factor_type":"totp","friendly_name":"phone","secret":"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"}]}
+{"email":"Jane@example.com"}
+{"email":"existing@example.com","user_metadata":{"name":"Existing"}}
+{"phone":"15555550100","identities":[{"provider":"google","provider_id":"123"}]}
+{"email":"invalid"}
+`
+
+	response := ts.importUsers("?dry_run=true", "application/x-ndjson", body)
+	require.True(ts.T(), response.DryRun)
+	require.Equal(ts.T(), 1, response.Created)
+	_, err = models.FindUserByEmailAndAudience(ts.API.db, "jane@example.com", ts.Config.JWT.Aud)
+	require.True(ts.T(), models.IsNotFoundError(err))
+
+	response = ts.importUsers("", "application/x-ndjson", body)
+	require.Equal(ts.T(), userimport.Summary{Created: 1, Skipped: 1, Failed: 3}, response.Summary)
+	require.Len(ts.T(), response.Results, 5)
+
+	statuses := make(map[int]string)
+	for _, result := range response.Results {
+		statuses[result.Line] = result.Status
+	}
+	require.Equal(ts.T(), map[int]string{
+		1: userimport.StatusCreated,
+		2: userimport.StatusFailed,
+		3: userimport.StatusSkipped,
+		4: userimport.StatusFailed,
+		5: userimport.StatusFailed,
+	}, statuses)
+
+	jane, err := models.FindUserByEmailAndAudience(ts.API.db, "jane@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), jane.IsConfirmed())
+	require.Equal(ts.T(), []interface{}{"email", "google"}, jane.AppMetaData["providers"])
+	require.Len(ts.T(), jane.Identities, 2)
+	require.Len(ts.T(), jane.Factors, 1)
+	require.Equal(ts.T(), models.FactorStateVerified.String(), jane.Factors[0].Status)
+	isAuthenticated, _, err := jane.Authenticate(context.Background(), ts.API.db, "test12345", ts.API.config.Security.DBEncryption.DecryptionKeys, ts.API.config.Security.DBEncryption.Encrypt, ts.API.config.Security.DBEncryption.EncryptionKeyID)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), isAuthenticated)
+
+	response = ts.importUsers("?on_conflict=update", "text/csv", `email,user_metadata,identities
+existing@example.com,"{""name"":""Existing""}","[{""provider"":""github"",""provider_id"":""42""}]"
+`)
+	require.Equal(ts.T(), userimport.Summary{Updated: 1}, response.Summary)
+	require.Equal(ts.T(), existing.ID.String(), response.Results[0].ID)
+
+	existing, err = models.FindUserByID(ts.API.db, existing.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "Existing", existing.UserMetaData["name"])
+	require.Len(ts.T(), existing.Identities, 1)
+	require.Equal(ts.T(), "github", existing.Identities[0].Provider)
+}
+
+func (ts *AdminTestSuite) TestAdminUsersImportInvalidOptions() {
+	for _, query := range []string{"?on_conflict=replace", "?batch_size=0", "?dry_run=maybe", "?format=xml"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/import"+query, strings.NewReader(`{"email":"jane@example.com"}`))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func (ts *AdminTestSuite) TestAdminUsersImportPartial() {
+	// the body fails after the second batch was read
+	body := io.MultiReader(strings.NewReader(`{"email":"jane@example.com"}
+{"email":"john@example.com"}
+{"email":"joe@example.com"}
+`), iotest.ErrReader(errors.New("connection reset")))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/import?batch_size=2", body)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusInternalServerError, w.Code, w.Body.String())
+
+	var response AdminUsersImportResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&response))
+	require.Equal(ts.T(), userimport.Summary{Created: 2, Unprocessed: 1}, response.Summary)
+	require.NotEmpty(ts.T(), response.Error)
+	require.Equal(ts.T(), userimport.StatusUnprocessed, response.Results[2].Status)
+
+	_, err := models.FindUserByEmailAndAudience(ts.API.db, "john@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+
+	// the users imported before the failure are audited
+	entries := []models.AuditLogEntry{}
+	require.NoError(ts.T(), ts.API.db.Q().All(&entries))
+	var created interface{}
+	for _, entry := range entries {
+		if entry.Payload["action"] == string(models.UsersImportedAction) {
+			created = entry.Payload["traits"].(map[string]interface{})["created"]
+		}
+	}
+	require.EqualValues(ts.T(), 2, created)
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/didip/tollbooth/v5/limiter"
+	"github.com/go-chi/chi/v5"
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/ratelimit"
+)
+
+// AdminRateLimiter is a limiter of the API and its limit
+type AdminRateLimiter struct {
+	Name string `json:"name"`
+	// Limit is the number of requests allowed per window
+	Limit int `json:"limit"`
+	// Window is the length of the window in seconds
+	Window int `json:"window"`
+}
+
+// AdminRateLimitKey is the state of the limit of a key of a limiter
+type AdminRateLimitKey struct {
+	Key       string `json:"key"`
+	Limit     int    `json:"limit"`
+	Remaining int    `json:"remaining"`
+	// Reset is the number of seconds until another request is allowed
+	Reset     int  `json:"reset"`
+	Throttled bool `json:"throttled"`
+}
+
+// AdminRateLimiterResponse is the response of the keys of a limiter
+type AdminRateLimiterResponse struct {
+	AdminRateLimiter
+	Keys []AdminRateLimitKey `json:"keys"`
+}
+
+// rateLimiter is a limiter of the options, either counted per key in a
+// backend or a global limit of emails or SMS messages.
+type rateLimiter struct {
+	name   string
+	keyed  *limiter.Limiter
+	global ratelimit.Limiter
+	rate   conf.Rate
+}
+
+func (l *rateLimiter) limit() AdminRateLimiter {
+	if l.keyed != nil {
+		events, window := backendWindow(l.keyed)
+		return AdminRateLimiter{Name: l.name, Limit: events, Window: seconds(window)}
+	}
+
+	window := l.rate.OverTime
+	if window <= 0 {
+		window = time.Hour
+	}
+
+	return AdminRateLimiter{Name: l.name, Limit: int(l.rate.Events), Window: seconds(window)}
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func newAdminRateLimitKey(key string, res ratelimit.Result) AdminRateLimitKey {
+	return AdminRateLimitKey{
+		Key:       key,
+		Limit:     res.Limit,
+		Remaining: res.Remaining,
+		Reset:     seconds(res.Reset),
+		Throttled: res.Remaining == 0,
+	}
+}
+
+// rateLimiters returns the limiters of the options sorted by name
+func (a *API) rateLimiters() []*rateLimiter {
+	limiters := []*rateLimiter{
+		{name: "email_sent", global: a.limiterOpts.Email, rate: a.config.RateLimitEmailSent},
+		{name: "sms_sent", global: a.limiterOpts.Phone, rate: a.config.RateLimitSmsSent},
+	}
+	for lmt, name := range a.limiterOpts.names {
+		limiters = append(limiters, &rateLimiter{name: name, keyed: lmt})
+	}
+
+	slices.SortFunc(limiters, func(x, y *rateLimiter) int {
+		return strings.Compare(x.name, y.name)
+	})
+
+	return limiters
+}
+
+func (a *API) rateLimitBackend() ratelimit.Backend {
+	if a.limiterOpts.Backend != nil {
+		return a.limiterOpts.Backend
+	}
+
+	return a.limiterOpts.memory
+}
+
+func (a *API) findRateLimiter(r *http.Request) (*rateLimiter, error) {
+	name := chi.URLParam(r, "limiter")
+	for _, l := range a.rateLimiters() {
+		if l.name == name {
+			return l, nil
+		}
+	}
+
+	return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeRateLimiterNotFound, "Rate limiter not found")
+}
+
+// adminRateLimiters lists the limiters and their limits
+func (a *API) adminRateLimiters(w http.ResponseWriter, r *http.Request) error {
+	limiters := []AdminRateLimiter{}
+	for _, l := range a.rateLimiters() {
+		limiters = append(limiters, l.limit())
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"limiters": limiters,
+	})
+}
+
+// adminRateLimiterKeys lists the keys of a limiter with requests counted and
+// what remains of their limits, or only the throttled ones when asked to
+func (a *API) adminRateLimiterKeys(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	now := time.Now()
+
+	l, err := a.findRateLimiter(r)
+	if err != nil {
+		return err
+	}
+
+	throttledOnly := false
+	if throttled := r.URL.Query().Get("throttled"); throttled != "" {
+		if throttledOnly, err = strconv.ParseBool(throttled); err != nil {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "throttled must be a boolean")
+		}
+	}
+
+	keys := []AdminRateLimitKey{}
+	if l.global != nil {
+		// emails and SMS messages are limited globally, under a single key
+		keys = append(keys, newAdminRateLimitKey("", l.global.PeekAt(ctx, now)))
+	} else {
+		events, window := backendWindow(l.keyed)
+		prefix := l.name + ":"
+
+		results, err := a.rateLimitBackend().ListAt(ctx, prefix, events, window, now)
+		if err != nil {
+			return apierrors.NewInternalServerError("Error finding rate limits").WithInternalError(err)
+		}
+
+		for _, res := range results {
+			keys = append(keys, newAdminRateLimitKey(strings.TrimPrefix(res.Key, prefix), res.Result))
+		}
+	}
+
+	if throttledOnly {
+		throttled := []AdminRateLimitKey{}
+		for _, key := range keys {
+			if key.Throttled {
+				throttled = append(throttled, key)
+			}
+		}
+		keys = throttled
+	}
+
+	return sendJSON(w, http.StatusOK, &AdminRateLimiterResponse{
+		AdminRateLimiter: l.limit(),
+		Keys:             keys,
+	})
+}
+
+// adminRateLimiterClear forgets the requests counted for a key of a limiter,
+// or for all of its keys when no key is given
+func (a *API) adminRateLimiterClear(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.config
+	adminUser := getAdminUser(ctx)
+	key := r.URL.Query().Get("key")
+
+	l, err := a.findRateLimiter(r)
+	if err != nil {
+		return err
+	}
+
+	if l.global != nil && key != "" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "%s is limited globally and has no keys", l.name)
+	}
+
+	if err := models.NewAuditLogEntry(config.AuditLog, r, a.db.WithContext(ctx), adminUser, models.RateLimitClearedAction, "", map[string]interface{}{
+		"limiter": l.name,
+		"key":     key,
+	}); err != nil {
+		return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(err)
+	}
+
+	switch {
+	case l.global != nil:
+		err = l.global.Reset(ctx)
+	case key != "":
+		err = a.rateLimitBackend().Clear(ctx, l.name+":"+key)
+	default:
+		err = a.rateLimitBackend().ClearPrefix(ctx, l.name+":")
+	}
+	if err != nil {
+		return apierrors.NewInternalServerError("Error clearing rate limits").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
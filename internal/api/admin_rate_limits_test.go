@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (ts *AdminTestSuite) rateLimitsRequest(method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, "/admin/rate_limits"+path, nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+	ts.API.handler.ServeHTTP(w, req)
+	return w
+}
+
+func (ts *AdminTestSuite) rateLimiterKeys(path string) *AdminRateLimiterResponse {
+	w := ts.rateLimitsRequest(http.MethodGet, path)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	res := &AdminRateLimiterResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(res))
+	return res
+}
+
+func (ts *AdminTestSuite) TestAdminRateLimiters() {
+	w := ts.rateLimitsRequest(http.MethodGet, "")
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var res struct {
+		Limiters []AdminRateLimiter `json:"limiters"`
+	}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&res))
+
+	names := map[string]AdminRateLimiter{}
+	for _, l := range res.Limiters {
+		names[l.Name] = l
+	}
+	require.Contains(ts.T(), names, "email_sent")
+	require.Contains(ts.T(), names, "sms_sent")
+	require.Contains(ts.T(), names, "verify")
+	require.Equal(ts.T(), 30, names["token"].Limit)
+}
+
+func (ts *AdminTestSuite) TestAdminRateLimiterKeys() {
+	w := ts.rateLimitsRequest(http.MethodDelete, "/token")
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	ctx := context.Background()
+	for i := 0; i < 31; i++ {
+		_, err := ts.API.takeKey(ctx, ts.API.limiterOpts.Token, "1.2.3.4")
+		require.NoError(ts.T(), err)
+	}
+	_, err := ts.API.takeKey(ctx, ts.API.limiterOpts.Token, "5.6.7.8")
+	require.NoError(ts.T(), err)
+
+	res := ts.rateLimiterKeys("/token")
+	require.Equal(ts.T(), "token", res.Name)
+	require.Len(ts.T(), res.Keys, 2)
+	require.Equal(ts.T(), "1.2.3.4", res.Keys[0].Key)
+	require.True(ts.T(), res.Keys[0].Throttled)
+	require.Equal(ts.T(), 0, res.Keys[0].Remaining)
+	require.Equal(ts.T(), "5.6.7.8", res.Keys[1].Key)
+	require.False(ts.T(), res.Keys[1].Throttled)
+	require.Equal(ts.T(), 29, res.Keys[1].Remaining)
+
+	res = ts.rateLimiterKeys("/token?throttled=true")
+	require.Len(ts.T(), res.Keys, 1)
+	require.Equal(ts.T(), "1.2.3.4", res.Keys[0].Key)
+
+	w = ts.rateLimitsRequest(http.MethodDelete, "/token?key=1.2.3.4")
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	res = ts.rateLimiterKeys("/token")
+	require.Len(ts.T(), res.Keys, 1)
+	require.Equal(ts.T(), "5.6.7.8", res.Keys[0].Key)
+
+	allowed, err := ts.API.takeKey(ctx, ts.API.limiterOpts.Token, "1.2.3.4")
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), allowed.Allowed)
+
+	w = ts.rateLimitsRequest(http.MethodDelete, "/token")
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+	require.Empty(ts.T(), ts.rateLimiterKeys("/token").Keys)
+}
+
+func (ts *AdminTestSuite) TestAdminRateLimiterGlobal() {
+	require.True(ts.T(), ts.API.limiterOpts.Email.Allow())
+
+	res := ts.rateLimiterKeys("/email_sent")
+	require.Len(ts.T(), res.Keys, 1)
+	require.Equal(ts.T(), ts.Config.RateLimitEmailSent.Events-1, float64(res.Keys[0].Remaining))
+
+	w := ts.rateLimitsRequest(http.MethodDelete, "/email_sent?key=1.2.3.4")
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	w = ts.rateLimitsRequest(http.MethodDelete, "/email_sent")
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	res = ts.rateLimiterKeys("/email_sent")
+	require.Equal(ts.T(), int(ts.Config.RateLimitEmailSent.Events), res.Keys[0].Remaining)
+}
+
+func (ts *AdminTestSuite) TestAdminRateLimiterNotFound() {
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		w := ts.rateLimitsRequest(method, "/unknown")
+		require.Equal(ts.T(), http.StatusNotFound, w.Code, method)
+	}
+
+	w := ts.rateLimitsRequest(http.MethodGet, "/token?throttled=maybe")
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 	"github.com/linkly-id/auth/internal/api/apierrors"
 	"github.com/linkly-id/auth/internal/conf"
 	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/utilities"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -198,6 +201,91 @@ func (ts *AdminTestSuite) TestAdminUsers_FilterEmail() {
 	assert.Equal(ts.T(), "test1@example.com", data.Users[0].GetEmail())
 }
 
+func (ts *AdminTestSuite) TestAdminUsers_Search() {
+	now := time.Now()
+
+	u, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	u.EmailConfirmedAt = &now
+	u.AppMetaData = map[string]interface{}{"billing": map[string]interface{}{"plan": "pro"}}
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	u, err = models.NewUser("", "test2@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	u.LastSignInAt = &now
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	for query, expected := range map[string][]string{
+		"confirmed=true":                          {"test1@example.com"},
+		"confirmed=false":                         {"test2@example.com"},
+		"app_metadata.billing.plan=pro":           {"test1@example.com"},
+		"banned=false&sort=email+desc":            {"test2@example.com", "test1@example.com"},
+		"last_sign_in_after=2000-01-01T00:00:00Z": {"test2@example.com"},
+		"sort=last_sign_in_at+asc":                {"test1@example.com", "test2@example.com"},
+		"has_mfa=true":                            {},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/admin/users?"+query, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusOK, w.Code, query)
+
+		data := AdminListUsersResponse{}
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+
+		emails := []string{}
+		for _, user := range data.Users {
+			emails = append(emails, user.GetEmail())
+		}
+		require.Equal(ts.T(), expected, emails, query)
+	}
+
+	for _, query := range []string{"confirmed=maybe", "created_after=yesterday", "user_metadata.a,b=c", "sort=phone", "cursor=&sort=email+asc&sort=created_at", "cursor=invalid"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/admin/users?"+query, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func (ts *AdminTestSuite) TestAdminUsers_Cursor() {
+	for _, email := range []string{"test2@example.com", "test3@example.com", "test1@example.com"} {
+		u, err := models.NewUser("", email, "test", ts.Config.JWT.Aud, nil)
+		require.NoError(ts.T(), err, "Error making new user")
+		require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+	}
+
+	emails := []string{}
+	path := "/admin/users?cursor=&per_page=2&sort=email+asc"
+	for path != "" {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusOK, w.Code)
+		require.Empty(ts.T(), w.Header().Get("X-Total-Count"))
+
+		data := AdminListUsersResponse{}
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+		for _, user := range data.Users {
+			emails = append(emails, user.GetEmail())
+		}
+
+		path = ""
+		if data.NextCursor != "" {
+			require.Len(ts.T(), data.Users, 2)
+			require.Contains(ts.T(), w.Header().Get("Link"), "rel=\"next\"")
+			path = "/admin/users?per_page=2&sort=email+asc&cursor=" + data.NextCursor
+		}
+	}
+
+	require.Equal(ts.T(), []string{"test1@example.com", "test2@example.com", "test3@example.com"}, emails)
+}
+
 // TestAdminUsers tests API /admin/users route
 func (ts *AdminTestSuite) TestAdminUsers_FilterName() {
 	u, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, map[string]interface{}{"full_name": "Test User"})
@@ -517,6 +605,84 @@ func (ts *AdminTestSuite) TestAdminUserUpdatePasswordFailed() {
 	})
 }
 
+func (ts *AdminTestSuite) TestAdminUserUpdatePasswordReused() {
+	ts.Config.Password.HistoryLength = 3
+	defer func() {
+		ts.Config.Password.HistoryLength = 0
+	}()
+
+	u, err := models.NewUser("12345678", "test1@example.com", "test1234", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+	require.NoError(ts.T(), models.AddPasswordHistory(ts.API.db, u.ID, "$2a$10$UnAyq0F0N2v11Q8BWgz1h.8YabayVfeQ7TYIjbtkeE2BCSMblET0i", ts.Config.Password.HistoryLength))
+
+	for _, password := range []string{"test1234", "secret"} {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"password": password,
+		}))
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/admin/users/%s", u.ID), &buffer)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+		var data HTTPError
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+		require.Equal(ts.T(), apierrors.ErrorCodePasswordReused, data.ErrorCode)
+	}
+}
+
+func (ts *AdminTestSuite) TestAdminUserCreatePwnedPassword() {
+	hash, err := utilities.HIBPHash(utilities.HIBPHashSHA1, "password1")
+	require.NoError(ts.T(), err)
+
+	path := filepath.Join(ts.T().TempDir(), "pwned-passwords.txt")
+	require.NoError(ts.T(), os.WriteFile(path, []byte(hash+":42\n"), 0600))
+
+	ts.Config.Password.HIBP = conf.HIBPConfiguration{
+		Enabled: true,
+		Dataset: conf.HIBPDatasetConfiguration{
+			Path:   path,
+			Format: "text",
+			Hash:   utilities.HIBPHashSHA1,
+		},
+	}
+	checker, err := loadHIBPDataset(&ts.Config.Password.HIBP.Dataset)
+	require.NoError(ts.T(), err)
+	ts.API.hibpClient = checker
+	defer func() {
+		ts.Config.Password.HIBP = conf.HIBPConfiguration{}
+		ts.API.hibpClient = nil
+	}()
+
+	for password, code := range map[string]int{
+		"password1":        http.StatusUnprocessableEntity,
+		"zZgXb5gzyCNrV36q": http.StatusOK,
+	} {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"email":    fmt.Sprintf("%s@example.com", password),
+			"password": password,
+		}))
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/users", &buffer)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), code, w.Code)
+
+		if code != http.StatusOK {
+			var data HTTPError
+			require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+			require.Equal(ts.T(), apierrors.ErrorCodeWeakPassword, data.ErrorCode)
+		}
+	}
+}
+
 func (ts *AdminTestSuite) TestAdminUserUpdateBannedUntilFailed() {
 	u, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
 	require.NoError(ts.T(), err, "Error making new user")
@@ -638,6 +804,7 @@ func (ts *AdminTestSuite) TestAdminUserSoftDeletion() {
 		"provider": "email",
 	}
 	require.NoError(ts.T(), ts.API.db.Create(u))
+	require.NoError(ts.T(), models.AddPasswordHistory(ts.API.db, u.ID, *u.EncryptedPassword, 5))
 	require.NoError(ts.T(), models.CreateOneTimeToken(ts.API.db, u.ID, u.GetEmail(), u.ConfirmationToken, models.ConfirmationToken))
 	require.NoError(ts.T(), models.CreateOneTimeToken(ts.API.db, u.ID, u.GetEmail(), u.RecoveryToken, models.RecoveryToken))
 	require.NoError(ts.T(), models.CreateOneTimeToken(ts.API.db, u.ID, u.GetEmail(), u.EmailChangeTokenCurrent, models.EmailChangeTokenCurrent))
@@ -690,6 +857,11 @@ func (ts *AdminTestSuite) TestAdminUserSoftDeletion() {
 	for _, identity := range deletedIdentities {
 		require.Empty(ts.T(), identity.IdentityData)
 	}
+
+	// previous passwords are wiped
+	entries, err := models.FindPasswordHistory(ts.API.db, deletedUser.ID, 10)
+	require.NoError(ts.T(), err)
+	require.Empty(ts.T(), entries)
 }
 
 func (ts *AdminTestSuite) TestAdminUserCreateWithDisabledLogin() {
@@ -789,6 +961,45 @@ func (ts *AdminTestSuite) TestAdminUserDeleteFactor() {
 
 }
 
+// TestAdminUserUnlock tests API /admin/users/<user_id>/lockout
+func (ts *AdminTestSuite) TestAdminUserUnlock() {
+	u, err := models.NewUser("123456789", "test-unlock@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	lockoutConfig := &conf.LockoutConfiguration{
+		AttemptWindow: time.Minute,
+		Duration:      time.Minute,
+		MaxDuration:   time.Hour,
+		Cooldown:      time.Hour,
+	}
+
+	keys := []string{
+		models.UserLockoutKey(u.ID),
+		models.IdentifierLockoutKey("email", u.GetEmail()),
+		models.IdentifierLockoutKey("phone", u.GetPhone()),
+	}
+	for _, key := range keys {
+		_, locked, err := models.RecordFailedLoginAttempt(ts.API.db, lockoutConfig, key, nil, 1, time.Now())
+		require.NoError(ts.T(), err)
+		require.True(ts.T(), locked)
+	}
+
+	// Setup request
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/admin/users/%s/lockout", u.ID), nil)
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	for _, key := range keys {
+		_, err := models.FindLoginLockout(ts.API.db, key)
+		require.EqualError(ts.T(), err, models.LoginLockoutNotFoundError{}.Error())
+	}
+}
+
 // TestAdminUserGetFactor tests API /admin/user/<user_id>/factors/
 func (ts *AdminTestSuite) TestAdminUserGetFactors() {
 	u, err := models.NewUser("123456789", "test-delete@example.com", "test", ts.Config.JWT.Aud, nil)
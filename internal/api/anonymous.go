@@ -33,6 +33,9 @@ func (a *API) SignupAnonymously(w http.ResponseWriter, r *http.Request) error {
 	if err := a.triggerBeforeUserCreated(r, db, newUser); err != nil {
 		return err
 	}
+	if err := a.triggerBeforeSignIn(r, db, newUser, models.Anonymous); err != nil {
+		return err
+	}
 
 	var grantParams models.GrantParams
 	grantParams.FillGrantParams(r)
@@ -51,6 +54,10 @@ func (a *API) SignupAnonymously(w http.ResponseWriter, r *http.Request) error {
 		return nil
 	})
 	if err != nil {
+		// hooks rejecting the sign-in return their own error
+		if httpErr, ok := err.(*HTTPError); ok {
+			return httpErr
+		}
 		return apierrors.NewInternalServerError("Database error creating anonymous user").WithInternalError(err)
 	}
 
@@ -5,15 +5,18 @@ import (
 	"regexp"
 	"time"
 
+	"github.com/go-webauthn/webauthn/metadata"
 	"github.com/linkly-id/auth/internal/api/apierrors"
 	"github.com/linkly-id/auth/internal/api/oauthserver"
 	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/crypto"
 	"github.com/linkly-id/auth/internal/hooks/hookshttp"
 	"github.com/linkly-id/auth/internal/hooks/hookspgfunc"
 	"github.com/linkly-id/auth/internal/hooks/v0hooks"
 	"github.com/linkly-id/auth/internal/mailer"
 	"github.com/linkly-id/auth/internal/models"
 	"github.com/linkly-id/auth/internal/observability"
+	"github.com/linkly-id/auth/internal/ratelimit"
 	"github.com/linkly-id/auth/internal/storage"
 	"github.com/linkly-id/auth/internal/utilities"
 	"github.com/rs/cors"
@@ -37,9 +40,12 @@ type API struct {
 	version string
 
 	hooksMgr    *v0hooks.Manager
-	hibpClient  *hibp.PwnedClient
+	hibpClient  pwnedPasswordChecker
 	oauthServer *oauthserver.Server
 
+	webAuthnMetadata metadata.Provider
+	hibpDataset      *HIBPDatasetOption
+
 	// overrideTime can be used to override the clock used by handlers. Should only be used in tests!
 	overrideTime func() time.Time
 
@@ -95,31 +101,53 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 	if api.limiterOpts == nil {
 		api.limiterOpts = NewLimiterOptions(globalConfig)
 	}
+	if globalConfig.RateLimitBackend == conf.RateLimitBackendPostgres && api.limiterOpts.Backend == nil {
+		api.limiterOpts = api.limiterOpts.WithBackend(ratelimit.NewPostgresBackend(db), globalConfig)
+	}
 	if api.hooksMgr == nil {
 		httpDr := hookshttp.New()
 		pgfuncDr := hookspgfunc.New(db)
 		api.hooksMgr = v0hooks.NewManager(globalConfig, httpDr, pgfuncDr)
 	}
-	if api.config.Password.HIBP.Enabled {
+	if api.config.Password.HIBP.Enabled && api.config.Password.HIBP.Dataset.Path != "" {
+		if api.hibpDataset != nil && api.hibpDataset.Matches(globalConfig) {
+			api.hibpClient = api.hibpDataset.checker
+		} else {
+			// checks are treated like HIBP API failures without the
+			// dataset
+			logrus.Error("Pwned passwords dataset is not loaded")
+		}
+	} else if api.config.Password.HIBP.Enabled {
 		httpClient := &http.Client{
 			// all HIBP API requests should finish quickly to avoid
 			// unnecessary slowdowns
 			Timeout: 5 * time.Second,
 		}
 
-		api.hibpClient = &hibp.PwnedClient{
+		hibpClient := &hibp.PwnedClient{
 			UserAgent: api.config.Password.HIBP.UserAgent,
 			HTTP:      httpClient,
 		}
 
 		if api.config.Password.HIBP.Bloom.Enabled {
 			cache := utilities.NewHIBPBloomCache(api.config.Password.HIBP.Bloom.Items, api.config.Password.HIBP.Bloom.FalsePositives)
-			api.hibpClient.Cache = cache
+			hibpClient.Cache = cache
 
 			logrus.Infof("Pwned passwords cache is %.2f KB", float64(cache.Cap())/(8*1024.0))
 		}
+
+		api.hibpClient = hibpClient
 	}
 
+	crypto.SetPasswordHashParams(crypto.PasswordHashParams{
+		Algorithm:     api.config.Password.Hash.Algorithm,
+		BcryptCost:    api.config.Password.Hash.BcryptCost,
+		Argon2Memory:  api.config.Password.Hash.Argon2Memory,
+		Argon2Time:    api.config.Password.Hash.Argon2Time,
+		Argon2Threads: api.config.Password.Hash.Argon2Threads,
+	})
+	registerLegacyPasswordHashesMetric(db)
+
 	api.deprecationNotices()
 
 	xffmw, _ := xff.Default()
@@ -145,6 +173,8 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 		r.UseBypass(api.databaseCleanup(cleanup))
 	}
 
+	r.Use(api.ipAccess)
+
 	r.Get("/health", api.HealthCheck)
 	r.Get("/.well-known/jwks.json", api.Jwks)
 
@@ -164,7 +194,8 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 
 		r.Get("/authorize", api.ExternalProviderRedirect)
 
-		r.With(api.requireAdminCredentials).Post("/invite", api.Invite)
+		r.With(api.requireAdminCredentials).
+			With(api.requireAdminScope(models.AdminScopeUsersWrite)).Post("/invite", api.Invite)
 		r.With(api.verifyCaptcha).Route("/signup", func(r *router) {
 			// rate limit per hour
 			limitAnonymousSignIns := api.limiterOpts.AnonymousSignIns
@@ -214,35 +245,62 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 		r.With(api.requireAuthentication).Post("/logout", api.Logout)
 
 		r.With(api.requireAuthentication).Route("/reauthenticate", func(r *router) {
+			r.Use(api.allowSessionRestriction(SessionRestrictionPasswordChange))
 			r.Get("/", api.Reauthenticate)
 		})
 
 		r.With(api.requireAuthentication).Route("/user", func(r *router) {
 			r.Get("/", api.UserGet)
-			r.With(api.limitHandler(api.limiterOpts.User)).Put("/", api.UserUpdate)
+			r.With(api.allowSessionRestriction(SessionRestrictionPasswordChange)).With(api.limitHandler(api.limiterOpts.User)).Put("/", api.UserUpdate)
 
 			r.Route("/identities", func(r *router) {
+				r.Use(api.requireUnrestrictedSession)
 				r.Use(api.requireManualLinkingEnabled)
 				r.Get("/authorize", api.LinkIdentity)
 				r.Delete("/{identity_id}", api.DeleteIdentity)
 			})
+
+			r.Route("/trusted_devices", func(r *router) {
+				r.Use(api.requireUnrestrictedSession)
+				r.Get("/", api.TrustedDevicesList)
+				r.Delete("/{device_id}", api.TrustedDeviceRevoke)
+			})
 		})
 
 		r.With(api.requireAuthentication).Route("/factors", func(r *router) {
 			r.Use(api.requireNotAnonymous)
-			r.Post("/", api.EnrollFactor)
+			r.With(api.allowSessionRestriction(SessionRestrictionMFAEnrollment)).Post("/", api.EnrollFactor)
 			r.Route("/{factor_id}", func(r *router) {
 				r.Use(api.loadFactor)
 
-				r.With(api.limitHandler(api.limiterOpts.FactorVerify)).
+				r.With(api.allowSessionRestriction(SessionRestrictionMFAEnrollment)).
+					With(api.limitHandler(api.limiterOpts.FactorVerify)).
 					Post("/verify", api.VerifyFactor)
-				r.With(api.limitHandler(api.limiterOpts.FactorChallenge)).
+				r.With(api.allowSessionRestriction(SessionRestrictionMFAEnrollment)).
+					With(api.limitHandler(api.limiterOpts.FactorChallenge)).
 					Post("/challenge", api.ChallengeFactor)
-				r.Delete("/", api.UnenrollFactor)
+				r.With(api.requireUnrestrictedSession).Post("/regenerate", api.RegenerateRecoveryCodes)
+				r.With(api.requireUnrestrictedSession).Delete("/", api.UnenrollFactor)
 
 			})
 		})
 
+		r.Route("/passkeys", func(r *router) {
+			r.Use(api.requirePasskeyEnabled)
+
+			r.With(api.limitHandler(api.limiterOpts.Passkey)).
+				Post("/challenge", api.PasskeyChallenge)
+
+			r.Route("/registration", func(r *router) {
+				r.Use(api.requireAuthentication)
+				r.Use(api.requireNotAnonymous)
+				r.Use(api.allowSessionRestriction(SessionRestrictionMFAEnrollment))
+
+				r.Post("/", api.PasskeyRegistration)
+				r.With(api.loadFactor).Post("/{factor_id}/verify", api.PasskeyRegistrationVerify)
+			})
+		})
+
 		r.Route("/sso", func(r *router) {
 			r.Use(api.requireSAMLEnabled)
 			r.With(api.limitHandler(api.limiterOpts.SSO)).
@@ -259,60 +317,114 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 		r.Route("/admin", func(r *router) {
 			r.Use(api.requireAdminCredentials)
 
+			// each route declares the scope admin API keys need to use it
 			r.Route("/audit", func(r *router) {
-				r.Get("/", api.adminAuditLog)
+				r.With(api.requireAdminScope(models.AdminScopeAuditRead)).Get("/", api.adminAuditLog)
 			})
 
 			r.Route("/users", func(r *router) {
-				r.Get("/", api.adminUsers)
-				r.Post("/", api.adminUserCreate)
+				usersRead := api.requireAdminScope(models.AdminScopeUsersRead)
+				usersWrite := api.requireAdminScope(models.AdminScopeUsersWrite)
+
+				r.With(usersRead).Get("/", api.adminUsers)
+				r.With(usersWrite).Post("/", api.adminUserCreate)
+				r.With(usersWrite).Post("/import", api.adminUsersImport)
+				r.With(usersRead).Get("/export", api.adminUsersExport)
 
 				r.Route("/{user_id}", func(r *router) {
 					r.Use(api.loadUser)
 					r.Route("/factors", func(r *router) {
-						r.Get("/", api.adminUserGetFactors)
+						r.With(usersRead).Get("/", api.adminUserGetFactors)
 						r.Route("/{factor_id}", func(r *router) {
 							r.Use(api.loadFactor)
-							r.Delete("/", api.adminUserDeleteFactor)
-							r.Put("/", api.adminUserUpdateFactor)
+							r.With(usersWrite).Delete("/", api.adminUserDeleteFactor)
+							r.With(usersWrite).Put("/", api.adminUserUpdateFactor)
 						})
 					})
 
-					r.Get("/", api.adminUserGet)
-					r.Put("/", api.adminUserUpdate)
-					r.Delete("/", api.adminUserDelete)
+					r.With(usersWrite).Delete("/lockout", api.adminUserUnlock)
+					r.With(usersWrite).Delete("/trusted_devices", api.adminUserDeleteTrustedDevices)
+					r.With(api.requireAdminScope(models.AdminScopeUsersImpersonate)).Post("/impersonate", api.adminUserImpersonate)
+
+					r.With(usersRead).Get("/", api.adminUserGet)
+					r.With(usersWrite).Put("/", api.adminUserUpdate)
+					r.With(usersWrite).Delete("/", api.adminUserDelete)
 				})
 			})
 
-			r.Post("/generate_link", api.adminGenerateLink)
+			r.With(api.requireAdminScope(models.AdminScopeUsersWrite)).Post("/generate_link", api.adminGenerateLink)
+
+			r.Route("/rate_limits", func(r *router) {
+				rateLimitsRead := api.requireAdminScope(models.AdminScopeRateLimitsRead)
+
+				r.With(rateLimitsRead).Get("/", api.adminRateLimiters)
+				r.With(rateLimitsRead).Get("/{limiter}", api.adminRateLimiterKeys)
+				r.With(api.requireAdminScope(models.AdminScopeRateLimitsWrite)).Delete("/{limiter}", api.adminRateLimiterClear)
+			})
+
+			r.Route("/api_keys", func(r *router) {
+				keysRead := api.requireAdminScope(models.AdminScopeAdminAPIKeysRead)
+				keysWrite := api.requireAdminScope(models.AdminScopeAdminAPIKeysWrite)
+
+				r.With(keysRead).Get("/", api.adminAPIKeysList)
+				r.With(keysWrite).Post("/", api.adminAPIKeysCreate)
+
+				r.Route("/{key_id}", func(r *router) {
+					r.Use(api.loadAdminAPIKey)
+
+					r.With(keysRead).Get("/", api.adminAPIKeysGet)
+					r.With(keysWrite).Delete("/", api.adminAPIKeysDelete)
+				})
+			})
+
+			r.Route("/events", func(r *router) {
+				eventsRead := api.requireAdminScope(models.AdminScopeEventsRead)
+				eventsWrite := api.requireAdminScope(models.AdminScopeEventsWrite)
+
+				r.With(eventsRead).Get("/", api.adminEvents)
+				r.With(eventsWrite).Post("/replay", api.adminEventsReplay)
+
+				r.Route("/{event_id}", func(r *router) {
+					r.Use(api.loadEvent)
+
+					r.With(eventsRead).Get("/", api.adminEventGet)
+					r.With(eventsWrite).Post("/replay", api.adminEventReplay)
+				})
+			})
 
 			r.Route("/sso", func(r *router) {
+				ssoRead := api.requireAdminScope(models.AdminScopeSSORead)
+				ssoWrite := api.requireAdminScope(models.AdminScopeSSOWrite)
+
 				r.Route("/providers", func(r *router) {
-					r.Get("/", api.adminSSOProvidersList)
-					r.Post("/", api.adminSSOProvidersCreate)
+					r.With(ssoRead).Get("/", api.adminSSOProvidersList)
+					r.With(ssoWrite).Post("/", api.adminSSOProvidersCreate)
 
 					r.Route("/{idp_id}", func(r *router) {
 						r.Use(api.loadSSOProvider)
 
-						r.Get("/", api.adminSSOProvidersGet)
-						r.Put("/", api.adminSSOProvidersUpdate)
-						r.Delete("/", api.adminSSOProvidersDelete)
+						r.With(ssoRead).Get("/", api.adminSSOProvidersGet)
+						r.With(ssoWrite).Put("/", api.adminSSOProvidersUpdate)
+						r.With(ssoWrite).Delete("/", api.adminSSOProvidersDelete)
 					})
 				})
 			})
 
 			// Admin only oauth client management endpoints
 			r.Route("/oauth", func(r *router) {
+				clientsRead := api.requireAdminScope(models.AdminScopeOAuthClientsRead)
+				clientsWrite := api.requireAdminScope(models.AdminScopeOAuthClientsWrite)
+
 				r.Route("/clients", func(r *router) {
 					// Manual client registration
-					r.Post("/", api.oauthServer.AdminOAuthServerClientRegister)
+					r.With(clientsWrite).Post("/", api.oauthServer.AdminOAuthServerClientRegister)
 
-					r.Get("/", api.oauthServer.OAuthServerClientList)
+					r.With(clientsRead).Get("/", api.oauthServer.OAuthServerClientList)
 
 					r.Route("/{client_id}", func(r *router) {
 						r.Use(api.oauthServer.LoadOAuthServerClient)
-						r.Get("/", api.oauthServer.OAuthServerClientGet)
-						r.Delete("/", api.oauthServer.OAuthServerClientDelete)
+						r.With(clientsRead).Get("/", api.oauthServer.OAuthServerClientGet)
+						r.With(clientsWrite).Delete("/", api.oauthServer.OAuthServerClientDelete)
 					})
 				})
 			})
@@ -328,7 +440,7 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 	corsHandler := cors.New(cors.Options{
 		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
 		AllowedHeaders:   globalConfig.CORS.AllAllowedHeaders([]string{"Accept", "Authorization", "Content-Type", "X-Client-IP", "X-Client-Info", audHeaderName, useCookieHeader, APIVersionHeaderName}),
-		ExposedHeaders:   []string{"X-Total-Count", "Link", APIVersionHeaderName},
+		ExposedHeaders:   []string{"X-Total-Count", "Link", APIVersionHeaderName, "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 	})
 
@@ -1,8 +1,14 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/linkly-id/auth/internal/conf"
 	"github.com/linkly-id/auth/internal/crypto"
 	"github.com/linkly-id/auth/internal/storage"
@@ -49,6 +55,50 @@ func setupAPIForTestWithCallback(cb func(*conf.GlobalConfiguration, *storage.Con
 	return NewAPIWithVersion(config, conn, apiTestVersion, limiterOpts), config, nil
 }
 
+// serveJSON serves a request with a JSON body, authorized with the token
+// unless it's empty.
+func serveJSON(t *testing.T, api *API, method, path, token string, body map[string]interface{}) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	require.NoError(t, json.NewEncoder(&buffer).Encode(body))
+
+	req := httptest.NewRequest(method, "http://localhost"+path, &buffer)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+
+	w := httptest.NewRecorder()
+	api.handler.ServeHTTP(w, req)
+	return w
+}
+
+// signInWithPassword signs in with the password grant, which must succeed,
+// and returns the response with the claims of its access token.
+func signInWithPassword(t *testing.T, api *API, body map[string]interface{}) (*AccessTokenResponse, *AccessTokenClaims) {
+	w := serveJSON(t, api, http.MethodPost, "/token?grant_type=password", "", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp AccessTokenResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	return &resp, parseAccessToken(t, api, resp.Token)
+}
+
+// parseAccessToken verifies an access token and returns its claims.
+func parseAccessToken(t *testing.T, api *API, token string) *AccessTokenClaims {
+	ctx, err := api.parseJWTClaims(token, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	return getClaims(ctx)
+}
+
+// generateAdminToken returns a token with the admin role.
+func generateAdminToken(t *testing.T, config *conf.GlobalConfiguration) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &AccessTokenClaims{
+		Role: "linkly_admin",
+	}).SignedString([]byte(config.JWT.Secret))
+	require.NoError(t, err)
+	return token
+}
+
 func TestEmailEnabledByDefault(t *testing.T) {
 	api, _, err := setupAPIForTest()
 	require.NoError(t, err)
@@ -52,6 +52,9 @@ type HTTPError struct {
 	InternalError   error  `json:"-"`
 	InternalMessage string `json:"-"`
 	ErrorID         string `json:"error_id,omitempty"`
+
+	// Headers are sent with the error response.
+	Headers http.Header `json:"-"`
 }
 
 func NewHTTPError(httpStatus int, errorCode ErrorCode, fmtString string, args ...any) *HTTPError {
@@ -67,6 +67,8 @@ const (
 	ErrorCodeUserSSOManaged                    ErrorCode = "user_sso_managed"
 	ErrorCodeReauthenticationNeeded            ErrorCode = "reauthentication_needed"
 	ErrorCodeSamePassword                      ErrorCode = "same_password"
+	ErrorCodePasswordReused                    ErrorCode = "password_reused"
+	ErrorCodePasswordChangeRequired            ErrorCode = "password_change_required"
 	ErrorCodeReauthenticationNotValid          ErrorCode = "reauthentication_not_valid"
 	ErrorCodeOTPExpired                        ErrorCode = "otp_expired"
 	ErrorCodeOTPDisabled                       ErrorCode = "otp_disabled"
@@ -84,10 +86,21 @@ const (
 	ErrorCodeRequestTimeout                    ErrorCode = "request_timeout"
 	ErrorCodeMFAPhoneEnrollDisabled            ErrorCode = "mfa_phone_enroll_not_enabled"
 	ErrorCodeMFAPhoneVerifyDisabled            ErrorCode = "mfa_phone_verify_not_enabled"
+	ErrorCodeMFAEmailEnrollDisabled            ErrorCode = "mfa_email_enroll_not_enabled"
+	ErrorCodeMFAEmailVerifyDisabled            ErrorCode = "mfa_email_verify_not_enabled"
+	ErrorCodeMFATrustedDevicesDisabled         ErrorCode = "mfa_trusted_devices_not_enabled"
+	ErrorCodeTrustedDeviceNotFound             ErrorCode = "trusted_device_not_found"
+	ErrorCodeMFAEnrollmentRequired             ErrorCode = "mfa_enrollment_required"
+	ErrorCodeMFAEnrollmentOverdue              ErrorCode = "mfa_enrollment_overdue"
 	ErrorCodeMFATOTPEnrollDisabled             ErrorCode = "mfa_totp_enroll_not_enabled"
 	ErrorCodeMFATOTPVerifyDisabled             ErrorCode = "mfa_totp_verify_not_enabled"
 	ErrorCodeMFAWebAuthnEnrollDisabled         ErrorCode = "mfa_webauthn_enroll_not_enabled"
 	ErrorCodeMFAWebAuthnVerifyDisabled         ErrorCode = "mfa_webauthn_verify_not_enabled"
+	ErrorCodeWebAuthnAuthenticatorNotAllowed   ErrorCode = "webauthn_authenticator_not_allowed"
+	ErrorCodeWebAuthnAttestationRejected       ErrorCode = "webauthn_attestation_rejected"
+	ErrorCodeWebAuthnUserVerificationRequired  ErrorCode = "webauthn_user_verification_required"
+	ErrorCodeMFARecoveryCodesEnrollDisabled    ErrorCode = "mfa_recovery_codes_enroll_not_enabled"
+	ErrorCodeMFARecoveryCodesVerifyDisabled    ErrorCode = "mfa_recovery_codes_verify_not_enabled"
 	ErrorCodeMFAVerifiedFactorExists           ErrorCode = "mfa_verified_factor_exists"
 	//#nosec G101 -- Not a secret value.
 	ErrorCodeInvalidCredentials        ErrorCode = "invalid_credentials"
@@ -95,6 +108,18 @@ const (
 	ErrorCodeEmailAddressInvalid       ErrorCode = "email_address_invalid"
 	ErrorCodeWeb3ProviderDisabled      ErrorCode = "web3_provider_disabled"
 	ErrorCodeWeb3UnsupportedChain      ErrorCode = "web3_unsupported_chain"
+	ErrorCodeUserLocked                ErrorCode = "user_locked"
+	ErrorCodePasskeyDisabled           ErrorCode = "passkey_disabled"
+	ErrorCodeRateLimiterNotFound       ErrorCode = "rate_limiter_not_found"
+	ErrorCodeIPAddressNotAllowed       ErrorCode = "ip_address_not_allowed"
+	ErrorCodeAdminAPIKeyInvalid        ErrorCode = "admin_api_key_invalid"
+	ErrorCodeAdminAPIKeyNotFound       ErrorCode = "admin_api_key_not_found"
+	ErrorCodeAdminScopeMissing         ErrorCode = "admin_scope_missing"
+	ErrorCodeAdminAPIKeyRestricted     ErrorCode = "admin_api_key_restricted"
+	ErrorCodeImpersonationDisabled     ErrorCode = "impersonation_disabled"
+	ErrorCodeImpersonationNotAllowed   ErrorCode = "impersonation_not_allowed"
+	ErrorCodeEventNotFound             ErrorCode = "event_not_found"
+	ErrorCodeHookRejected              ErrorCode = "hook_rejected"
 
 	ErrorCodeOAuthDynamicClientRegistrationDisabled ErrorCode = "oauth_dynamic_client_registration_disabled"
 )
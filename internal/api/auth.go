@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gofrs/uuid"
 	jwt "github.com/golang-jwt/jwt/v5"
@@ -49,6 +50,10 @@ func (a *API) requireAdmin(ctx context.Context) (context.Context, error) {
 		return nil, apierrors.NewForbiddenError(apierrors.ErrorCodeBadJWT, "Invalid token")
 	}
 
+	if err := sessionRestrictionError(claims.Restriction); err != nil {
+		return nil, err
+	}
+
 	adminRoles := a.config.JWT.AdminRoles
 
 	if isStringInSlice(claims.Role, adminRoles) {
@@ -59,6 +64,123 @@ func (a *API) requireAdmin(ctx context.Context) (context.Context, error) {
 	return nil, apierrors.NewForbiddenError(apierrors.ErrorCodeNotAdmin, "User not allowed").WithInternalMessage(fmt.Sprintf("this token needs to have one of the following roles: %v", strings.Join(adminRoles, ", ")))
 }
 
+// findAdminAPIKey finds the admin API key of the request, checking that it
+// hasn't expired and can be used from the IP address of the request, which
+// it returns along with the key.
+func (a *API) findAdminAPIKey(r *http.Request, token string) (*models.AdminAPIKey, string, error) {
+	db := a.db.WithContext(r.Context())
+
+	key, err := models.FindAdminAPIKeyByKey(db, token)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, "", apierrors.NewHTTPError(http.StatusUnauthorized, apierrors.ErrorCodeAdminAPIKeyInvalid, "Invalid admin API key")
+		}
+		return nil, "", apierrors.NewInternalServerError("Database error finding admin API key").WithInternalError(err)
+	}
+
+	if key.IsExpired(time.Now()) {
+		return nil, "", apierrors.NewHTTPError(http.StatusUnauthorized, apierrors.ErrorCodeAdminAPIKeyInvalid, "Admin API key has expired")
+	}
+
+	addr, ok := remoteAddr(r)
+	if cidrs := key.GetAllowedCIDRs(); len(cidrs) > 0 && (!ok || !cidrs.Contains(addr)) {
+		return nil, "", apierrors.NewForbiddenError(apierrors.ErrorCodeIPAddressNotAllowed, "Admin API key can't be used from this IP address")
+	}
+
+	ipAddress := r.RemoteAddr
+	if ok {
+		ipAddress = addr.String()
+	}
+
+	return key, ipAddress, nil
+}
+
+// requireAdminAPIKey authenticates a request to the admin API with an admin
+// API key, recording its use in the audit log.
+func (a *API) requireAdminAPIKey(r *http.Request, token string) (context.Context, error) {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	key, ipAddress, err := a.findAdminAPIKey(r, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// the key acts as the admin in audit log entries
+	adminUser := &models.User{ID: key.ID, Role: "admin_api_key", Email: storage.NullString(key.Name)}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := key.Touch(tx, ipAddress); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(a.config.AuditLog, r, tx, adminUser, models.AdminAPIKeyUsedAction, ipAddress, map[string]interface{}{
+			"admin_api_key_id": key.ID,
+			"method":           r.Method,
+			"path":             r.URL.Path,
+		})
+	}); err != nil {
+		return nil, apierrors.NewInternalServerError("Error recording admin API key use").WithInternalError(err)
+	}
+
+	ctx = withAdminAPIKey(ctx, key)
+	return withAdminUser(ctx, adminUser), nil
+}
+
+// requireAdminScope requires admin API keys to grant the scope. Admins
+// authenticated with a JWT of an admin role have every scope.
+func (a *API) requireAdminScope(scope string) middlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+		ctx := r.Context()
+
+		if getAdminAPIKey(ctx) == nil {
+			return ctx, nil
+		}
+		if err := checkAdminScope(ctx, scope); err != nil {
+			return nil, err
+		}
+
+		return withAdminScopeGranted(ctx), nil
+	}
+}
+
+// checkAdminScope refuses requests authenticated with an admin API key
+// missing the scope, for parameters needing more than the scope of their
+// route.
+func checkAdminScope(ctx context.Context, scope string) error {
+	if key := getAdminAPIKey(ctx); key != nil && !key.HasScope(scope) {
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeAdminScopeMissing, "Admin API key is missing the %s scope", scope)
+	}
+	return nil
+}
+
+// checkAdminRoleChange refuses setting the role of users to admin API keys
+// without the users:roles scope. Admin roles can only be set by admins
+// authenticated with a JWT, as the users could then sign in with every
+// scope.
+func (a *API) checkAdminRoleChange(ctx context.Context, role string) error {
+	if getAdminAPIKey(ctx) == nil {
+		return nil
+	}
+	if err := checkAdminScope(ctx, models.AdminScopeUsersRoles); err != nil {
+		return err
+	}
+	if isStringInSlice(role, a.config.JWT.AdminRoles) {
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeAdminScopeMissing, "Admin API keys can't grant admin roles")
+	}
+	return nil
+}
+
+// checkAdminScopeGranted refuses requests authenticated with an admin API
+// key unless the route declared a scope the key holds, so that keys can't
+// use routes that don't declare one.
+func checkAdminScopeGranted(ctx context.Context) error {
+	if getAdminAPIKey(ctx) != nil && !isAdminScopeGranted(ctx) {
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeAdminScopeMissing, "Admin API keys can't be used on this route")
+	}
+	return nil
+}
+
 func (a *API) extractBearerToken(r *http.Request) (string, error) {
 	authHeader := r.Header.Get("Authorization")
 	matches := bearerRegexp.FindStringSubmatch(authHeader)
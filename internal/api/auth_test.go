@@ -283,3 +283,39 @@ func (ts *AuthTestSuite) TestMaybeLoadUserOrSession() {
 		})
 	}
 }
+
+func TestRequireAdminScope(t *testing.T) {
+	api := &API{}
+
+	for _, c := range []struct {
+		key      *models.AdminAPIKey
+		path     string
+		expected int
+	}{
+		{nil, "/scoped", http.StatusOK},
+		{nil, "/unscoped", http.StatusOK},
+		{&models.AdminAPIKey{Scopes: models.AdminScopeUsersRead}, "/scoped", http.StatusOK},
+		{&models.AdminAPIKey{Scopes: models.AdminScopeAuditRead}, "/scoped", http.StatusForbidden},
+		// keys are denied on routes that don't declare a scope
+		{&models.AdminAPIKey{Scopes: models.AdminScopeUsersRead}, "/unscoped", http.StatusForbidden},
+	} {
+		r := newRouter()
+		r.UseBypass(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if c.key != nil {
+					req = req.WithContext(withAdminAPIKey(req.Context(), c.key))
+				}
+				next.ServeHTTP(w, req)
+			})
+		})
+		ok := func(w http.ResponseWriter, r *http.Request) error {
+			return sendJSON(w, http.StatusOK, map[string]interface{}{})
+		}
+		r.With(api.requireAdminScope(models.AdminScopeUsersRead)).Get("/scoped", ok)
+		r.Get("/unscoped", ok)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		require.Equal(t, c.expected, w.Code, "%s with scopes %v", c.path, c.key)
+	}
+}
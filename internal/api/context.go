@@ -31,6 +31,12 @@ const (
 	ssoProviderKey          = contextKey("sso_provider")
 	externalHostKey         = contextKey("external_host")
 	flowStateKey            = contextKey("flow_state_id")
+	ipAllowedKey            = contextKey("ip_allowed")
+	adminAPIKeyKey          = contextKey("admin_api_key")
+	adminScopeGrantedKey    = contextKey("admin_scope_granted")
+	targetAdminAPIKeyKey    = contextKey("target_admin_api_key")
+	targetEventKey          = contextKey("target_event")
+	messagesLimitedKey      = contextKey("messages_limited")
 )
 
 // withToken adds the JWT token to the context.
@@ -193,6 +199,65 @@ func getAdminUser(ctx context.Context) *models.User {
 	return obj.(*models.User)
 }
 
+// withAdminAPIKey adds the admin API key authenticating the request to the
+// context.
+func withAdminAPIKey(ctx context.Context, k *models.AdminAPIKey) context.Context {
+	return context.WithValue(ctx, adminAPIKeyKey, k)
+}
+
+// getAdminAPIKey reads the admin API key from the context, which is nil
+// when the admin is authenticated with a JWT.
+func getAdminAPIKey(ctx context.Context) *models.AdminAPIKey {
+	obj := ctx.Value(adminAPIKeyKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(*models.AdminAPIKey)
+}
+
+// withAdminScopeGranted records that the admin API key authenticating the
+// request holds the scope the route declares.
+func withAdminScopeGranted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminScopeGrantedKey, true)
+}
+
+// isAdminScopeGranted returns whether the route declared a scope that the
+// admin API key authenticating the request holds.
+func isAdminScopeGranted(ctx context.Context) bool {
+	granted, _ := ctx.Value(adminScopeGrantedKey).(bool)
+	return granted
+}
+
+// withTargetAdminAPIKey adds the admin API key an admin route acts on to the
+// context.
+func withTargetAdminAPIKey(ctx context.Context, k *models.AdminAPIKey) context.Context {
+	return context.WithValue(ctx, targetAdminAPIKeyKey, k)
+}
+
+// getTargetAdminAPIKey reads the admin API key an admin route acts on from
+// the context.
+func getTargetAdminAPIKey(ctx context.Context) *models.AdminAPIKey {
+	obj := ctx.Value(targetAdminAPIKeyKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(*models.AdminAPIKey)
+}
+
+// withTargetEvent adds the event an admin route acts on to the context.
+func withTargetEvent(ctx context.Context, e *models.Event) context.Context {
+	return context.WithValue(ctx, targetEventKey, e)
+}
+
+// getTargetEvent reads the event an admin route acts on from the context.
+func getTargetEvent(ctx context.Context) *models.Event {
+	obj := ctx.Value(targetEventKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(*models.Event)
+}
+
 // withRequestToken adds the request token to the context
 func withRequestToken(ctx context.Context, token string) context.Context {
 	return context.WithValue(ctx, oauthTokenKey, token)
@@ -241,3 +306,27 @@ func getExternalHost(ctx context.Context) *url.URL {
 	}
 	return obj.(*url.URL)
 }
+
+// withIPAllowed marks the request as coming from an allowed IP address.
+func withIPAllowed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ipAllowedKey, true)
+}
+
+// isIPAllowed reads whether the request comes from an allowed IP address.
+func isIPAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(ipAllowedKey).(bool)
+	return allowed
+}
+
+// withMessagesLimited marks the request as already counted against the
+// messages limit of its email address or phone number.
+func withMessagesLimited(ctx context.Context) context.Context {
+	return context.WithValue(ctx, messagesLimitedKey, true)
+}
+
+// isMessagesLimited reads whether the request was already counted against
+// the messages limit of its email address or phone number.
+func isMessagesLimited(ctx context.Context) bool {
+	limited, _ := ctx.Value(messagesLimitedKey).(bool)
+	return limited
+}
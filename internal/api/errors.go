@@ -92,13 +92,15 @@ func HandleResponseError(err error, w http.ResponseWriter, r *http.Request) {
 			var output struct {
 				HTTPErrorResponse20240101
 				Payload struct {
-					Reasons []string `json:"reasons,omitempty"`
+					Reasons  []string                  `json:"reasons,omitempty"`
+					Feedback *PasswordStrengthFeedback `json:"feedback,omitempty"`
 				} `json:"weak_password,omitempty"`
 			}
 
 			output.Code = apierrors.ErrorCodeWeakPassword
 			output.Message = e.Message
 			output.Payload.Reasons = e.Reasons
+			output.Payload.Feedback = e.Feedback
 
 			if jsonErr := sendJSON(w, http.StatusUnprocessableEntity, output); jsonErr != nil && jsonErr != context.DeadlineExceeded {
 				log.WithError(jsonErr).Warn("Failed to send JSON on ResponseWriter")
@@ -108,7 +110,8 @@ func HandleResponseError(err error, w http.ResponseWriter, r *http.Request) {
 			var output struct {
 				HTTPError
 				Payload struct {
-					Reasons []string `json:"reasons,omitempty"`
+					Reasons  []string                  `json:"reasons,omitempty"`
+					Feedback *PasswordStrengthFeedback `json:"feedback,omitempty"`
 				} `json:"weak_password,omitempty"`
 			}
 
@@ -116,6 +119,7 @@ func HandleResponseError(err error, w http.ResponseWriter, r *http.Request) {
 			output.ErrorCode = apierrors.ErrorCodeWeakPassword
 			output.Message = e.Message
 			output.Payload.Reasons = e.Reasons
+			output.Payload.Feedback = e.Feedback
 
 			w.Header().Set("x-sb-error-code", output.ErrorCode)
 
@@ -140,6 +144,10 @@ func HandleResponseError(err error, w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("x-sb-error-code", e.ErrorCode)
 		}
 
+		for name, values := range e.Headers {
+			w.Header()[name] = values
+		}
+
 		if apiVersion.Compare(APIVersion20240101) >= 0 {
 			resp := HTTPErrorResponse20240101{
 				Code:    e.ErrorCode,
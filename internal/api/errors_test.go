@@ -69,6 +69,23 @@ func TestHandleResponseErrorWithHTTPError(t *testing.T) {
 	}
 }
 
+func TestHandleResponseErrorWithHeaders(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	require.NoError(t, err)
+
+	httpErr := apierrors.NewTooManyRequestsError(apierrors.ErrorCodeOverRequestRateLimit, "Request rate limit reached")
+	httpErr.Headers = http.Header{"Retry-After": []string{"2"}}
+
+	HandleResponseError(httpErr, rec, req)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "2", rec.Header().Get("Retry-After"))
+
+	rec = httptest.NewRecorder()
+	HandleResponseError(apierrors.NewTooManyRequestsError(apierrors.ErrorCodeOverRequestRateLimit, "Request rate limit reached"), rec, req)
+	require.Empty(t, rec.Header().Get("Retry-After"))
+}
+
 func TestRecoverer(t *testing.T) {
 	var logBuffer bytes.Buffer
 	config, err := conf.LoadGlobal(apiTestConfig)
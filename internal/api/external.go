@@ -14,6 +14,7 @@ import (
 	"github.com/linkly-id/auth/internal/api/apierrors"
 	"github.com/linkly-id/auth/internal/api/provider"
 	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/hooks/v0hooks"
 	"github.com/linkly-id/auth/internal/metering"
 	"github.com/linkly-id/auth/internal/models"
 	"github.com/linkly-id/auth/internal/observability"
@@ -213,6 +214,13 @@ func (a *API) internalExternalProviderCallback(w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	// sessions are only issued here outside of the PKCE flow
+	if flowState == nil {
+		if err := a.triggerBeforeSignInExternalCallback(r, db, userData, providerType, targetUser, inviteToken); err != nil {
+			return err
+		}
+	}
+
 	var user *models.User
 	var token *AccessTokenResponse
 	err = db.Transaction(func(tx *storage.Connection) error {
@@ -310,6 +318,12 @@ func (a *API) createAccountFromExternalIdentity(tx *storage.Connection, r *http.
 			return nil, terr
 		}
 
+		if terr = models.NewEvent(config.Events, tx, models.IdentityLinkedEvent, user, map[string]interface{}{
+			"identity": identity,
+		}); terr != nil {
+			return nil, terr
+		}
+
 		if terr = user.UpdateUserMetaData(tx, identityData); terr != nil {
 			return nil, terr
 		}
@@ -399,6 +413,9 @@ func (a *API) createAccountFromExternalIdentity(tx *storage.Connection, r *http.
 			if terr = user.Confirm(tx); terr != nil {
 				return nil, apierrors.NewInternalServerError("Error updating user").WithInternalError(terr)
 			}
+			if terr = models.NewEvent(config.Events, tx, models.UserConfirmedEvent, user, nil); terr != nil {
+				return nil, apierrors.NewInternalServerError("Error recording event").WithInternalError(terr)
+			}
 		} else {
 			// Some providers, like web3 don't have email data.
 			// Treat these as if a confirmation email has been
@@ -429,6 +446,29 @@ func (a *API) createAccountFromExternalIdentity(tx *storage.Connection, r *http.
 	return user, nil
 }
 
+// triggerBeforeSignInExternalCallback runs the before sign-in hook for the
+// user the callback signs in: the user linking the identity, the invited
+// user or the user of the identity.
+func (a *API) triggerBeforeSignInExternalCallback(r *http.Request, conn *storage.Connection, userData *provider.UserProvidedData, providerType string, targetUser *models.User, inviteToken string) error {
+	switch {
+	case targetUser != nil:
+		return a.triggerBeforeSignIn(r, conn, targetUser, models.OAuth)
+
+	case inviteToken != "":
+		if !a.hooksMgr.Enabled(v0hooks.BeforeSignIn) {
+			return nil
+		}
+		user, err := models.FindUserByConfirmationToken(conn, inviteToken)
+		if err != nil {
+			// the transaction refuses invites that can't be found
+			return nil
+		}
+		return a.triggerBeforeSignIn(r, conn, user, models.OAuth)
+	}
+
+	return a.triggerBeforeSignInExternal(r, conn, userData, providerType, models.OAuth)
+}
+
 func (a *API) processInvite(r *http.Request, tx *storage.Connection, userData *provider.UserProvidedData, inviteToken, providerType string) (*models.User, error) {
 	config := a.config
 
@@ -494,6 +534,9 @@ func (a *API) processInvite(r *http.Request, tx *storage.Connection, userData *p
 	if err := user.Confirm(tx); err != nil {
 		return nil, err
 	}
+	if err := models.NewEvent(config.Events, tx, models.UserConfirmedEvent, user, nil); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
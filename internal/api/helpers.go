@@ -56,6 +56,7 @@ func isStringInSlice(checkValue string, list []string) bool {
 
 type RequestParams interface {
 	AdminUserParams |
+		AdminAPIKeyParams |
 		CreateSSOProviderParams |
 		EnrollFactorParams |
 		GenerateLinkParams |
@@ -63,6 +64,9 @@ type RequestParams interface {
 		InviteParams |
 		OtpParams |
 		PKCEGrantParams |
+		PasskeyGrantParams |
+		PasskeyRegistrationParams |
+		PasskeyRegistrationVerifyParams |
 		PasswordGrantParams |
 		RecoverParams |
 		RefreshTokenGrantParams |
@@ -42,6 +42,30 @@ func (a *API) triggerBeforeUserCreatedExternal(
 		return err
 	}
 
+	decision, user, err := a.externalIdentityUser(r, userData, providerType)
+	if err != nil {
+		return err
+	}
+
+	if decision != models.CreateAccount {
+		return nil
+	}
+	if a.config.DisableSignup {
+		return apierrors.NewUnprocessableEntityError(
+			apierrors.ErrorCodeSignupDisabled,
+			"Signups not allowed for this instance")
+	}
+	return a.triggerBeforeUserCreated(r, conn, user)
+}
+
+// externalIdentityUser returns the account linking decision of the external
+// identity along with the user it signs in: the existing user, or the one
+// that would be created.
+func (a *API) externalIdentityUser(
+	r *http.Request,
+	userData *provider.UserProvidedData,
+	providerType string,
+) (models.AccountLinkingDecision, *models.User, error) {
 	ctx := r.Context()
 	aud := a.requestAud(ctx, r)
 	config := a.config
@@ -65,16 +89,11 @@ func (a *API) triggerBeforeUserCreatedExternal(
 		return nil
 	})
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
 
 	if decision.Decision != models.CreateAccount {
-		return nil
-	}
-	if config.DisableSignup {
-		return apierrors.NewUnprocessableEntityError(
-			apierrors.ErrorCodeSignupDisabled,
-			"Signups not allowed for this instance")
+		return decision.Decision, decision.User, nil
 	}
 
 	params := &SignupParams{
@@ -90,10 +109,117 @@ func (a *API) triggerBeforeUserCreatedExternal(
 	}
 
 	user, err := params.ToUserModel(isSSOUser)
+	if err != nil {
+		return 0, nil, err
+	}
+	return decision.Decision, user, nil
+}
+
+// triggerBeforeSignInExternal runs the before sign-in hook for the user the
+// external identity signs in, before the transaction creating or linking
+// the account and issuing the session is opened.
+func (a *API) triggerBeforeSignInExternal(
+	r *http.Request,
+	conn *storage.Connection,
+	userData *provider.UserProvidedData,
+	providerType string,
+	authenticationMethod models.AuthenticationMethod,
+) error {
+	if !a.hooksMgr.Enabled(v0hooks.BeforeSignIn) {
+		return nil
+	}
+
+	_, user, err := a.externalIdentityUser(r, userData, providerType)
 	if err != nil {
 		return err
 	}
-	return a.triggerBeforeUserCreated(r, conn, user)
+	if user == nil {
+		// the transaction refuses identities matching several accounts
+		return nil
+	}
+	return a.triggerBeforeSignIn(r, conn, user, authenticationMethod)
+}
+
+// triggerBeforeSignIn runs the before sign-in hook, if enabled, when a session
+// is about to be issued to the user. It must be called before the transaction
+// issuing the session is opened, so that the hook doesn't hold it open and
+// rejections leave the sign-in, including one-time tokens, untouched.
+func (a *API) triggerBeforeSignIn(
+	r *http.Request,
+	conn *storage.Connection,
+	user *models.User,
+	authenticationMethod models.AuthenticationMethod,
+) error {
+	if !a.hooksMgr.Enabled(v0hooks.BeforeSignIn) {
+		return nil
+	}
+	if err := checkTX(conn); err != nil {
+		return err
+	}
+
+	req := v0hooks.NewBeforeSignInInput(r, user, authenticationMethod.String())
+	res := new(v0hooks.BeforeSignInOutput)
+	if err := a.hooksMgr.InvokeHook(conn, r, req, res); err != nil {
+		return err
+	}
+	return hookRejection(res.Decision, res.Message,
+		v0hooks.DefaultSignInHookRejectionMessage)
+}
+
+func (a *API) triggerBeforeTokenRefresh(
+	r *http.Request,
+	conn *storage.Connection,
+	user *models.User,
+	session *models.Session,
+) error {
+	if !a.hooksMgr.Enabled(v0hooks.BeforeTokenRefresh) {
+		return nil
+	}
+	if err := checkTX(conn); err != nil {
+		return err
+	}
+
+	req := v0hooks.NewBeforeTokenRefreshInput(r, user, session.ID)
+	res := new(v0hooks.BeforeTokenRefreshOutput)
+	if err := a.hooksMgr.InvokeHook(conn, r, req, res); err != nil {
+		return err
+	}
+	return hookRejection(res.Decision, res.Message,
+		v0hooks.DefaultTokenRefreshHookRejectionMessage)
+}
+
+func (a *API) triggerBeforeUserUpdated(
+	r *http.Request,
+	conn *storage.Connection,
+	user *models.User,
+	update *v0hooks.UserUpdate,
+) error {
+	if !a.hooksMgr.Enabled(v0hooks.BeforeUserUpdated) {
+		return nil
+	}
+	if err := checkTX(conn); err != nil {
+		return err
+	}
+
+	req := v0hooks.NewBeforeUserUpdatedInput(r, user, update)
+	res := new(v0hooks.BeforeUserUpdatedOutput)
+	if err := a.hooksMgr.InvokeHook(conn, r, req, res); err != nil {
+		return err
+	}
+	return hookRejection(res.Decision, res.Message,
+		v0hooks.DefaultUserUpdatedHookRejectionMessage)
+}
+
+// hookRejection returns a forbidden error when a hook decided to reject the
+// request, with the hook's message or the default one.
+func hookRejection(decision, message, defaultMessage string) error {
+	if decision != v0hooks.HookRejection {
+		return nil
+	}
+	if message == "" {
+		message = defaultMessage
+	}
+	return apierrors.NewForbiddenError(apierrors.ErrorCodeHookRejected, message)
 }
 
 func checkTX(conn *storage.Connection) error {
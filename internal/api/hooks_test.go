@@ -1,11 +1,16 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"net/http/httptest"
 
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/linkly-id/auth/internal/api/apierrors"
 	"github.com/linkly-id/auth/internal/conf"
 	"github.com/linkly-id/auth/internal/hooks/hookserrors"
 	"github.com/linkly-id/auth/internal/hooks/v0hooks"
@@ -294,3 +299,118 @@ func (ts *HooksTestSuite) TestInvokeHookIntegration() {
 	// Ensure that all expected HTTP interactions (mocks) have been called
 	require.True(ts.T(), gock.IsDone(), "Expected all mocks to have been called including retry")
 }
+
+func (ts *HooksTestSuite) TestBeforeSignInRefreshAndUserUpdatedHooks() {
+	hookFunctionSQL := `
+        create or replace function before_hooks_test(input jsonb)
+        returns json as $$
+        begin
+            if input->'user'->'user_metadata'->>'blocked' = 'true' or
+               input->'update'->'user_metadata'->>'blocked' = 'true' then
+                return '{"decision": "reject", "message": "blocked"}'::jsonb;
+            end if;
+            return '{}'::jsonb;
+        end; $$ language plpgsql;`
+	require.NoError(ts.T(), ts.API.db.RawQuery(hookFunctionSQL).Exec())
+
+	points := []*conf.ExtensibilityPointConfiguration{
+		&ts.Config.Hook.BeforeSignIn,
+		&ts.Config.Hook.BeforeTokenRefresh,
+		&ts.Config.Hook.BeforeUserUpdated,
+	}
+	for _, point := range points {
+		*point = conf.ExtensibilityPointConfiguration{
+			Enabled: true,
+			URI:     "pg-functions://postgres/auth/before_hooks_test",
+		}
+		require.NoError(ts.T(), point.PopulateExtensibilityPoint())
+	}
+	defer func() {
+		for _, point := range points {
+			*point = conf.ExtensibilityPointConfiguration{}
+		}
+	}()
+
+	now := time.Now()
+	ts.TestUser.EmailConfirmedAt = &now
+	require.NoError(ts.T(), ts.API.db.UpdateOnly(ts.TestUser, "email_confirmed_at"))
+
+	request := func(method, path, token string, body map[string]interface{}) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+		req := httptest.NewRequest(method, "http://localhost"+path, &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+	signIn := func() *httptest.ResponseRecorder {
+		return request(http.MethodPost, "/token?grant_type=password", "", map[string]interface{}{
+			"email":    ts.TestUser.GetEmail(),
+			"password": "securetestpassword",
+		})
+	}
+
+	w := signIn()
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+	token := AccessTokenResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&token))
+
+	// the update is rejected as it would block the user
+	w = request(http.MethodPut, "/user", token.Token, map[string]interface{}{
+		"data": map[string]interface{}{"blocked": true},
+	})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code, w.Body.String())
+	require.Contains(ts.T(), w.Body.String(), string(apierrors.ErrorCodeHookRejected))
+	require.Contains(ts.T(), w.Body.String(), "blocked")
+
+	w = request(http.MethodPost, "/token?grant_type=refresh_token", "", map[string]interface{}{
+		"refresh_token": token.RefreshToken,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&token))
+
+	// so is an admin update
+	adminToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &AccessTokenClaims{
+		Role: "linkly_admin",
+	}).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err)
+	w = request(http.MethodPut, "/admin/users/"+ts.TestUser.ID.String(), adminToken, map[string]interface{}{
+		"user_metadata": map[string]interface{}{"blocked": true},
+	})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code, w.Body.String())
+	require.Contains(ts.T(), w.Body.String(), string(apierrors.ErrorCodeHookRejected))
+
+	ts.TestUser.UserMetaData = map[string]interface{}{"blocked": true}
+	require.NoError(ts.T(), ts.API.db.UpdateOnly(ts.TestUser, "raw_user_meta_data"))
+
+	w = request(http.MethodPost, "/token?grant_type=refresh_token", "", map[string]interface{}{
+		"refresh_token": token.RefreshToken,
+	})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code, w.Body.String())
+	require.Contains(ts.T(), w.Body.String(), string(apierrors.ErrorCodeHookRejected))
+
+	w = signIn()
+	require.Equal(ts.T(), http.StatusForbidden, w.Code, w.Body.String())
+	require.Contains(ts.T(), w.Body.String(), string(apierrors.ErrorCodeHookRejected))
+
+	// a rejected magic link isn't used up
+	ts.TestUser.RecoveryToken = "hooks-test-recovery-token"
+	ts.TestUser.RecoverySentAt = &now
+	require.NoError(ts.T(), ts.API.db.UpdateOnly(ts.TestUser, "recovery_token", "recovery_sent_at"))
+	require.NoError(ts.T(), models.CreateOneTimeToken(ts.API.db, ts.TestUser.ID, ts.TestUser.GetEmail(), ts.TestUser.RecoveryToken, models.RecoveryToken))
+
+	w = request(http.MethodPost, "/verify", "", map[string]interface{}{
+		"type":       "magiclink",
+		"token_hash": ts.TestUser.RecoveryToken,
+	})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code, w.Body.String())
+	require.Contains(ts.T(), w.Body.String(), string(apierrors.ErrorCodeHookRejected))
+
+	user, err := models.FindUserByRecoveryToken(ts.API.db, ts.TestUser.RecoveryToken)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), ts.TestUser.ID, user.ID)
+}
@@ -121,7 +121,13 @@ func (a *API) linkIdentityToUser(r *http.Request, ctx context.Context, tx *stora
 		}
 		return nil, apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeIdentityAlreadyExists, "Identity is already linked to another user")
 	}
-	if _, terr := a.createNewIdentity(tx, targetUser, providerType, structs.Map(userData.Metadata)); terr != nil {
+	identity, terr = a.createNewIdentity(tx, targetUser, providerType, structs.Map(userData.Metadata))
+	if terr != nil {
+		return nil, terr
+	}
+	if terr := models.NewEvent(a.config.Events, tx, models.IdentityLinkedEvent, targetUser, map[string]interface{}{
+		"identity": identity,
+	}); terr != nil {
 		return nil, terr
 	}
 
@@ -141,6 +147,9 @@ func (a *API) linkIdentityToUser(r *http.Request, ctx context.Context, tx *stora
 		if terr := targetUser.Confirm(tx); terr != nil {
 			return nil, terr
 		}
+		if terr := models.NewEvent(a.config.Events, tx, models.UserConfirmedEvent, targetUser, nil); terr != nil {
+			return nil, terr
+		}
 
 		if targetUser.IsAnonymous {
 			targetUser.IsAnonymous = false
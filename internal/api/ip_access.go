@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/conf"
+)
+
+// ipAccessRules returns the IP access rules of the group of routes the path
+// belongs to, or nil when it isn't in a group.
+func ipAccessRules(config *conf.IPAccessConfiguration, path string) *conf.IPAccessRules {
+	switch {
+	case path == "/admin" || strings.HasPrefix(path, "/admin/"):
+		return &config.Admin
+	case path == "/token":
+		return &config.Token
+	case path == "/otp" || path == "/magiclink":
+		return &config.Otp
+	case path == "/signup":
+		return &config.Signup
+	case path == "/verify":
+		return &config.Verify
+	}
+
+	return nil
+}
+
+// remoteAddr returns the client's address, which the xff middleware has
+// resolved from the X-Forwarded-For header of trusted proxies.
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
+
+// ipAccess rejects requests from addresses denied access to the route, and
+// marks those from allowed addresses so they skip IP rate limits and
+// captcha verification. Addresses allowed by a group are only exempt from
+// its own deny list, so that addresses allowed globally to skip limits are
+// still denied by the deny list of a group.
+func (a *API) ipAccess(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	config := &a.config.IPAccess
+
+	rules := []*conf.IPAccessRules{&config.Global}
+	if group := ipAccessRules(config, r.URL.Path); group != nil {
+		rules = append(rules, group)
+	}
+
+	allowed, denied := false, false
+	addr, ok := remoteAddr(r)
+	for _, rule := range rules {
+		if ok && rule.Allow.Contains(addr) {
+			allowed = true
+			continue
+		}
+
+		// addresses that can't be parsed can't be allowed either
+		denied = denied || len(rule.Deny) > 0 && (!ok || rule.Deny.Contains(addr))
+	}
+
+	if denied {
+		return nil, apierrors.NewForbiddenError(apierrors.ErrorCodeIPAddressNotAllowed, "Access from this IP address is not allowed")
+	}
+
+	if allowed {
+		return withIPAllowed(ctx), nil
+	}
+
+	return ctx, nil
+}
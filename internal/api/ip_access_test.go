@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/didip/tollbooth/v5"
+	"github.com/didip/tollbooth/v5/limiter"
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func newIPAccessTestAPI(t *testing.T) *API {
+	cfg := &conf.GlobalConfiguration{}
+	cfg.ApplyDefaults()
+	require.NoError(t, cfg.IPAccess.Global.Deny.Decode("203.0.113.0/24"))
+	require.NoError(t, cfg.IPAccess.Global.Allow.Decode("198.51.100.1"))
+	require.NoError(t, cfg.IPAccess.Token.Allow.Decode("192.0.2.10"))
+	require.NoError(t, cfg.IPAccess.Admin.Deny.Decode("0.0.0.0/0,::/0"))
+	require.NoError(t, cfg.IPAccess.Admin.Allow.Decode("10.0.0.0/8"))
+
+	return &API{config: cfg, limiterOpts: NewLimiterOptions(cfg)}
+}
+
+func TestIPAccess(t *testing.T) {
+	a := newIPAccessTestAPI(t)
+
+	for _, c := range []struct {
+		path, remoteAddr string
+		allowed          bool
+		denied           bool
+	}{
+		{path: "/token", remoteAddr: "192.0.2.10:1234", allowed: true},
+		{path: "/token", remoteAddr: "192.0.2.11:1234"},
+		{path: "/otp", remoteAddr: "192.0.2.10:1234"},
+		{path: "/token", remoteAddr: "203.0.113.7:1234", denied: true},
+		{path: "/admin/users", remoteAddr: "10.1.2.3:1234", allowed: true},
+		{path: "/admin/users", remoteAddr: "[::ffff:10.1.2.3]:1234", allowed: true},
+		{path: "/admin/users", remoteAddr: "192.0.2.10:1234", denied: true},
+		{path: "/admin", remoteAddr: "[2001:db8::1]:1234", denied: true},
+		{path: "/admin/users", remoteAddr: "unknown", denied: true},
+		{path: "/administrators", remoteAddr: "192.0.2.10:1234"},
+		// global allows skip limits, but don't lift the deny list of groups
+		{path: "/token", remoteAddr: "198.51.100.1:1234", allowed: true},
+		{path: "/admin/users", remoteAddr: "198.51.100.1:1234", denied: true},
+	} {
+		req := httptest.NewRequest(http.MethodPost, c.path, nil)
+		req.RemoteAddr = c.remoteAddr
+
+		ctx, err := a.ipAccess(httptest.NewRecorder(), req)
+		if c.denied {
+			require.Error(t, err, c.path+" "+c.remoteAddr)
+			httpErr, ok := err.(*HTTPError)
+			require.True(t, ok)
+			require.Equal(t, http.StatusForbidden, httpErr.HTTPStatus)
+			require.Equal(t, apierrors.ErrorCodeIPAddressNotAllowed, httpErr.ErrorCode)
+			continue
+		}
+
+		require.NoError(t, err, c.path+" "+c.remoteAddr)
+		require.Equal(t, c.allowed, isIPAllowed(ctx), c.path+" "+c.remoteAddr)
+	}
+}
+
+func TestIPAccessSkipsRateLimitsAndCaptcha(t *testing.T) {
+	a := newIPAccessTestAPI(t)
+	a.config.RateLimitHeader = "X-Rate-Limit"
+	lmt := tollbooth.NewLimiter(1, &limiter.ExpirableOptions{
+		DefaultExpirationTTL: time.Hour,
+	}).SetBurst(1)
+
+	for i, exp := range []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		remoteAddr := "192.0.2.10:1234"
+		if i >= 2 {
+			remoteAddr = "192.0.2.11:1234"
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/token", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(a.config.RateLimitHeader, "key")
+		w := httptest.NewRecorder()
+
+		ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		middlewareHandler(a.ipAccess).handler(a.limitHandler(lmt).handler(ok)).ServeHTTP(w, req)
+		require.Equal(t, exp, w.Code, i)
+	}
+
+	// captcha verification is skipped as well, so the request needs no token
+	a.config.Security.Captcha.Enabled = true
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	_, err := a.verifyCaptcha(httptest.NewRecorder(), req.WithContext(withIPAllowed(req.Context())))
+	require.NoError(t, err)
+}
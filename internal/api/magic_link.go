@@ -62,6 +62,12 @@ func (a *API) MagicLink(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	if !isMessagesLimited(ctx) {
+		if err := a.limitMessagesTo(ctx, params.Email, ""); err != nil {
+			return err
+		}
+	}
+
 	if params.Data == nil {
 		params.Data = make(map[string]interface{})
 	}
@@ -119,7 +125,9 @@ func (a *API) MagicLink(w http.ResponseWriter, r *http.Request) error {
 				panic(fmt.Errorf("failed to marshal SignupParams: %w", err))
 			}
 			r.Body = io.NopCloser(bytes.NewReader(metadata))
-			return a.MagicLink(w, r)
+			// this request already counted against the messages
+			// limit of the email address
+			return a.MagicLink(w, r.WithContext(withMessagesLimited(ctx)))
 		}
 		// otherwise confirmation email already contains 'magic link'
 		if err := a.Signup(fakeResponse, r); err != nil {
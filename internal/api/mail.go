@@ -626,13 +626,13 @@ func (a *API) sendEmail(r *http.Request, tx *storage.Connection, u *models.User,
 	// TODO(km): Deprecate this behaviour - rate limits should still be applied to autoconfirm
 	if !config.Mailer.Autoconfirm {
 		// apply rate limiting before the email is sent out
-		if ok := a.limiterOpts.Email.Allow(); !ok {
+		if res := a.limiterOpts.Email.TakeAt(ctx, time.Now()); !res.Allowed {
 			emailRateLimitCounter.Add(
 				ctx,
 				1,
 				metric.WithAttributeSet(attribute.NewSet(attribute.String("path", r.URL.Path))),
 			)
-			return EmailRateLimitExceeded
+			return rateLimitError(apierrors.ErrorCodeOverEmailSendRateLimit, EmailRateLimitExceeded.Error(), res)
 		}
 	}
 
@@ -716,3 +716,60 @@ func (a *API) sendEmail(r *http.Request, tx *storage.Connection, u *models.User,
 		return err
 	}
 }
+
+// sendMFAEmail sends the code for an MFA email factor challenge to the
+// factor's address, which can differ from the user's email.
+func (a *API) sendMFAEmail(r *http.Request, tx *storage.Connection, u *models.User, email, otp string) error {
+	ctx := r.Context()
+	config := a.config
+	externalURL := getExternalHost(ctx)
+
+	if !a.checkEmailAddressAuthorization(email) {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeEmailAddressNotAuthorized, "Email address %q cannot be used as it is not authorized", email)
+	}
+
+	if config.RateLimitEmailSent.Events == 0 {
+		emailRateLimitCounter.Add(
+			ctx,
+			1,
+			metric.WithAttributeSet(attribute.NewSet(attribute.String("path", r.URL.Path))),
+		)
+		return EmailRateLimitExceeded
+	}
+
+	if res := a.limiterOpts.Email.TakeAt(ctx, time.Now()); !res.Allowed {
+		emailRateLimitCounter.Add(
+			ctx,
+			1,
+			metric.WithAttributeSet(attribute.NewSet(attribute.String("path", r.URL.Path))),
+		)
+		return rateLimitError(apierrors.ErrorCodeOverEmailSendRateLimit, EmailRateLimitExceeded.Error(), res)
+	}
+
+	if config.Hook.SendEmail.Enabled {
+		input := v0hooks.SendEmailInput{
+			User: u,
+			EmailData: mail.EmailData{
+				Token:           otp,
+				EmailActionType: mail.MFAEmailVerification,
+				SiteURL:         externalURL.String(),
+				Email:           email,
+			},
+		}
+		output := v0hooks.SendEmailOutput{}
+		return a.hooksMgr.InvokeHook(tx, r, &input, &output)
+	}
+
+	err := a.Mailer().MFAEmailMail(r, u, email, otp)
+	switch {
+	case errors.Is(err, mail.ErrInvalidEmailAddress),
+		errors.Is(err, mail.ErrInvalidEmailFormat),
+		errors.Is(err, mail.ErrInvalidEmailDNS):
+		return apierrors.NewBadRequestError(
+			apierrors.ErrorCodeEmailAddressInvalid,
+			"Email address %q is invalid",
+			email)
+	default:
+		return err
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -13,6 +14,7 @@ import (
 	"github.com/aaronarduino/goqrsvg"
 	svg "github.com/ajstarks/svgo"
 	"github.com/boombuler/barcode/qr"
+	"github.com/go-webauthn/webauthn/metadata"
 	wbnprotocol "github.com/go-webauthn/webauthn/protocol"
 	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/gofrs/uuid"
@@ -36,6 +38,7 @@ type EnrollFactorParams struct {
 	FactorType   string `json:"factor_type"`
 	Issuer       string `json:"issuer"`
 	Phone        string `json:"phone"`
+	Email        string `json:"email"`
 }
 
 type TOTPObject struct {
@@ -50,6 +53,9 @@ type EnrollFactorResponse struct {
 	FriendlyName string      `json:"friendly_name"`
 	TOTP         *TOTPObject `json:"totp,omitempty"`
 	Phone        string      `json:"phone,omitempty"`
+	Email        string      `json:"email,omitempty"`
+	// RecoveryCodes is only ever returned once, when the codes are generated
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
 }
 
 type ChallengeFactorParams struct {
@@ -61,6 +67,10 @@ type VerifyFactorParams struct {
 	ChallengeID uuid.UUID       `json:"challenge_id"`
 	Code        string          `json:"code"`
 	WebAuthn    *WebAuthnParams `json:"web_authn,omitempty"`
+	// TrustDevice asks for a trusted device token, which skips MFA on
+	// later sign-ins from the same device
+	TrustDevice bool   `json:"trust_device"`
+	DeviceLabel string `json:"device_label"`
 }
 
 type ChallengeFactorResponse struct {
@@ -90,7 +100,7 @@ func (w *WebAuthnParams) GetRPOrigins() []string {
 	return strings.Split(w.RPOrigins, ",")
 }
 
-func (w *WebAuthnParams) ToConfig() (*webauthn.WebAuthn, error) {
+func (w *WebAuthnParams) ToConfig(policy *conf.WebAuthnFactorTypeConfiguration, mds metadata.Provider) (*webauthn.WebAuthn, error) {
 	if w.RPID == "" {
 		return nil, fmt.Errorf("webAuthn RP ID cannot be empty")
 	}
@@ -122,6 +132,7 @@ func (w *WebAuthnParams) ToConfig() (*webauthn.WebAuthn, error) {
 		RPID:          w.RPID,
 		RPOrigins:     validOrigins,
 	}
+	applyWebAuthnPolicy(wconfig, policy, mds)
 
 	return webauthn.New(wconfig)
 }
@@ -228,6 +239,74 @@ func (a *API) enrollPhoneFactor(w http.ResponseWriter, r *http.Request, params *
 	})
 }
 
+func (a *API) enrollEmailFactor(w http.ResponseWriter, r *http.Request, params *EnrollFactorParams) error {
+	ctx := r.Context()
+	config := a.config
+	user := getUser(ctx)
+	session := getSession(ctx)
+	db := a.db.WithContext(ctx)
+
+	// the factor can use a different address than the user's email, but
+	// falls back to it when none is given
+	email := params.Email
+	if email == "" {
+		email = user.GetEmail()
+	}
+	if email == "" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Email address required to enroll Email factor")
+	}
+
+	email, err := a.validateEmail(email)
+	if err != nil {
+		return err
+	}
+
+	var factorsToDelete []models.Factor
+	for _, factor := range user.Factors {
+		if factor.IsEmailFactor() && factor.Email.String() == email {
+			if factor.IsVerified() {
+				return apierrors.NewUnprocessableEntityError(
+					apierrors.ErrorCodeMFAVerifiedFactorExists,
+					"A verified email factor already exists, unenroll the existing factor to continue",
+				)
+			} else if factor.IsUnverified() {
+				factorsToDelete = append(factorsToDelete, factor)
+			}
+		}
+	}
+
+	if err := db.Destroy(&factorsToDelete); err != nil {
+		return apierrors.NewInternalServerError("Database error deleting unverified email factors").WithInternalError(err)
+	}
+
+	if err := validateFactors(db, user, params.FriendlyName, a.config, session); err != nil {
+		return err
+	}
+
+	factor := models.NewEmailFactor(user, email, params.FriendlyName)
+	err = db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Create(factor); terr != nil {
+			return terr
+		}
+		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.EnrollFactorAction, r.RemoteAddr, map[string]interface{}{
+			"factor_id":   factor.ID,
+			"factor_type": factor.FactorType,
+		}); terr != nil {
+			return terr
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return sendJSON(w, http.StatusOK, &EnrollFactorResponse{
+		ID:           factor.ID,
+		Type:         models.Email,
+		FriendlyName: factor.FriendlyName,
+		Email:        email,
+	})
+}
+
 func (a *API) enrollWebAuthnFactor(w http.ResponseWriter, r *http.Request, params *EnrollFactorParams) error {
 	ctx := r.Context()
 	user := getUser(ctx)
@@ -336,6 +415,61 @@ func (a *API) enrollTOTPFactor(w http.ResponseWriter, r *http.Request, params *E
 	})
 }
 
+func (a *API) enrollRecoveryCodesFactor(w http.ResponseWriter, r *http.Request, params *EnrollFactorParams) error {
+	ctx := r.Context()
+	user := getUser(ctx)
+	config := a.config
+	session := getSession(ctx)
+	db := a.db.WithContext(ctx)
+
+	// Recovery codes are a fallback for the other factors and must not be
+	// enrolled as the only factor of a user.
+	if !session.IsAAL2() {
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeInsufficientAAL, "AAL2 required to enroll recovery codes")
+	}
+
+	if err := validateFactors(db, user, params.FriendlyName, config, session); err != nil {
+		return err
+	}
+
+	for _, factor := range user.Factors {
+		if factor.IsRecoveryCodesFactor() {
+			return apierrors.NewUnprocessableEntityError(
+				apierrors.ErrorCodeMFAVerifiedFactorExists,
+				"Recovery codes already exist, regenerate them to get new codes",
+			)
+		}
+	}
+
+	factor := models.NewRecoveryCodesFactor(user, params.FriendlyName)
+	var codes []string
+	err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if terr = tx.Create(factor); terr != nil {
+			return terr
+		}
+		if codes, terr = models.GenerateRecoveryCodes(tx, factor, config.MFA.RecoveryCodes.Count); terr != nil {
+			return terr
+		}
+		if terr = models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.GenerateRecoveryCodesAction, r.RemoteAddr, map[string]interface{}{
+			"factor_id":   factor.ID,
+			"factor_type": factor.FactorType,
+		}); terr != nil {
+			return terr
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return sendJSON(w, http.StatusOK, &EnrollFactorResponse{
+		ID:            factor.ID,
+		Type:          models.RecoveryCodes,
+		FriendlyName:  factor.FriendlyName,
+		RecoveryCodes: codes,
+	})
+}
+
 func (a *API) EnrollFactor(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	user := getUser(ctx)
@@ -356,6 +490,11 @@ func (a *API) EnrollFactor(w http.ResponseWriter, r *http.Request) error {
 			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAPhoneEnrollDisabled, "MFA enroll is disabled for Phone")
 		}
 		return a.enrollPhoneFactor(w, r, params)
+	case models.Email:
+		if !config.MFA.Email.EnrollEnabled {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAEmailEnrollDisabled, "MFA enroll is disabled for Email")
+		}
+		return a.enrollEmailFactor(w, r, params)
 	case models.TOTP:
 		if !config.MFA.TOTP.EnrollEnabled {
 			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFATOTPEnrollDisabled, "MFA enroll is disabled for TOTP")
@@ -366,8 +505,13 @@ func (a *API) EnrollFactor(w http.ResponseWriter, r *http.Request) error {
 			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAWebAuthnEnrollDisabled, "MFA enroll is disabled for WebAuthn")
 		}
 		return a.enrollWebAuthnFactor(w, r, params)
+	case models.RecoveryCodes:
+		if !config.MFA.RecoveryCodes.EnrollEnabled {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFARecoveryCodesEnrollDisabled, "MFA enroll is disabled for recovery codes")
+		}
+		return a.enrollRecoveryCodesFactor(w, r, params)
 	default:
-		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "factor_type needs to be totp, phone, or webauthn")
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "factor_type needs to be totp, phone, email, webauthn, or recovery_codes")
 	}
 
 }
@@ -454,6 +598,58 @@ func (a *API) challengePhoneFactor(w http.ResponseWriter, r *http.Request) error
 	})
 }
 
+func (a *API) challengeEmailFactor(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.config
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+	factor := getFactor(ctx)
+	ipAddress := utilities.GetIPAddress(r)
+
+	if factor.LastChallengedAt != nil {
+		if !factor.LastChallengedAt.Add(config.MFA.Email.MaxFrequency).Before(time.Now()) {
+			return apierrors.NewTooManyRequestsError(apierrors.ErrorCodeOverEmailSendRateLimit, generateFrequencyLimitErrorMessage(factor.LastChallengedAt, config.MFA.Email.MaxFrequency))
+		}
+	}
+
+	otp := crypto.GenerateOtp(config.MFA.Email.OtpLength)
+
+	challenge, err := factor.CreateEmailChallenge(ipAddress, otp, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey)
+	if err != nil {
+		return apierrors.NewInternalServerError("error creating Email Challenge")
+	}
+
+	if err := a.sendMFAEmail(r, db, user, factor.Email.String(), otp); err != nil {
+		if errors.Is(err, EmailRateLimitExceeded) {
+			return apierrors.NewTooManyRequestsError(apierrors.ErrorCodeOverEmailSendRateLimit, EmailRateLimitExceeded.Error())
+		} else if herr, ok := err.(*HTTPError); ok {
+			return herr
+		}
+		return apierrors.NewInternalServerError("error sending MFA email").WithInternalError(err)
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := factor.WriteChallengeToDatabase(tx, challenge); terr != nil {
+			return terr
+		}
+
+		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.CreateChallengeAction, r.RemoteAddr, map[string]interface{}{
+			"factor_id":     factor.ID,
+			"factor_status": factor.Status,
+		}); terr != nil {
+			return terr
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return sendJSON(w, http.StatusOK, &ChallengeFactorResponse{
+		ID:        challenge.ID,
+		Type:      factor.FactorType,
+		ExpiresAt: challenge.GetExpiryTime(config.MFA.ChallengeExpiryDuration).Unix(),
+	})
+}
+
 func (a *API) challengeTOTPFactor(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	config := a.config
@@ -503,7 +699,7 @@ func (a *API) challengeWebAuthnFactor(w http.ResponseWriter, r *http.Request) er
 	if params.WebAuthn == nil {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "web_authn config required")
 	}
-	webAuthn, err := params.WebAuthn.ToConfig()
+	webAuthn, err := params.WebAuthn.ToConfig(&config.MFA.WebAuthn, a.webAuthnMetadata)
 	if err != nil {
 		return err
 	}
@@ -589,6 +785,11 @@ func (a *API) ChallengeFactor(w http.ResponseWriter, r *http.Request) error {
 			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAPhoneVerifyDisabled, "MFA verification is disabled for Phone")
 		}
 		return a.challengePhoneFactor(w, r)
+	case models.Email:
+		if !config.MFA.Email.VerifyEnabled {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAEmailVerifyDisabled, "MFA verification is disabled for Email")
+		}
+		return a.challengeEmailFactor(w, r)
 
 	case models.TOTP:
 		if !config.MFA.TOTP.VerifyEnabled {
@@ -600,8 +801,15 @@ func (a *API) ChallengeFactor(w http.ResponseWriter, r *http.Request) error {
 			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAWebAuthnVerifyDisabled, "MFA verification is disabled for WebAuthn")
 		}
 		return a.challengeWebAuthnFactor(w, r)
+	case models.RecoveryCodes:
+		if !config.MFA.RecoveryCodes.VerifyEnabled {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFARecoveryCodesVerifyDisabled, "MFA verification is disabled for recovery codes")
+		}
+		// recovery codes don't need anything sent out, so they are
+		// challenged the same way as TOTP factors
+		return a.challengeTOTPFactor(w, r)
 	default:
-		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "factor_type needs to be totp, phone, or webauthn")
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "factor_type needs to be totp, phone, email, webauthn, or recovery_codes")
 	}
 
 }
@@ -688,6 +896,11 @@ func (a *API) verifyTOTPFactor(w http.ResponseWriter, r *http.Request, params *V
 			if terr = factor.UpdateStatus(tx, models.FactorStateVerified); terr != nil {
 				return terr
 			}
+			if terr = models.NewEvent(config.Events, tx, models.FactorEnrolledEvent, user, map[string]interface{}{
+				"factor": factor,
+			}); terr != nil {
+				return terr
+			}
 		}
 		if shouldReEncrypt && config.Security.DBEncryption.Encrypt {
 			es, terr := crypto.NewEncryptedString(factor.ID.String(), []byte(secret), config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey)
@@ -711,6 +924,9 @@ func (a *API) verifyTOTPFactor(w http.ResponseWriter, r *http.Request, params *V
 		if terr != nil {
 			return terr
 		}
+		if terr = a.issueTrustedDevice(r, tx, user, factor, params, token); terr != nil {
+			return terr
+		}
 		if terr = models.InvalidateSessionsWithAALLessThan(tx, user.ID, models.AAL2.String()); terr != nil {
 			return apierrors.NewInternalServerError("Failed to update sessions. %s", terr)
 		}
@@ -830,6 +1046,11 @@ func (a *API) verifyPhoneFactor(w http.ResponseWriter, r *http.Request, params *
 			if terr = factor.UpdateStatus(tx, models.FactorStateVerified); terr != nil {
 				return terr
 			}
+			if terr = models.NewEvent(config.Events, tx, models.FactorEnrolledEvent, user, map[string]interface{}{
+				"factor": factor,
+			}); terr != nil {
+				return terr
+			}
 		}
 		user, terr = models.FindUserByID(tx, user.ID)
 		if terr != nil {
@@ -842,6 +1063,9 @@ func (a *API) verifyPhoneFactor(w http.ResponseWriter, r *http.Request, params *
 		if terr != nil {
 			return terr
 		}
+		if terr = a.issueTrustedDevice(r, tx, user, factor, params, token); terr != nil {
+			return terr
+		}
 		if terr = models.InvalidateSessionsWithAALLessThan(tx, user.ID, models.AAL2.String()); terr != nil {
 			return apierrors.NewInternalServerError("Failed to update sessions. %s", terr)
 		}
@@ -861,6 +1085,132 @@ func (a *API) verifyPhoneFactor(w http.ResponseWriter, r *http.Request, params *
 	return sendJSON(w, http.StatusOK, token)
 }
 
+func (a *API) verifyEmailFactor(w http.ResponseWriter, r *http.Request, params *VerifyFactorParams) error {
+	ctx := r.Context()
+	config := a.config
+	user := getUser(ctx)
+	factor := getFactor(ctx)
+	db := a.db.WithContext(ctx)
+	currentIP := utilities.GetIPAddress(r)
+
+	challenge, err := a.validateChallenge(r, db, factor, params.ChallengeID)
+	if err != nil {
+		return err
+	}
+
+	if challenge.VerifiedAt != nil || challenge.IPAddress != currentIP {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAIPAddressMismatch, "Challenge and verify IP addresses mismatch")
+	}
+
+	if challenge.HasExpired(config.MFA.ChallengeExpiryDuration) {
+		if err := db.Destroy(challenge); err != nil {
+			return apierrors.NewInternalServerError("Database error deleting challenge").WithInternalError(err)
+		}
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAChallengeExpired, "MFA challenge %v has expired, verify against another challenge or create a new challenge.", challenge.ID)
+	}
+
+	otpCode, shouldReEncrypt, err := challenge.GetOtpCode(config.Security.DBEncryption.DecryptionKeys, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error verifying MFA Email code").WithInternalError(err)
+	}
+	valid := subtle.ConstantTimeCompare([]byte(otpCode), []byte(params.Code)) == 1
+
+	if config.Hook.MFAVerificationAttempt.Enabled {
+		input := v0hooks.MFAVerificationAttemptInput{
+			UserID:     user.ID,
+			FactorID:   factor.ID,
+			FactorType: factor.FactorType,
+			Valid:      valid,
+		}
+
+		output := v0hooks.MFAVerificationAttemptOutput{}
+		err := a.hooksMgr.InvokeHook(nil, r, &input, &output)
+		if err != nil {
+			return err
+		}
+
+		if output.Decision == v0hooks.HookRejection {
+			if err := models.Logout(db, user.ID); err != nil {
+				return err
+			}
+
+			if output.Message == "" {
+				output.Message = v0hooks.DefaultMFAHookRejectionMessage
+			}
+
+			return apierrors.NewForbiddenError(apierrors.ErrorCodeMFAVerificationRejected, output.Message)
+		}
+	}
+	if !valid {
+		if shouldReEncrypt && config.Security.DBEncryption.Encrypt {
+			if err := challenge.SetOtpCode(otpCode, true, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey); err != nil {
+				return err
+			}
+
+			if err := db.UpdateOnly(challenge, "otp_code"); err != nil {
+				return err
+			}
+		}
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAVerificationFailed, "Invalid MFA Email code entered")
+	}
+
+	var token *AccessTokenResponse
+
+	err = db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if terr = models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.VerifyFactorAction, r.RemoteAddr, map[string]interface{}{
+			"factor_id":    factor.ID,
+			"challenge_id": challenge.ID,
+			"factor_type":  factor.FactorType,
+		}); terr != nil {
+			return terr
+		}
+		if terr = challenge.Verify(tx); terr != nil {
+			return terr
+		}
+		if !factor.IsVerified() {
+			if terr = factor.UpdateStatus(tx, models.FactorStateVerified); terr != nil {
+				return terr
+			}
+			if terr = models.NewEvent(config.Events, tx, models.FactorEnrolledEvent, user, map[string]interface{}{
+				"factor": factor,
+			}); terr != nil {
+				return terr
+			}
+		}
+		user, terr = models.FindUserByID(tx, user.ID)
+		if terr != nil {
+			return terr
+		}
+
+		token, terr = a.updateMFASessionAndClaims(r, tx, user, models.MFAEmail, models.GrantParams{
+			FactorID: &factor.ID,
+		})
+		if terr != nil {
+			return terr
+		}
+		if terr = a.issueTrustedDevice(r, tx, user, factor, params, token); terr != nil {
+			return terr
+		}
+		if terr = models.InvalidateSessionsWithAALLessThan(tx, user.ID, models.AAL2.String()); terr != nil {
+			return apierrors.NewInternalServerError("Failed to update sessions. %s", terr)
+		}
+		if terr = models.DeleteUnverifiedFactors(tx, user, factor.FactorType); terr != nil {
+			return apierrors.NewInternalServerError("Error removing unverified factors. %s", terr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	metering.RecordLogin(metering.LoginTypeMFA, user.ID, &metering.LoginData{
+		Provider: metering.ProviderMFAEmail,
+	})
+
+	return sendJSON(w, http.StatusOK, token)
+}
+
 func (a *API) verifyWebAuthnFactor(w http.ResponseWriter, r *http.Request, params *VerifyFactorParams) error {
 	ctx := r.Context()
 	config := a.config
@@ -880,7 +1230,7 @@ func (a *API) verifyWebAuthnFactor(w http.ResponseWriter, r *http.Request, param
 	case factor.IsUnverified() && params.WebAuthn.CreationResponse == nil:
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "assertion_response required to login")
 	default:
-		webAuthn, err = params.WebAuthn.ToConfig()
+		webAuthn, err = params.WebAuthn.ToConfig(&config.MFA.WebAuthn, a.webAuthnMetadata)
 		if err != nil {
 			return err
 		}
@@ -901,8 +1251,17 @@ func (a *API) verifyWebAuthnFactor(w http.ResponseWriter, r *http.Request, param
 		if err != nil {
 			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Invalid credential_creation_response")
 		}
+		if err := a.validateWebAuthnAuthenticator(parsedResponse); err != nil {
+			return err
+		}
 		credential, err = webAuthn.CreateCredential(user, webAuthnSession, parsedResponse)
 		if err != nil {
+			if aerr := webAuthnAttestationError(err); aerr != nil {
+				return aerr
+			}
+			return err
+		}
+		if err := a.validateWebAuthnCredential(credential); err != nil {
 			return err
 		}
 
@@ -931,6 +1290,11 @@ func (a *API) verifyWebAuthnFactor(w http.ResponseWriter, r *http.Request, param
 			if terr = factor.UpdateStatus(tx, models.FactorStateVerified); terr != nil {
 				return terr
 			}
+			if terr = models.NewEvent(config.Events, tx, models.FactorEnrolledEvent, user, map[string]interface{}{
+				"factor": factor,
+			}); terr != nil {
+				return terr
+			}
 			if terr = factor.SaveWebAuthnCredential(tx, credential); terr != nil {
 				return terr
 			}
@@ -945,6 +1309,9 @@ func (a *API) verifyWebAuthnFactor(w http.ResponseWriter, r *http.Request, param
 		if terr != nil {
 			return terr
 		}
+		if terr = a.issueTrustedDevice(r, tx, user, factor, params, token); terr != nil {
+			return terr
+		}
 		if terr = models.InvalidateSessionsWithAALLessThan(tx, user.ID, models.AAL2.String()); terr != nil {
 			return apierrors.NewInternalServerError("Failed to update session").WithInternalError(terr)
 		}
@@ -964,6 +1331,110 @@ func (a *API) verifyWebAuthnFactor(w http.ResponseWriter, r *http.Request, param
 	return sendJSON(w, http.StatusOK, token)
 }
 
+func (a *API) verifyRecoveryCodesFactor(w http.ResponseWriter, r *http.Request, params *VerifyFactorParams) error {
+	ctx := r.Context()
+	config := a.config
+	user := getUser(ctx)
+	factor := getFactor(ctx)
+	db := a.db.WithContext(ctx)
+
+	if err := a.limitIdentifier(ctx, a.limiterOpts.RecoveryCodeVerify, user.ID.String(), apierrors.ErrorCodeOverRequestRateLimit, "Too many recovery code verification attempts, please try again later"); err != nil {
+		return err
+	}
+
+	challenge, err := a.validateChallenge(r, db, factor, params.ChallengeID)
+	if err != nil {
+		return err
+	}
+
+	recoveryCode, err := models.FindUnusedRecoveryCode(db, factor, params.Code)
+	if err != nil && !models.IsNotFoundError(err) {
+		return apierrors.NewInternalServerError("Database error verifying recovery code").WithInternalError(err)
+	}
+	valid := recoveryCode != nil
+
+	if config.Hook.MFAVerificationAttempt.Enabled {
+		input := v0hooks.MFAVerificationAttemptInput{
+			UserID:     user.ID,
+			FactorID:   factor.ID,
+			FactorType: factor.FactorType,
+			Valid:      valid,
+		}
+
+		output := v0hooks.MFAVerificationAttemptOutput{}
+		err := a.hooksMgr.InvokeHook(nil, r, &input, &output)
+		if err != nil {
+			return err
+		}
+
+		if output.Decision == v0hooks.HookRejection {
+			if err := models.Logout(db, user.ID); err != nil {
+				return err
+			}
+
+			if output.Message == "" {
+				output.Message = v0hooks.DefaultMFAHookRejectionMessage
+			}
+
+			return apierrors.NewForbiddenError(apierrors.ErrorCodeMFAVerificationRejected, output.Message)
+		}
+	}
+	if !valid {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAVerificationFailed, "Invalid recovery code entered")
+	}
+
+	var token *AccessTokenResponse
+
+	err = db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if terr = recoveryCode.Use(tx); terr != nil {
+			if models.IsNotFoundError(terr) {
+				return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAVerificationFailed, "Invalid recovery code entered")
+			}
+			return terr
+		}
+		if terr = models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.VerifyFactorAction, r.RemoteAddr, map[string]interface{}{
+			"factor_id":    factor.ID,
+			"challenge_id": challenge.ID,
+			"factor_type":  factor.FactorType,
+		}); terr != nil {
+			return terr
+		}
+		if terr = challenge.Verify(tx); terr != nil {
+			return terr
+		}
+		user, terr = models.FindUserByID(tx, user.ID)
+		if terr != nil {
+			return terr
+		}
+
+		token, terr = a.updateMFASessionAndClaims(r, tx, user, models.MFARecoveryCode, models.GrantParams{
+			FactorID: &factor.ID,
+		})
+		if terr != nil {
+			return terr
+		}
+		remaining, terr := models.CountUnusedRecoveryCodes(tx, factor)
+		if terr != nil {
+			return apierrors.NewInternalServerError("Database error counting recovery codes").WithInternalError(terr)
+		}
+		token.RecoveryCodesRemaining = &remaining
+		if terr = models.InvalidateSessionsWithAALLessThan(tx, user.ID, models.AAL2.String()); terr != nil {
+			return apierrors.NewInternalServerError("Failed to update sessions. %s", terr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	metering.RecordLogin(metering.LoginTypeMFA, user.ID, &metering.LoginData{
+		Provider: metering.ProviderMFARecoveryCode,
+	})
+
+	return sendJSON(w, http.StatusOK, token)
+}
+
 func (a *API) VerifyFactor(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	factor := getFactor(ctx)
@@ -976,6 +1447,19 @@ func (a *API) VerifyFactor(w http.ResponseWriter, r *http.Request) error {
 	if params.Code == "" && factor.FactorType != models.WebAuthn {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Code needs to be non-empty")
 	}
+	if err := a.limitIdentifier(ctx, a.limiterOpts.FactorVerifyPerUser, getUser(ctx).ID.String(), apierrors.ErrorCodeOverRequestRateLimit, "Too many verification attempts, please try again later"); err != nil {
+		return err
+	}
+	if params.TrustDevice {
+		if !config.MFA.TrustedDevices.Enabled {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFATrustedDevicesDisabled, "Trusted devices are disabled")
+		}
+		if factor.IsRecoveryCodesFactor() {
+			// a recovery code is used when the usual factor is not
+			// available, which is no reason to trust the device
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Devices can't be trusted when verifying recovery codes")
+		}
+	}
 
 	switch factor.FactorType {
 	case models.Phone:
@@ -984,6 +1468,11 @@ func (a *API) VerifyFactor(w http.ResponseWriter, r *http.Request) error {
 		}
 
 		return a.verifyPhoneFactor(w, r, params)
+	case models.Email:
+		if !config.MFA.Email.VerifyEnabled {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAEmailVerifyDisabled, "MFA verification is disabled for Email")
+		}
+		return a.verifyEmailFactor(w, r, params)
 	case models.TOTP:
 		if !config.MFA.TOTP.VerifyEnabled {
 			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFATOTPVerifyDisabled, "MFA verification is disabled for TOTP")
@@ -994,8 +1483,13 @@ func (a *API) VerifyFactor(w http.ResponseWriter, r *http.Request) error {
 			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAWebAuthnEnrollDisabled, "MFA verification is disabled for WebAuthn")
 		}
 		return a.verifyWebAuthnFactor(w, r, params)
+	case models.RecoveryCodes:
+		if !config.MFA.RecoveryCodes.VerifyEnabled {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFARecoveryCodesVerifyDisabled, "MFA verification is disabled for recovery codes")
+		}
+		return a.verifyRecoveryCodesFactor(w, r, params)
 	default:
-		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "factor_type needs to be totp, phone, or webauthn")
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "factor_type needs to be totp, phone, email, webauthn, or recovery_codes")
 	}
 
 }
@@ -1017,12 +1511,29 @@ func (a *API) UnenrollFactor(w http.ResponseWriter, r *http.Request) error {
 		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeInsufficientAAL, "AAL2 required to unenroll verified factor")
 	}
 
+	if factor.IsVerified() && config.MFA.Policy.Matches(user.Role, user.Aud, user.GetEmail(), user.AppMetaData) {
+		numVerifiedFactors := 0
+		for _, f := range user.Factors {
+			if f.IsVerified() {
+				numVerifiedFactors++
+			}
+		}
+		if numVerifiedFactors <= 1 {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAEnrollmentRequired, "The last verified factor can't be unenrolled as MFA is required")
+		}
+	}
+
+	action := models.UnenrollFactorAction
+	if factor.IsRecoveryCodesFactor() {
+		action = models.DeleteRecoveryCodesAction
+	}
+
 	err = db.Transaction(func(tx *storage.Connection) error {
 		var terr error
 		if terr := tx.Destroy(factor); terr != nil {
 			return terr
 		}
-		if terr = models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.UnenrollFactorAction, r.RemoteAddr, map[string]interface{}{
+		if terr = models.NewAuditLogEntry(config.AuditLog, r, tx, user, action, r.RemoteAddr, map[string]interface{}{
 			"factor_id":     factor.ID,
 			"factor_status": factor.Status,
 			"session_id":    session.ID,
@@ -1042,3 +1553,56 @@ func (a *API) UnenrollFactor(w http.ResponseWriter, r *http.Request) error {
 		ID: factor.ID,
 	})
 }
+
+// RegenerateRecoveryCodes replaces all recovery codes of a recovery codes
+// factor with new ones, invalidating the previous codes.
+func (a *API) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.config
+	user := getUser(ctx)
+	factor := getFactor(ctx)
+	session := getSession(ctx)
+	db := a.db.WithContext(ctx)
+
+	if factor == nil || session == nil || user == nil {
+		return apierrors.NewInternalServerError("A valid session and factor are required to regenerate recovery codes")
+	}
+
+	if !factor.IsRecoveryCodesFactor() {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Only recovery codes can be regenerated")
+	}
+
+	if !config.MFA.RecoveryCodes.EnrollEnabled {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFARecoveryCodesEnrollDisabled, "MFA enroll is disabled for recovery codes")
+	}
+
+	if !session.IsAAL2() {
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeInsufficientAAL, "AAL2 required to regenerate recovery codes")
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if codes, terr = models.GenerateRecoveryCodes(tx, factor, config.MFA.RecoveryCodes.Count); terr != nil {
+			return terr
+		}
+		if terr = models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.GenerateRecoveryCodesAction, r.RemoteAddr, map[string]interface{}{
+			"factor_id":   factor.ID,
+			"factor_type": factor.FactorType,
+			"session_id":  session.ID,
+		}); terr != nil {
+			return terr
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, &EnrollFactorResponse{
+		ID:            factor.ID,
+		Type:          models.RecoveryCodes,
+		FriendlyName:  factor.FriendlyName,
+		RecoveryCodes: codes,
+	})
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"time"
+
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/storage"
+)
+
+// SessionRestrictionMFAEnrollment restricts a session to enrolling an MFA
+// factor, as required by the MFA policy.
+const SessionRestrictionMFAEnrollment = "mfa_enrollment"
+
+// mfaPolicyApplies reports whether the MFA policy requires the user to
+// enroll a factor they don't have yet.
+func (a *API) mfaPolicyApplies(user *models.User) bool {
+	if user.IsAnonymous || user.HasMFAEnabled() {
+		return false
+	}
+
+	return a.config.MFA.Policy.Matches(user.Role, user.Aud, user.GetEmail(), user.AppMetaData)
+}
+
+// mfaEnrollmentDeadline returns when the user has to enroll a factor by,
+// or nil if the MFA policy doesn't require them to. Users who haven't
+// signed in since the policy started applying to them get the full grace
+// period.
+func (a *API) mfaEnrollmentDeadline(user *models.User) *time.Time {
+	if !a.mfaPolicyApplies(user) {
+		return nil
+	}
+
+	requiredAt := a.Now()
+	if user.MFARequiredAt != nil {
+		requiredAt = *user.MFARequiredAt
+	}
+
+	deadline := requiredAt.Add(a.config.MFA.Policy.GracePeriod)
+	return &deadline
+}
+
+// startMFAPolicy starts the grace period of users the MFA policy newly
+// applies to when they sign in, and starts it over for users who enrolled a
+// factor, should they remove all of them.
+func (a *API) startMFAPolicy(tx *storage.Connection, user *models.User) error {
+	var requiredAt *time.Time
+	if a.mfaPolicyApplies(user) {
+		if user.MFARequiredAt != nil {
+			return nil
+		}
+		now := a.Now()
+		requiredAt = &now
+	} else if user.MFARequiredAt == nil || !user.HasMFAEnabled() {
+		return nil
+	}
+
+	if err := user.SetMFARequiredAt(tx, requiredAt); err != nil {
+		return apierrors.NewInternalServerError("Database error updating user").WithInternalError(err)
+	}
+	return nil
+}
+
+// mfaPolicyRestriction returns the restriction of the sessions of users the
+// MFA policy applies to. Once the grace period is over, no tokens are issued
+// until the user has a factor.
+func (a *API) mfaPolicyRestriction(user *models.User) (string, error) {
+	deadline := a.mfaEnrollmentDeadline(user)
+	user.MFAEnrollmentDeadline = deadline
+	if deadline == nil {
+		return "", nil
+	}
+
+	if a.Now().After(*deadline) {
+		return "", apierrors.NewForbiddenError(apierrors.ErrorCodeMFAEnrollmentOverdue, "MFA enrollment was required by %s, contact an administrator", deadline.UTC().Format(time.RFC3339))
+	}
+
+	return SessionRestrictionMFAEnrollment, nil
+}
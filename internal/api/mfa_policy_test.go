@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+// createMFAPolicyUser enables the MFA policy and creates a confirmed user it
+// applies to.
+func (ts *MFATestSuite) createMFAPolicyUser() *models.User {
+	ts.Config.MFA.Policy = conf.MFAPolicyConfiguration{
+		Enabled:         true,
+		AppMetadataFlag: "mfa_required",
+		GracePeriod:     24 * time.Hour,
+	}
+
+	u, err := models.NewUser("", "policy@example.com", ts.TestPassword, ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	now := time.Now()
+	u.EmailConfirmedAt = &now
+	u.AppMetaData = map[string]interface{}{
+		"mfa_required": true,
+	}
+	require.NoError(ts.T(), ts.API.db.Create(u))
+
+	return u
+}
+
+func (ts *MFATestSuite) TestMFAPolicyRestrictedSession() {
+	user := ts.createMFAPolicyUser()
+
+	resp, claims := signInWithPassword(ts.T(), ts.API, map[string]interface{}{
+		"email":    user.GetEmail(),
+		"password": ts.TestPassword,
+	})
+	require.NotNil(ts.T(), resp.User.MFAEnrollmentDeadline)
+	require.WithinDuration(ts.T(), time.Now().Add(24*time.Hour), *resp.User.MFAEnrollmentDeadline, time.Minute)
+	require.Equal(ts.T(), SessionRestrictionMFAEnrollment, claims.Restriction)
+	require.Equal(ts.T(), SessionRestrictedRole, claims.Role)
+
+	user, err := models.FindUserByID(ts.API.db, user.ID)
+	require.NoError(ts.T(), err)
+	require.NotNil(ts.T(), user.MFARequiredAt)
+
+	// the user object reports the requirement
+	w := serveJSON(ts.T(), ts.API, http.MethodGet, "/user", resp.Token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	var body map[string]interface{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&body))
+	require.Contains(ts.T(), body, "mfa_enrollment_deadline")
+
+	// a restricted session can't be used to update the user
+	w = serveJSON(ts.T(), ts.API, http.MethodPut, "/user", resp.Token, map[string]interface{}{
+		"data": map[string]interface{}{"name": "policy"},
+	})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+	var data HTTPError
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), apierrors.ErrorCodeMFAEnrollmentRequired, data.ErrorCode)
+
+	// or anything else but enrolling a factor
+	w = serveJSON(ts.T(), ts.API, http.MethodGet, "/user/trusted_devices", resp.Token, nil)
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), apierrors.ErrorCodeMFAEnrollmentRequired, data.ErrorCode)
+
+	// but it can be used to enroll a factor
+	w = serveJSON(ts.T(), ts.API, http.MethodPost, "/factors", resp.Token, map[string]interface{}{
+		"factor_type": models.TOTP,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var factor EnrollFactorResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&factor))
+	w = serveJSON(ts.T(), ts.API, http.MethodPost, fmt.Sprintf("/factors/%s/challenge", factor.ID), resp.Token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	// and not to remove it again
+	w = serveJSON(ts.T(), ts.API, http.MethodDelete, fmt.Sprintf("/factors/%s", factor.ID), resp.Token, nil)
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+}
+
+func (ts *MFATestSuite) TestMFAPolicySignInRefusedAfterGracePeriod() {
+	user := ts.createMFAPolicyUser()
+	requiredAt := time.Now().Add(-48 * time.Hour)
+	require.NoError(ts.T(), user.SetMFARequiredAt(ts.API.db, &requiredAt))
+
+	w := serveJSON(ts.T(), ts.API, http.MethodPost, "/token?grant_type=password", "", map[string]interface{}{
+		"email":    user.GetEmail(),
+		"password": ts.TestPassword,
+	})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+
+	var data HTTPError
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), apierrors.ErrorCodeMFAEnrollmentOverdue, data.ErrorCode)
+}
+
+func (ts *MFATestSuite) TestMFAPolicyEnrolledUser() {
+	user := ts.createMFAPolicyUser()
+	requiredAt := time.Now().Add(-48 * time.Hour)
+	require.NoError(ts.T(), user.SetMFARequiredAt(ts.API.db, &requiredAt))
+
+	f := models.NewTOTPFactor(user, "totp")
+	f.Status = models.FactorStateVerified.String()
+	require.NoError(ts.T(), ts.API.db.Create(f))
+
+	resp, claims := signInWithPassword(ts.T(), ts.API, map[string]interface{}{
+		"email":    user.GetEmail(),
+		"password": ts.TestPassword,
+	})
+	require.Nil(ts.T(), resp.User.MFAEnrollmentDeadline)
+	require.Empty(ts.T(), claims.Restriction)
+
+	// the grace period starts over should the user lose their factors
+	user, err := models.FindUserByID(ts.API.db, user.ID)
+	require.NoError(ts.T(), err)
+	require.Nil(ts.T(), user.MFARequiredAt)
+
+	// the last factor can't be unenrolled
+	session, err := models.FindSessionByID(ts.API.db, uuid.FromStringOrNil(claims.SessionId), false)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), session.UpdateAALAndAssociatedFactor(ts.API.db, models.AAL2, &f.ID))
+
+	w := serveJSON(ts.T(), ts.API, http.MethodDelete, fmt.Sprintf("/factors/%s", f.ID), ts.generateAAL1Token(user, &session.ID), nil)
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+	var data HTTPError
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), apierrors.ErrorCodeMFAEnrollmentRequired, data.ErrorCode)
+}
+
+func (ts *MFATestSuite) TestMFAPolicyUnmatchedUser() {
+	user := ts.createMFAPolicyUser()
+	user.AppMetaData = map[string]interface{}{}
+	require.NoError(ts.T(), ts.API.db.UpdateOnly(user, "raw_app_meta_data"))
+
+	resp, claims := signInWithPassword(ts.T(), ts.API, map[string]interface{}{
+		"email":    user.GetEmail(),
+		"password": ts.TestPassword,
+	})
+	require.Nil(ts.T(), resp.User.MFAEnrollmentDeadline)
+	require.Empty(ts.T(), claims.Restriction)
+}
+
+func (ts *MFATestSuite) TestMFAPolicySettings() {
+	ts.createMFAPolicyUser()
+
+	w := serveJSON(ts.T(), ts.API, http.MethodGet, "/settings", "", nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var settings Settings
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&settings))
+	require.True(ts.T(), settings.MFAPolicy.Enabled)
+	require.Equal(ts.T(), int64(24*60*60), settings.MFAPolicy.GracePeriod)
+}
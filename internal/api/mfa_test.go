@@ -87,9 +87,24 @@ func (ts *MFATestSuite) SetupTest() {
 	ts.Config.MFA.Phone.EnrollEnabled = true
 	ts.Config.MFA.Phone.VerifyEnabled = true
 
+	ts.Config.MFA.Email.EnrollEnabled = true
+	ts.Config.MFA.Email.VerifyEnabled = true
+
 	ts.Config.MFA.WebAuthn.EnrollEnabled = true
 	ts.Config.MFA.WebAuthn.VerifyEnabled = true
 
+	ts.Config.External.Passkey.Enabled = true
+	ts.Config.External.Passkey.RPID = "localhost"
+	ts.Config.External.Passkey.RPDisplayName = "localhost"
+	ts.Config.External.Passkey.RPOrigins = []string{"http://localhost:3000"}
+	ts.Config.External.Passkey.ChallengeExpiryDuration = 5 * time.Minute
+
+	ts.Config.MFA.TrustedDevices.Enabled = true
+	ts.Config.MFA.TrustedDevices.ExpiryDuration = 24 * time.Hour
+
+	// tests enable the MFA policy with createMFAPolicyUser
+	ts.Config.MFA.Policy = conf.MFAPolicyConfiguration{}
+
 	key, err := totp.Generate(totp.GenerateOpts{
 		Issuer:      ts.TestDomain,
 		AccountName: ts.TestEmail,
@@ -456,6 +471,112 @@ func (ts *MFATestSuite) TestChallengeSMSFactor() {
 	}
 }
 
+func (ts *MFATestSuite) TestEnrollEmailFactor() {
+	token := ts.generateAAL1Token(ts.TestUser, &ts.TestSession.ID)
+
+	enroll := func(friendlyName, email string, expectedCode int) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(EnrollFactorParams{FriendlyName: friendlyName, FactorType: models.Email, Email: email}))
+		w := ServeAuthenticatedRequest(ts, http.MethodPost, "http://localhost/factors/", token, buffer)
+		require.Equal(ts.T(), expectedCode, w.Code)
+		return w
+	}
+
+	require.NoError(ts.T(), ts.API.db.Destroy(ts.TestUser.Factors))
+
+	// falls back to the user's email
+	w := enroll("primary", "", http.StatusOK)
+	enrollResp := EnrollFactorResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&enrollResp))
+	require.Equal(ts.T(), models.Email, enrollResp.Type)
+	require.Equal(ts.T(), ts.TestEmail, enrollResp.Email)
+
+	// a different address can be used
+	w = enroll("work", "Work@Example.com", http.StatusOK)
+	enrollResp = EnrollFactorResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&enrollResp))
+	require.Equal(ts.T(), "work@example.com", enrollResp.Email)
+
+	factor, err := models.FindFactorByFactorID(ts.API.db, enrollResp.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "work@example.com", factor.Email.String())
+	require.NoError(ts.T(), factor.UpdateStatus(ts.API.db, models.FactorStateVerified))
+
+	// the same address can't be enrolled twice once verified
+	w = enroll("work2", "work@example.com", http.StatusUnprocessableEntity)
+	data := HTTPError{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), apierrors.ErrorCodeMFAVerifiedFactorExists, data.ErrorCode)
+
+	_ = enroll("invalid", "not-an-email", http.StatusBadRequest)
+
+	ts.Config.MFA.Email.EnrollEnabled = false
+	w = enroll("disabled", "other@example.com", http.StatusUnprocessableEntity)
+	data = HTTPError{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), apierrors.ErrorCodeMFAEmailEnrollDisabled, data.ErrorCode)
+}
+
+func (ts *MFATestSuite) TestChallengeAndVerifyEmailFactor() {
+	ts.Config.Hook.SendEmail.Enabled = true
+	ts.Config.Hook.SendEmail.URI = "pg-functions://postgres/auth/send_email_mfa_mock"
+	ts.Config.MFA.Email.MaxFrequency = 1 * time.Minute
+
+	require.NoError(ts.T(), ts.Config.Hook.SendEmail.PopulateExtensibilityPoint())
+	require.NoError(ts.T(), ts.API.db.RawQuery(`
+        create or replace function send_email_mfa_mock(input jsonb)
+        returns json as $$
+        begin
+            return '{}'::jsonb;
+       end; $$ language plpgsql;`).Exec())
+	defer func() {
+		ts.Config.Hook.SendEmail.Enabled = false
+	}()
+
+	f := models.NewEmailFactor(ts.TestUser, "mfa@example.com", "testchallengeemailfactor")
+	require.NoError(ts.T(), ts.API.db.Create(f), "Error creating new email factor")
+	token := ts.generateAAL1Token(ts.TestUser, &ts.TestSession.ID)
+
+	w := ServeAuthenticatedRequest(ts, http.MethodPost, fmt.Sprintf("http://localhost/factors/%s/challenge", f.ID), token, bytes.Buffer{})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	challengeResp := ChallengeFactorResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&challengeResp))
+	require.Equal(ts.T(), models.Email, challengeResp.Type)
+
+	// a second challenge within the max frequency is rejected
+	w = ServeAuthenticatedRequest(ts, http.MethodPost, fmt.Sprintf("http://localhost/factors/%s/challenge", f.ID), token, bytes.Buffer{})
+	require.Equal(ts.T(), http.StatusTooManyRequests, w.Code)
+
+	challenge, err := f.FindChallengeByID(ts.API.db, challengeResp.ID)
+	require.NoError(ts.T(), err)
+	code, _, err := challenge.GetOtpCode(ts.Config.Security.DBEncryption.DecryptionKeys, ts.Config.Security.DBEncryption.Encrypt, ts.Config.Security.DBEncryption.EncryptionKeyID)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), code, ts.Config.MFA.Email.OtpLength)
+
+	verify := func(code string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"challenge_id": challengeResp.ID,
+			"code":         code,
+		}))
+		return ServeAuthenticatedRequest(ts, http.MethodPost, fmt.Sprintf("/factors/%s/verify", f.ID), token, buffer)
+	}
+
+	w = verify("000000" + code)
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	w = verify(code)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	f, err = models.FindFactorByFactorID(ts.API.db, f.ID)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), f.IsVerified())
+
+	session, err := models.FindSessionByID(ts.API.db, ts.TestSession.ID, false)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), session.IsAAL2())
+}
+
 func (ts *MFATestSuite) TestMFAVerifyFactor() {
 	cases := []struct {
 		desc             string
@@ -714,6 +835,91 @@ func (ts *MFATestSuite) TestMFAFollowedByPasswordSignIn() {
 	require.True(ts.T(), session.IsAAL2())
 }
 
+func (ts *MFATestSuite) TestRecoveryCodes() {
+	ts.Config.MFA.RecoveryCodes.EnrollEnabled = true
+	ts.Config.MFA.RecoveryCodes.VerifyEnabled = true
+	ts.Config.MFA.RecoveryCodes.Count = 10
+	defer func() {
+		ts.Config.MFA.RecoveryCodes.EnrollEnabled = false
+		ts.Config.MFA.RecoveryCodes.VerifyEnabled = false
+	}()
+
+	email := "recovery@example.com"
+	signUpResp := signUp(ts, email, ts.TestPassword)
+
+	// recovery codes can't be the first factor of a user
+	performEnrollFlow(ts, signUpResp.Token, "", models.RecoveryCodes, "", "", http.StatusForbidden)
+
+	resp := performEnrollAndVerify(ts, signUpResp.Token, true)
+	aal2Resp := &AccessTokenResponse{}
+	require.NoError(ts.T(), json.NewDecoder(resp.Body).Decode(aal2Resp))
+
+	w := performEnrollFlow(ts, aal2Resp.Token, "recovery", models.RecoveryCodes, "", "", http.StatusOK)
+	enrollResp := EnrollFactorResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&enrollResp))
+	require.Equal(ts.T(), models.RecoveryCodes, enrollResp.Type)
+	require.Len(ts.T(), enrollResp.RecoveryCodes, 10)
+
+	// only one set of recovery codes can exist
+	performEnrollFlow(ts, aal2Resp.Token, "recovery2", models.RecoveryCodes, "", "", http.StatusUnprocessableEntity)
+
+	verifyRecoveryCode := func(token, code string) *httptest.ResponseRecorder {
+		w := performChallengeFlow(ts, enrollResp.ID, token)
+		challengeResp := ChallengeFactorResponse{}
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&challengeResp))
+
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"challenge_id": challengeResp.ID,
+			"code":         code,
+		}))
+		return ServeAuthenticatedRequest(ts, http.MethodPost, fmt.Sprintf("/factors/%s/verify", enrollResp.ID), token, buffer)
+	}
+
+	signIn := func() string {
+		resp, _ := signInWithPassword(ts.T(), ts.API, map[string]interface{}{
+			"email":    email,
+			"password": ts.TestPassword,
+		})
+		return resp.Token
+	}
+
+	// codes are accepted with or without the separator
+	code := enrollResp.RecoveryCodes[0]
+	w = verifyRecoveryCode(signIn(), strings.ToUpper(strings.ReplaceAll(code, "-", "")))
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	data := &AccessTokenResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(data))
+	claims := parseAccessToken(ts.T(), ts.API, data.Token)
+	require.Equal(ts.T(), models.AAL2.String(), claims.AuthenticatorAssuranceLevel)
+	var methods []string
+	for _, entry := range claims.AuthenticationMethodReference {
+		methods = append(methods, entry.Method)
+	}
+	require.Contains(ts.T(), methods, models.MFARecoveryCode.String())
+	require.NotNil(ts.T(), data.RecoveryCodesRemaining)
+	require.Equal(ts.T(), 9, *data.RecoveryCodesRemaining)
+
+	// codes are single-use
+	w = verifyRecoveryCode(signIn(), code)
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	// regenerating invalidates the previous codes
+	var buffer bytes.Buffer
+	w = ServeAuthenticatedRequest(ts, http.MethodPost, fmt.Sprintf("/factors/%s/regenerate", enrollResp.ID), data.Token, buffer)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	regenerateResp := EnrollFactorResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&regenerateResp))
+	require.Len(ts.T(), regenerateResp.RecoveryCodes, 10)
+
+	w = verifyRecoveryCode(signIn(), enrollResp.RecoveryCodes[1])
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	w = verifyRecoveryCode(signIn(), regenerateResp.RecoveryCodes[1])
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+}
+
 func (ts *MFATestSuite) TestChallengeWebAuthnFactor() {
 	factor := models.NewWebAuthnFactor(ts.TestUser, "WebAuthnfactor")
 	validWebAuthnConfiguration := &WebAuthnParams{
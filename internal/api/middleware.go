@@ -5,8 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,6 +18,7 @@ import (
 	"github.com/linkly-id/auth/internal/api/oauthserver"
 	"github.com/linkly-id/auth/internal/models"
 	"github.com/linkly-id/auth/internal/observability"
+	"github.com/linkly-id/auth/internal/ratelimit"
 	"github.com/linkly-id/auth/internal/security"
 	"github.com/linkly-id/auth/internal/utilities"
 	"github.com/sirupsen/logrus"
@@ -58,7 +61,11 @@ func (f *FunctionHooks) UnmarshalJSON(b []byte) error {
 
 var emailRateLimitCounter = observability.ObtainMetricCounter("gotrue_email_rate_limit_counter", "Number of times an email rate limit has been triggered")
 
-func (a *API) performRateLimiting(lmt *limiter.Limiter, req *http.Request) error {
+func (a *API) performRateLimiting(w http.ResponseWriter, lmt *limiter.Limiter, req *http.Request) error {
+	if isIPAllowed(req.Context()) {
+		return nil
+	}
+
 	if limitHeader := a.config.RateLimitHeader; limitHeader != "" {
 		key := req.Header.Get(limitHeader)
 
@@ -66,8 +73,15 @@ func (a *API) performRateLimiting(lmt *limiter.Limiter, req *http.Request) error
 			log := observability.GetLogEntry(req).Entry
 			log.WithField("header", limitHeader).Warn("request does not have a value for the rate limiting header, rate limiting is not applied")
 		} else {
-			err := tollbooth.LimitByKeys(lmt, []string{key})
+			res, err := a.takeKey(req.Context(), lmt, key)
 			if err != nil {
+				return apierrors.NewInternalServerError("Error checking rate limit").WithInternalError(err)
+			}
+
+			for name, values := range rateLimitHeaders(res) {
+				w.Header()[name] = values
+			}
+			if !res.Allowed {
 				return apierrors.NewTooManyRequestsError(apierrors.ErrorCodeOverRequestRateLimit, "Request rate limit reached")
 			}
 		}
@@ -76,9 +90,88 @@ func (a *API) performRateLimiting(lmt *limiter.Limiter, req *http.Request) error
 	return nil
 }
 
+// takeKey counts a request of the key against the limiter, in the rate
+// limit backend when there's one and in memory otherwise, and returns the
+// state of the key's limit.
+func (a *API) takeKey(ctx context.Context, lmt *limiter.Limiter, key string) (ratelimit.Result, error) {
+	name, ok := a.limiterOpts.names[lmt]
+	if !ok {
+		// limiters that aren't part of the options are only counted by
+		// tollbooth, which doesn't tell what remains of the limit
+		if tollbooth.LimitByKeys(lmt, []string{key}) != nil {
+			return ratelimit.Result{Reset: retryAfter(lmt)}, nil
+		}
+		return ratelimit.Result{Allowed: true}, nil
+	}
+
+	backend := a.limiterOpts.Backend
+	if backend == nil {
+		backend = a.limiterOpts.memory
+	}
+
+	events, window := backendWindow(lmt)
+	return backend.TakeAt(ctx, name+":"+key, events, window, time.Now())
+}
+
+// rateLimitHeaders returns the RateLimit headers of the IETF draft for the
+// state of a limit, and a Retry-After when the request wasn't allowed.
+func rateLimitHeaders(res ratelimit.Result) http.Header {
+	h := http.Header{}
+	reset := strconv.Itoa(int(math.Ceil(res.Reset.Seconds())))
+
+	if res.Limit > 0 {
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", reset)
+	}
+
+	if !res.Allowed {
+		h.Set("Retry-After", reset)
+	}
+
+	return h
+}
+
+// rateLimitError returns a too many requests error with the error code and
+// message, and the headers of the limit that was reached.
+func rateLimitError(errorCode apierrors.ErrorCode, message string, res ratelimit.Result) *HTTPError {
+	err := apierrors.NewTooManyRequestsError(errorCode, "%s", message)
+	err.Headers = rateLimitHeaders(res)
+	return err
+}
+
+// limitIdentifier counts a request for an email address, phone number or
+// user against the limiter, which is disabled when nil. Requests over the
+// limit are rejected with the error code and message.
+func (a *API) limitIdentifier(ctx context.Context, lmt *limiter.Limiter, key string, errorCode apierrors.ErrorCode, message string) error {
+	if lmt == nil {
+		return nil
+	}
+
+	res, err := a.takeKey(ctx, lmt, key)
+	if err != nil {
+		return apierrors.NewInternalServerError("Error checking rate limit").WithInternalError(err)
+	}
+	if !res.Allowed {
+		return rateLimitError(errorCode, message, res)
+	}
+
+	return nil
+}
+
+// limitMessagesTo limits the messages sent to the email address, or the
+// phone number when there's no email address.
+func (a *API) limitMessagesTo(ctx context.Context, email, phone string) error {
+	if email != "" {
+		return a.limitIdentifier(ctx, a.limiterOpts.OtpPerIdentifier, models.IdentifierLockoutKey("email", email), apierrors.ErrorCodeOverEmailSendRateLimit, EmailRateLimitExceeded.Error())
+	}
+
+	return a.limitIdentifier(ctx, a.limiterOpts.OtpPerIdentifier, models.IdentifierLockoutKey("phone", phone), apierrors.ErrorCodeOverSMSSendRateLimit, "SMS rate limit exceeded")
+}
+
 func (a *API) limitHandler(lmt *limiter.Limiter) middlewareHandler {
 	return func(w http.ResponseWriter, req *http.Request) (context.Context, error) {
-		return req.Context(), a.performRateLimiting(lmt, req)
+		return req.Context(), a.performRateLimiting(w, lmt, req)
 	}
 }
 
@@ -123,6 +216,10 @@ func (a *API) requireAdminCredentials(w http.ResponseWriter, req *http.Request)
 		return nil, err
 	}
 
+	if models.IsAdminAPIKey(t) {
+		return a.requireAdminAPIKey(req, t)
+	}
+
 	ctx, err := a.parseJWTClaims(t, req)
 	if err != nil {
 		return nil, err
@@ -142,15 +239,37 @@ func (a *API) requireEmailProvider(w http.ResponseWriter, req *http.Request) (co
 	return ctx, nil
 }
 
+// canBypassCaptcha returns whether the request is authenticated with a JWT
+// of an admin role, or an admin API key granting the captcha:bypass scope.
+// Unlike admin routes, uses of the key aren't recorded in the audit log.
+func (a *API) canBypassCaptcha(req *http.Request) bool {
+	t, err := a.extractBearerToken(req)
+	if err != nil || t == "" {
+		return false
+	}
+
+	if models.IsAdminAPIKey(t) {
+		key, _, err := a.findAdminAPIKey(req, t)
+		return err == nil && key.HasScope(models.AdminScopeCaptchaBypass)
+	}
+
+	ctx, err := a.parseJWTClaims(t, req)
+	if err != nil {
+		return false
+	}
+
+	_, err = a.requireAdmin(ctx)
+	return err == nil
+}
+
 func (a *API) verifyCaptcha(w http.ResponseWriter, req *http.Request) (context.Context, error) {
 	ctx := req.Context()
 	config := a.config
 
-	if !config.Security.Captcha.Enabled {
+	if !config.Security.Captcha.Enabled || isIPAllowed(ctx) {
 		return ctx, nil
 	}
-	if _, err := a.requireAdminCredentials(w, req); err == nil {
-		// skip captcha validation if authorization header contains an admin role
+	if a.canBypassCaptcha(req) {
 		return ctx, nil
 	}
 	if shouldIgnore := isIgnoreCaptchaRoute(req); shouldIgnore {
@@ -194,6 +313,9 @@ func isIgnoreCaptchaRoute(req *http.Request) bool {
 
 	case "web3":
 		return false
+
+	case "webauthn":
+		return false
 	}
 
 	return false
@@ -293,6 +415,14 @@ func (a *API) requireSAMLEnabled(w http.ResponseWriter, req *http.Request) (cont
 	return ctx, nil
 }
 
+func (a *API) requirePasskeyEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.External.Passkey.Enabled {
+		return nil, apierrors.NewUnprocessableEntityError(apierrors.ErrorCodePasskeyDisabled, "Passkeys are disabled")
+	}
+	return ctx, nil
+}
+
 func (a *API) requireManualLinkingEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
 	ctx := req.Context()
 	if !a.config.Security.ManualLinkingEnabled {
@@ -15,6 +15,7 @@ import (
 	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/linkly-id/auth/internal/api/apierrors"
 	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/models"
 	"github.com/linkly-id/auth/internal/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -134,6 +135,41 @@ func (ts *MiddlewareTestSuite) TestVerifyCaptchaValid() {
 	}
 }
 
+func (ts *MiddlewareTestSuite) TestCanBypassCaptcha() {
+	models.TruncateAll(ts.API.db)
+
+	adminJwt, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &AccessTokenClaims{
+		Role: "linkly_admin",
+	}).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err)
+
+	bypass, bypassKey, err := models.NewAdminAPIKey("signup tests", []string{models.AdminScopeCaptchaBypass}, nil, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(bypass))
+
+	users, usersKey, err := models.NewAdminAPIKey("backoffice", []string{models.AdminScopeUsersWrite}, nil, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(users))
+
+	for bearer, expected := range map[string]bool{
+		"":        false,
+		adminJwt:  true,
+		bypassKey: true,
+		usersKey:  false,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/signup", nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		require.Equal(ts.T(), expected, ts.API.canBypassCaptcha(req))
+	}
+
+	// bypassing captcha isn't recorded in the audit log
+	entries := []models.AuditLogEntry{}
+	require.NoError(ts.T(), ts.API.db.Q().All(&entries))
+	require.Empty(ts.T(), entries)
+}
+
 func (ts *MiddlewareTestSuite) TestVerifyCaptchaInvalid() {
 	cases := []struct {
 		desc         string
@@ -28,16 +28,70 @@ type LimiterOptions struct {
 	User                *limiter.Limiter
 	FactorVerify        *limiter.Limiter
 	FactorChallenge     *limiter.Limiter
+	RecoveryCodeVerify  *limiter.Limiter
 	SSO                 *limiter.Limiter
 	SAMLAssertion       *limiter.Limiter
 	Web3                *limiter.Limiter
+	Passkey             *limiter.Limiter
 	OAuthClientRegister *limiter.Limiter
+
+	// These limit requests per email address, phone number or user
+	// rather than per client, and are nil when they're disabled.
+	OtpPerIdentifier      *limiter.Limiter
+	PasswordPerIdentifier *limiter.Limiter
+	FactorVerifyPerUser   *limiter.Limiter
+
+	// Backend counts the requests of the limiters instead of the memory of
+	// this instance, when it's set.
+	Backend ratelimit.Backend
+
+	// names are the prefixes of the limiters' keys in the backend
+	names map[*limiter.Limiter]string
+
+	// memory counts the requests of the limiters without a backend
+	memory *ratelimit.MemoryBackend
 }
 
 func (lo *LimiterOptions) apply(a *API) { a.limiterOpts = lo }
 
+// WithBackend returns a copy of the options counting requests, emails and
+// SMS messages in the backend.
+func (lo *LimiterOptions) WithBackend(backend ratelimit.Backend, gc *conf.GlobalConfiguration) *LimiterOptions {
+	o := *lo
+	o.Backend = backend
+	o.Email = ratelimit.NewBackendLimiter(backend, "email_sent", gc.RateLimitEmailSent)
+	o.Phone = ratelimit.NewBackendLimiter(backend, "sms_sent", gc.RateLimitSmsSent)
+
+	return &o
+}
+
+// backendWindow returns the limit of a token bucket limiter as the events
+// per sliding window of a backend: its burst, over the time it takes to
+// refill.
+func backendWindow(lmt *limiter.Limiter) (int, time.Duration) {
+	burst := max(lmt.GetBurst(), 1)
+
+	if lmt.GetMax() <= 0 {
+		return burst, lmt.GetTokenBucketExpirationTTL()
+	}
+
+	return burst, time.Duration(float64(burst) / lmt.GetMax() * float64(time.Second))
+}
+
+// retryAfter returns the time it takes a token bucket limiter to allow
+// another request once its limit is reached.
+func retryAfter(lmt *limiter.Limiter) time.Duration {
+	if lmt.GetMax() <= 0 {
+		return lmt.GetTokenBucketExpirationTTL()
+	}
+
+	return time.Duration(float64(time.Second) / lmt.GetMax())
+}
+
 func NewLimiterOptions(gc *conf.GlobalConfiguration) *LimiterOptions {
-	o := &LimiterOptions{}
+	o := &LimiterOptions{
+		memory: ratelimit.NewMemoryBackend(),
+	}
 
 	o.Email = ratelimit.New(gc.RateLimitEmailSent)
 	o.Phone = ratelimit.New(gc.RateLimitSmsSent)
@@ -72,6 +126,13 @@ func NewLimiterOptions(gc *conf.GlobalConfiguration) *LimiterOptions {
 			DefaultExpirationTTL: time.Minute,
 		}).SetBurst(30)
 
+	// Recovery codes are limited per user rather than per client, as they
+	// are long lived and a successful guess grants AAL2.
+	o.RecoveryCodeVerify = tollbooth.NewLimiter(gc.MFA.RateLimitRecoveryCodeVerify/(60*5),
+		&limiter.ExpirableOptions{
+			DefaultExpirationTTL: time.Hour,
+		}).SetBurst(int(gc.MFA.RateLimitRecoveryCodeVerify))
+
 	o.SSO = tollbooth.NewLimiter(gc.RateLimitSso/(60*5),
 		&limiter.ExpirableOptions{
 			DefaultExpirationTTL: time.Hour,
@@ -98,8 +159,42 @@ func NewLimiterOptions(gc *conf.GlobalConfiguration) *LimiterOptions {
 	o.MagicLink = newLimiterPer5mOver1h(gc.RateLimitOtp)
 	o.Otp = newLimiterPer5mOver1h(gc.RateLimitOtp)
 
+	o.Passkey = newLimiterPer5mOver1h(gc.RateLimitPasskey)
 	o.OAuthClientRegister = newLimiterPer5mOver1h(gc.RateLimitOAuthDynamicClientRegister)
 
+	o.OtpPerIdentifier = newIdentifierLimiter(gc.RateLimitOtpPerIdentifier)
+	o.PasswordPerIdentifier = newIdentifierLimiter(gc.RateLimitPasswordPerIdentifier)
+	o.FactorVerifyPerUser = newIdentifierLimiter(gc.MFA.RateLimitVerifyPerUser)
+
+	o.names = map[*limiter.Limiter]string{
+		o.Signups:             "signups",
+		o.AnonymousSignIns:    "anonymous_sign_ins",
+		o.Recover:             "recover",
+		o.Resend:              "resend",
+		o.MagicLink:           "magic_link",
+		o.Otp:                 "otp",
+		o.Token:               "token",
+		o.Verify:              "verify",
+		o.User:                "user",
+		o.FactorVerify:        "factor_verify",
+		o.FactorChallenge:     "factor_challenge",
+		o.RecoveryCodeVerify:  "recovery_code_verify",
+		o.SSO:                 "sso",
+		o.SAMLAssertion:       "saml_assertion",
+		o.Web3:                "web3",
+		o.Passkey:             "passkey",
+		o.OAuthClientRegister: "oauth_client_register",
+	}
+	for lmt, name := range map[*limiter.Limiter]string{
+		o.OtpPerIdentifier:      "otp_per_identifier",
+		o.PasswordPerIdentifier: "password_per_identifier",
+		o.FactorVerifyPerUser:   "factor_verify_per_user",
+	} {
+		if lmt != nil {
+			o.names[lmt] = name
+		}
+	}
+
 	return o
 }
 
@@ -110,3 +205,20 @@ func newLimiterPer5mOver1h(rate float64) *limiter.Limiter {
 	}).SetBurst(30)
 	return lim
 }
+
+// newIdentifierLimiter returns a limiter allowing the rate's events over its
+// time, or nil when the rate has no events.
+func newIdentifierLimiter(rate conf.Rate) *limiter.Limiter {
+	if rate.Events <= 0 {
+		return nil
+	}
+
+	overTime := rate.OverTime
+	if overTime <= 0 {
+		overTime = time.Hour
+	}
+
+	return tollbooth.NewLimiter(rate.Events/overTime.Seconds(), &limiter.ExpirableOptions{
+		DefaultExpirationTTL: overTime,
+	}).SetBurst(int(rate.Events))
+}
@@ -1,10 +1,17 @@
 package api
 
 import (
+	"context"
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/didip/tollbooth/v5"
+	"github.com/linkly-id/auth/internal/api/apierrors"
 	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/ratelimit"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewLimiterOptions(t *testing.T) {
@@ -28,3 +35,125 @@ func TestNewLimiterOptions(t *testing.T) {
 	assert.NotNil(t, rl.SSO)
 	assert.NotNil(t, rl.SAMLAssertion)
 }
+
+type testRateLimitBackend struct {
+	keys   []string
+	events int
+	window time.Duration
+}
+
+func (b *testRateLimitBackend) TakeAt(ctx context.Context, key string, events int, window time.Duration, at time.Time) (ratelimit.Result, error) {
+	b.keys = append(b.keys, key)
+	b.events, b.window = events, window
+	return ratelimit.Result{Allowed: len(b.keys) <= events, Limit: events}, nil
+}
+
+func (b *testRateLimitBackend) ListAt(ctx context.Context, prefix string, events int, window time.Duration, at time.Time) ([]ratelimit.KeyResult, error) {
+	return nil, nil
+}
+
+func (b *testRateLimitBackend) Clear(ctx context.Context, key string) error {
+	return nil
+}
+
+func (b *testRateLimitBackend) ClearPrefix(ctx context.Context, prefix string) error {
+	return nil
+}
+
+func TestLimiterOptionsWithBackend(t *testing.T) {
+	cfg := &conf.GlobalConfiguration{}
+	cfg.ApplyDefaults()
+	cfg.RateLimitTokenRefresh = 150
+
+	rl := NewLimiterOptions(cfg)
+	backend := &testRateLimitBackend{}
+	withBackend := rl.WithBackend(backend, cfg)
+	assert.Nil(t, rl.Backend)
+	assert.IsType(t, &ratelimit.BackendLimiter{}, withBackend.Email)
+	assert.IsType(t, &ratelimit.BackendLimiter{}, withBackend.Phone)
+
+	a := &API{limiterOpts: withBackend}
+	for i := 0; i < 30; i++ {
+		res, err := a.takeKey(context.Background(), withBackend.Token, "1.2.3.4")
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+	}
+	res, err := a.takeKey(context.Background(), withBackend.Token, "1.2.3.4")
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+
+	// burst of 30 at 150 per 5 minutes
+	assert.Equal(t, "token:1.2.3.4", backend.keys[0])
+	assert.Equal(t, 30, backend.events)
+	assert.Equal(t, time.Minute, backend.window)
+
+	// limiters without a name are counted by tollbooth
+	lmt := tollbooth.NewLimiter(1, nil)
+	res, err = a.takeKey(context.Background(), lmt, "1.2.3.4")
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	assert.Len(t, backend.keys, 31)
+}
+
+func TestNewLimiterOptionsPerIdentifier(t *testing.T) {
+	cfg := &conf.GlobalConfiguration{}
+	cfg.ApplyDefaults()
+
+	rl := NewLimiterOptions(cfg)
+	assert.Nil(t, rl.OtpPerIdentifier)
+	assert.Nil(t, rl.PasswordPerIdentifier)
+	assert.Nil(t, rl.FactorVerifyPerUser)
+
+	require.NoError(t, cfg.RateLimitOtpPerIdentifier.Decode("5/15m"))
+	require.NoError(t, cfg.RateLimitPasswordPerIdentifier.Decode("10"))
+	cfg.MFA.RateLimitVerifyPerUser = conf.Rate{Events: 3, OverTime: time.Minute}
+
+	rl = NewLimiterOptions(cfg)
+	require.NotNil(t, rl.OtpPerIdentifier)
+	require.NotNil(t, rl.PasswordPerIdentifier)
+	require.NotNil(t, rl.FactorVerifyPerUser)
+
+	events, window := backendWindow(rl.OtpPerIdentifier)
+	assert.Equal(t, 5, events)
+	assert.Equal(t, 15*time.Minute, window)
+	assert.Equal(t, 3*time.Minute, retryAfter(rl.OtpPerIdentifier))
+
+	events, window = backendWindow(rl.PasswordPerIdentifier)
+	assert.Equal(t, 10, events)
+	assert.Equal(t, time.Hour, window)
+
+	a := &API{limiterOpts: rl}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, a.limitIdentifier(context.Background(), rl.FactorVerifyPerUser, "user", apierrors.ErrorCodeOverRequestRateLimit, "Request rate limit reached"))
+	}
+	err := a.limitIdentifier(context.Background(), rl.FactorVerifyPerUser, "user", apierrors.ErrorCodeOverRequestRateLimit, "Request rate limit reached")
+	require.Error(t, err)
+	httpErr, ok := err.(*HTTPError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, httpErr.HTTPStatus)
+	assert.Equal(t, "20", httpErr.Headers.Get("Retry-After"))
+	assert.Equal(t, "3", httpErr.Headers.Get("RateLimit-Limit"))
+	assert.Equal(t, "0", httpErr.Headers.Get("RateLimit-Remaining"))
+
+	// other users aren't limited
+	require.NoError(t, a.limitIdentifier(context.Background(), rl.FactorVerifyPerUser, "other", apierrors.ErrorCodeOverRequestRateLimit, "Request rate limit reached"))
+	// nor are identifiers when the limit is disabled
+	require.NoError(t, a.limitIdentifier(context.Background(), nil, "user", apierrors.ErrorCodeOverRequestRateLimit, "Request rate limit reached"))
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	h := rateLimitHeaders(ratelimit.Result{Allowed: true, Limit: 30, Remaining: 12, Reset: 1500 * time.Millisecond})
+	assert.Equal(t, "30", h.Get("RateLimit-Limit"))
+	assert.Equal(t, "12", h.Get("RateLimit-Remaining"))
+	assert.Equal(t, "2", h.Get("RateLimit-Reset"))
+	assert.Empty(t, h.Get("Retry-After"))
+
+	h = rateLimitHeaders(ratelimit.Result{Limit: 30, Reset: time.Minute})
+	assert.Equal(t, "0", h.Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", h.Get("Retry-After"))
+
+	// limits that are unknown only tell when to retry
+	h = rateLimitHeaders(ratelimit.Result{Reset: time.Second})
+	assert.Empty(t, h.Get("RateLimit-Limit"))
+	assert.Equal(t, "1", h.Get("Retry-After"))
+}
@@ -123,6 +123,10 @@ func (a *API) SmsOtp(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	if err := a.limitMessagesTo(ctx, "", params.Phone); err != nil {
+		return err
+	}
+
 	var isNewUser bool
 	aud := a.requestAud(ctx, r)
 	user, err := models.FindUserByPhoneAndAudience(db, params.Phone, aud)
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/linkly-id/auth/internal/api/apierrors"
 	"github.com/linkly-id/auth/internal/conf"
@@ -310,3 +311,28 @@ func (ts *OtpTestSuite) TestSubsequentOtp() {
 	require.Empty(ts.T(), user.RecoverySentAt)
 	require.Empty(ts.T(), user.EmailConfirmedAt)
 }
+
+func (ts *OtpTestSuite) TestMagicLinkAutoconfirmPerIdentifierRateLimit() {
+	ts.Config.Mailer.Autoconfirm = true
+	ts.Config.SMTP.MaxFrequency = 0
+	ts.API.limiterOpts.OtpPerIdentifier = newIdentifierLimiter(conf.Rate{Events: 1, OverTime: time.Hour})
+	defer func() {
+		ts.Config.Mailer.Autoconfirm = false
+		ts.API.limiterOpts.OtpPerIdentifier = nil
+	}()
+
+	// signing up and sending the magic link count once
+	for _, expected := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"email": "new@example.com",
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "/magiclink", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), expected, w.Code, w.Body.String())
+	}
+}
@@ -37,6 +37,21 @@ func addPaginationHeaders(w http.ResponseWriter, r *http.Request, p *models.Pagi
 	w.Header().Add("X-Total-Count", fmt.Sprintf("%v", p.Count))
 }
 
+// addCursorPaginationHeaders links the next page of cursor pagination, if
+// there is one.
+func addCursorPaginationHeaders(w http.ResponseWriter, r *http.Request, next string) {
+	if next == "" {
+		return
+	}
+
+	url, _ := url.ParseRequestURI(r.URL.String())
+	query := url.Query()
+	query.Set("cursor", next)
+	url.RawQuery = query.Encode()
+
+	w.Header().Add("Link", "<"+url.String()+">; rel=\"next\"")
+}
+
 func paginate(r *http.Request) (*models.Pagination, error) {
 	params := r.URL.Query()
 	queryPage := params.Get("page")
@@ -0,0 +1,430 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	wbnprotocol "github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/metering"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/linkly-id/auth/internal/utilities"
+)
+
+const invalidPasskeyMessage = "Invalid passkey credential"
+
+// PasskeyChallengeResponse is the response to a passkey sign-in challenge.
+type PasskeyChallengeResponse struct {
+	ChallengeID              uuid.UUID                        `json:"challenge_id"`
+	CredentialRequestOptions *wbnprotocol.CredentialAssertion `json:"credential_request_options"`
+	ExpiresAt                int64                            `json:"expires_at"`
+}
+
+// PasskeyGrantParams are the parameters of the webauthn grant.
+type PasskeyGrantParams struct {
+	ChallengeID       uuid.UUID       `json:"challenge_id"`
+	AssertionResponse json.RawMessage `json:"assertion_response"`
+}
+
+// PasskeyRegistrationParams are the parameters to start registering a passkey.
+type PasskeyRegistrationParams struct {
+	FriendlyName string `json:"friendly_name"`
+}
+
+// PasskeyRegistrationResponse is the response to a request to register a
+// passkey. The ID is the ID of the WebAuthn factor backing the passkey.
+type PasskeyRegistrationResponse struct {
+	ID                        uuid.UUID                       `json:"id"`
+	ChallengeID               uuid.UUID                       `json:"challenge_id"`
+	CredentialCreationOptions *wbnprotocol.CredentialCreation `json:"credential_creation_options"`
+	ExpiresAt                 int64                           `json:"expires_at"`
+}
+
+// PasskeyRegistrationVerifyParams are the parameters to finish registering a
+// passkey.
+type PasskeyRegistrationVerifyParams struct {
+	ChallengeID      uuid.UUID       `json:"challenge_id"`
+	CreationResponse json.RawMessage `json:"creation_response"`
+}
+
+func (a *API) passkeyWebAuthn() (*webauthn.WebAuthn, error) {
+	config := a.config.External.Passkey
+
+	wconfig := &webauthn.Config{
+		RPID:          config.RPID,
+		RPDisplayName: config.RPDisplayName,
+		RPOrigins:     config.RPOrigins,
+	}
+	applyWebAuthnPolicy(wconfig, &a.config.MFA.WebAuthn, a.webAuthnMetadata)
+
+	return webauthn.New(wconfig)
+}
+
+// PasskeyChallenge issues a challenge for signing in with a passkey. No
+// session is required, as the user is identified by the credential the
+// authenticator picks.
+func (a *API) PasskeyChallenge(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.config
+	db := a.db.WithContext(ctx)
+
+	webAuthn, err := a.passkeyWebAuthn()
+	if err != nil {
+		return apierrors.NewInternalServerError("Failed to configure WebAuthn").WithInternalError(err)
+	}
+
+	options, session, err := webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(wbnprotocol.VerificationRequired))
+	if err != nil {
+		return apierrors.NewInternalServerError("Failed to generate WebAuthn login data").WithInternalError(err)
+	}
+
+	challenge := models.NewPasskeyChallenge(session, utilities.GetIPAddress(r))
+	if err := db.Create(challenge); err != nil {
+		return apierrors.NewInternalServerError("Database error creating passkey challenge").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, &PasskeyChallengeResponse{
+		ChallengeID:              challenge.ID,
+		CredentialRequestOptions: options,
+		ExpiresAt:                challenge.GetExpiryTime(config.External.Passkey.ChallengeExpiryDuration).Unix(),
+	})
+}
+
+// PasskeyGrant implements the webauthn grant type, signing in the user that
+// owns the passkey which answered a challenge issued by PasskeyChallenge.
+func (a *API) PasskeyGrant(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	config := a.config
+	db := a.db.WithContext(ctx)
+
+	if !config.External.Passkey.Enabled {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodePasskeyDisabled, "Passkeys are disabled")
+	}
+
+	params := &PasskeyGrantParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if params.ChallengeID == uuid.Nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "challenge_id required to sign in with a passkey")
+	}
+
+	if len(params.AssertionResponse) == 0 {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "assertion_response required to sign in with a passkey")
+	}
+
+	parsedResponse, err := wbnprotocol.ParseCredentialRequestResponseBody(bytes.NewReader(params.AssertionResponse))
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Invalid assertion_response")
+	}
+
+	webAuthn, err := a.passkeyWebAuthn()
+	if err != nil {
+		return apierrors.NewInternalServerError("Failed to configure WebAuthn").WithInternalError(err)
+	}
+
+	challenge, err := models.FindPasskeyChallengeByID(db, params.ChallengeID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return apierrors.NewOAuthError("invalid_grant", "Passkey challenge not found")
+		}
+		return apierrors.NewInternalServerError("Database error finding passkey challenge").WithInternalError(err)
+	}
+
+	// the challenge is consumed before validating the response so that
+	// every challenge can only be answered once
+	if err := challenge.Consume(db); err != nil {
+		if models.IsNotFoundError(err) {
+			return apierrors.NewOAuthError("invalid_grant", "Passkey challenge not found")
+		}
+		return apierrors.NewInternalServerError("Database error consuming passkey challenge").WithInternalError(err)
+	}
+
+	if challenge.IPAddress != utilities.GetIPAddress(r) {
+		return apierrors.NewOAuthError("invalid_grant", "Passkey challenge and sign in IP addresses mismatch")
+	}
+
+	if challenge.HasExpired(a.Now(), config.External.Passkey.ChallengeExpiryDuration) {
+		return apierrors.NewOAuthError("invalid_grant", "Passkey challenge has expired")
+	}
+
+	var user *models.User
+	var lookupErr error
+
+	credential, err := webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := uuid.FromString(string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+
+		user, lookupErr = models.FindUserByID(db, userID)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+
+		return user, nil
+	}, *challenge.WebAuthnSessionData.SessionData, parsedResponse)
+	if err != nil {
+		if lookupErr != nil && !models.IsNotFoundError(lookupErr) {
+			return apierrors.NewInternalServerError("Database error finding user").WithInternalError(lookupErr)
+		}
+		return apierrors.NewOAuthError("invalid_grant", invalidPasskeyMessage).WithInternalError(err)
+	}
+
+	if user.Aud != a.requestAud(ctx, r) {
+		return apierrors.NewOAuthError("invalid_grant", invalidPasskeyMessage)
+	}
+
+	if user.IsBanned() {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeUserBanned, "User is banned")
+	}
+
+	if credential.Authenticator.CloneWarning {
+		return apierrors.NewOAuthError("invalid_grant", "Passkey signature counter is out of sync, the authenticator may have been cloned")
+	}
+
+	var factor *models.Factor
+	for i := range user.Factors {
+		f := &user.Factors[i]
+		if f.IsVerified() && f.FactorType == models.WebAuthn && f.WebAuthnCredential != nil && bytes.Equal(f.WebAuthnCredential.ID, credential.ID) {
+			factor = f
+			break
+		}
+	}
+
+	if factor == nil {
+		return apierrors.NewOAuthError("invalid_grant", invalidPasskeyMessage)
+	}
+
+	var grantParams models.GrantParams
+	grantParams.FillGrantParams(r)
+	grantParams.FactorID = &factor.ID
+
+	if err := a.triggerBeforeSignIn(r, db, user, models.Passkey); err != nil {
+		return err
+	}
+
+	var token *AccessTokenResponse
+	err = db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+
+		if terr = factor.SaveWebAuthnCredential(tx, credential); terr != nil {
+			return terr
+		}
+
+		if terr = models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.LoginAction, "", map[string]interface{}{
+			"provider":  "passkey",
+			"factor_id": factor.ID,
+		}); terr != nil {
+			return terr
+		}
+
+		token, terr = a.issueRefreshToken(r, tx, user, models.Passkey, grantParams)
+		if terr != nil {
+			return terr
+		}
+
+		return nil
+	})
+	if err != nil {
+		switch err.(type) {
+		case *storage.CommitWithError:
+			return err
+		case *HTTPError:
+			return err
+		default:
+			return apierrors.NewOAuthError("server_error", "Internal Server Error").WithInternalError(err)
+		}
+	}
+
+	metering.RecordLogin(metering.LoginTypePasskey, user.ID, nil)
+
+	return sendJSON(w, http.StatusOK, token)
+}
+
+// PasskeyRegistration starts registering a passkey for the signed in user. It
+// creates an unverified WebAuthn factor which requires the authenticator to
+// store a discoverable credential, so that it can later be used to sign in
+// without a password.
+func (a *API) PasskeyRegistration(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.config
+	user := getUser(ctx)
+	session := getSession(ctx)
+	db := a.db.WithContext(ctx)
+
+	params := &PasskeyRegistrationParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := validateFactors(db, user, params.FriendlyName, config, session); err != nil {
+		return err
+	}
+
+	webAuthn, err := a.passkeyWebAuthn()
+	if err != nil {
+		return apierrors.NewInternalServerError("Failed to configure WebAuthn").WithInternalError(err)
+	}
+
+	var exclusions []wbnprotocol.CredentialDescriptor
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	options, sessionData, err := webAuthn.BeginRegistration(user,
+		webauthn.WithAuthenticatorSelection(wbnprotocol.AuthenticatorSelection{
+			ResidentKey:        wbnprotocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: wbnprotocol.ResidentKeyRequired(),
+			UserVerification:   wbnprotocol.VerificationRequired,
+		}),
+		webauthn.WithExclusions(exclusions),
+	)
+	if err != nil {
+		return apierrors.NewInternalServerError("Failed to generate WebAuthn registration data").WithInternalError(err)
+	}
+
+	factor := models.NewWebAuthnFactor(user, params.FriendlyName)
+	ws := &models.WebAuthnSessionData{
+		SessionData: sessionData,
+	}
+	challenge := ws.ToChallenge(factor.ID, utilities.GetIPAddress(r))
+
+	err = db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Create(factor); terr != nil {
+			return terr
+		}
+		if terr := factor.WriteChallengeToDatabase(tx, challenge); terr != nil {
+			return terr
+		}
+		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.EnrollFactorAction, r.RemoteAddr, map[string]interface{}{
+			"factor_id":   factor.ID,
+			"factor_type": factor.FactorType,
+			"passkey":     true,
+		}); terr != nil {
+			return terr
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, &PasskeyRegistrationResponse{
+		ID:                        factor.ID,
+		ChallengeID:               challenge.ID,
+		CredentialCreationOptions: options,
+		ExpiresAt:                 challenge.GetExpiryTime(config.MFA.ChallengeExpiryDuration).Unix(),
+	})
+}
+
+// PasskeyRegistrationVerify finishes registering a passkey. As creating a
+// passkey proves possession of it, the current session is upgraded as if the
+// user had just signed in with it.
+func (a *API) PasskeyRegistrationVerify(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.config
+	user := getUser(ctx)
+	factor := getFactor(ctx)
+	db := a.db.WithContext(ctx)
+
+	params := &PasskeyRegistrationVerifyParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if factor.FactorType != models.WebAuthn || factor.IsVerified() {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeValidationFailed, "Factor is not a pending passkey registration")
+	}
+
+	if len(params.CreationResponse) == 0 {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "creation_response required to register a passkey")
+	}
+
+	parsedResponse, err := wbnprotocol.ParseCredentialCreationResponseBody(bytes.NewReader(params.CreationResponse))
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Invalid creation_response")
+	}
+
+	webAuthn, err := a.passkeyWebAuthn()
+	if err != nil {
+		return apierrors.NewInternalServerError("Failed to configure WebAuthn").WithInternalError(err)
+	}
+
+	challenge, err := a.validateChallenge(r, db, factor, params.ChallengeID)
+	if err != nil {
+		return err
+	}
+	webAuthnSession := *challenge.WebAuthnSessionData.SessionData
+	// Once the challenge is validated, we consume the challenge
+	if err := db.Destroy(challenge); err != nil {
+		return apierrors.NewInternalServerError("Database error deleting challenge").WithInternalError(err)
+	}
+
+	if err := a.validateWebAuthnAuthenticator(parsedResponse); err != nil {
+		return err
+	}
+
+	credential, err := webAuthn.CreateCredential(user, webAuthnSession, parsedResponse)
+	if err != nil {
+		if aerr := webAuthnAttestationError(err); aerr != nil {
+			return aerr
+		}
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Failed to validate passkey registration").WithInternalError(err)
+	}
+
+	if err := a.validateWebAuthnCredential(credential); err != nil {
+		return err
+	}
+
+	var token *AccessTokenResponse
+	err = db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if terr = models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.VerifyFactorAction, r.RemoteAddr, map[string]interface{}{
+			"factor_id":    factor.ID,
+			"challenge_id": challenge.ID,
+			"factor_type":  factor.FactorType,
+			"passkey":      true,
+		}); terr != nil {
+			return terr
+		}
+		if terr = factor.UpdateStatus(tx, models.FactorStateVerified); terr != nil {
+			return terr
+		}
+		if terr = factor.SaveWebAuthnCredential(tx, credential); terr != nil {
+			return terr
+		}
+		if terr = models.NewEvent(config.Events, tx, models.FactorEnrolledEvent, user, map[string]interface{}{
+			"factor": factor,
+		}); terr != nil {
+			return terr
+		}
+		user, terr = models.FindUserByID(tx, user.ID)
+		if terr != nil {
+			return terr
+		}
+		token, terr = a.updateMFASessionAndClaims(r, tx, user, models.Passkey, models.GrantParams{
+			FactorID: &factor.ID,
+		})
+		if terr != nil {
+			return terr
+		}
+		if terr = models.InvalidateSessionsWithAALLessThan(tx, user.ID, models.AAL2.String()); terr != nil {
+			return apierrors.NewInternalServerError("Failed to update session").WithInternalError(terr)
+		}
+		if terr = models.DeleteUnverifiedFactors(tx, user, models.WebAuthn); terr != nil {
+			return apierrors.NewInternalServerError("Failed to remove unverified MFA WebAuthn factors").WithInternalError(terr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, token)
+}
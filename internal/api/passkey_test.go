@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func (ts *MFATestSuite) TestPasskeyDisabled() {
+	ts.Config.External.Passkey.Enabled = false
+
+	for _, path := range []string{"/passkeys/challenge", "/token?grant_type=webauthn"} {
+		w := serveJSON(ts.T(), ts.API, http.MethodPost, path, "", map[string]interface{}{})
+		require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code, path)
+
+		var result HTTPError
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&result))
+		require.Equal(ts.T(), apierrors.ErrorCodePasskeyDisabled, result.ErrorCode)
+	}
+}
+
+func (ts *MFATestSuite) TestPasskeyChallenge() {
+	w := serveJSON(ts.T(), ts.API, http.MethodPost, "/passkeys/challenge", "", map[string]interface{}{})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var resp PasskeyChallengeResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&resp))
+	require.NotNil(ts.T(), resp.CredentialRequestOptions)
+	require.Equal(ts.T(), "localhost", resp.CredentialRequestOptions.Response.RelyingPartyID)
+	require.Empty(ts.T(), resp.CredentialRequestOptions.Response.AllowedCredentials)
+
+	challenge, err := models.FindPasskeyChallengeByID(ts.API.db, resp.ChallengeID)
+	require.NoError(ts.T(), err)
+	require.Nil(ts.T(), challenge.WebAuthnSessionData.UserID)
+}
+
+func (ts *MFATestSuite) TestPasskeyGrantInvalidChallenge() {
+	cases := []struct {
+		desc   string
+		body   map[string]interface{}
+		status int
+	}{
+		{
+			desc:   "Missing challenge",
+			body:   map[string]interface{}{},
+			status: http.StatusBadRequest,
+		},
+		{
+			desc: "Missing assertion response",
+			body: map[string]interface{}{
+				"challenge_id": uuid.Must(uuid.NewV4()),
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			desc: "Malformed assertion response",
+			body: map[string]interface{}{
+				"challenge_id":       uuid.Must(uuid.NewV4()),
+				"assertion_response": map[string]interface{}{"id": "abc"},
+			},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range cases {
+		ts.Run(c.desc, func() {
+			w := serveJSON(ts.T(), ts.API, http.MethodPost, "/token?grant_type=webauthn", "", c.body)
+			require.Equal(ts.T(), c.status, w.Code)
+		})
+	}
+}
+
+func (ts *MFATestSuite) TestPasskeyRegistration() {
+	token := ts.generateAAL1Token(ts.TestUser, &ts.TestSession.ID)
+
+	w := serveJSON(ts.T(), ts.API, http.MethodPost, "/passkeys/registration", token, map[string]interface{}{
+		"friendly_name": "laptop",
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var resp PasskeyRegistrationResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&resp))
+	require.NotNil(ts.T(), resp.CredentialCreationOptions)
+
+	selection := resp.CredentialCreationOptions.Response.AuthenticatorSelection
+	require.Equal(ts.T(), "required", string(selection.ResidentKey))
+	require.Equal(ts.T(), "required", string(selection.UserVerification))
+
+	factor, err := models.FindFactorByFactorID(ts.API.db, resp.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), models.WebAuthn, factor.FactorType)
+	require.True(ts.T(), factor.IsUnverified())
+
+	// a pending registration can't be finished without a creation response
+	w = serveJSON(ts.T(), ts.API, http.MethodPost, fmt.Sprintf("/passkeys/registration/%s/verify", resp.ID), token, map[string]interface{}{
+		"challenge_id": resp.ChallengeID,
+	})
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	// registering requires a session
+	w = serveJSON(ts.T(), ts.API, http.MethodPost, "/passkeys/registration", "", map[string]interface{}{
+		"friendly_name": "phone",
+	})
+	require.Equal(ts.T(), http.StatusUnauthorized, w.Code)
+}
+
+func (ts *MFATestSuite) TestPasskeyRegistrationVerifyOtherFactor() {
+	factor := models.NewTOTPFactor(ts.TestUser, "totp")
+	require.NoError(ts.T(), ts.API.db.Create(factor))
+	token := ts.generateAAL1Token(ts.TestUser, &ts.TestSession.ID)
+
+	w := serveJSON(ts.T(), ts.API, http.MethodPost, fmt.Sprintf("/passkeys/registration/%s/verify", factor.ID), token, map[string]interface{}{
+		"challenge_id":      uuid.Must(uuid.NewV4()),
+		"creation_response": map[string]interface{}{},
+	})
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+}
@@ -2,10 +2,16 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/linkly-id/auth/internal/utilities"
 	"github.com/sirupsen/logrus"
 )
 
@@ -19,13 +25,95 @@ const MaxPasswordLength = 72
 type WeakPasswordError struct {
 	Message string   `json:"message,omitempty"`
 	Reasons []string `json:"reasons,omitempty"`
+
+	// Feedback is set when the password scored below the minimum
+	// strength score
+	Feedback *PasswordStrengthFeedback `json:"feedback,omitempty"`
 }
 
 func (e *WeakPasswordError) Error() string {
 	return e.Message
 }
 
-func (a *API) checkPasswordStrength(ctx context.Context, password string) error {
+// pwnedPasswordChecker reports whether a password is known to have been
+// exposed in a data breach, either through the HIBP API or a local copy of
+// the Pwned Passwords dataset.
+type pwnedPasswordChecker interface {
+	Check(ctx context.Context, password string) (bool, error)
+}
+
+// loadHIBPDataset opens the local Pwned Passwords dataset, or the Bloom
+// filter built from it, used instead of the HIBP API.
+func loadHIBPDataset(config *conf.HIBPDatasetConfiguration) (pwnedPasswordChecker, error) {
+	switch config.Format {
+	case "bloom":
+		filter, err := utilities.LoadHIBPDatasetFilter(config.Path, config.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		logrus.Infof("Pwned passwords filter is %.2f MB", float64(filter.Cap())/(8*1024.0*1024.0))
+
+		return filter, nil
+
+	default:
+		dataset, err := utilities.OpenHIBPDatasetFile(config.Path, config.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		return dataset, nil
+	}
+}
+
+// HIBPDatasetOption shares the local Pwned Passwords dataset, or the Bloom
+// filter built from it, between the APIs built on configuration reloads so
+// the file is only opened once.
+type HIBPDatasetOption struct {
+	config  conf.HIBPDatasetConfiguration
+	checker pwnedPasswordChecker
+}
+
+func (o *HIBPDatasetOption) apply(a *API) { a.hibpDataset = o }
+
+// NewHIBPDatasetOption loads the Pwned Passwords dataset of the
+// configuration, if any. It fails when the dataset can't be loaded, as
+// passwords wouldn't be checked otherwise.
+func NewHIBPDatasetOption(config *conf.GlobalConfiguration) (*HIBPDatasetOption, error) {
+	o := &HIBPDatasetOption{}
+	if hibpConfig := config.Password.HIBP; hibpConfig.Enabled && hibpConfig.Dataset.Path != "" {
+		checker, err := loadHIBPDataset(&hibpConfig.Dataset)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load pwned passwords dataset from %q: %w", hibpConfig.Dataset.Path, err)
+		}
+		o.config = hibpConfig.Dataset
+		o.checker = checker
+	}
+	return o, nil
+}
+
+// Matches reports whether the option holds the dataset of the configuration,
+// so it can be reused instead of loading the dataset again.
+func (o *HIBPDatasetOption) Matches(config *conf.GlobalConfiguration) bool {
+	var want conf.HIBPDatasetConfiguration
+	if hibpConfig := config.Password.HIBP; hibpConfig.Enabled && hibpConfig.Dataset.Path != "" {
+		want = hibpConfig.Dataset
+	}
+	return o.config == want
+}
+
+// Close closes the dataset file, if it was kept open.
+func (o *HIBPDatasetOption) Close() error {
+	if closer, ok := o.checker.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// checkPasswordStrength checks the password against the configured
+// requirements. The user inputs, like their email address and name, make
+// passwords based on them score lower.
+func (a *API) checkPasswordStrength(ctx context.Context, password string, userInputs ...string) error {
 	config := a.config
 
 	if len(password) > MaxPasswordLength {
@@ -49,24 +137,114 @@ func (a *API) checkPasswordStrength(ctx context.Context, password string) error
 		}
 	}
 
-	if config.Password.HIBP.Enabled {
-		pwned, err := a.hibpClient.Check(ctx, password)
-		if err != nil {
-			if config.Password.HIBP.FailClosed {
-				return apierrors.NewInternalServerError("Unable to perform password strength check with HaveIBeenPwned.org.").WithInternalError(err)
-			} else {
-				logrus.WithError(err).Warn("Unable to perform password strength check with HaveIBeenPwned.org, pwned passwords are being allowed")
-			}
-		} else if pwned {
-			reasons = append(reasons, "pwned")
-			messages = append(messages, "Password is known to be weak and easy to guess, please choose a different one.")
-		}
+	feedback := checkPasswordScore(password, config.Password.MinScore, userInputs)
+	if feedback != nil {
+		reasons = append(reasons, "strength")
+		messages = append(messages, feedback.message())
+	}
+
+	if pwned, err := a.checkPwnedPassword(ctx, password); err != nil {
+		return err
+	} else if pwned {
+		reasons = append(reasons, "pwned")
+		messages = append(messages, pwnedPasswordMessage)
 	}
 
 	if len(reasons) > 0 {
 		return &WeakPasswordError{
-			Message: strings.Join(messages, " "),
-			Reasons: reasons,
+			Message:  strings.Join(messages, " "),
+			Reasons:  reasons,
+			Feedback: feedback,
+		}
+	}
+
+	return nil
+}
+
+const pwnedPasswordMessage = "Password is known to be weak and easy to guess, please choose a different one."
+
+// checkPwnedPassword reports whether the password is known to have been
+// exposed in a data breach, when pwned password checks are enabled.
+func (a *API) checkPwnedPassword(ctx context.Context, password string) (bool, error) {
+	config := a.config
+
+	if !config.Password.HIBP.Enabled {
+		return false, nil
+	}
+
+	var pwned bool
+	var err error
+
+	if a.hibpClient == nil {
+		err = errors.New("pwned passwords dataset is not loaded")
+	} else {
+		pwned, err = a.hibpClient.Check(ctx, password)
+	}
+
+	if err != nil {
+		if config.Password.HIBP.FailClosed {
+			return false, apierrors.NewInternalServerError("Unable to perform password strength check with HaveIBeenPwned.org.").WithInternalError(err)
+		}
+
+		logrus.WithError(err).Warn("Unable to perform password strength check with HaveIBeenPwned.org, pwned passwords are being allowed")
+		return false, nil
+	}
+
+	return pwned, nil
+}
+
+// previousPasswordsKept returns how many previous passwords the history
+// keeps. The current password counts towards the history length.
+func (a *API) previousPasswordsKept() int {
+	return a.config.Password.HistoryLength - 1
+}
+
+// checkPasswordHistory rejects a new password matching the user's current
+// password or any of their previous passwords kept in the history.
+func (a *API) checkPasswordHistory(ctx context.Context, db *storage.Connection, user *models.User, password string) error {
+	config := a.config
+
+	if config.Password.HistoryLength <= 0 || password == "" {
+		return nil
+	}
+
+	if user.HasPassword() {
+		isSamePassword, _, err := user.Authenticate(ctx, db, password, config.Security.DBEncryption.DecryptionKeys, false, "")
+		if err != nil {
+			return err
+		}
+
+		if isSamePassword {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodePasswordReused, "New password should be different from recently used passwords.")
+		}
+	}
+
+	return a.checkPreviousPasswords(ctx, db, user, password)
+}
+
+// checkPreviousPasswords rejects a new password matching any of the user's
+// previous passwords kept in the history, for callers that already compared
+// it with the current password.
+func (a *API) checkPreviousPasswords(ctx context.Context, db *storage.Connection, user *models.User, password string) error {
+	config := a.config
+
+	if config.Password.HistoryLength <= 0 || password == "" {
+		return nil
+	}
+
+	entries, err := models.FindPasswordHistory(db, user.ID, a.previousPasswordsKept())
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding password history").WithInternalError(err)
+	}
+
+	for _, entry := range entries {
+		matches, err := entry.Matches(ctx, password, config.Security.DBEncryption.DecryptionKeys)
+		if err != nil {
+			return apierrors.NewInternalServerError("Error checking password history").WithInternalError(err)
+		}
+
+		if matches {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodePasswordReused, "New password should be different from recently used passwords.")
 		}
 	}
 
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/linkly-id/auth/internal/crypto"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/observability"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// legacyPasswordHashesInterval is how often legacy password hashes are
+// counted, as counting scans the users table.
+const legacyPasswordHashesInterval = 5 * time.Minute
+
+var legacyPasswordHashesOnce sync.Once
+
+// registerLegacyPasswordHashesMetric reports how many users still have a
+// password hash not generated with the configured algorithm and cost. They
+// are rehashed the next time the user signs in with their password.
+func registerLegacyPasswordHashesMetric(db *storage.Connection) {
+	legacyPasswordHashesOnce.Do(func() {
+		var mu sync.Mutex
+		var count int64
+		var countedAt time.Time
+		var pattern string
+
+		_, err := observability.Meter("gotrue").Int64ObservableGauge(
+			"gotrue_legacy_password_hashes",
+			metric.WithDescription("Number of users with a password hash not generated with the configured algorithm and cost"),
+			metric.WithInt64Callback(func(ctx context.Context, obsrv metric.Int64Observer) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if current := crypto.PasswordHashPattern(); current != pattern || time.Since(countedAt) >= legacyPasswordHashesInterval {
+					n, err := models.CountLegacyPasswordHashes(db.WithContext(ctx), current)
+					if err != nil {
+						return err
+					}
+
+					count, countedAt, pattern = int64(n), time.Now(), current
+				}
+
+				obsrv.Observe(count)
+				return nil
+			}),
+		)
+		if err != nil {
+			logrus.WithError(err).Error("unable to get gotrue.gotrue_legacy_password_hashes gauge metric")
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/nbutton23/zxcvbn-go"
+	"github.com/nbutton23/zxcvbn-go/match"
+)
+
+// Patterns reported in PasswordStrengthFeedback, describing what made a
+// password easy to guess.
+const (
+	PasswordPatternCommonPassword = "common_password"
+	PasswordPatternDictionaryWord = "dictionary_word"
+	PasswordPatternName           = "name"
+	PasswordPatternUserInput      = "user_input"
+	PasswordPatternKeyboardWalk   = "keyboard_walk"
+	PasswordPatternRepeat         = "repeat"
+	PasswordPatternSequence       = "sequence"
+	PasswordPatternDate           = "date"
+)
+
+var passwordPatternSuggestions = map[string]string{
+	PasswordPatternCommonPassword: "Avoid commonly used passwords.",
+	PasswordPatternDictionaryWord: "Avoid single words, use a few uncommon words together.",
+	PasswordPatternName:           "Avoid names and surnames.",
+	PasswordPatternUserInput:      "Avoid your email address, phone number or name.",
+	PasswordPatternKeyboardWalk:   "Avoid keyboard patterns like qwerty.",
+	PasswordPatternRepeat:         "Avoid repeated words and characters.",
+	PasswordPatternSequence:       "Avoid sequences like abc or 1234.",
+	PasswordPatternDate:           "Avoid dates and years associated with you.",
+}
+
+// PasswordStrengthFeedback describes why a password scored below the
+// minimum strength score, so that UIs can show hints.
+type PasswordStrengthFeedback struct {
+	Score       int      `json:"score"`
+	MinScore    int      `json:"min_score"`
+	Patterns    []string `json:"patterns,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// passwordUserInputs returns the words a password shouldn't be based on:
+// the user's email address, phone number and name.
+func passwordUserInputs(email, phone string, data map[string]interface{}) []string {
+	var inputs []string
+
+	add := func(value string) {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			return
+		}
+
+		inputs = append(inputs, value)
+
+		for _, word := range strings.FieldsFunc(value, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if word != value {
+				inputs = append(inputs, word)
+			}
+		}
+	}
+
+	add(email)
+	add(phone)
+
+	for _, key := range []string{"name", "full_name", "given_name", "family_name", "nickname", "preferred_username", "user_name"} {
+		if value, ok := data[key].(string); ok {
+			add(value)
+		}
+	}
+
+	return inputs
+}
+
+// passwordPattern maps a zxcvbn match to the pattern reported to users, or
+// an empty string for matches that aren't worth pointing out.
+func passwordPattern(m match.Match) string {
+	switch m.Pattern {
+	case "dictionary":
+		switch strings.TrimSuffix(m.DictionaryName, "_3117") {
+		case "Passwords":
+			return PasswordPatternCommonPassword
+		case "English":
+			return PasswordPatternDictionaryWord
+		case "MaleNames", "FemaleNames", "Surname":
+			return PasswordPatternName
+		case "user_inputs":
+			return PasswordPatternUserInput
+		}
+
+	case "spatial":
+		return PasswordPatternKeyboardWalk
+
+	case "repeat":
+		return PasswordPatternRepeat
+
+	case "sequence":
+		return PasswordPatternSequence
+
+	case "date":
+		return PasswordPatternDate
+	}
+
+	return ""
+}
+
+// checkPasswordScore estimates how easy the password is to guess, zxcvbn
+// style, returning feedback when it scores below minScore.
+func checkPasswordScore(password string, minScore int, userInputs []string) *PasswordStrengthFeedback {
+	if minScore <= 0 {
+		return nil
+	}
+
+	result := zxcvbn.PasswordStrength(password, userInputs)
+	if result.Score >= minScore {
+		return nil
+	}
+
+	feedback := &PasswordStrengthFeedback{
+		Score:    result.Score,
+		MinScore: minScore,
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range result.MatchSequence {
+		pattern := passwordPattern(m)
+		if pattern == "" || seen[pattern] {
+			continue
+		}
+		seen[pattern] = true
+
+		feedback.Patterns = append(feedback.Patterns, pattern)
+		feedback.Suggestions = append(feedback.Suggestions, passwordPatternSuggestions[pattern])
+	}
+
+	feedback.Suggestions = append(feedback.Suggestions, "Use a longer password, a few more uncommon words will do.")
+
+	return feedback
+}
+
+func (f *PasswordStrengthFeedback) message() string {
+	return fmt.Sprintf("Password is too easy to guess, its strength is %d out of 4 and should be at least %d.", f.Score, f.MinScore)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordUserInputs(t *testing.T) {
+	inputs := passwordUserInputs("Jane.Doe@example.com", "15555550100", map[string]interface{}{
+		"full_name": "Jane Doe",
+		"age":       42,
+	})
+
+	require.Equal(t, []string{
+		"jane.doe@example.com",
+		"jane",
+		"doe",
+		"example",
+		"com",
+		"15555550100",
+		"jane doe",
+		"jane",
+		"doe",
+	}, inputs)
+
+	require.Empty(t, passwordUserInputs("", "", nil))
+}
+
+func TestCheckPasswordScore(t *testing.T) {
+	userInputs := passwordUserInputs("jane.doe@example.com", "", map[string]interface{}{
+		"full_name": "Jane Doe",
+	})
+
+	examples := []struct {
+		Password string
+		MinScore int
+		Patterns []string
+	}{
+		{
+			Password: "password",
+			MinScore: 0,
+		},
+		{
+			Password: "password",
+			MinScore: 3,
+			Patterns: []string{PasswordPatternCommonPassword},
+		},
+		{
+			Password: "aaaaaaaaaaaa",
+			MinScore: 3,
+			Patterns: []string{PasswordPatternRepeat},
+		},
+		{
+			Password: "abcdefgh12345",
+			MinScore: 3,
+			Patterns: []string{PasswordPatternSequence},
+		},
+		{
+			Password: "jane.doe@example.com",
+			MinScore: 3,
+			Patterns: []string{PasswordPatternUserInput},
+		},
+		{
+			Password: "correct horse battery staple",
+			MinScore: 4,
+		},
+	}
+
+	for i, example := range examples {
+		feedback := checkPasswordScore(example.Password, example.MinScore, userInputs)
+		if example.Patterns == nil {
+			require.Nil(t, feedback, "Example %d should not have feedback", i)
+			continue
+		}
+
+		require.NotNil(t, feedback, "Example %d should have feedback", i)
+		require.Less(t, feedback.Score, example.MinScore, "Example %d scored too high", i)
+		require.Equal(t, example.MinScore, feedback.MinScore)
+		require.Equal(t, example.Patterns, feedback.Patterns, "Example %d failed with wrong patterns", i)
+		require.Len(t, feedback.Suggestions, len(example.Patterns)+1)
+	}
+}
+
+func TestPasswordStrengthScore(t *testing.T) {
+	api := &API{
+		config: &conf.GlobalConfiguration{
+			Password: conf.PasswordConfiguration{
+				MinLength: 6,
+				MinScore:  3,
+			},
+		},
+	}
+
+	err := api.checkPasswordStrength(context.Background(), "Password1!")
+	weakErr, ok := err.(*WeakPasswordError)
+	require.True(t, ok)
+	require.Equal(t, []string{"strength"}, weakErr.Reasons)
+	require.NotNil(t, weakErr.Feedback)
+	require.Contains(t, weakErr.Feedback.Patterns, PasswordPatternCommonPassword)
+
+	err = api.checkPasswordStrength(context.Background(), "jane.doe.example", passwordUserInputs("jane.doe@example.com", "", nil)...)
+	weakErr, ok = err.(*WeakPasswordError)
+	require.True(t, ok)
+	require.Contains(t, weakErr.Feedback.Patterns, PasswordPatternUserInput)
+
+	require.NoError(t, api.checkPasswordStrength(context.Background(), "correct horse battery staple"))
+}
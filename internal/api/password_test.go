@@ -2,10 +2,16 @@ package api
 
 import (
 	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/linkly-id/auth/internal/api/apierrors"
 	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/utilities"
 	"github.com/stretchr/testify/require"
 )
 
@@ -116,3 +122,97 @@ func TestPasswordStrengthChecks(t *testing.T) {
 		}
 	}
 }
+
+func TestPasswordStrengthPwnedDataset(t *testing.T) {
+	var lines []string
+	for _, password := range []string{"password", "123456", "qwerty"} {
+		hash, err := utilities.HIBPHash(utilities.HIBPHashSHA1, password)
+		require.NoError(t, err)
+
+		lines = append(lines, hash+":1")
+	}
+	slices.Sort(lines)
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+
+	config := &conf.GlobalConfiguration{
+		Password: conf.PasswordConfiguration{
+			HIBP: conf.HIBPConfiguration{
+				Enabled: true,
+				Dataset: conf.HIBPDatasetConfiguration{
+					Path:   path,
+					Format: "text",
+					Hash:   utilities.HIBPHashSHA1,
+				},
+			},
+		},
+	}
+
+	checker, err := loadHIBPDataset(&config.Password.HIBP.Dataset)
+	require.NoError(t, err)
+
+	api := &API{
+		config:     config,
+		hibpClient: checker,
+	}
+
+	err = api.checkPasswordStrength(context.Background(), "qwerty")
+	weakErr, ok := err.(*WeakPasswordError)
+	require.True(t, ok)
+	require.Equal(t, []string{"pwned"}, weakErr.Reasons)
+
+	require.NoError(t, api.checkPasswordStrength(context.Background(), "zZgXb5gzyCNrV36q"))
+
+	// a dataset that failed to load only rejects passwords when failing
+	// closed
+	api.hibpClient = nil
+	require.NoError(t, api.checkPasswordStrength(context.Background(), "qwerty"))
+
+	config.Password.HIBP.FailClosed = true
+	err = api.checkPasswordStrength(context.Background(), "qwerty")
+	httpErr, ok := err.(*HTTPError)
+	require.True(t, ok)
+	require.Equal(t, http.StatusInternalServerError, httpErr.HTTPStatus)
+}
+
+func TestNewHIBPDatasetOption(t *testing.T) {
+	config := &conf.GlobalConfiguration{}
+	opt, err := NewHIBPDatasetOption(config)
+	require.NoError(t, err)
+	require.True(t, opt.Matches(config))
+	require.NoError(t, opt.Close())
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords.txt")
+	require.NoError(t, os.WriteFile(path, []byte("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:1\n"), 0600))
+
+	config.Password.HIBP = conf.HIBPConfiguration{
+		Enabled: true,
+		Dataset: conf.HIBPDatasetConfiguration{
+			Path:   path,
+			Format: "text",
+			Hash:   utilities.HIBPHashSHA1,
+		},
+	}
+	require.False(t, opt.Matches(config))
+
+	opt, err = NewHIBPDatasetOption(config)
+	require.NoError(t, err)
+	require.True(t, opt.Matches(config))
+
+	// a reload keeps the dataset until its configuration changes
+	reloaded := *config
+	reloaded.Password.HIBP.FailClosed = true
+	require.True(t, opt.Matches(&reloaded))
+	reloaded.Password.HIBP.Dataset.Hash = utilities.HIBPHashNTLM
+	require.False(t, opt.Matches(&reloaded))
+
+	pwned, err := opt.checker.Check(context.Background(), "password")
+	require.NoError(t, err)
+	require.True(t, pwned)
+	require.NoError(t, opt.Close())
+
+	config.Password.HIBP.Dataset.Format = "bloom"
+	_, err = NewHIBPDatasetOption(config)
+	require.Error(t, err)
+}
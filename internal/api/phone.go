@@ -88,8 +88,8 @@ func (a *API) sendPhoneConfirmation(r *http.Request, tx *storage.Connection, use
 		// TODO(km): Deprecate this behaviour - rate limits should still be applied to autoconfirm
 		if !config.Sms.Autoconfirm {
 			// apply rate limiting before the sms is sent out
-			if ok := a.limiterOpts.Phone.Allow(); !ok {
-				return "", apierrors.NewTooManyRequestsError(apierrors.ErrorCodeOverSMSSendRateLimit, "SMS rate limit exceeded")
+			if res := a.limiterOpts.Phone.TakeAt(r.Context(), time.Now()); !res.Allowed {
+				return "", rateLimitError(apierrors.ErrorCodeOverSMSSendRateLimit, "SMS rate limit exceeded", res)
 			}
 		}
 		otp = crypto.GenerateOtp(config.Sms.OtpLength)
@@ -45,6 +45,10 @@ func (a *API) Recover(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	if err := a.limitMessagesTo(ctx, params.Email, ""); err != nil {
+		return err
+	}
+
 	var user *models.User
 	var err error
 	aud := a.requestAud(ctx, r)
@@ -75,6 +75,10 @@ func (a *API) Resend(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	if err := a.limitMessagesTo(ctx, params.Email, params.Phone); err != nil {
+		return err
+	}
+
 	var user *models.User
 	var err error
 	aud := a.requestAud(ctx, r)
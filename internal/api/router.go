@@ -62,6 +62,11 @@ func handler(fn apiHandler) http.HandlerFunc {
 }
 
 func (h apiHandler) serve(w http.ResponseWriter, r *http.Request) {
+	// admin API keys are denied by default, whatever the route's middleware
+	if err := checkAdminScopeGranted(r.Context()); err != nil {
+		HandleResponseError(err, w, r)
+		return
+	}
 	if err := h(w, r); err != nil {
 		HandleResponseError(err, w, r)
 	}
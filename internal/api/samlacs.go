@@ -300,6 +300,11 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	if err := a.triggerBeforeSignInExternal(
+		r, db, &userProvidedData, providerType, models.SSOSAML); err != nil {
+		return err
+	}
+
 	if err := db.Transaction(func(tx *storage.Connection) error {
 		var terr error
 		var user *models.User
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/models"
+)
+
+// SessionRestrictionPasswordChange restricts a session to changing the
+// password, as it expired or an administrator requires it to be changed.
+const SessionRestrictionPasswordChange = "password_change"
+
+// SessionRestrictedRole is the role of the access tokens of restricted
+// sessions, so that services not checking the restriction claim don't
+// accept them as the user's.
+const SessionRestrictedRole = "restricted"
+
+// sessionRestriction returns the restriction of the user's session, if any.
+// Enrolling a required MFA factor comes first. Changing the password is only
+// required of sessions signed in with the password.
+func (a *API) sessionRestriction(user *models.User, session *models.Session) (string, error) {
+	restriction, err := a.mfaPolicyRestriction(user)
+	if err != nil || restriction != "" {
+		return restriction, err
+	}
+
+	if !signedInWithPassword(session) {
+		return "", nil
+	}
+
+	if user.MustChangePassword || user.PasswordExpired(a.config.Password.MaxAge, a.Now()) {
+		return SessionRestrictionPasswordChange, nil
+	}
+
+	return "", nil
+}
+
+func signedInWithPassword(session *models.Session) bool {
+	for _, claim := range session.AMRClaims {
+		if claim.GetAuthenticationMethod() == models.PasswordGrant.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionRestrictionError returns the error for using a restricted session
+// for anything but resolving its restriction.
+func sessionRestrictionError(restriction string) error {
+	switch restriction {
+	case SessionRestrictionMFAEnrollment:
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeMFAEnrollmentRequired, "MFA enrollment is required to continue")
+	case SessionRestrictionPasswordChange:
+		return apierrors.NewForbiddenError(apierrors.ErrorCodePasswordChangeRequired, "Password change is required to continue")
+	}
+	return nil
+}
+
+// requireUnrestrictedSession rejects restricted sessions.
+func (a *API) requireUnrestrictedSession(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	if claims := getClaims(ctx); claims != nil {
+		if err := sessionRestrictionError(claims.Restriction); err != nil {
+			return nil, err
+		}
+	}
+	return ctx, nil
+}
+
+// allowSessionRestriction rejects restricted sessions, except for the
+// provided restriction.
+func (a *API) allowSessionRestriction(restriction string) middlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+		ctx := r.Context()
+		if claims := getClaims(ctx); claims != nil && claims.Restriction != restriction {
+			if err := sessionRestrictionError(claims.Restriction); err != nil {
+				return nil, err
+			}
+		}
+		return ctx, nil
+	}
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func (ts *TokenTestSuite) TestMustChangePassword() {
+	ts.Config.Security.UpdatePasswordRequireReauthentication = false
+
+	// an administrator sets a temporary password
+	w := serveJSON(ts.T(), ts.API, http.MethodPut, fmt.Sprintf("/admin/users/%s", ts.User.ID), generateAdminToken(ts.T(), ts.Config), map[string]interface{}{
+		"password":             "temporary",
+		"must_change_password": true,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	resp, claims := signInWithPassword(ts.T(), ts.API, map[string]interface{}{
+		"email":    ts.User.GetEmail(),
+		"password": "temporary",
+	})
+	require.Equal(ts.T(), SessionRestrictionPasswordChange, claims.Restriction)
+	require.Equal(ts.T(), SessionRestrictedRole, claims.Role)
+	require.True(ts.T(), resp.User.MustChangePassword)
+
+	// the session can't be used for anything but changing the password
+	w = serveJSON(ts.T(), ts.API, http.MethodPut, "/user", resp.Token, map[string]interface{}{
+		"data": map[string]interface{}{"name": "rotate"},
+	})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+	var data HTTPError
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), apierrors.ErrorCodePasswordChangeRequired, data.ErrorCode)
+
+	w = serveJSON(ts.T(), ts.API, http.MethodPut, "/user", resp.Token, map[string]interface{}{
+		"password": "permanent",
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	user, err := models.FindUserByID(ts.API.db, ts.User.ID)
+	require.NoError(ts.T(), err)
+	require.False(ts.T(), user.MustChangePassword)
+	require.NotNil(ts.T(), user.PasswordChangedAt)
+
+	// refreshing the session lifts the restriction
+	w = serveJSON(ts.T(), ts.API, http.MethodPost, "/token?grant_type=refresh_token", "", map[string]interface{}{
+		"refresh_token": resp.RefreshToken,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	var refreshed AccessTokenResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&refreshed))
+
+	claims = parseAccessToken(ts.T(), ts.API, refreshed.Token)
+	require.Empty(ts.T(), claims.Restriction)
+	require.Equal(ts.T(), "authenticated", claims.Role)
+}
+
+func (ts *TokenTestSuite) TestPasswordMaxAge() {
+	ts.Config.Security.UpdatePasswordRequireReauthentication = false
+	ts.Config.Password.MaxAge = 90 * 24 * time.Hour
+	defer func() {
+		ts.Config.Password.MaxAge = 0
+	}()
+
+	signIn := func(password string) (*AccessTokenResponse, *AccessTokenClaims) {
+		return signInWithPassword(ts.T(), ts.API, map[string]interface{}{
+			"email":    ts.User.GetEmail(),
+			"password": password,
+		})
+	}
+
+	_, claims := signIn("password")
+	require.Empty(ts.T(), claims.Restriction)
+
+	changedAt := time.Now().Add(-91 * 24 * time.Hour)
+	ts.User.PasswordChangedAt = &changedAt
+	require.NoError(ts.T(), ts.API.db.UpdateOnly(ts.User, "password_changed_at"))
+
+	resp, claims := signIn("password")
+	require.Equal(ts.T(), SessionRestrictionPasswordChange, claims.Restriction)
+
+	w := serveJSON(ts.T(), ts.API, http.MethodGet, "/user/identities/authorize?provider=github", resp.Token, nil)
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+
+	// the password must actually change
+	w = serveJSON(ts.T(), ts.API, http.MethodPut, "/user", resp.Token, map[string]interface{}{
+		"password": "password",
+	})
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	w = serveJSON(ts.T(), ts.API, http.MethodPut, "/user", resp.Token, map[string]interface{}{
+		"password": "rotated-password",
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	_, claims = signIn("rotated-password")
+	require.Empty(ts.T(), claims.Restriction)
+}
+
+func (ts *TokenTestSuite) TestPasswordChangeOnlyRestrictsPasswordSessions() {
+	ts.User.MustChangePassword = true
+	require.NoError(ts.T(), ts.API.db.UpdateOnly(ts.User, "must_change_password"))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/verify", nil)
+	token, err := ts.API.issueRefreshToken(req, ts.API.db, ts.User, models.MagicLink, models.GrantParams{})
+	require.NoError(ts.T(), err)
+	require.Empty(ts.T(), parseAccessToken(ts.T(), ts.API, token.Token).Restriction)
+}
+
+func (ts *TokenTestSuite) TestCustomAccessTokenKeepsRestriction() {
+	hookFunctionSQL := `
+        create or replace function custom_access_token_lift_restriction(input jsonb)
+        returns jsonb as $$
+        begin
+            return jsonb_build_object('claims', (input->'claims') - 'restriction' || '{"role": "authenticated"}'::jsonb);
+        end; $$ language plpgsql;`
+	require.NoError(ts.T(), ts.API.db.RawQuery(hookFunctionSQL).Exec())
+
+	ts.Config.Hook.CustomAccessToken.Enabled = true
+	ts.Config.Hook.CustomAccessToken.URI = "pg-functions://postgres/auth/custom_access_token_lift_restriction"
+	require.NoError(ts.T(), ts.Config.Hook.CustomAccessToken.PopulateExtensibilityPoint())
+	defer func() {
+		require.NoError(ts.T(), ts.API.db.RawQuery("drop function if exists custom_access_token_lift_restriction").Exec())
+		ts.Config.Hook.CustomAccessToken.Enabled = false
+	}()
+
+	ts.User.MustChangePassword = true
+	require.NoError(ts.T(), ts.API.db.UpdateOnly(ts.User, "must_change_password"))
+
+	_, claims := signInWithPassword(ts.T(), ts.API, map[string]interface{}{
+		"email":    ts.User.GetEmail(),
+		"password": "password",
+	})
+	require.Equal(ts.T(), SessionRestrictionPasswordChange, claims.Restriction)
+	require.Equal(ts.T(), SessionRestrictedRole, claims.Role)
+}
@@ -20,6 +20,7 @@ type ProviderSettings struct {
 	Linkedin       bool `json:"linkedin"`
 	LinkedinOIDC   bool `json:"linkedin_oidc"`
 	Notion         bool `json:"notion"`
+	Passkey        bool `json:"passkey"`
 	Spotify        bool `json:"spotify"`
 	Slack          bool `json:"slack"`
 	SlackOIDC      bool `json:"slack_oidc"`
@@ -32,12 +33,20 @@ type ProviderSettings struct {
 }
 
 type Settings struct {
-	ExternalProviders ProviderSettings `json:"external"`
-	DisableSignup     bool             `json:"disable_signup"`
-	MailerAutoconfirm bool             `json:"mailer_autoconfirm"`
-	PhoneAutoconfirm  bool             `json:"phone_autoconfirm"`
-	SmsProvider       string           `json:"sms_provider"`
-	SAMLEnabled       bool             `json:"saml_enabled"`
+	ExternalProviders ProviderSettings  `json:"external"`
+	DisableSignup     bool              `json:"disable_signup"`
+	MailerAutoconfirm bool              `json:"mailer_autoconfirm"`
+	PhoneAutoconfirm  bool              `json:"phone_autoconfirm"`
+	SmsProvider       string            `json:"sms_provider"`
+	SAMLEnabled       bool              `json:"saml_enabled"`
+	MFAPolicy         MFAPolicySettings `json:"mfa_policy"`
+}
+
+// MFAPolicySettings tells clients whether users may be required to enroll
+// an MFA factor, in which case the user object has a deadline to do so.
+type MFAPolicySettings struct {
+	Enabled     bool  `json:"enabled"`
+	GracePeriod int64 `json:"grace_period"`
 }
 
 func (a *API) Settings(w http.ResponseWriter, r *http.Request) error {
@@ -62,6 +71,7 @@ func (a *API) Settings(w http.ResponseWriter, r *http.Request) error {
 			Linkedin:       config.External.Linkedin.Enabled,
 			LinkedinOIDC:   config.External.LinkedinOIDC.Enabled,
 			Notion:         config.External.Notion.Enabled,
+			Passkey:        config.External.Passkey.Enabled,
 			Spotify:        config.External.Spotify.Enabled,
 			Slack:          config.External.Slack.Enabled,
 			SlackOIDC:      config.External.SlackOIDC.Enabled,
@@ -77,5 +87,9 @@ func (a *API) Settings(w http.ResponseWriter, r *http.Request) error {
 		PhoneAutoconfirm:  config.Sms.Autoconfirm,
 		SmsProvider:       config.Sms.Provider,
 		SAMLEnabled:       config.SAML.Enabled,
+		MFAPolicy: MFAPolicySettings{
+			Enabled:     config.MFA.Policy.Enabled,
+			GracePeriod: int64(config.MFA.Policy.GracePeriod.Seconds()),
+		},
 	})
 }
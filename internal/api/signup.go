@@ -36,7 +36,7 @@ func (a *API) validateSignupParams(ctx context.Context, p *SignupParams) error {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Signup requires a valid password")
 	}
 
-	if err := a.checkPasswordStrength(ctx, p.Password); err != nil {
+	if err := a.checkPasswordStrength(ctx, p.Password, passwordUserInputs(p.Email, p.Phone, p.Data)...); err != nil {
 		return err
 	}
 	if p.Email != "" && p.Phone != "" {
@@ -304,6 +304,10 @@ func (a *API) Signup(w http.ResponseWriter, r *http.Request) error {
 
 	// handles case where Mailer.Autoconfirm is true or Phone.Autoconfirm is true
 	if user.IsConfirmed() || user.IsPhoneConfirmed() {
+		if err := a.triggerBeforeSignIn(r, db, user, models.PasswordGrant); err != nil {
+			return err
+		}
+
 		var token *AccessTokenResponse
 		err = db.Transaction(func(tx *storage.Connection) error {
 			var terr error
@@ -383,6 +387,9 @@ func (a *API) signupNewUser(conn *storage.Connection, user *models.User) (*model
 		if terr = user.SetRole(tx, config.JWT.DefaultGroupName); terr != nil {
 			return apierrors.NewInternalServerError("Database error updating user").WithInternalError(terr)
 		}
+		if terr = models.NewEvent(config.Events, tx, models.UserCreatedEvent, user, nil); terr != nil {
+			return apierrors.NewInternalServerError("Database error recording event").WithInternalError(terr)
+		}
 		return nil
 	})
 	if err != nil {
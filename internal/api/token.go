@@ -32,6 +32,12 @@ type AccessTokenClaims struct {
 	AuthenticationMethodReference []models.AMREntry      `json:"amr,omitempty"`
 	SessionId                     string                 `json:"session_id,omitempty"`
 	IsAnonymous                   bool                   `json:"is_anonymous"`
+	// Restriction is set on sessions that may only be used to resolve it,
+	// such as by enrolling an MFA factor
+	Restriction string `json:"restriction,omitempty"`
+	// Actor is set on impersonation sessions to the administrator acting
+	// as the user
+	Actor *v0hooks.ActorClaims `json:"act,omitempty"`
 	// TODO(cemalkilic) : client_id claim will be added later
 	// ClientId                      string                 `json:"client_id,omitempty"`
 }
@@ -47,6 +53,10 @@ type AccessTokenResponse struct {
 	ProviderAccessToken  string             `json:"provider_token,omitempty"`
 	ProviderRefreshToken string             `json:"provider_refresh_token,omitempty"`
 	WeakPassword         *WeakPasswordError `json:"weak_password,omitempty"`
+	TrustedDeviceToken   string             `json:"trusted_device_token,omitempty"`
+
+	// RecoveryCodesRemaining is only set after verifying a recovery code
+	RecoveryCodesRemaining *int `json:"recovery_codes_remaining,omitempty"`
 }
 
 // AsRedirectURL encodes the AccessTokenResponse as a redirect URL that
@@ -63,15 +73,17 @@ func (r *AccessTokenResponse) AsRedirectURL(redirectURL string, extraParams url.
 
 // PasswordGrantParams are the parameters the ResourceOwnerPasswordGrant method accepts
 type PasswordGrantParams struct {
-	Email    string `json:"email"`
-	Phone    string `json:"phone"`
-	Password string `json:"password"`
+	Email              string `json:"email"`
+	Phone              string `json:"phone"`
+	Password           string `json:"password"`
+	TrustedDeviceToken string `json:"trusted_device_token"`
 }
 
 // PKCEGrantParams are the parameters the PKCEGrant method accepts
 type PKCEGrantParams struct {
-	AuthCode     string `json:"auth_code"`
-	CodeVerifier string `json:"code_verifier"`
+	AuthCode           string `json:"auth_code"`
+	CodeVerifier       string `json:"code_verifier"`
+	TrustedDeviceToken string `json:"trusted_device_token"`
 }
 
 const useCookieHeader = "x-use-cookie"
@@ -97,11 +109,14 @@ func (a *API) Token(w http.ResponseWriter, r *http.Request) error {
 	case "web3":
 		handler = a.Web3Grant
 		limiter = a.limiterOpts.Web3
+	case "webauthn":
+		handler = a.PasskeyGrant
+		limiter = a.limiterOpts.Passkey
 	default:
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeInvalidCredentials, "unsupported_grant_type")
 	}
 
-	if err := a.performRateLimiting(limiter, r); err != nil {
+	if err := a.performRateLimiting(w, limiter, r); err != nil {
 		return err
 	}
 
@@ -126,15 +141,18 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 	var user *models.User
 	var grantParams models.GrantParams
 	var provider string
+	var identifierKey string
 	var err error
 
 	grantParams.FillGrantParams(r)
+	grantParams.TrustedDeviceToken = params.TrustedDeviceToken
 
 	if params.Email != "" {
 		provider = "email"
 		if !config.External.Email.Enabled {
 			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeEmailProviderDisabled, "Email logins are disabled")
 		}
+		identifierKey = models.IdentifierLockoutKey(provider, params.Email)
 		user, err = models.FindUserByEmailAndAudience(db, params.Email, aud)
 	} else if params.Phone != "" {
 		provider = "phone"
@@ -142,19 +160,39 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodePhoneProviderDisabled, "Phone logins are disabled")
 		}
 		params.Phone = formatPhoneNumber(params.Phone)
+		identifierKey = models.IdentifierLockoutKey(provider, params.Phone)
 		user, err = models.FindUserByPhoneAndAudience(db, params.Phone, aud)
 	} else {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "missing email or phone")
 	}
 
+	if err := a.limitIdentifier(ctx, a.limiterOpts.PasswordPerIdentifier, identifierKey, apierrors.ErrorCodeOverRequestRateLimit, "Request rate limit reached"); err != nil {
+		return err
+	}
+
 	if err != nil {
 		if models.IsNotFoundError(err) {
+			// unknown identifiers are locked out just like known ones
+			// so that lockouts can't be used to enumerate users
+			if err := a.checkLoginLockout(db, nil, identifierKey); err != nil {
+				return err
+			}
+			if err := a.recordFailedLoginAttempt(r, db, nil, identifierKey); err != nil {
+				return err
+			}
 			return apierrors.NewBadRequestError(apierrors.ErrorCodeInvalidCredentials, InvalidLoginMessage)
 		}
 		return apierrors.NewInternalServerError("Database error querying schema").WithInternalError(err)
 	}
 
+	if err := a.checkLoginLockout(db, user, identifierKey); err != nil {
+		return err
+	}
+
 	if !user.HasPassword() {
+		if err := a.recordFailedLoginAttempt(r, db, user, identifierKey); err != nil {
+			return err
+		}
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeInvalidCredentials, InvalidLoginMessage)
 	}
 
@@ -162,14 +200,20 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeUserBanned, "User is banned")
 	}
 
-	isValidPassword, shouldReEncrypt, err := user.Authenticate(ctx, db, params.Password, config.Security.DBEncryption.DecryptionKeys, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID)
+	isValidPassword, shouldUpdatePassword, err := user.Authenticate(ctx, db, params.Password, config.Security.DBEncryption.DecryptionKeys, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID)
 	if err != nil {
 		return err
 	}
 
+	if !isValidPassword {
+		if err := a.recordFailedLoginAttempt(r, db, user, identifierKey); err != nil {
+			return err
+		}
+	}
+
 	var weakPasswordError *WeakPasswordError
 	if isValidPassword {
-		if err := a.checkPasswordStrength(ctx, params.Password); err != nil {
+		if err := a.checkPasswordStrength(ctx, params.Password, passwordUserInputs(user.GetEmail(), user.GetPhone(), user.UserMetaData)...); err != nil {
 			if wpe, ok := err.(*WeakPasswordError); ok {
 				weakPasswordError = wpe
 			} else {
@@ -177,15 +221,17 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 			}
 		}
 
-		if shouldReEncrypt {
-			if err := user.SetPassword(ctx, params.Password, true, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey); err != nil {
-				return err
+		if shouldUpdatePassword {
+			if config.Security.DBEncryption.Encrypt {
+				if err := user.EncryptPassword(config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey); err != nil {
+					return err
+				}
 			}
 
 			// directly change this in the database without
 			// calling user.UpdatePassword() because this
-			// is not a password change, just encryption
-			// change in the database
+			// is not a password change, just a rehash or
+			// encryption change in the database
 			if err := db.UpdateOnly(user, "encrypted_password"); err != nil {
 				return err
 			}
@@ -224,6 +270,10 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 		return apierrors.NewBadRequestError(apierrors.ErrorCodePhoneNotConfirmed, "Phone not confirmed")
 	}
 
+	if err := a.triggerBeforeSignIn(r, db, user, models.PasswordGrant); err != nil {
+		return err
+	}
+
 	var token *AccessTokenResponse
 	err = db.Transaction(func(tx *storage.Connection) error {
 		var terr error
@@ -237,6 +287,10 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 			return terr
 		}
 
+		if terr = a.clearLoginLockouts(tx, user, identifierKey); terr != nil {
+			return terr
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -266,6 +320,7 @@ func (a *API) PKCE(ctx context.Context, w http.ResponseWriter, r *http.Request)
 	if err := retrieveRequestParams(r, params); err != nil {
 		return err
 	}
+	grantParams.TrustedDeviceToken = params.TrustedDeviceToken
 
 	if params.AuthCode == "" || params.CodeVerifier == "" {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "invalid request: both auth code and code verifier should be non-empty")
@@ -290,13 +345,17 @@ func (a *API) PKCE(ctx context.Context, w http.ResponseWriter, r *http.Request)
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeBadCodeVerifier, err.Error())
 	}
 
+	authMethod, err := models.ParseAuthenticationMethod(flowState.AuthenticationMethod)
+	if err != nil {
+		return err
+	}
+	if err := a.triggerBeforeSignIn(r, db, user, authMethod); err != nil {
+		return err
+	}
+
 	var token *AccessTokenResponse
 	err = db.Transaction(func(tx *storage.Connection) error {
 		var terr error
-		authMethod, err := models.ParseAuthenticationMethod(flowState.AuthenticationMethod)
-		if err != nil {
-			return err
-		}
 		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.LoginAction, "", map[string]interface{}{
 			"provider_type": flowState.ProviderType,
 		}); terr != nil {
@@ -342,6 +401,10 @@ func (a *API) generateAccessToken(r *http.Request, tx *storage.Connection, user
 	if terr != nil {
 		return "", 0, terr
 	}
+	restriction, terr := a.sessionRestriction(user, session)
+	if terr != nil {
+		return "", 0, terr
+	}
 
 	issuedAt := time.Now().UTC()
 	expiresAt := issuedAt.Add(time.Second * time.Duration(config.JWT.Exp))
@@ -363,6 +426,15 @@ func (a *API) generateAccessToken(r *http.Request, tx *storage.Connection, user
 		AuthenticatorAssuranceLevel:   aal.String(),
 		AuthenticationMethodReference: amr,
 		IsAnonymous:                   user.IsAnonymous,
+		Restriction:                   restriction,
+	}
+
+	if restriction != "" {
+		claims.Role = SessionRestrictedRole
+	}
+
+	if session.IsImpersonation() {
+		claims.Actor = &v0hooks.ActorClaims{Subject: *session.Impersonator}
 	}
 
 	var gotrueClaims jwt.Claims = claims
@@ -382,6 +454,15 @@ func (a *API) generateAccessToken(r *http.Request, tx *storage.Connection, user
 		if err := validateTokenClaims(output.Claims); err != nil {
 			return "", 0, err
 		}
+		if claims.Actor != nil {
+			// the hook can't hide that the session is an impersonation
+			output.Claims["act"] = claims.Actor
+		}
+		if claims.Restriction != "" {
+			// nor lift the restriction of the session
+			output.Claims["restriction"] = claims.Restriction
+			output.Claims["role"] = claims.Role
+		}
 		gotrueClaims = jwt.MapClaims(output.Claims)
 	}
 
@@ -404,7 +485,6 @@ func (a *API) issueRefreshToken(r *http.Request, conn *storage.Connection, user
 
 	err := conn.Transaction(func(tx *storage.Connection) error {
 		var terr error
-
 		refreshToken, terr = models.GrantAuthenticatedUser(tx, user, grantParams)
 		if terr != nil {
 			return apierrors.NewInternalServerError("Database error granting user").WithInternalError(terr)
@@ -415,6 +495,34 @@ func (a *API) issueRefreshToken(r *http.Request, conn *storage.Connection, user
 			return terr
 		}
 
+		if terr := models.NewEvent(config.Events, tx, models.UserSignedInEvent, user, map[string]interface{}{
+			"authentication_method": authenticationMethod.String(),
+			"session_id":            refreshToken.SessionId,
+		}); terr != nil {
+			return apierrors.NewInternalServerError("Database error recording event").WithInternalError(terr)
+		}
+
+		if authenticationMethod == models.Passkey {
+			// passkeys are phishing-resistant and verify the user, so
+			// signing in with one satisfies AAL2 on its own
+			session, terr := models.FindSessionByID(tx, *refreshToken.SessionId, false)
+			if terr != nil {
+				return terr
+			}
+
+			if terr := session.UpdateAALAndAssociatedFactor(tx, models.AAL2, grantParams.FactorID); terr != nil {
+				return terr
+			}
+		}
+
+		if terr := a.applyTrustedDevice(tx, user, *refreshToken.SessionId, grantParams.TrustedDeviceToken); terr != nil {
+			return terr
+		}
+
+		if terr := a.startMFAPolicy(tx, user); terr != nil {
+			return terr
+		}
+
 		tokenString, expiresAt, terr = a.generateAccessToken(r, tx, user, refreshToken.SessionId, authenticationMethod)
 		if terr != nil {
 			// Account for Hook Error
@@ -481,6 +589,10 @@ func (a *API) updateMFASessionAndClaims(r *http.Request, tx *storage.Connection,
 			return err
 		}
 
+		if err := a.startMFAPolicy(tx, user); err != nil {
+			return err
+		}
+
 		tokenString, expiresAt, terr = a.generateAccessToken(r, tx, user, &session.ID, authenticationMethod)
 		if terr != nil {
 			httpErr, ok := terr.(*HTTPError)
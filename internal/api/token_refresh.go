@@ -41,6 +41,7 @@ func (a *API) RefreshTokenGrant(ctx context.Context, w http.ResponseWriter, r *h
 	// milliseconds.
 	retryStart := a.Now()
 	retry := true
+	refreshHookRan := false
 
 	for retry && time.Since(retryStart).Seconds() < retryLoopDuration {
 		retry = false
@@ -87,6 +88,14 @@ func (a *API) RefreshTokenGrant(ctx context.Context, w http.ResponseWriter, r *h
 			return apierrors.NewBadRequestError(apierrors.ErrorCodeSessionExpired, "Invalid Refresh Token: Session Expired")
 		}
 
+		if !refreshHookRan {
+			if err := a.triggerBeforeTokenRefresh(r, db, user, session); err != nil {
+				return err
+			}
+			// the hook isn't run again when retrying a locked session
+			refreshHookRan = true
+		}
+
 		// Basic checks above passed, now we need to serialize access
 		// to the session in a transaction so that there's no
 		// concurrent modification. In the event that the refresh
@@ -234,10 +234,16 @@ func (ts *TokenTestSuite) TestRateLimitTokenRefresh() {
 		w := httptest.NewRecorder()
 		ts.API.handler.ServeHTTP(w, req)
 		assert.Equal(ts.T(), http.StatusBadRequest, w.Code)
+		assert.Equal(ts.T(), "30", w.Header().Get("RateLimit-Limit"))
+		if i == 0 {
+			assert.Equal(ts.T(), "29", w.Header().Get("RateLimit-Remaining"))
+		}
 	}
 	w := httptest.NewRecorder()
 	ts.API.handler.ServeHTTP(w, req)
 	assert.Equal(ts.T(), http.StatusTooManyRequests, w.Code)
+	assert.Equal(ts.T(), "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(ts.T(), "2", w.Header().Get("Retry-After"))
 
 	// It ignores X-Forwarded-For by default
 	req.Header.Set("X-Forwarded-For", "1.1.1.1")
@@ -300,6 +306,35 @@ func (ts *TokenTestSuite) TestTokenPasswordGrantSuccess() {
 	assert.Equal(ts.T(), http.StatusOK, w.Code)
 }
 
+func (ts *TokenTestSuite) TestTokenPasswordGrantPerIdentifierRateLimit() {
+	ts.API.limiterOpts.PasswordPerIdentifier = newIdentifierLimiter(conf.Rate{Events: 2, OverTime: time.Hour})
+	defer func() {
+		ts.API.limiterOpts.PasswordPerIdentifier = nil
+	}()
+
+	// the limit applies to the email address whichever IP signs in
+	for i, expected := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"email":    "test@example.com",
+			"password": "password",
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=password", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("My-Custom-Header", fmt.Sprintf("1.2.3.%d", i))
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), expected, w.Code, w.Body.String())
+
+		if expected == http.StatusTooManyRequests {
+			require.Equal(ts.T(), apierrors.ErrorCodeOverRequestRateLimit, w.Header().Get("x-sb-error-code"))
+			require.Equal(ts.T(), "1800", w.Header().Get("Retry-After"))
+		}
+	}
+}
+
 func (ts *TokenTestSuite) TestTokenRefreshTokenGrantSuccess() {
 	var buffer bytes.Buffer
 	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
@@ -684,6 +719,73 @@ func (ts *TokenTestSuite) TestPasswordVerificationHook() {
 
 }
 
+func (ts *TokenTestSuite) TestPasswordGrantLockout() {
+	ts.Config.Security.Lockout = conf.LockoutConfiguration{
+		Enabled:                  true,
+		MaxAttemptsPerUser:       3,
+		MaxAttemptsPerIdentifier: 5,
+		AttemptWindow:            15 * time.Minute,
+		Duration:                 5 * time.Minute,
+		MaxDuration:              time.Hour,
+		Cooldown:                 24 * time.Hour,
+	}
+	defer func() {
+		ts.Config.Security.Lockout.Enabled = false
+		ts.API.overrideTime = nil
+	}()
+
+	signIn := func(email, password string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"email":    email,
+			"password": password,
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=password", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	requireErrorCode := func(w *httptest.ResponseRecorder, status int, errorCode string) {
+		require.Equal(ts.T(), status, w.Code)
+
+		var data HTTPError
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+		require.Equal(ts.T(), errorCode, data.ErrorCode)
+	}
+
+	for i := 0; i < 3; i++ {
+		requireErrorCode(signIn("test@example.com", "wrong-password"), http.StatusBadRequest, apierrors.ErrorCodeInvalidCredentials)
+	}
+
+	// the correct password is refused while locked out
+	requireErrorCode(signIn("test@example.com", "password"), http.StatusTooManyRequests, apierrors.ErrorCodeUserLocked)
+
+	lockout, err := models.FindLoginLockout(ts.API.db, models.UserLockoutKey(ts.User.ID))
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), lockout.IsLocked(time.Now()))
+	require.Equal(ts.T(), 1, lockout.LockoutCount)
+
+	// the lockout is lifted automatically once it expires
+	ts.API.overrideTime = func() time.Time {
+		return time.Now().Add(5 * time.Minute).Add(time.Second)
+	}
+	require.Equal(ts.T(), http.StatusOK, signIn("test@example.com", "password").Code)
+
+	_, err = models.FindLoginLockout(ts.API.db, models.UserLockoutKey(ts.User.ID))
+	require.True(ts.T(), models.IsNotFoundError(err))
+
+	// unknown identifiers are locked out too
+	ts.API.overrideTime = nil
+	for i := 0; i < 5; i++ {
+		requireErrorCode(signIn("unknown@example.com", "password"), http.StatusBadRequest, apierrors.ErrorCodeInvalidCredentials)
+	}
+	requireErrorCode(signIn("unknown@example.com", "password"), http.StatusTooManyRequests, apierrors.ErrorCodeUserLocked)
+}
+
 func (ts *TokenTestSuite) TestCustomAccessToken() {
 	type customAccessTokenTestcase struct {
 		desc            string
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/storage"
+)
+
+// maxTrustedDeviceLabelLength is the maximum length of a trusted device
+// label, which defaults to the User-Agent of the verifying request.
+const maxTrustedDeviceLabelLength = 255
+
+// issueTrustedDevice trusts the device verifying the factor when the client
+// asked for it, adding the device token to the token response.
+func (a *API) issueTrustedDevice(r *http.Request, tx *storage.Connection, user *models.User, factor *models.Factor, params *VerifyFactorParams, token *AccessTokenResponse) error {
+	config := a.config
+
+	if !params.TrustDevice {
+		return nil
+	}
+
+	label := params.DeviceLabel
+	if label == "" {
+		label = r.Header.Get("User-Agent")
+	}
+	if len(label) > maxTrustedDeviceLabelLength {
+		label = label[:maxTrustedDeviceLabelLength]
+	}
+
+	device, deviceToken := models.NewTrustedDevice(user, factor, label, config.MFA.TrustedDevices.ExpiryDuration)
+	if err := tx.Create(device); err != nil {
+		return apierrors.NewInternalServerError("Database error creating trusted device").WithInternalError(err)
+	}
+
+	if err := models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.TrustDeviceAction, r.RemoteAddr, map[string]interface{}{
+		"factor_id":         factor.ID,
+		"trusted_device_id": device.ID,
+	}); err != nil {
+		return err
+	}
+
+	token.TrustedDeviceToken = deviceToken
+	return nil
+}
+
+// applyTrustedDevice upgrades the session to AAL2 when the sign-in presented
+// the token of a device the user trusts. Unknown or expired tokens are
+// ignored, leaving the session at AAL1 so the client falls back to MFA.
+func (a *API) applyTrustedDevice(tx *storage.Connection, user *models.User, sessionID uuid.UUID, deviceToken string) error {
+	if !a.config.MFA.TrustedDevices.Enabled || deviceToken == "" {
+		return nil
+	}
+
+	device, err := models.FindTrustedDeviceByToken(tx, user.ID, deviceToken)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil
+		}
+		return apierrors.NewInternalServerError("Database error finding trusted device").WithInternalError(err)
+	}
+
+	if err := device.Touch(tx); err != nil {
+		return apierrors.NewInternalServerError("Database error updating trusted device").WithInternalError(err)
+	}
+
+	if err := models.AddClaimToSession(tx, sessionID, models.MFATrustedDevice); err != nil {
+		return err
+	}
+
+	session, err := models.FindSessionByID(tx, sessionID, false)
+	if err != nil {
+		return err
+	}
+
+	return session.UpdateAALAndAssociatedFactor(tx, models.AAL2, &device.FactorID)
+}
+
+// TrustedDevicesList lists the devices the user currently trusts
+func (a *API) TrustedDevicesList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	user := getUser(ctx)
+	db := a.db.WithContext(ctx)
+
+	devices, err := models.FindTrustedDevicesByUser(db, user.ID)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding trusted devices").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, devices)
+}
+
+// TrustedDeviceRevoke stops trusting a device, so signing in on it requires
+// MFA again
+func (a *API) TrustedDeviceRevoke(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.config
+	user := getUser(ctx)
+	db := a.db.WithContext(ctx)
+
+	deviceID, err := uuid.FromString(chi.URLParam(r, "device_id"))
+	if err != nil {
+		return apierrors.NewNotFoundError(apierrors.ErrorCodeValidationFailed, "device_id must be an UUID")
+	}
+
+	device, err := models.FindTrustedDeviceByID(db, user.ID, deviceID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return apierrors.NewNotFoundError(apierrors.ErrorCodeTrustedDeviceNotFound, "Trusted device not found")
+		}
+		return apierrors.NewInternalServerError("Database error finding trusted device").WithInternalError(err)
+	}
+
+	err = db.Transaction(func(tx *storage.Connection) error {
+		if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.RevokeTrustedDeviceAction, r.RemoteAddr, map[string]interface{}{
+			"trusted_device_id": device.ID,
+		}); terr != nil {
+			return terr
+		}
+
+		if terr := tx.Destroy(device); terr != nil {
+			return apierrors.NewInternalServerError("Database error deleting trusted device").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, device)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+)
+
+// trustedDeviceSecret is the TOTP secret of the factor created by
+// createTrustedDeviceUser.
+const trustedDeviceSecret = "JBSWY3DPEHPK3PXP"
+
+// createTrustedDeviceUser creates a confirmed user with a verified TOTP
+// factor, and returns an AAL1 token of a new session of theirs.
+func (ts *MFATestSuite) createTrustedDeviceUser() (*models.User, *models.Factor, string) {
+	u, err := models.NewUser("", "trusted@example.com", ts.TestPassword, ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	now := time.Now()
+	u.EmailConfirmedAt = &now
+	require.NoError(ts.T(), ts.API.db.Create(u))
+
+	f := models.NewTOTPFactor(u, "totp")
+	require.NoError(ts.T(), f.SetSecret(trustedDeviceSecret, ts.Config.Security.DBEncryption.Encrypt, ts.Config.Security.DBEncryption.EncryptionKeyID, ts.Config.Security.DBEncryption.EncryptionKey))
+	f.Status = models.FactorStateVerified.String()
+	require.NoError(ts.T(), ts.API.db.Create(f))
+
+	s, err := models.NewSession(u.ID, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(s))
+
+	return u, f, ts.generateAAL1Token(u, &s.ID)
+}
+
+func (ts *MFATestSuite) TestVerifyIssuesTrustedDevice() {
+	user, factor, token := ts.createTrustedDeviceUser()
+
+	w := serveJSON(ts.T(), ts.API, http.MethodPost, fmt.Sprintf("/factors/%s/challenge", factor.ID), token, map[string]interface{}{})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	var challenge ChallengeFactorResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&challenge))
+
+	code, err := totp.GenerateCode(trustedDeviceSecret, time.Now().UTC())
+	require.NoError(ts.T(), err)
+
+	w = serveJSON(ts.T(), ts.API, http.MethodPost, fmt.Sprintf("/factors/%s/verify", factor.ID), token, map[string]interface{}{
+		"challenge_id": challenge.ID,
+		"code":         code,
+		"trust_device": true,
+		"device_label": "laptop",
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var resp AccessTokenResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&resp))
+	require.NotEmpty(ts.T(), resp.TrustedDeviceToken)
+
+	devices, err := models.FindTrustedDevicesByUser(ts.API.db, user.ID)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), devices, 1)
+	require.Equal(ts.T(), "laptop", devices[0].Label)
+	require.Equal(ts.T(), factor.ID, devices[0].FactorID)
+	require.NotEqual(ts.T(), resp.TrustedDeviceToken, devices[0].TokenHash)
+
+	ts.Config.MFA.TrustedDevices.Enabled = false
+	w = serveJSON(ts.T(), ts.API, http.MethodPost, fmt.Sprintf("/factors/%s/verify", factor.ID), token, map[string]interface{}{
+		"challenge_id": challenge.ID,
+		"code":         code,
+		"trust_device": true,
+	})
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	var data HTTPError
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), apierrors.ErrorCodeMFATrustedDevicesDisabled, data.ErrorCode)
+}
+
+func (ts *MFATestSuite) TestSignInWithTrustedDevice() {
+	user, factor, _ := ts.createTrustedDeviceUser()
+	signIn := func(deviceToken string) *AccessTokenClaims {
+		_, claims := signInWithPassword(ts.T(), ts.API, map[string]interface{}{
+			"email":                user.GetEmail(),
+			"password":             ts.TestPassword,
+			"trusted_device_token": deviceToken,
+		})
+		return claims
+	}
+
+	device, deviceToken := models.NewTrustedDevice(user, factor, "laptop", time.Hour)
+	require.NoError(ts.T(), ts.API.db.Create(device))
+
+	claims := signIn(deviceToken)
+	require.Equal(ts.T(), models.AAL2.String(), claims.AuthenticatorAssuranceLevel)
+
+	methods := []string{}
+	for _, entry := range claims.AuthenticationMethodReference {
+		methods = append(methods, entry.Method)
+	}
+	require.Contains(ts.T(), methods, models.MFATrustedDevice.String())
+
+	device, err := models.FindTrustedDeviceByID(ts.API.db, user.ID, device.ID)
+	require.NoError(ts.T(), err)
+	require.NotNil(ts.T(), device.LastUsedAt)
+
+	// unknown tokens fall back to AAL1
+	claims = signIn("not-a-device-token")
+	require.Equal(ts.T(), models.AAL1.String(), claims.AuthenticatorAssuranceLevel)
+
+	// expired devices are no longer trusted
+	expired, expiredToken := models.NewTrustedDevice(user, factor, "old laptop", -time.Hour)
+	require.NoError(ts.T(), ts.API.db.Create(expired))
+	claims = signIn(expiredToken)
+	require.Equal(ts.T(), models.AAL1.String(), claims.AuthenticatorAssuranceLevel)
+}
+
+func (ts *MFATestSuite) TestListAndRevokeTrustedDevices() {
+	user, factor, token := ts.createTrustedDeviceUser()
+	device, _ := models.NewTrustedDevice(user, factor, "laptop", time.Hour)
+	require.NoError(ts.T(), ts.API.db.Create(device))
+
+	w := serveJSON(ts.T(), ts.API, http.MethodGet, "/user/trusted_devices", token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var devices []models.TrustedDevice
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&devices))
+	require.Len(ts.T(), devices, 1)
+	require.Equal(ts.T(), device.ID, devices[0].ID)
+
+	w = serveJSON(ts.T(), ts.API, http.MethodDelete, fmt.Sprintf("/user/trusted_devices/%s", uuid.Must(uuid.NewV4())), token, nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+
+	w = serveJSON(ts.T(), ts.API, http.MethodDelete, fmt.Sprintf("/user/trusted_devices/%s", device.ID), token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	_, err := models.FindTrustedDeviceByID(ts.API.db, user.ID, device.ID)
+	require.True(ts.T(), models.IsNotFoundError(err))
+}
+
+func (ts *MFATestSuite) TestAdminDeleteTrustedDevices() {
+	user, factor, _ := ts.createTrustedDeviceUser()
+	for _, label := range []string{"laptop", "phone"} {
+		device, _ := models.NewTrustedDevice(user, factor, label, time.Hour)
+		require.NoError(ts.T(), ts.API.db.Create(device))
+	}
+
+	w := serveJSON(ts.T(), ts.API, http.MethodDelete, fmt.Sprintf("/admin/users/%s/trusted_devices", user.ID), generateAdminToken(ts.T(), ts.Config), nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	devices, err := models.FindTrustedDevicesByUser(ts.API.db, user.ID)
+	require.NoError(ts.T(), err)
+	require.Empty(ts.T(), devices)
+}
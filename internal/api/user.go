@@ -8,6 +8,7 @@ import (
 	"github.com/gofrs/uuid"
 	"github.com/linkly-id/auth/internal/api/apierrors"
 	"github.com/linkly-id/auth/internal/api/sms_provider"
+	"github.com/linkly-id/auth/internal/hooks/v0hooks"
 	"github.com/linkly-id/auth/internal/mailer"
 	"github.com/linkly-id/auth/internal/models"
 	"github.com/linkly-id/auth/internal/storage"
@@ -26,7 +27,7 @@ type UserUpdateParams struct {
 	CodeChallengeMethod string                 `json:"code_challenge_method"`
 }
 
-func (a *API) validateUserUpdateParams(ctx context.Context, p *UserUpdateParams) error {
+func (a *API) validateUserUpdateParams(ctx context.Context, user *models.User, p *UserUpdateParams) error {
 	config := a.config
 
 	var err error
@@ -50,7 +51,9 @@ func (a *API) validateUserUpdateParams(ctx context.Context, p *UserUpdateParams)
 	}
 
 	if p.Password != nil {
-		if err := a.checkPasswordStrength(ctx, *p.Password); err != nil {
+		userInputs := append(passwordUserInputs(user.GetEmail(), user.GetPhone(), user.UserMetaData), passwordUserInputs(p.Email, p.Phone, p.Data)...)
+
+		if err := a.checkPasswordStrength(ctx, *p.Password, userInputs...); err != nil {
 			return err
 		}
 	}
@@ -73,6 +76,7 @@ func (a *API) UserGet(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	user := getUser(ctx)
+	user.MFAEnrollmentDeadline = a.mfaEnrollmentDeadline(user)
 	return sendJSON(w, http.StatusOK, user)
 }
 
@@ -91,10 +95,17 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 	user := getUser(ctx)
 	session := getSession(ctx)
 
-	if err := a.validateUserUpdateParams(ctx, params); err != nil {
+	if err := a.validateUserUpdateParams(ctx, user, params); err != nil {
 		return err
 	}
 
+	if claims := getClaims(ctx); claims != nil && claims.Restriction == SessionRestrictionPasswordChange {
+		// the session can only be used to change the password
+		if params.Password == nil || *params.Password == "" || params.Email != "" || params.Phone != "" || params.Data != nil || params.AppData != nil {
+			return sessionRestrictionError(claims.Restriction)
+		}
+	}
+
 	if params.AppData != nil && !isAdmin(user, config) {
 		if !isAdmin(user, config) {
 			return apierrors.NewForbiddenError(apierrors.ErrorCodeNotAdmin, "Updating app_metadata requires admin privileges")
@@ -144,6 +155,22 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
+	update := &v0hooks.UserUpdate{
+		Password:     params.Password != nil && *params.Password != "",
+		UserMetadata: params.Data,
+		AppMetadata:  params.AppData,
+	}
+	if params.Email != "" && params.Email != user.GetEmail() {
+		update.Email = params.Email
+	}
+	if params.Phone != "" && params.Phone != user.GetPhone() {
+		update.Phone = params.Phone
+	}
+	if err := a.triggerBeforeUserUpdated(r, db, user, update); err != nil {
+		return err
+	}
+
+	var previousPassword *string
 	if params.Password != nil {
 		if config.Security.UpdatePasswordRequireReauthentication {
 			now := time.Now()
@@ -174,8 +201,13 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 			if isSamePassword {
 				return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeSamePassword, "New password should be different from the old password.")
 			}
+
+			if err := a.checkPreviousPasswords(ctx, db, user, password); err != nil {
+				return err
+			}
 		}
 
+		previousPassword = user.EncryptedPassword
 		if err := user.SetPassword(ctx, password, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey); err != nil {
 			return err
 		}
@@ -193,9 +225,19 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 				return apierrors.NewInternalServerError("Error during password storage").WithInternalError(terr)
 			}
 
+			if previousPassword != nil {
+				if terr = models.AddPasswordHistory(tx, user.ID, *previousPassword, a.previousPasswordsKept()); terr != nil {
+					return apierrors.NewInternalServerError("Error during password storage").WithInternalError(terr)
+				}
+			}
+
 			if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.UserUpdatePasswordAction, "", nil); terr != nil {
 				return terr
 			}
+
+			if terr := models.NewEvent(config.Events, tx, models.UserPasswordChangedEvent, user, nil); terr != nil {
+				return apierrors.NewInternalServerError("Error recording event").WithInternalError(terr)
+			}
 		}
 
 		if params.Data != nil {
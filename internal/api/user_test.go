@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/api/apierrors"
 	"github.com/linkly-id/auth/internal/conf"
 	"github.com/linkly-id/auth/internal/crypto"
 	"github.com/linkly-id/auth/internal/models"
@@ -480,6 +481,54 @@ func (ts *UserTestSuite) TestUserUpdatePasswordReauthentication() {
 	require.Nil(ts.T(), u.ReauthenticationSentAt)
 }
 
+func (ts *UserTestSuite) TestUserUpdatePasswordHistory() {
+	ts.Config.Security.UpdatePasswordRequireReauthentication = false
+	ts.Config.Password.HistoryLength = 3
+	defer func() {
+		ts.Config.Password.HistoryLength = 0
+	}()
+
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+	token := ts.generateAccessTokenAndSession(u)
+
+	var cases = []struct {
+		desc        string
+		newPassword string
+		code        int
+	}{
+		{desc: "First change", newPassword: "password2", code: http.StatusOK},
+		{desc: "Second change", newPassword: "password3", code: http.StatusOK},
+		{desc: "Reuse of the first password", newPassword: "password", code: http.StatusUnprocessableEntity},
+		{desc: "Reuse of a recent password", newPassword: "password2", code: http.StatusUnprocessableEntity},
+		{desc: "Third change", newPassword: "password4", code: http.StatusOK},
+		{desc: "First password is no longer in the history", newPassword: "password", code: http.StatusOK},
+	}
+
+	for _, c := range cases {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]string{"password": c.newPassword}))
+
+		req := httptest.NewRequest(http.MethodPut, "http://localhost/user", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), c.code, w.Code, c.desc)
+
+		if c.code != http.StatusOK {
+			var data HTTPError
+			require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+			require.Equal(ts.T(), apierrors.ErrorCodePasswordReused, data.ErrorCode, c.desc)
+		}
+	}
+
+	entries, err := models.FindPasswordHistory(ts.API.db, u.ID, 10)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), entries, 2)
+}
+
 func (ts *UserTestSuite) TestUserUpdatePasswordLogoutOtherSessions() {
 	ts.Config.Security.UpdatePasswordRequireReauthentication = false
 	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
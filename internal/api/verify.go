@@ -14,6 +14,7 @@ import (
 	"github.com/linkly-id/auth/internal/api/provider"
 	"github.com/linkly-id/auth/internal/api/sms_provider"
 	"github.com/linkly-id/auth/internal/crypto"
+	"github.com/linkly-id/auth/internal/hooks/v0hooks"
 	mail "github.com/linkly-id/auth/internal/mailer"
 	"github.com/linkly-id/auth/internal/metering"
 	"github.com/linkly-id/auth/internal/models"
@@ -141,58 +142,65 @@ func (a *API) verifyGet(w http.ResponseWriter, r *http.Request, params *VerifyPa
 		}
 	}
 
-	err = db.Transaction(func(tx *storage.Connection) error {
-		var terr error
-		user, terr = a.verifyTokenHash(tx, params)
-		if terr != nil {
-			return terr
-		}
-		switch params.Type {
-		case mail.SignupVerification, mail.InviteVerification:
-			user, terr = a.signupVerify(r, ctx, tx, user)
-		case mail.RecoveryVerification, mail.MagicLinkVerification:
-			user, terr = a.recoverVerify(r, tx, user)
-		case mail.EmailChangeVerification:
-			user, terr = a.emailChangeVerify(r, tx, params, user)
-			if user == nil && terr == nil {
-				// only one OTP is confirmed at this point, so we return early and ask the user to confirm the second OTP
-				rurl, terr = a.prepRedirectURL(singleConfirmationAccepted, params.RedirectTo, flowType)
-				if terr != nil {
-					return terr
+	// sessions are only issued here in the implicit flow
+	if isImplicitFlow(flowType) {
+		err = a.triggerBeforeVerifySignIn(r, db, params)
+	}
+
+	if err == nil {
+		err = db.Transaction(func(tx *storage.Connection) error {
+			var terr error
+			user, terr = a.verifyTokenHash(tx, params)
+			if terr != nil {
+				return terr
+			}
+			switch params.Type {
+			case mail.SignupVerification, mail.InviteVerification:
+				user, terr = a.signupVerify(r, ctx, tx, user)
+			case mail.RecoveryVerification, mail.MagicLinkVerification:
+				user, terr = a.recoverVerify(r, tx, user)
+			case mail.EmailChangeVerification:
+				user, terr = a.emailChangeVerify(r, tx, params, user)
+				if user == nil && terr == nil {
+					// only one OTP is confirmed at this point, so we return early and ask the user to confirm the second OTP
+					rurl, terr = a.prepRedirectURL(singleConfirmationAccepted, params.RedirectTo, flowType)
+					if terr != nil {
+						return terr
+					}
+					return nil
 				}
-				return nil
+			default:
+				return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Unsupported verification type")
 			}
-		default:
-			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Unsupported verification type")
-		}
 
-		if terr != nil {
-			return terr
-		}
-
-		if terr := user.UpdateAppMetaDataProviders(tx); terr != nil {
-			return terr
-		}
+			if terr != nil {
+				return terr
+			}
 
-		// Reload user model from db.
-		// This is important for refreshing the data in any generated columns like IsAnonymous.
-		if terr := tx.Reload(user); err != nil {
-			return terr
-		}
+			if terr := user.UpdateAppMetaDataProviders(tx); terr != nil {
+				return terr
+			}
 
-		if isImplicitFlow(flowType) {
-			token, terr = a.issueRefreshToken(r, tx, user, models.OTP, grantParams)
-			if terr != nil {
+			// Reload user model from db.
+			// This is important for refreshing the data in any generated columns like IsAnonymous.
+			if terr := tx.Reload(user); err != nil {
 				return terr
 			}
 
-		} else if isPKCEFlow(flowType) {
-			if authCode, terr = issueAuthCode(tx, user, authenticationMethod); terr != nil {
-				return apierrors.NewBadRequestError(apierrors.ErrorCodeFlowStateNotFound, "No associated flow state found. %s", terr)
+			if isImplicitFlow(flowType) {
+				token, terr = a.issueRefreshToken(r, tx, user, models.OTP, grantParams)
+				if terr != nil {
+					return terr
+				}
+
+			} else if isPKCEFlow(flowType) {
+				if authCode, terr = issueAuthCode(tx, user, authenticationMethod); terr != nil {
+					return apierrors.NewBadRequestError(apierrors.ErrorCodeFlowStateNotFound, "No associated flow state found. %s", terr)
+				}
 			}
-		}
-		return nil
-	})
+			return nil
+		})
+	}
 
 	if err != nil {
 		var herr *HTTPError
@@ -238,6 +246,10 @@ func (a *API) verifyPost(w http.ResponseWriter, r *http.Request, params *VerifyP
 
 	grantParams.FillGrantParams(r)
 
+	if err := a.triggerBeforeVerifySignIn(r, db, params); err != nil {
+		return err
+	}
+
 	err := db.Transaction(func(tx *storage.Connection) error {
 		var terr error
 		aud := a.requestAud(ctx, r)
@@ -309,6 +321,28 @@ func (a *API) verifyPost(w http.ResponseWriter, r *http.Request, params *VerifyP
 	return sendJSON(w, http.StatusOK, token)
 }
 
+// triggerBeforeVerifySignIn runs the before sign-in hook for the user the
+// token was sent to, before the transaction verifying the token and issuing
+// the session is opened, so that rejections don't use the token up.
+func (a *API) triggerBeforeVerifySignIn(r *http.Request, conn *storage.Connection, params *VerifyParams) error {
+	if !a.hooksMgr.Enabled(v0hooks.BeforeSignIn) {
+		return nil
+	}
+
+	var user *models.User
+	var err error
+	if r.Method == http.MethodGet || isUsingTokenHash(params) {
+		user, err = findUserByTokenHash(conn, params)
+	} else {
+		user, err = a.findUserToVerify(conn, params, a.requestAud(r.Context(), r))
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.triggerBeforeSignIn(r, conn, user, models.OTP)
+}
+
 func (a *API) signupVerify(r *http.Request, ctx context.Context, conn *storage.Connection, user *models.User) (*models.User, error) {
 	config := a.config
 
@@ -346,6 +380,10 @@ func (a *API) signupVerify(r *http.Request, ctx context.Context, conn *storage.C
 			return apierrors.NewInternalServerError("Error confirming user").WithInternalError(terr)
 		}
 
+		if terr = models.NewEvent(config.Events, tx, models.UserConfirmedEvent, user, nil); terr != nil {
+			return apierrors.NewInternalServerError("Error recording event").WithInternalError(terr)
+		}
+
 		for _, identity := range user.Identities {
 			if identity.Email == "" || user.Email == "" || identity.Email != user.Email {
 				continue
@@ -384,6 +422,10 @@ func (a *API) recoverVerify(r *http.Request, conn *storage.Connection, user *mod
 			if terr = user.Confirm(tx); terr != nil {
 				return terr
 			}
+
+			if terr = models.NewEvent(config.Events, tx, models.UserConfirmedEvent, user, nil); terr != nil {
+				return terr
+			}
 		} else {
 			if terr = models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.LoginAction, "", nil); terr != nil {
 				return terr
@@ -412,6 +454,9 @@ func (a *API) smsVerify(r *http.Request, conn *storage.Connection, user *models.
 			if terr := user.ConfirmPhone(tx); terr != nil {
 				return apierrors.NewInternalServerError("Error confirming user").WithInternalError(terr)
 			}
+			if terr := models.NewEvent(config.Events, tx, models.UserConfirmedEvent, user, nil); terr != nil {
+				return apierrors.NewInternalServerError("Error recording event").WithInternalError(terr)
+			}
 		} else if params.Type == phoneChangeVerification {
 			if terr := models.NewAuditLogEntry(config.AuditLog, r, tx, user, models.UserModifiedAction, "", nil); terr != nil {
 				return terr
@@ -606,9 +651,9 @@ func (a *API) emailChangeVerify(r *http.Request, conn *storage.Connection, param
 	return user, nil
 }
 
-func (a *API) verifyTokenHash(conn *storage.Connection, params *VerifyParams) (*models.User, error) {
-	config := a.config
-
+// findUserByTokenHash finds the user the token hash was sent to, without
+// verifying it.
+func findUserByTokenHash(conn *storage.Connection, params *VerifyParams) (*models.User, error) {
 	var user *models.User
 	var err error
 	switch params.Type {
@@ -632,6 +677,17 @@ func (a *API) verifyTokenHash(conn *storage.Connection, params *VerifyParams) (*
 		return nil, apierrors.NewInternalServerError("Database error finding user from email link").WithInternalError(err)
 	}
 
+	return user, nil
+}
+
+func (a *API) verifyTokenHash(conn *storage.Connection, params *VerifyParams) (*models.User, error) {
+	config := a.config
+
+	user, err := findUserByTokenHash(conn, params)
+	if err != nil {
+		return nil, err
+	}
+
 	if user.IsBanned() {
 		return nil, apierrors.NewForbiddenError(apierrors.ErrorCodeUserBanned, "User is banned")
 	}
@@ -662,12 +718,11 @@ func (a *API) verifyTokenHash(conn *storage.Connection, params *VerifyParams) (*
 }
 
 // verifyUserAndToken verifies the token associated to the user based on the verify type
-func (a *API) verifyUserAndToken(conn *storage.Connection, params *VerifyParams, aud string) (*models.User, error) {
-	config := a.config
-
+// findUserToVerify finds the user the token was sent to, without verifying
+// it.
+func (a *API) findUserToVerify(conn *storage.Connection, params *VerifyParams, aud string) (*models.User, error) {
 	var user *models.User
 	var err error
-	tokenHash := params.TokenHash
 
 	switch params.Type {
 	case phoneChangeVerification:
@@ -677,7 +732,7 @@ func (a *API) verifyUserAndToken(conn *storage.Connection, params *VerifyParams,
 	case mail.EmailChangeVerification:
 		// Since the email change could be trigger via the implicit or PKCE flow,
 		// the query used has to also check if the token saved in the db contains the pkce_ prefix
-		user, err = models.FindUserForEmailChange(conn, params.Email, tokenHash, aud, config.Mailer.SecureEmailChangeEnabled)
+		user, err = models.FindUserForEmailChange(conn, params.Email, params.TokenHash, aud, a.config.Mailer.SecureEmailChangeEnabled)
 	default:
 		user, err = models.FindUserByEmailAndAudience(conn, params.Email, aud)
 	}
@@ -689,6 +744,18 @@ func (a *API) verifyUserAndToken(conn *storage.Connection, params *VerifyParams,
 		return nil, apierrors.NewInternalServerError("Database error finding user").WithInternalError(err)
 	}
 
+	return user, nil
+}
+
+func (a *API) verifyUserAndToken(conn *storage.Connection, params *VerifyParams, aud string) (*models.User, error) {
+	config := a.config
+	tokenHash := params.TokenHash
+
+	user, err := a.findUserToVerify(conn, params, aud)
+	if err != nil {
+		return nil, err
+	}
+
 	if user.IsBanned() {
 		return nil, apierrors.NewForbiddenError(apierrors.ErrorCodeUserBanned, "User is banned")
 	}
@@ -143,6 +143,10 @@ func (a *API) web3GrantSolana(ctx context.Context, w http.ResponseWriter, r *htt
 		return err
 	}
 
+	if err := a.triggerBeforeSignInExternal(r, db, &userData, providerType, models.Web3); err != nil {
+		return err
+	}
+
 	err = db.Transaction(func(tx *storage.Connection) error {
 		user, terr := a.createAccountFromExternalIdentity(tx, r, &userData, providerType)
 		if terr != nil {
@@ -279,6 +283,10 @@ func (a *API) web3GrantEthereum(ctx context.Context, w http.ResponseWriter, r *h
 		return err
 	}
 
+	if err := a.triggerBeforeSignInExternal(r, db, &userData, providerType, models.Web3); err != nil {
+		return err
+	}
+
 	err = db.Transaction(func(tx *storage.Connection) error {
 		user, terr := a.createAccountFromExternalIdentity(tx, r, &userData, providerType)
 		if terr != nil {
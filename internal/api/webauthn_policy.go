@@ -0,0 +1,153 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-webauthn/webauthn/metadata"
+	"github.com/go-webauthn/webauthn/metadata/providers/memory"
+	wbnprotocol "github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/conf"
+)
+
+type webAuthnMetadataOption struct {
+	mds metadata.Provider
+}
+
+func (o *webAuthnMetadataOption) apply(a *API) { a.webAuthnMetadata = o.mds }
+
+// NewWebAuthnMetadataOption loads the FIDO Metadata Service blob of the
+// WebAuthn configuration, if any. It fails when the blob can't be loaded, as
+// every enrollment would be refused otherwise.
+func NewWebAuthnMetadataOption(config *conf.GlobalConfiguration) (Option, error) {
+	o := &webAuthnMetadataOption{}
+	if path := config.MFA.WebAuthn.MetadataPath; path != "" {
+		mds, err := loadWebAuthnMetadata(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load WebAuthn metadata from %q: %w", path, err)
+		}
+		o.mds = mds
+	}
+	return o, nil
+}
+
+// loadWebAuthnMetadata loads a FIDO Metadata Service blob from disk. The
+// blob's signature is verified against the FIDO root certificate, and
+// attestations of authenticators missing from it, or with an undesired
+// status, are refused.
+func loadWebAuthnMetadata(path string) (metadata.Provider, error) {
+	blob, err := os.ReadFile(path) // #nosec G304 -- path is from configuration
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := metadata.NewDecoder(metadata.WithIgnoreEntryParsingErrors())
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := decoder.DecodeBytes(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	mds, err := decoder.Parse(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return memory.New(
+		memory.WithMetadata(mds.ToMap()),
+		memory.WithValidateEntry(true),
+		memory.WithValidateEntryPermitZeroAAGUID(false),
+		memory.WithValidateTrustAnchor(true),
+		memory.WithValidateStatus(true),
+	)
+}
+
+// applyWebAuthnPolicy configures the relying party to request attestations
+// and user verification as required by the policy.
+func applyWebAuthnPolicy(wconfig *webauthn.Config, policy *conf.WebAuthnFactorTypeConfiguration, mds metadata.Provider) {
+	if policy.AttestationPreference != "" {
+		wconfig.AttestationPreference = wbnprotocol.ConveyancePreference(policy.AttestationPreference)
+	}
+	if policy.RequireUserVerification {
+		wconfig.AuthenticatorSelection.UserVerification = wbnprotocol.VerificationRequired
+	}
+	if mds != nil {
+		wconfig.MDS = mds
+	}
+}
+
+// validateWebAuthnAuthenticator checks a credential creation response
+// against the policy before the credential is created and its attestation
+// verified.
+func (a *API) validateWebAuthnAuthenticator(parsedResponse *wbnprotocol.ParsedCredentialCreationData) error {
+	policy := &a.config.MFA.WebAuthn
+	attestation := parsedResponse.Response.AttestationObject
+
+	if policy.RequireUserVerification && !attestation.AuthData.Flags.HasUserVerified() {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeWebAuthnUserVerificationRequired, "The authenticator must verify the user, for example with a PIN or biometrics")
+	}
+
+	if policy.MetadataPath != "" {
+		if a.webAuthnMetadata == nil {
+			return apierrors.NewInternalServerError("WebAuthn metadata is unavailable")
+		}
+		if attestation.Format == string(wbnprotocol.AttestationFormatNone) {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeWebAuthnAttestationRejected, "The authenticator must provide an attestation")
+		}
+	}
+
+	return nil
+}
+
+// validateWebAuthnCredential checks the model of the authenticator of a
+// created credential against the policy. The AAGUID is only trusted once the
+// attestation was verified against the metadata, which the configuration
+// requires for AAGUID lists.
+func (a *API) validateWebAuthnCredential(credential *webauthn.Credential) error {
+	policy := &a.config.MFA.WebAuthn
+	if len(policy.AllowedAAGUIDs) == 0 && len(policy.DeniedAAGUIDs) == 0 {
+		return nil
+	}
+
+	if a.webAuthnMetadata == nil || credential.AttestationType == string(wbnprotocol.AttestationFormatNone) {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeWebAuthnAttestationRejected, "The authenticator must provide an attestation")
+	}
+
+	aaguid := uuid.Nil
+	if len(credential.Authenticator.AAGUID) != 0 {
+		var err error
+		if aaguid, err = uuid.FromBytes(credential.Authenticator.AAGUID); err != nil {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Invalid authenticator AAGUID")
+		}
+	}
+
+	if !policy.AllowsAAGUID(aaguid) {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeWebAuthnAuthenticatorNotAllowed, "This authenticator model is not allowed")
+	}
+
+	return nil
+}
+
+// webAuthnAttestationError turns attestation failures from creating a
+// credential into errors clients can act on, returning nil for other
+// failures.
+func webAuthnAttestationError(err error) error {
+	var perr *wbnprotocol.Error
+	if !errors.As(err, &perr) {
+		return nil
+	}
+
+	switch perr.Type {
+	case wbnprotocol.ErrInvalidAttestation.Type, wbnprotocol.ErrAttestationFormat.Type, wbnprotocol.ErrAttestation.Type:
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeWebAuthnAttestationRejected, "The authenticator attestation was rejected").WithInternalError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/metadata/providers/memory"
+	wbnprotocol "github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/api/apierrors"
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWebAuthnAuthenticator(t *testing.T) {
+	yubikey := uuid.Must(uuid.FromString("cb69481e-8ff7-4039-93ec-0a2729a154a8"))
+	other := uuid.Must(uuid.FromString("ee882879-721c-4913-9775-3dfcce97072a"))
+
+	response := func(aaguid uuid.UUID, format string, flags wbnprotocol.AuthenticatorFlags) *wbnprotocol.ParsedCredentialCreationData {
+		return &wbnprotocol.ParsedCredentialCreationData{
+			Response: wbnprotocol.ParsedAttestationResponse{
+				AttestationObject: wbnprotocol.AttestationObject{
+					Format: format,
+					AuthData: wbnprotocol.AuthenticatorData{
+						Flags: flags,
+						AttData: wbnprotocol.AttestedCredentialData{
+							AAGUID: aaguid.Bytes(),
+						},
+					},
+				},
+			},
+		}
+	}
+
+	examples := []struct {
+		Policy    conf.WebAuthnFactorTypeConfiguration
+		Response  *wbnprotocol.ParsedCredentialCreationData
+		ErrorCode apierrors.ErrorCode
+	}{
+		{
+			Policy:   conf.WebAuthnFactorTypeConfiguration{},
+			Response: response(uuid.Nil, "none", wbnprotocol.FlagUserPresent),
+		},
+		{
+			// the AAGUID is only checked once the attestation is verified
+			Policy: conf.WebAuthnFactorTypeConfiguration{
+				AllowedAAGUIDs: []string{yubikey.String()},
+			},
+			Response: response(other, "packed", wbnprotocol.FlagUserPresent),
+		},
+		{
+			Policy: conf.WebAuthnFactorTypeConfiguration{
+				MetadataPath: "blob.jwt",
+			},
+			Response:  response(yubikey, "packed", wbnprotocol.FlagUserPresent),
+			ErrorCode: apierrors.ErrorCodeUnexpectedFailure,
+		},
+		{
+			Policy: conf.WebAuthnFactorTypeConfiguration{
+				RequireUserVerification: true,
+			},
+			Response:  response(yubikey, "packed", wbnprotocol.FlagUserPresent),
+			ErrorCode: apierrors.ErrorCodeWebAuthnUserVerificationRequired,
+		},
+		{
+			Policy: conf.WebAuthnFactorTypeConfiguration{
+				RequireUserVerification: true,
+			},
+			Response: response(yubikey, "packed", wbnprotocol.FlagUserPresent|wbnprotocol.FlagUserVerified),
+		},
+	}
+
+	for i, example := range examples {
+		api := &API{
+			config: &conf.GlobalConfiguration{
+				MFA: conf.MFAConfiguration{
+					WebAuthn: example.Policy,
+				},
+			},
+		}
+
+		err := api.validateWebAuthnAuthenticator(example.Response)
+		if example.ErrorCode == "" {
+			require.NoError(t, err, "Example %d failed with error", i)
+			continue
+		}
+
+		httpErr, ok := err.(*HTTPError)
+		require.True(t, ok, "Example %d failed with unexpected error %v", i, err)
+		require.Equal(t, example.ErrorCode, httpErr.ErrorCode, "Example %d failed with wrong error code", i)
+	}
+}
+
+func TestValidateWebAuthnCredential(t *testing.T) {
+	yubikey := uuid.Must(uuid.FromString("cb69481e-8ff7-4039-93ec-0a2729a154a8"))
+	other := uuid.Must(uuid.FromString("ee882879-721c-4913-9775-3dfcce97072a"))
+
+	credential := func(aaguid uuid.UUID, attestationType string) *webauthn.Credential {
+		return &webauthn.Credential{
+			AttestationType: attestationType,
+			Authenticator: webauthn.Authenticator{
+				AAGUID: aaguid.Bytes(),
+			},
+		}
+	}
+
+	examples := []struct {
+		Policy     conf.WebAuthnFactorTypeConfiguration
+		Credential *webauthn.Credential
+		ErrorCode  apierrors.ErrorCode
+	}{
+		{
+			Policy:     conf.WebAuthnFactorTypeConfiguration{},
+			Credential: credential(uuid.Nil, "none"),
+		},
+		{
+			Policy: conf.WebAuthnFactorTypeConfiguration{
+				AllowedAAGUIDs: []string{yubikey.String()},
+			},
+			Credential: credential(yubikey, "packed"),
+		},
+		{
+			Policy: conf.WebAuthnFactorTypeConfiguration{
+				AllowedAAGUIDs: []string{yubikey.String()},
+			},
+			Credential: credential(other, "packed"),
+			ErrorCode:  apierrors.ErrorCodeWebAuthnAuthenticatorNotAllowed,
+		},
+		{
+			Policy: conf.WebAuthnFactorTypeConfiguration{
+				DeniedAAGUIDs: []string{other.String()},
+			},
+			Credential: credential(other, "packed"),
+			ErrorCode:  apierrors.ErrorCodeWebAuthnAuthenticatorNotAllowed,
+		},
+		{
+			// unattested AAGUIDs are never trusted
+			Policy: conf.WebAuthnFactorTypeConfiguration{
+				AllowedAAGUIDs: []string{yubikey.String()},
+			},
+			Credential: credential(yubikey, "none"),
+			ErrorCode:  apierrors.ErrorCodeWebAuthnAttestationRejected,
+		},
+	}
+
+	for i, example := range examples {
+		api := &API{
+			config: &conf.GlobalConfiguration{
+				MFA: conf.MFAConfiguration{
+					WebAuthn: example.Policy,
+				},
+			},
+			webAuthnMetadata: &memory.Provider{},
+		}
+
+		err := api.validateWebAuthnCredential(example.Credential)
+		if example.ErrorCode == "" {
+			require.NoError(t, err, "Example %d failed with error", i)
+			continue
+		}
+
+		httpErr, ok := err.(*HTTPError)
+		require.True(t, ok, "Example %d failed with unexpected error %v", i, err)
+		require.Equal(t, example.ErrorCode, httpErr.ErrorCode, "Example %d failed with wrong error code", i)
+	}
+}
+
+func TestNewWebAuthnMetadataOption(t *testing.T) {
+	config := &conf.GlobalConfiguration{}
+	opt, err := NewWebAuthnMetadataOption(config)
+	require.NoError(t, err)
+	require.NotNil(t, opt)
+
+	config.MFA.WebAuthn.MetadataPath = filepath.Join(t.TempDir(), "blob.jwt")
+	require.NoError(t, os.WriteFile(config.MFA.WebAuthn.MetadataPath, []byte("not a blob"), 0600))
+	_, err = NewWebAuthnMetadataOption(config)
+	require.Error(t, err)
+}
+
+func TestApplyWebAuthnPolicy(t *testing.T) {
+	wconfig := &webauthn.Config{}
+	applyWebAuthnPolicy(wconfig, &conf.WebAuthnFactorTypeConfiguration{
+		AttestationPreference:   "direct",
+		RequireUserVerification: true,
+	}, nil)
+
+	require.Equal(t, wbnprotocol.PreferDirectAttestation, wconfig.AttestationPreference)
+	require.Equal(t, wbnprotocol.VerificationRequired, wconfig.AuthenticatorSelection.UserVerification)
+	require.Nil(t, wconfig.MDS)
+}
+
+func TestWebAuthnAttestationError(t *testing.T) {
+	err := webAuthnAttestationError(wbnprotocol.ErrInvalidAttestation.WithDetails("AAGUID not found in metadata"))
+	httpErr, ok := err.(*HTTPError)
+	require.True(t, ok)
+	require.Equal(t, apierrors.ErrorCodeWebAuthnAttestationRejected, httpErr.ErrorCode)
+
+	require.Nil(t, webAuthnAttestationError(wbnprotocol.ErrVerification))
+}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/gobwas/glob"
+	"github.com/gofrs/uuid"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -139,16 +140,188 @@ type PhoneFactorTypeConfiguration struct {
 	Template     string             `json:"template"`
 }
 
+type EmailFactorTypeConfiguration struct {
+	// Default to false in order to ensure Email MFA is opt-in
+	MFAFactorTypeConfiguration
+	OtpLength    int           `json:"otp_length" split_words:"true"`
+	MaxFrequency time.Duration `json:"max_frequency" split_words:"true"`
+	// Subject and Template override the subject and the URL of the template
+	// used for MFA emails, like the mailer subjects and templates do.
+	Subject  string `json:"subject"`
+	Template string `json:"template"`
+}
+
+type TrustedDevicesConfiguration struct {
+	Enabled bool `json:"enabled" default:"false"`
+	// ExpiryDuration is how long a device stays trusted after the factor
+	// was verified on it
+	ExpiryDuration time.Duration `json:"expiry_duration" split_words:"true" default:"720h"`
+}
+
+// MFAPolicyConfiguration requires users matching any of the roles,
+// audiences, email domains or the app_metadata flag to enroll an MFA factor
+// within the grace period.
+type MFAPolicyConfiguration struct {
+	Enabled         bool          `json:"enabled" default:"false"`
+	Roles           []string      `json:"roles"`
+	Audiences       []string      `json:"audiences"`
+	EmailDomains    []string      `json:"email_domains" split_words:"true"`
+	AppMetadataFlag string        `json:"app_metadata_flag" split_words:"true"`
+	GracePeriod     time.Duration `json:"grace_period" split_words:"true" default:"168h"`
+}
+
+func (c *MFAPolicyConfiguration) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.Roles) == 0 && len(c.Audiences) == 0 && len(c.EmailDomains) == 0 && c.AppMetadataFlag == "" {
+		return errors.New("conf: MFA policy must match at least one role, audience, email domain or app_metadata flag when enabled")
+	}
+
+	if c.GracePeriod < 0 {
+		return fmt.Errorf("conf: MFA policy grace period must not be negative, was %v", c.GracePeriod.String())
+	}
+
+	return nil
+}
+
+// Matches reports whether a user with the provided attributes is subject to
+// the MFA policy. The app_metadata flag matches only when set to true.
+func (c *MFAPolicyConfiguration) Matches(role, aud, email string, appMetadata map[string]interface{}) bool {
+	if !c.Enabled {
+		return false
+	}
+
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	for _, a := range c.Audiences {
+		if a == aud {
+			return true
+		}
+	}
+
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		domain := email[at+1:]
+		for _, d := range c.EmailDomains {
+			if strings.EqualFold(d, domain) {
+				return true
+			}
+		}
+	}
+
+	if c.AppMetadataFlag != "" {
+		if flag, ok := appMetadata[c.AppMetadataFlag].(bool); ok && flag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WebAuthnFactorTypeConfiguration restricts which authenticators can be
+// enrolled as WebAuthn factors, including passkeys.
+type WebAuthnFactorTypeConfiguration struct {
+	MFAFactorTypeConfiguration
+	// AttestationPreference is the attestation conveyance preference sent
+	// to clients: none, indirect, direct or enterprise
+	AttestationPreference string `json:"attestation_preference" split_words:"true" default:"none"`
+	// AllowedAAGUIDs, when set, only allows authenticators of these models
+	AllowedAAGUIDs []string `json:"allowed_aaguids" envconfig:"ALLOWED_AAGUIDS"`
+	// DeniedAAGUIDs refuses authenticators of these models
+	DeniedAAGUIDs           []string `json:"denied_aaguids" envconfig:"DENIED_AAGUIDS"`
+	RequireUserVerification bool     `json:"require_user_verification" split_words:"true"`
+	// MetadataPath is the path to a FIDO Metadata Service blob that
+	// attestations are validated against
+	MetadataPath string `json:"metadata_path" split_words:"true"`
+}
+
+func (c *WebAuthnFactorTypeConfiguration) Validate() error {
+	switch c.AttestationPreference {
+	case "", "none", "indirect", "direct", "enterprise":
+	default:
+		return fmt.Errorf("conf: WebAuthn attestation preference must be one of none, indirect, direct or enterprise, was %q", c.AttestationPreference)
+	}
+
+	for _, aaguid := range append(append([]string{}, c.AllowedAAGUIDs...), c.DeniedAAGUIDs...) {
+		if _, err := uuid.FromString(aaguid); err != nil {
+			return fmt.Errorf("conf: WebAuthn AAGUID must be a UUID, was %q", aaguid)
+		}
+	}
+
+	// authenticators assert their own AAGUID, which can only be trusted
+	// once their attestation is verified against the metadata
+	if (len(c.AllowedAAGUIDs) != 0 || len(c.DeniedAAGUIDs) != 0) && c.MetadataPath == "" {
+		return errors.New("conf: WebAuthn AAGUID lists require a metadata path")
+	}
+
+	if c.MetadataPath != "" {
+		if _, err := os.Stat(c.MetadataPath); err != nil {
+			return fmt.Errorf("conf: WebAuthn metadata path must be a readable file, was %q", c.MetadataPath)
+		}
+
+		if c.AttestationPreference == "" || c.AttestationPreference == "none" {
+			return errors.New("conf: WebAuthn attestation preference must not be none when a metadata path is set")
+		}
+	}
+
+	return nil
+}
+
+// AllowsAAGUID reports whether authenticators with the AAGUID can be
+// enrolled.
+func (c *WebAuthnFactorTypeConfiguration) AllowsAAGUID(aaguid uuid.UUID) bool {
+	for _, denied := range c.DeniedAAGUIDs {
+		if uuid.FromStringOrNil(denied) == aaguid {
+			return false
+		}
+	}
+
+	if len(c.AllowedAAGUIDs) == 0 {
+		return true
+	}
+
+	for _, allowed := range c.AllowedAAGUIDs {
+		if uuid.FromStringOrNil(allowed) == aaguid {
+			return true
+		}
+	}
+
+	return false
+}
+
+type RecoveryCodesFactorTypeConfiguration struct {
+	// Default to false in order to ensure recovery codes are opt-in
+	MFAFactorTypeConfiguration
+	// Count is the number of recovery codes generated at once
+	Count int `json:"count" default:"10"`
+}
+
 // MFAConfiguration holds all the MFA related Configuration
 type MFAConfiguration struct {
-	ChallengeExpiryDuration     float64                      `json:"challenge_expiry_duration" default:"300" split_words:"true"`
-	FactorExpiryDuration        time.Duration                `json:"factor_expiry_duration" default:"300s" split_words:"true"`
-	RateLimitChallengeAndVerify float64                      `split_words:"true" default:"15"`
-	MaxEnrolledFactors          float64                      `split_words:"true" default:"10"`
-	MaxVerifiedFactors          int                          `split_words:"true" default:"10"`
-	Phone                       PhoneFactorTypeConfiguration `split_words:"true"`
-	TOTP                        TOTPFactorTypeConfiguration  `split_words:"true"`
-	WebAuthn                    MFAFactorTypeConfiguration   `split_words:"true"`
+	ChallengeExpiryDuration     float64       `json:"challenge_expiry_duration" default:"300" split_words:"true"`
+	FactorExpiryDuration        time.Duration `json:"factor_expiry_duration" default:"300s" split_words:"true"`
+	RateLimitChallengeAndVerify float64       `split_words:"true" default:"15"`
+	// RateLimitRecoveryCodeVerify is the number of recovery code
+	// verifications allowed per user every 5 minutes
+	RateLimitRecoveryCodeVerify float64                              `split_words:"true" default:"5"`
+	MaxEnrolledFactors          float64                              `split_words:"true" default:"10"`
+	MaxVerifiedFactors          int                                  `split_words:"true" default:"10"`
+	Phone                       PhoneFactorTypeConfiguration         `split_words:"true"`
+	Email                       EmailFactorTypeConfiguration         `split_words:"true"`
+	TOTP                        TOTPFactorTypeConfiguration          `split_words:"true"`
+	WebAuthn                    WebAuthnFactorTypeConfiguration      `split_words:"true"`
+	RecoveryCodes               RecoveryCodesFactorTypeConfiguration `split_words:"true"`
+	TrustedDevices              TrustedDevicesConfiguration          `split_words:"true"`
+	Policy                      MFAPolicyConfiguration               `split_words:"true"`
+
+	// RateLimitVerifyPerUser limits the factor verifications of each
+	// user. Disabled when unset.
+	RateLimitVerifyPerUser Rate `split_words:"true"`
 }
 
 type APIConfiguration struct {
@@ -232,6 +405,41 @@ type HIBPBloomConfiguration struct {
 	FalsePositives float64 `json:"false_positives" split_words:"true" default:"0.0000099"`
 }
 
+// HIBPDatasetConfiguration configures checking pwned passwords against a
+// local copy of the Pwned Passwords dataset instead of the HIBP API. The
+// file is either the dataset itself, with one HASH:COUNT line per password
+// ordered by hash, or a Bloom filter built from it with `auth hibp
+// build-filter`.
+type HIBPDatasetConfiguration struct {
+	Path   string `json:"path"`
+	Format string `json:"format" default:"text"`
+	Hash   string `json:"hash" default:"sha1"`
+}
+
+func (c *HIBPDatasetConfiguration) Validate() error {
+	if c.Path == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(c.Path); err != nil {
+		return fmt.Errorf("conf: HIBP dataset path must be a readable file, was %q", c.Path)
+	}
+
+	switch c.Format {
+	case "text", "bloom":
+	default:
+		return fmt.Errorf("conf: HIBP dataset format must be one of text or bloom, was %q", c.Format)
+	}
+
+	switch c.Hash {
+	case "sha1", "ntlm":
+	default:
+		return fmt.Errorf("conf: HIBP dataset hash must be one of sha1 or ntlm, was %q", c.Hash)
+	}
+
+	return nil
+}
+
 type HIBPConfiguration struct {
 	Enabled    bool `json:"enabled"`
 	FailClosed bool `json:"fail_closed" split_words:"true"`
@@ -239,6 +447,48 @@ type HIBPConfiguration struct {
 	UserAgent string `json:"user_agent" split_words:"true" default:"https://github.com/linkly/gotrue"`
 
 	Bloom HIBPBloomConfiguration `json:"bloom"`
+
+	Dataset HIBPDatasetConfiguration `json:"dataset"`
+}
+
+func (c *HIBPConfiguration) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	return c.Dataset.Validate()
+}
+
+// PasswordHashConfiguration configures the algorithm and cost new password
+// hashes are generated with. Passwords hashed otherwise, like imported
+// ones, are rehashed when users sign in with them.
+type PasswordHashConfiguration struct {
+	Algorithm  string `json:"algorithm" default:"bcrypt"`
+	BcryptCost int    `json:"bcrypt_cost" split_words:"true" default:"10"`
+
+	// Argon2Memory is in KiB
+	Argon2Memory  uint32 `json:"argon2_memory" split_words:"true" default:"19456"`
+	Argon2Time    uint32 `json:"argon2_time" split_words:"true" default:"2"`
+	Argon2Threads uint8  `json:"argon2_threads" split_words:"true" default:"1"`
+}
+
+func (c *PasswordHashConfiguration) Validate() error {
+	switch c.Algorithm {
+	case "", "bcrypt":
+		if c.BcryptCost != 0 && (c.BcryptCost < 4 || c.BcryptCost > 31) {
+			return fmt.Errorf("conf: password hash bcrypt cost must be between 4 and 31, was %d", c.BcryptCost)
+		}
+
+	case "argon2id":
+		if c.Argon2Time == 0 || c.Argon2Threads == 0 || c.Argon2Memory < 8*uint32(c.Argon2Threads) {
+			return fmt.Errorf("conf: password hash argon2 parameters must be positive with at least 8 KiB of memory per thread, was m=%d,t=%d,p=%d", c.Argon2Memory, c.Argon2Time, c.Argon2Threads)
+		}
+
+	default:
+		return fmt.Errorf("conf: password hash algorithm must be one of bcrypt or argon2id, was %q", c.Algorithm)
+	}
+
+	return nil
 }
 
 type PasswordConfiguration struct {
@@ -246,7 +496,29 @@ type PasswordConfiguration struct {
 
 	RequiredCharacters PasswordRequiredCharacters `json:"required_characters" split_words:"true"`
 
+	// MinScore is the minimum strength score from 0 to 4 estimated from
+	// how easy the password is to guess, 0 disables scoring
+	MinScore int `json:"min_score" split_words:"true"`
+
+	// HistoryLength is the number of recent passwords, including the
+	// current one, a user can't reuse
+	HistoryLength int `json:"history_length" split_words:"true"`
+
+	// MaxAge is how long a password can be used before it has to be
+	// changed
+	MaxAge time.Duration `json:"max_age" split_words:"true"`
+
 	HIBP HIBPConfiguration `json:"hibp"`
+
+	Hash PasswordHashConfiguration `json:"hash"`
+}
+
+func (c *PasswordConfiguration) Validate() error {
+	if c.MinScore < 0 || c.MinScore > 4 {
+		return fmt.Errorf("conf: password minimum score must be between 0 and 4, was %d", c.MinScore)
+	}
+
+	return c.Hash.Validate()
 }
 
 type AuditLogConfiguration struct {
@@ -268,6 +540,7 @@ type GlobalConfiguration struct {
 	AuditLog      AuditLogConfiguration `split_words:"true"`
 
 	RateLimitHeader                     string  `split_words:"true"`
+	RateLimitBackend                    string  `split_words:"true" default:"memory"`
 	RateLimitEmailSent                  Rate    `split_words:"true" default:"30"`
 	RateLimitSmsSent                    Rate    `split_words:"true" default:"30"`
 	RateLimitVerify                     float64 `split_words:"true" default:"30"`
@@ -276,8 +549,27 @@ type GlobalConfiguration struct {
 	RateLimitAnonymousUsers             float64 `split_words:"true" default:"30"`
 	RateLimitOtp                        float64 `split_words:"true" default:"30"`
 	RateLimitWeb3                       float64 `split_words:"true" default:"30"`
+	RateLimitPasskey                    float64 `split_words:"true" default:"30"`
 	RateLimitOAuthDynamicClientRegister float64 `split_words:"true" default:"10"`
 
+	// RateLimitOtpPerIdentifier limits the OTPs, magic links and recovery
+	// and confirmation messages sent to each email address or phone
+	// number, whichever IP they're requested from. Disabled when unset.
+	RateLimitOtpPerIdentifier Rate `split_words:"true"`
+	// RateLimitPasswordPerIdentifier limits the password sign ins of each
+	// email address or phone number. Disabled when unset.
+	RateLimitPasswordPerIdentifier Rate `split_words:"true"`
+
+	// IPAccess allows and denies access to groups of routes by the client's
+	// IP address.
+	IPAccess IPAccessConfiguration `json:"ip_access" split_words:"true"`
+
+	// Impersonation allows administrators to sign in as users.
+	Impersonation ImpersonationConfiguration `json:"impersonation"`
+
+	// Events are delivered to webhook endpoints by a background dispatcher.
+	Events EventsConfiguration `json:"events"`
+
 	SiteURL         string   `json:"site_url" split_words:"true" required:"true"`
 	URIAllowList    []string `json:"uri_allow_list" split_words:"true"`
 	URIAllowListMap map[string]glob.Glob
@@ -363,6 +655,8 @@ type ProviderConfiguration struct {
 
 	Web3Solana   SolanaConfiguration   `json:"web3_solana" split_words:"true"`
 	Web3Ethereum EthereumConfiguration `json:"web3_ethereum" split_words:"true"`
+
+	Passkey PasskeyConfiguration `json:"passkey"`
 }
 
 type SolanaConfiguration struct {
@@ -375,6 +669,46 @@ type EthereumConfiguration struct {
 	MaximumValidityDuration time.Duration `json:"maximum_validity_duration,omitempty" default:"10m" split_words:"true"`
 }
 
+// PasskeyConfiguration configures signing in with discoverable WebAuthn
+// credentials (passkeys) as a first factor. Unlike the WebAuthn MFA factor,
+// the relying party is configured on the server, as there is no session to
+// tie the request to.
+type PasskeyConfiguration struct {
+	Enabled       bool     `json:"enabled" default:"false"`
+	RPID          string   `json:"rp_id" envconfig:"RP_ID"`
+	RPDisplayName string   `json:"rp_display_name" envconfig:"RP_DISPLAY_NAME"`
+	RPOrigins     []string `json:"rp_origins" envconfig:"RP_ORIGINS"`
+
+	ChallengeExpiryDuration time.Duration `json:"challenge_expiry_duration" split_words:"true" default:"5m"`
+}
+
+func (c *PasskeyConfiguration) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.RPID == "" {
+		return errors.New("conf: passkey RP ID must be set when passkeys are enabled")
+	}
+
+	if len(c.RPOrigins) == 0 {
+		return errors.New("conf: passkey RP origins must be set when passkeys are enabled")
+	}
+
+	for _, origin := range c.RPOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && !(u.Scheme == "http" && u.Hostname() == "localhost")) {
+			return fmt.Errorf("conf: passkey RP origin must be an https URL, was %q", origin)
+		}
+	}
+
+	if c.ChallengeExpiryDuration <= 0 {
+		return fmt.Errorf("conf: passkey challenge expiry duration must be positive, was %v", c.ChallengeExpiryDuration.String())
+	}
+
+	return nil
+}
+
 type SMTPConfiguration struct {
 	MaxFrequency   time.Duration `json:"max_frequency" split_words:"true"`
 	Host           string        `json:"host"`
@@ -626,6 +960,74 @@ type SecurityConfiguration struct {
 	ManualLinkingEnabled                  bool                 `json:"manual_linking_enabled" split_words:"true" default:"false"`
 
 	DBEncryption DatabaseEncryptionConfiguration `json:"database_encryption" split_words:"true"`
+	Lockout      LockoutConfiguration            `json:"lockout"`
+}
+
+// LockoutConfiguration controls the built-in account lockout that is applied
+// after repeated failed password sign-in attempts. Attempts are counted both
+// per user and per sign-in identifier (email or phone), the latter also
+// covering identifiers that do not belong to any user.
+type LockoutConfiguration struct {
+	Enabled bool `json:"enabled" default:"false"`
+
+	MaxAttemptsPerUser       int `json:"max_attempts_per_user" split_words:"true" default:"5"`
+	MaxAttemptsPerIdentifier int `json:"max_attempts_per_identifier" split_words:"true" default:"10"`
+
+	// AttemptWindow is the period after the last failed attempt within
+	// which consecutive failures are counted towards the thresholds.
+	AttemptWindow time.Duration `json:"attempt_window" split_words:"true" default:"15m"`
+
+	// Duration is the length of the first lockout. Every subsequent
+	// lockout doubles it, up to MaxDuration.
+	Duration    time.Duration `json:"duration" default:"5m"`
+	MaxDuration time.Duration `json:"max_duration" split_words:"true" default:"24h"`
+
+	// Cooldown is the period without failed attempts after which the
+	// exponential backoff is reset.
+	Cooldown time.Duration `json:"cooldown" default:"24h"`
+}
+
+func (c *LockoutConfiguration) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.MaxAttemptsPerUser < 1 {
+		return fmt.Errorf("conf: lockout max attempts per user must be at least 1, was %d", c.MaxAttemptsPerUser)
+	}
+
+	if c.MaxAttemptsPerIdentifier < 1 {
+		return fmt.Errorf("conf: lockout max attempts per identifier must be at least 1, was %d", c.MaxAttemptsPerIdentifier)
+	}
+
+	if c.AttemptWindow <= 0 {
+		return fmt.Errorf("conf: lockout attempt window must be positive, was %v", c.AttemptWindow.String())
+	}
+
+	if c.Duration <= 0 {
+		return fmt.Errorf("conf: lockout duration must be positive, was %v", c.Duration.String())
+	}
+
+	if c.MaxDuration < c.Duration {
+		return fmt.Errorf("conf: lockout max duration must not be less than lockout duration, was %v", c.MaxDuration.String())
+	}
+
+	if c.Cooldown <= 0 {
+		return fmt.Errorf("conf: lockout cooldown must be positive, was %v", c.Cooldown.String())
+	}
+
+	return nil
+}
+
+// LockoutDuration returns how long a key should be locked for, given the
+// number of lockouts it has already served since the last cooldown.
+func (c *LockoutConfiguration) LockoutDuration(previousLockouts int) time.Duration {
+	duration := c.Duration
+	for i := 0; i < previousLockouts && duration < c.MaxDuration; i++ {
+		duration *= 2
+	}
+
+	return min(duration, c.MaxDuration)
 }
 
 func (c *SecurityConfiguration) Validate() error {
@@ -637,6 +1039,10 @@ func (c *SecurityConfiguration) Validate() error {
 		return err
 	}
 
+	if err := c.Lockout.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -664,6 +1070,10 @@ type HookConfiguration struct {
 
 	BeforeUserCreated ExtensibilityPointConfiguration `json:"before_user_created" split_words:"true"`
 	AfterUserCreated  ExtensibilityPointConfiguration `json:"after_user_created" split_words:"true"`
+
+	BeforeSignIn       ExtensibilityPointConfiguration `json:"before_sign_in" split_words:"true"`
+	BeforeTokenRefresh ExtensibilityPointConfiguration `json:"before_token_refresh" split_words:"true"`
+	BeforeUserUpdated  ExtensibilityPointConfiguration `json:"before_user_updated" split_words:"true"`
 }
 
 type HTTPHookSecrets []string
@@ -697,6 +1107,9 @@ func (h *HookConfiguration) Validate() error {
 		h.SendEmail,
 		h.BeforeUserCreated,
 		h.AfterUserCreated,
+		h.BeforeSignIn,
+		h.BeforeTokenRefresh,
+		h.BeforeUserUpdated,
 	}
 	for _, point := range points {
 		if err := point.ValidateExtensibilityPoint(); err != nil {
@@ -926,6 +1339,24 @@ func populateGlobal(config *GlobalConfiguration) error {
 		}
 	}
 
+	if config.Hook.BeforeSignIn.Enabled {
+		if err := config.Hook.BeforeSignIn.PopulateExtensibilityPoint(); err != nil {
+			return err
+		}
+	}
+
+	if config.Hook.BeforeTokenRefresh.Enabled {
+		if err := config.Hook.BeforeTokenRefresh.PopulateExtensibilityPoint(); err != nil {
+			return err
+		}
+	}
+
+	if config.Hook.BeforeUserUpdated.Enabled {
+		if err := config.Hook.BeforeUserUpdated.PopulateExtensibilityPoint(); err != nil {
+			return err
+		}
+	}
+
 	if config.SAML.Enabled {
 		if err := config.SAML.PopulateFields(config.API.ExternalURL); err != nil {
 			return err
@@ -1083,6 +1514,35 @@ func (config *GlobalConfiguration) ApplyDefaults() error {
 		config.MFA.Phone.OtpLength = 6
 	}
 
+	if config.MFA.Email.MaxFrequency == 0 {
+		config.MFA.Email.MaxFrequency = 1 * time.Minute
+	}
+
+	if config.MFA.Email.OtpLength < 6 || config.MFA.Email.OtpLength > 10 {
+		// 6-digit otp by default
+		config.MFA.Email.OtpLength = 6
+	}
+
+	if config.MFA.RecoveryCodes.Count < 1 || config.MFA.RecoveryCodes.Count > 20 {
+		config.MFA.RecoveryCodes.Count = 10
+	}
+
+	if config.MFA.WebAuthn.AttestationPreference == "" {
+		config.MFA.WebAuthn.AttestationPreference = "none"
+	}
+
+	if config.Password.HIBP.Dataset.Format == "" {
+		config.Password.HIBP.Dataset.Format = "text"
+	}
+
+	if config.Password.HIBP.Dataset.Hash == "" {
+		config.Password.HIBP.Dataset.Hash = "sha1"
+	}
+
+	if config.External.Passkey.RPDisplayName == "" {
+		config.External.Passkey.RPDisplayName = config.External.Passkey.RPID
+	}
+
 	if config.External.FlowStateExpiryDuration < defaultFlowStateExpiryDuration {
 		config.External.FlowStateExpiryDuration = defaultFlowStateExpiryDuration
 	}
@@ -1147,8 +1607,15 @@ func (c *GlobalConfiguration) Validate() error {
 		&c.SAML,
 		&c.Security,
 		&c.Sessions,
+		&c.External.Passkey,
+		&c.MFA.Policy,
+		&c.MFA.WebAuthn,
+		&c.Password,
+		&c.Password.HIBP,
 		&c.Hook,
 		&c.JWT.Keys,
+		&c.Impersonation,
+		&c.Events,
 	}
 
 	for _, validatable := range validatables {
@@ -1157,6 +1624,12 @@ func (c *GlobalConfiguration) Validate() error {
 		}
 	}
 
+	switch c.RateLimitBackend {
+	case "", RateLimitBackendMemory, RateLimitBackendPostgres:
+	default:
+		return fmt.Errorf("conf: rate limit backend must be %q or %q, was %q", RateLimitBackendMemory, RateLimitBackendPostgres, c.RateLimitBackend)
+	}
+
 	return nil
 }
 
@@ -4,11 +4,13 @@ import (
 	"encoding/base64"
 	"errors"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/gofrs/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/stretchr/testify/assert"
@@ -206,6 +208,51 @@ func TestGlobal(t *testing.T) {
 		os.Setenv("API_EXTERNAL_URL", "http://localhost:9999")
 	}
 
+	{
+		os.Setenv("API_EXTERNAL_URL", "")
+		cfg := new(GlobalConfiguration)
+		cfg.Hook = HookConfiguration{
+			BeforeSignIn: ExtensibilityPointConfiguration{
+				Enabled: true,
+				URI:     "\n",
+			},
+		}
+
+		err := populateGlobal(cfg)
+		require.Error(t, err)
+		os.Setenv("API_EXTERNAL_URL", "http://localhost:9999")
+	}
+
+	{
+		os.Setenv("API_EXTERNAL_URL", "")
+		cfg := new(GlobalConfiguration)
+		cfg.Hook = HookConfiguration{
+			BeforeTokenRefresh: ExtensibilityPointConfiguration{
+				Enabled: true,
+				URI:     "\n",
+			},
+		}
+
+		err := populateGlobal(cfg)
+		require.Error(t, err)
+		os.Setenv("API_EXTERNAL_URL", "http://localhost:9999")
+	}
+
+	{
+		os.Setenv("API_EXTERNAL_URL", "")
+		cfg := new(GlobalConfiguration)
+		cfg.Hook = HookConfiguration{
+			BeforeUserUpdated: ExtensibilityPointConfiguration{
+				Enabled: true,
+				URI:     "\n",
+			},
+		}
+
+		err := populateGlobal(cfg)
+		require.Error(t, err)
+		os.Setenv("API_EXTERNAL_URL", "http://localhost:9999")
+	}
+
 	{
 		os.Setenv("API_EXTERNAL_URL", "")
 		cfg := new(GlobalConfiguration)
@@ -488,6 +535,9 @@ func TestTime(t *testing.T) {
 }
 
 func TestValidate(t *testing.T) {
+	metadataPath := filepath.Join(t.TempDir(), "blob.jwt")
+	require.NoError(t, os.WriteFile(metadataPath, []byte("blob"), 0600))
+
 	type testCase struct {
 		val   interface{ Validate() error }
 		check func(t *testing.T, v any)
@@ -679,6 +729,228 @@ func TestValidate(t *testing.T) {
 			},
 		},
 
+		{
+			val: &LockoutConfiguration{Enabled: false},
+		},
+		{
+			val: &LockoutConfiguration{
+				Enabled:                  true,
+				MaxAttemptsPerUser:       5,
+				MaxAttemptsPerIdentifier: 10,
+				AttemptWindow:            time.Minute,
+				Duration:                 time.Minute,
+				MaxDuration:              time.Hour,
+				Cooldown:                 time.Hour,
+			},
+		},
+		{
+			val: &LockoutConfiguration{Enabled: true},
+			err: `conf: lockout max attempts per user must be at least 1, was 0`,
+		},
+		{
+			val: &LockoutConfiguration{
+				Enabled:            true,
+				MaxAttemptsPerUser: 5,
+			},
+			err: `conf: lockout max attempts per identifier must be at least 1, was 0`,
+		},
+		{
+			val: &LockoutConfiguration{
+				Enabled:                  true,
+				MaxAttemptsPerUser:       5,
+				MaxAttemptsPerIdentifier: 10,
+			},
+			err: `conf: lockout attempt window must be positive, was 0s`,
+		},
+		{
+			val: &LockoutConfiguration{
+				Enabled:                  true,
+				MaxAttemptsPerUser:       5,
+				MaxAttemptsPerIdentifier: 10,
+				AttemptWindow:            time.Minute,
+			},
+			err: `conf: lockout duration must be positive, was 0s`,
+		},
+		{
+			val: &LockoutConfiguration{
+				Enabled:                  true,
+				MaxAttemptsPerUser:       5,
+				MaxAttemptsPerIdentifier: 10,
+				AttemptWindow:            time.Minute,
+				Duration:                 time.Hour,
+				MaxDuration:              time.Minute,
+			},
+			err: `conf: lockout max duration must not be less than lockout duration, was 1m0s`,
+		},
+		{
+			val: &LockoutConfiguration{
+				Enabled:                  true,
+				MaxAttemptsPerUser:       5,
+				MaxAttemptsPerIdentifier: 10,
+				AttemptWindow:            time.Minute,
+				Duration:                 time.Minute,
+				MaxDuration:              time.Hour,
+				Cooldown:                 -time.Hour,
+			},
+			err: `conf: lockout cooldown must be positive, was -1h0m0s`,
+		},
+
+		{
+			val: &PasskeyConfiguration{Enabled: false},
+		},
+		{
+			val: &PasskeyConfiguration{
+				Enabled:                 true,
+				RPID:                    "example.com",
+				RPOrigins:               []string{"https://example.com", "http://localhost:3000"},
+				ChallengeExpiryDuration: time.Minute,
+			},
+		},
+		{
+			val: &PasskeyConfiguration{Enabled: true},
+			err: `conf: passkey RP ID must be set when passkeys are enabled`,
+		},
+		{
+			val: &PasskeyConfiguration{
+				Enabled: true,
+				RPID:    "example.com",
+			},
+			err: `conf: passkey RP origins must be set when passkeys are enabled`,
+		},
+		{
+			val: &PasskeyConfiguration{
+				Enabled:   true,
+				RPID:      "example.com",
+				RPOrigins: []string{"http://example.com"},
+			},
+			err: `conf: passkey RP origin must be an https URL, was "http://example.com"`,
+		},
+		{
+			val: &PasskeyConfiguration{
+				Enabled:   true,
+				RPID:      "example.com",
+				RPOrigins: []string{"https://example.com"},
+			},
+			err: `conf: passkey challenge expiry duration must be positive, was 0s`,
+		},
+
+		{
+			val: &MFAPolicyConfiguration{Enabled: false},
+		},
+		{
+			val: &MFAPolicyConfiguration{
+				Enabled:     true,
+				Roles:       []string{"staff"},
+				GracePeriod: time.Hour,
+			},
+		},
+		{
+			val: &MFAPolicyConfiguration{Enabled: true},
+			err: `conf: MFA policy must match at least one role, audience, email domain or app_metadata flag when enabled`,
+		},
+		{
+			val: &MFAPolicyConfiguration{
+				Enabled:         true,
+				AppMetadataFlag: "mfa_required",
+				GracePeriod:     -time.Hour,
+			},
+			err: `conf: MFA policy grace period must not be negative, was -1h0m0s`,
+		},
+
+		{
+			val: &WebAuthnFactorTypeConfiguration{},
+		},
+		{
+			val: &WebAuthnFactorTypeConfiguration{
+				AttestationPreference: "direct",
+				AllowedAAGUIDs:        []string{"cb69481e-8ff7-4039-93ec-0a2729a154a8"},
+				DeniedAAGUIDs:         []string{"ee882879-721c-4913-9775-3dfcce97072a"},
+				MetadataPath:          metadataPath,
+			},
+		},
+		{
+			val: &WebAuthnFactorTypeConfiguration{
+				AttestationPreference: "direct",
+				AllowedAAGUIDs:        []string{"cb69481e-8ff7-4039-93ec-0a2729a154a8"},
+			},
+			err: `conf: WebAuthn AAGUID lists require a metadata path`,
+		},
+		{
+			val: &WebAuthnFactorTypeConfiguration{
+				DeniedAAGUIDs: []string{"ee882879-721c-4913-9775-3dfcce97072a"},
+				MetadataPath:  metadataPath,
+			},
+			err: `conf: WebAuthn attestation preference must not be none when a metadata path is set`,
+		},
+		{
+			val: &WebAuthnFactorTypeConfiguration{AttestationPreference: "always"},
+			err: `conf: WebAuthn attestation preference must be one of none, indirect, direct or enterprise, was "always"`,
+		},
+		{
+			val: &WebAuthnFactorTypeConfiguration{AllowedAAGUIDs: []string{"yubikey"}},
+			err: `conf: WebAuthn AAGUID must be a UUID, was "yubikey"`,
+		},
+		{
+			val: &WebAuthnFactorTypeConfiguration{MetadataPath: "/nonexistent/blob.jwt"},
+			err: `conf: WebAuthn metadata path must be a readable file, was "/nonexistent/blob.jwt"`,
+		},
+
+		{
+			val: &PasswordConfiguration{MinScore: 3},
+		},
+		{
+			val: &PasswordConfiguration{MinScore: 5},
+			err: `conf: password minimum score must be between 0 and 4, was 5`,
+		},
+		{
+			val: &PasswordHashConfiguration{Algorithm: "argon2id", Argon2Memory: 19456, Argon2Time: 2, Argon2Threads: 1},
+		},
+		{
+			val: &PasswordHashConfiguration{Algorithm: "scrypt"},
+			err: `conf: password hash algorithm must be one of bcrypt or argon2id, was "scrypt"`,
+		},
+		{
+			val: &PasswordHashConfiguration{Algorithm: "bcrypt", BcryptCost: 32},
+			err: `conf: password hash bcrypt cost must be between 4 and 31, was 32`,
+		},
+		{
+			val: &PasswordHashConfiguration{Algorithm: "argon2id", Argon2Memory: 4, Argon2Time: 2, Argon2Threads: 1},
+			err: `conf: password hash argon2 parameters must be positive with at least 8 KiB of memory per thread, was m=4,t=2,p=1`,
+		},
+
+		{
+			val: &HIBPConfiguration{
+				Dataset: HIBPDatasetConfiguration{Path: "/nonexistent/pwned-passwords.txt"},
+			},
+		},
+		{
+			val: &HIBPConfiguration{
+				Enabled: true,
+				Dataset: HIBPDatasetConfiguration{Path: "configuration.go", Format: "text", Hash: "ntlm"},
+			},
+		},
+		{
+			val: &HIBPConfiguration{
+				Enabled: true,
+				Dataset: HIBPDatasetConfiguration{Path: "/nonexistent/pwned-passwords.txt", Format: "text", Hash: "sha1"},
+			},
+			err: `conf: HIBP dataset path must be a readable file, was "/nonexistent/pwned-passwords.txt"`,
+		},
+		{
+			val: &HIBPConfiguration{
+				Enabled: true,
+				Dataset: HIBPDatasetConfiguration{Path: "configuration.go", Format: "csv", Hash: "sha1"},
+			},
+			err: `conf: HIBP dataset format must be one of text or bloom, was "csv"`,
+		},
+		{
+			val: &HIBPConfiguration{
+				Enabled: true,
+				Dataset: HIBPDatasetConfiguration{Path: "configuration.go", Format: "bloom", Hash: "md5"},
+			},
+			err: `conf: HIBP dataset hash must be one of sha1 or ntlm, was "md5"`,
+		},
+
 		{
 			val: &SecurityConfiguration{
 				Captcha: CaptchaConfiguration{
@@ -948,6 +1220,41 @@ func TestMethods(t *testing.T) {
 		require.Equal(t, ([]string)(nil), got)
 	}
 
+	{
+		val := &MFAPolicyConfiguration{
+			Roles:           []string{"staff"},
+			Audiences:       []string{"admin-portal"},
+			EmailDomains:    []string{"example.com"},
+			AppMetadataFlag: "mfa_required",
+		}
+		require.False(t, val.Matches("staff", "", "", nil))
+
+		val.Enabled = true
+		require.True(t, val.Matches("staff", "authenticated", "", nil))
+		require.True(t, val.Matches("authenticated", "admin-portal", "", nil))
+		require.True(t, val.Matches("authenticated", "authenticated", "someone@EXAMPLE.com", nil))
+		require.True(t, val.Matches("authenticated", "authenticated", "", map[string]interface{}{"mfa_required": true}))
+		require.False(t, val.Matches("authenticated", "authenticated", "someone@example.org", map[string]interface{}{"mfa_required": "true"}))
+		require.False(t, val.Matches("authenticated", "authenticated", "someone@notexample.com", nil))
+	}
+
+	{
+		yubikey := uuid.Must(uuid.FromString("cb69481e-8ff7-4039-93ec-0a2729a154a8"))
+		other := uuid.Must(uuid.FromString("ee882879-721c-4913-9775-3dfcce97072a"))
+
+		val := &WebAuthnFactorTypeConfiguration{}
+		require.True(t, val.AllowsAAGUID(yubikey))
+		require.True(t, val.AllowsAAGUID(uuid.Nil))
+
+		val.DeniedAAGUIDs = []string{other.String()}
+		require.True(t, val.AllowsAAGUID(yubikey))
+		require.False(t, val.AllowsAAGUID(other))
+
+		val.AllowedAAGUIDs = []string{yubikey.String()}
+		require.True(t, val.AllowsAAGUID(yubikey))
+		require.False(t, val.AllowsAAGUID(uuid.Nil))
+	}
+
 	{
 		val := &SmsProviderConfiguration{}
 		ok := val.IsTwilioVerifyProvider()
@@ -986,6 +1293,18 @@ func TestMethods(t *testing.T) {
 		require.Equal(t, "", got)
 	}
 
+	{
+		val := &LockoutConfiguration{
+			Duration:    time.Minute,
+			MaxDuration: time.Hour,
+		}
+		require.Equal(t, time.Minute, val.LockoutDuration(0))
+		require.Equal(t, 2*time.Minute, val.LockoutDuration(1))
+		require.Equal(t, 32*time.Minute, val.LockoutDuration(5))
+		require.Equal(t, time.Hour, val.LockoutDuration(6))
+		require.Equal(t, time.Hour, val.LockoutDuration(1000))
+	}
+
 	{
 		val := &OAuthProviderConfiguration{}
 
@@ -0,0 +1,84 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// EventsConfiguration configures the delivery of events, such as users
+// signing up or signing in, to webhook endpoints. Events are written to an
+// outbox in the transaction of the change and POSTed to every endpoint,
+// signed like HTTP hooks, by a background dispatcher.
+//
+// Failed deliveries are retried with exponential backoff starting at
+// Backoff and capped at MaxBackoff. Events that fail MaxAttempts times are
+// dead-lettered until an admin replays them. Delivered and dead-lettered
+// events are deleted once they're older than Retention.
+type EventsConfiguration struct {
+	Enabled   bool     `json:"enabled" default:"false"`
+	Endpoints []string `json:"endpoints"`
+	// Secrets sign the payloads, in the format of HTTP hook secrets.
+	Secrets HTTPHookSecrets `json:"secrets"`
+
+	MaxAttempts  int           `json:"max_attempts" split_words:"true" default:"10"`
+	Backoff      time.Duration `json:"backoff" default:"30s"`
+	MaxBackoff   time.Duration `json:"max_backoff" split_words:"true" default:"1h"`
+	Timeout      time.Duration `json:"timeout" default:"5s"`
+	PollInterval time.Duration `json:"poll_interval" split_words:"true" default:"5s"`
+	BatchSize    int           `json:"batch_size" split_words:"true" default:"100"`
+	Retention    time.Duration `json:"retention" default:"720h"`
+}
+
+func (c *EventsConfiguration) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.Endpoints) == 0 {
+		return errors.New("conf: events require at least one endpoint when enabled")
+	}
+
+	if len(c.Secrets) == 0 {
+		return errors.New("conf: events require at least one secret to sign payloads when enabled")
+	}
+
+	for _, endpoint := range c.Endpoints {
+		if err := validateEventsEndpoint(endpoint, c.Secrets); err != nil {
+			return fmt.Errorf("conf: invalid events endpoint %q: %w", endpoint, err)
+		}
+	}
+
+	if c.MaxAttempts <= 0 || c.BatchSize <= 0 {
+		return errors.New("conf: events max attempts and batch size must be positive")
+	}
+
+	if c.Backoff <= 0 || c.MaxBackoff < c.Backoff || c.Timeout <= 0 || c.PollInterval <= 0 {
+		return errors.New("conf: events backoff, timeout and poll interval must be positive, and max backoff at least the backoff")
+	}
+
+	if c.Retention <= 0 {
+		return errors.New("conf: events retention must be positive")
+	}
+
+	return nil
+}
+
+// validateEventsEndpoint applies the rules of HTTP hook URIs to an events
+// endpoint, which can't be a Postgres function: it's either HTTPS, or HTTP
+// on localhost for development.
+func validateEventsEndpoint(endpoint string, secrets HTTPHookSecrets) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+
+	if scheme := strings.ToLower(u.Scheme); scheme != "https" && scheme != "http" {
+		return errors.New("only HTTPS endpoints, or HTTP endpoints on localhost, are supported")
+	}
+
+	point := &ExtensibilityPointConfiguration{URI: endpoint, HTTPHookSecrets: secrets}
+	return point.ValidateExtensibilityPoint()
+}
@@ -0,0 +1,47 @@
+package conf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventsValidate(t *testing.T) {
+	valid := func() *EventsConfiguration {
+		return &EventsConfiguration{
+			Enabled:      true,
+			Endpoints:    []string{"https://crm.example.com/events", "http://localhost:8080/events"},
+			Secrets:      HTTPHookSecrets{"v1,whsec_aWxpa2VzdXBhYmFzZXZlcnltdWNoYW5kaWhvcGV5b3Vkb3Rvbw=="},
+			MaxAttempts:  10,
+			Backoff:      30 * time.Second,
+			MaxBackoff:   time.Hour,
+			Timeout:      5 * time.Second,
+			PollInterval: 5 * time.Second,
+			BatchSize:    100,
+			Retention:    720 * time.Hour,
+		}
+	}
+
+	require.NoError(t, (&EventsConfiguration{}).Validate())
+	require.NoError(t, valid().Validate())
+
+	for desc, update := range map[string]func(*EventsConfiguration){
+		"no endpoints":      func(c *EventsConfiguration) { c.Endpoints = nil },
+		"no secrets":        func(c *EventsConfiguration) { c.Secrets = nil },
+		"invalid secret":    func(c *EventsConfiguration) { c.Secrets = HTTPHookSecrets{"secret"} },
+		"http endpoint":     func(c *EventsConfiguration) { c.Endpoints = []string{"http://crm.example.com/events"} },
+		"postgres endpoint": func(c *EventsConfiguration) { c.Endpoints = []string{"pg-functions://postgres/public/events"} },
+		"no attempts":       func(c *EventsConfiguration) { c.MaxAttempts = 0 },
+		"short max backoff": func(c *EventsConfiguration) { c.MaxBackoff = time.Second },
+		"no retention":      func(c *EventsConfiguration) { c.Retention = 0 },
+	} {
+		c := valid()
+		update(c)
+		require.Error(t, c.Validate(), desc)
+	}
+
+	c := valid()
+	c.Endpoints = []string{"ftp://crm.example.com/events"}
+	require.ErrorContains(t, c.Validate(), "only HTTPS endpoints, or HTTP endpoints on localhost, are supported")
+}
@@ -0,0 +1,54 @@
+package conf
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ImpersonationConfiguration allows administrators to sign in as users to
+// reproduce their issues. Impersonation sessions can't be refreshed past
+// MaxDuration.
+//
+// Users with one of the admin roles are never impersonated unless
+// AllowAdmins is set, and neither are users with one of the DenyRoles or
+// the DenyAppMetadataFlag set to true.
+type ImpersonationConfiguration struct {
+	Enabled             bool          `json:"enabled" default:"false"`
+	MaxDuration         time.Duration `json:"max_duration" split_words:"true" default:"1h"`
+	AllowAdmins         bool          `json:"allow_admins" split_words:"true" default:"false"`
+	DenyRoles           []string      `json:"deny_roles" split_words:"true"`
+	DenyAppMetadataFlag string        `json:"deny_app_metadata_flag" split_words:"true"`
+}
+
+func (c *ImpersonationConfiguration) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.MaxDuration <= 0 {
+		return fmt.Errorf("conf: impersonation max duration must be positive, was %v", c.MaxDuration.String())
+	}
+
+	return nil
+}
+
+// Denies reports whether users with the provided attributes can't be
+// impersonated.
+func (c *ImpersonationConfiguration) Denies(role string, appMetadata map[string]interface{}, adminRoles []string) bool {
+	if !c.AllowAdmins && slices.Contains(adminRoles, role) {
+		return true
+	}
+
+	if slices.Contains(c.DenyRoles, role) {
+		return true
+	}
+
+	if c.DenyAppMetadataFlag != "" {
+		if flag, ok := appMetadata[c.DenyAppMetadataFlag].(bool); ok && flag {
+			return true
+		}
+	}
+
+	return false
+}
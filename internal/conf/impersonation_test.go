@@ -0,0 +1,42 @@
+package conf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImpersonationValidate(t *testing.T) {
+	require.NoError(t, (&ImpersonationConfiguration{}).Validate())
+	require.NoError(t, (&ImpersonationConfiguration{Enabled: true, MaxDuration: time.Hour}).Validate())
+	require.Error(t, (&ImpersonationConfiguration{Enabled: true}).Validate())
+	require.Error(t, (&ImpersonationConfiguration{Enabled: true, MaxDuration: -time.Hour}).Validate())
+}
+
+func TestImpersonationDenies(t *testing.T) {
+	adminRoles := []string{"service_role", "linkly_admin"}
+	config := &ImpersonationConfiguration{
+		Enabled:             true,
+		DenyRoles:           []string{"support"},
+		DenyAppMetadataFlag: "protected",
+	}
+
+	for _, c := range []struct {
+		role        string
+		appMetadata map[string]interface{}
+		denies      bool
+	}{
+		{role: "authenticated"},
+		{role: "linkly_admin", denies: true},
+		{role: "support", denies: true},
+		{role: "authenticated", appMetadata: map[string]interface{}{"protected": true}, denies: true},
+		{role: "authenticated", appMetadata: map[string]interface{}{"protected": "true"}},
+	} {
+		require.Equal(t, c.denies, config.Denies(c.role, c.appMetadata, adminRoles), c.role)
+	}
+
+	config.AllowAdmins = true
+	require.False(t, config.Denies("linkly_admin", nil, adminRoles))
+	require.True(t, config.Denies("support", nil, adminRoles))
+}
@@ -0,0 +1,104 @@
+package conf
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// IPAccessConfiguration holds the addresses allowed and denied access to
+// groups of routes. Global rules apply to every route, alongside the rules
+// of the route's group.
+//
+// Addresses on an allow list are never denied, so a group can be
+// restricted to a few ranges by denying 0.0.0.0/0 and ::/0 and allowing
+// those ranges. Requests from them also skip IP rate limits and captcha
+// verification.
+type IPAccessConfiguration struct {
+	Global IPAccessRules `json:"global"`
+	Admin  IPAccessRules `json:"admin"`
+	Token  IPAccessRules `json:"token"`
+	Otp    IPAccessRules `json:"otp"`
+	Signup IPAccessRules `json:"signup"`
+	Verify IPAccessRules `json:"verify"`
+}
+
+// IPAccessRules are the addresses allowed and denied access to a group of
+// routes.
+type IPAccessRules struct {
+	Allow CIDRList `json:"allow"`
+	Deny  CIDRList `json:"deny"`
+}
+
+// CIDRList is a list of IP ranges, configured as comma separated CIDRs or
+// single addresses.
+type CIDRList []netip.Prefix
+
+// Decode is used by envconfig to parse the env-config string to a CIDRList.
+func (l *CIDRList) Decode(value string) error {
+	list := CIDRList{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return fmt.Errorf("conf: %q is not an IP address or CIDR", entry)
+			}
+			list = append(list, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return fmt.Errorf("conf: %q is not an IP address or CIDR", entry)
+		}
+		list = append(list, prefix.Masked())
+	}
+
+	*l = list
+	return nil
+}
+
+// Covers returns whether every range of the other list is within one of
+// the ranges. Empty lists cover every address.
+func (l CIDRList) Covers(other CIDRList) bool {
+	if len(l) == 0 {
+		return true
+	}
+	if len(other) == 0 {
+		return false
+	}
+
+	for _, o := range other {
+		covered := false
+		for _, prefix := range l {
+			if prefix.Bits() <= o.Bits() && prefix.Contains(o.Addr()) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Contains returns whether the address is in one of the ranges.
+func (l CIDRList) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range l {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+package conf
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIDRListDecode(t *testing.T) {
+	var l CIDRList
+	require.NoError(t, l.Decode("10.0.0.0/8, 192.0.2.7,2001:db8::/32,,::ffff:198.51.100.1"))
+	require.Equal(t, CIDRList{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.7/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("198.51.100.1/32"),
+	}, l)
+
+	for addr, contains := range map[string]bool{
+		"10.1.2.3":         true,
+		"::ffff:10.1.2.3":  true,
+		"192.0.2.7":        true,
+		"192.0.2.8":        false,
+		"2001:db8::1":      true,
+		"2001:db9::1":      false,
+		"198.51.100.1":     true,
+		"::ffff:192.0.2.7": true,
+	} {
+		require.Equal(t, contains, l.Contains(netip.MustParseAddr(addr)), addr)
+	}
+
+	require.NoError(t, l.Decode(""))
+	require.Empty(t, l)
+
+	for _, value := range []string{"10.0.0.0/33", "example.com", "10.0.0.0/8,nope"} {
+		require.Error(t, l.Decode(value), value)
+	}
+}
+
+func TestCIDRListCovers(t *testing.T) {
+	var vpn CIDRList
+	require.NoError(t, vpn.Decode("10.0.0.0/8,192.0.2.7"))
+
+	for value, covers := range map[string]bool{
+		"10.8.0.0/16":           true,
+		"10.0.0.0/8,192.0.2.7":  true,
+		"192.0.2.7":             true,
+		"":                      false,
+		"0.0.0.0/0":             false,
+		"10.0.0.0/7":            false,
+		"10.8.0.0/16,192.0.2.8": false,
+	} {
+		var other CIDRList
+		require.NoError(t, other.Decode(value))
+		require.Equal(t, covers, vpn.Covers(other), value)
+	}
+
+	require.True(t, CIDRList{}.Covers(vpn))
+}
+
+func TestIPAccessConfiguration(t *testing.T) {
+	t.Setenv("GOTRUE_IP_ACCESS_TOKEN_ALLOW", "192.0.2.0/24")
+	t.Setenv("GOTRUE_IP_ACCESS_ADMIN_DENY", "0.0.0.0/0,::/0")
+
+	var c struct {
+		IPAccess IPAccessConfiguration `split_words:"true"`
+	}
+	require.NoError(t, envconfig.Process("gotrue", &c))
+	require.True(t, c.IPAccess.Token.Allow.Contains(netip.MustParseAddr("192.0.2.1")))
+	require.True(t, c.IPAccess.Admin.Deny.Contains(netip.MustParseAddr("2001:db8::1")))
+	require.Empty(t, c.IPAccess.Otp.Allow)
+}
@@ -14,6 +14,16 @@ const (
 	IntervalRateType = "interval"
 )
 
+// Backends counting rate limited events.
+const (
+	// RateLimitBackendMemory counts events in the memory of each
+	// instance.
+	RateLimitBackendMemory = "memory"
+	// RateLimitBackendPostgres counts events in the database, shared by
+	// all instances.
+	RateLimitBackendPostgres = "postgres"
+)
+
 type Rate struct {
 	Events   float64       `json:"events,omitempty"`
 	OverTime time.Duration `json:"over_time,omitempty"`
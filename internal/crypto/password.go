@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
 	"errors"
@@ -11,6 +12,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/linkly-id/auth/internal/observability"
 	"go.opentelemetry.io/otel/attribute"
@@ -43,6 +45,72 @@ const (
 // GenerateHashFromPassword.
 var PasswordHashCost = DefaultHashCost
 
+const (
+	// PasswordHashBcrypt generates bcrypt password hashes.
+	PasswordHashBcrypt = "bcrypt"
+	// PasswordHashArgon2id generates argon2id password hashes.
+	PasswordHashArgon2id = "argon2id"
+
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+// PasswordHashParams are the algorithm and cost new password hashes are
+// generated with. Passwords hashed otherwise, like imported ones, are
+// rehashed with them on sign in.
+type PasswordHashParams struct {
+	Algorithm string
+
+	BcryptCost int
+
+	Argon2Memory  uint32
+	Argon2Time    uint32
+	Argon2Threads uint8
+}
+
+func (p PasswordHashParams) withDefaults() PasswordHashParams {
+	if p.Algorithm == "" {
+		p.Algorithm = PasswordHashBcrypt
+	}
+
+	if p.BcryptCost == 0 {
+		p.BcryptCost = bcrypt.DefaultCost
+	}
+
+	if p.Argon2Memory == 0 {
+		p.Argon2Memory = 19 * 1024
+	}
+
+	if p.Argon2Time == 0 {
+		p.Argon2Time = 2
+	}
+
+	if p.Argon2Threads == 0 {
+		p.Argon2Threads = 1
+	}
+
+	return p
+}
+
+var passwordHashParams atomic.Pointer[PasswordHashParams]
+
+// SetPasswordHashParams sets the algorithm and cost of all new hashes
+// generated with GenerateFromPassword.
+func SetPasswordHashParams(params PasswordHashParams) {
+	params = params.withDefaults()
+	passwordHashParams.Store(&params)
+}
+
+// GetPasswordHashParams returns the algorithm and cost of new hashes
+// generated with GenerateFromPassword.
+func GetPasswordHashParams() PasswordHashParams {
+	if params := passwordHashParams.Load(); params != nil {
+		return *params
+	}
+
+	return PasswordHashParams{}.withDefaults()
+}
+
 var (
 	generateFromPasswordSubmittedCounter = observability.ObtainMetricCounter("gotrue_generate_from_password_submitted", "Number of submitted GenerateFromPassword hashing attempts")
 	generateFromPasswordCompletedCounter = observability.ObtainMetricCounter("gotrue_generate_from_password_completed", "Number of completed GenerateFromPassword hashing attempts")
@@ -331,6 +399,12 @@ func CompareHashAndPassword(ctx context.Context, hash, password string) error {
 		return compareHashAndPasswordArgon2(ctx, hash, password)
 	} else if strings.HasPrefix(hash, FirebaseScryptPrefix) {
 		return compareHashAndPasswordFirebaseScrypt(ctx, hash, password)
+	} else if strings.HasPrefix(hash, PBKDF2SHA256Prefix) {
+		return compareHashAndPasswordPBKDF2(ctx, hash, password)
+	} else if strings.HasPrefix(hash, SHA256CryptPrefix) || strings.HasPrefix(hash, SHA512CryptPrefix) {
+		return compareHashAndPasswordSHACrypt(ctx, hash, password)
+	} else if strings.HasPrefix(hash, PhpassPrefix) || strings.HasPrefix(hash, PhpassPhpBBPrefix) {
+		return compareHashAndPasswordPhpass(ctx, hash, password)
 	}
 
 	// assume bcrypt
@@ -358,11 +432,94 @@ func CompareHashAndPassword(ctx context.Context, hash, password string) error {
 	return err
 }
 
+// NeedsRehash reports whether the hash wasn't generated with the current
+// algorithm and cost, and the password should be hashed again when it's
+// next verified.
+func NeedsRehash(hash string) bool {
+	params := GetPasswordHashParams()
+
+	switch params.Algorithm {
+	case PasswordHashArgon2id:
+		input, err := ParseArgon2Hash(hash)
+		if err != nil {
+			return true
+		}
+
+		return input.alg != PasswordHashArgon2id ||
+			input.memory != uint64(params.Argon2Memory) ||
+			input.time != uint64(params.Argon2Time) ||
+			input.threads != uint64(params.Argon2Threads) ||
+			len(input.rawHash) != argon2KeyLen
+
+	default:
+		if strings.HasPrefix(hash, Argon2Prefix) || strings.HasPrefix(hash, FirebaseScryptPrefix) {
+			return true
+		}
+
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return true
+		}
+
+		return cost != params.BcryptCost
+	}
+}
+
+// PasswordHashPattern returns a SQL LIKE pattern matching hashes generated
+// with the current algorithm and cost.
+func PasswordHashPattern() string {
+	params := GetPasswordHashParams()
+
+	switch params.Algorithm {
+	case PasswordHashArgon2id:
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%%", argon2.Version, params.Argon2Memory, params.Argon2Time, params.Argon2Threads)
+
+	default:
+		return fmt.Sprintf("$2_$%02d$%%", params.BcryptCost)
+	}
+}
+
+func generateFromPasswordArgon2(ctx context.Context, password string, params PasswordHashParams) (string, error) {
+	attributes := []attribute.KeyValue{
+		attribute.String("alg", PasswordHashArgon2id),
+		attribute.Int64("m", int64(params.Argon2Memory)),
+		attribute.Int64("t", int64(params.Argon2Time)),
+		attribute.Int("p", int(params.Argon2Threads)),
+	}
+
+	generateFromPasswordSubmittedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	defer generateFromPasswordCompletedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	derivedKey := argon2.IDKey([]byte(password), salt, params.Argon2Time, params.Argon2Memory, params.Argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		params.Argon2Memory,
+		params.Argon2Time,
+		params.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(derivedKey),
+	), nil
+}
+
 // GenerateFromPassword generates a password hash from a
-// password, using PasswordHashCost. Context can be used to cancel the hashing
-// if the algorithm supports it.
+// password, using the algorithm and cost set with SetPasswordHashParams, or
+// the quickest bcrypt cost when PasswordHashCost is QuickHashCost. Context
+// can be used to cancel the hashing if the algorithm supports it.
 func GenerateFromPassword(ctx context.Context, password string) (string, error) {
-	hashCost := bcrypt.DefaultCost
+	params := GetPasswordHashParams()
+
+	if params.Algorithm == PasswordHashArgon2id {
+		return generateFromPasswordArgon2(ctx, password, params)
+	}
+
+	hashCost := params.BcryptCost
 
 	switch PasswordHashCost {
 	case QuickHashCost:
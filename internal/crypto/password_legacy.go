@@ -0,0 +1,455 @@
+package crypto
+
+import (
+	"context"
+	"crypto/md5" // #nosec G501 -- phpass hashes are iterated MD5
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Password hashes of other systems which can be imported, verified on sign
+// in and then rehashed with the current algorithm.
+const (
+	// PBKDF2SHA256Prefix starts Django's default password hashes.
+	PBKDF2SHA256Prefix = "pbkdf2_sha256$"
+	// SHA256CryptPrefix starts SHA-256 crypt hashes, as in /etc/shadow.
+	SHA256CryptPrefix = "$5$"
+	// SHA512CryptPrefix starts SHA-512 crypt hashes, as in /etc/shadow.
+	SHA512CryptPrefix = "$6$"
+	// PhpassPrefix starts portable phpass hashes, used by WordPress.
+	PhpassPrefix = "$P$"
+	// PhpassPhpBBPrefix starts phpass hashes generated by phpBB.
+	PhpassPhpBBPrefix = "$H$"
+)
+
+// The iteration counts of imported hashes are capped well below what their
+// formats allow, as each sign-in with a hash has to pay for them. PBKDF2
+// leaves room for Django raising its default (1,200,000 in Django 6.0) with
+// each release.
+const (
+	pbkdf2MaxIterations = 5000000
+
+	shaCryptDefaultRounds = 5000
+	shaCryptMinRounds     = 1000
+	shaCryptMaxRounds     = 1000000
+
+	phpassMinCountLog2 = 7
+	phpassMaxCountLog2 = 20
+)
+
+// itoa64 is the alphabet of the base64 variant used by crypt(3) and phpass.
+const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var ErrPBKDF2MismatchedHashAndPassword = errors.New("crypto: pbkdf2 hash and password mismatch")
+var ErrSHACryptMismatchedHashAndPassword = errors.New("crypto: sha-crypt hash and password mismatch")
+var ErrPhpassMismatchedHashAndPassword = errors.New("crypto: phpass hash and password mismatch")
+
+// pbkdf2HashRegexp https://docs.djangoproject.com/en/stable/topics/auth/passwords/#how-django-stores-passwords
+var pbkdf2HashRegexp = regexp.MustCompile(`^pbkdf2_sha256\$(?P<iterations>[0-9]+)\$(?P<salt>[^$]+)\$(?P<hash>[A-Za-z0-9+/]+={0,2})$`)
+
+// shaCryptHashRegexp https://www.akkadia.org/drepper/SHA-crypt.txt
+var shaCryptHashRegexp = regexp.MustCompile(`^\$(?P<alg>[56])\$(?:rounds=(?P<rounds>[0-9]+)\$)?(?P<salt>[^$:\n]{0,16})\$(?P<hash>[./0-9A-Za-z]+)$`)
+
+// phpassHashRegexp https://www.openwall.com/phpass/
+var phpassHashRegexp = regexp.MustCompile(`^\$(?P<alg>[PH])\$(?P<count>[./0-9A-Za-z])(?P<salt>[./0-9A-Za-z]{8})(?P<hash>[./0-9A-Za-z]{22})$`)
+
+type PBKDF2HashInput struct {
+	iterations uint64
+	salt       []byte
+	rawHash    []byte
+}
+
+type SHACryptHashInput struct {
+	alg    string
+	rounds uint64
+	salt   []byte
+	hash   string
+}
+
+type PhpassHashInput struct {
+	alg   string
+	count uint64
+	salt  []byte
+	hash  string
+}
+
+// ParsePBKDF2Hash parses Django's pbkdf2_sha256$<iterations>$<salt>$<hash>
+// format.
+func ParsePBKDF2Hash(hash string) (*PBKDF2HashInput, error) {
+	submatch := pbkdf2HashRegexp.FindStringSubmatchIndex(hash)
+	if submatch == nil {
+		return nil, errors.New("crypto: incorrect pbkdf2 hash format")
+	}
+
+	iterations := string(pbkdf2HashRegexp.ExpandString(nil, "$iterations", hash, submatch))
+	salt := string(pbkdf2HashRegexp.ExpandString(nil, "$salt", hash, submatch))
+	hashB64 := string(pbkdf2HashRegexp.ExpandString(nil, "$hash", hash, submatch))
+
+	iter, err := strconv.ParseUint(iterations, 10, 31)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: pbkdf2 hash has invalid iterations %q %w", iterations, err)
+	}
+	if iter == 0 {
+		return nil, errors.New("crypto: pbkdf2 hash has invalid iterations=0")
+	}
+	if iter > pbkdf2MaxIterations {
+		return nil, fmt.Errorf("crypto: pbkdf2 hash has iterations=%d above the maximum of %d", iter, pbkdf2MaxIterations)
+	}
+
+	rawHash, err := base64.StdEncoding.DecodeString(hashB64)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: pbkdf2 hash has invalid base64 in the hash section %w", err)
+	}
+	if len(rawHash) == 0 {
+		return nil, errors.New("crypto: pbkdf2 hash is empty")
+	}
+
+	input := &PBKDF2HashInput{
+		iterations: iter,
+		salt:       []byte(salt),
+		rawHash:    rawHash,
+	}
+
+	return input, nil
+}
+
+// ParseSHACryptHash parses the $5$ (SHA-256) and $6$ (SHA-512) crypt(3)
+// formats, with an optional rounds=<rounds>$ parameter.
+func ParseSHACryptHash(hash string) (*SHACryptHashInput, error) {
+	submatch := shaCryptHashRegexp.FindStringSubmatchIndex(hash)
+	if submatch == nil {
+		return nil, errors.New("crypto: incorrect sha-crypt hash format")
+	}
+
+	alg := string(shaCryptHashRegexp.ExpandString(nil, "$alg", hash, submatch))
+	rounds := string(shaCryptHashRegexp.ExpandString(nil, "$rounds", hash, submatch))
+	salt := string(shaCryptHashRegexp.ExpandString(nil, "$salt", hash, submatch))
+	encoded := string(shaCryptHashRegexp.ExpandString(nil, "$hash", hash, submatch))
+
+	input := &SHACryptHashInput{
+		rounds: shaCryptDefaultRounds,
+		salt:   []byte(salt),
+		hash:   encoded,
+	}
+
+	switch alg {
+	case "5":
+		input.alg = "sha256"
+		if len(encoded) != 43 {
+			return nil, fmt.Errorf("crypto: sha-crypt hash has invalid length %d for sha256", len(encoded))
+		}
+
+	case "6":
+		input.alg = "sha512"
+		if len(encoded) != 86 {
+			return nil, fmt.Errorf("crypto: sha-crypt hash has invalid length %d for sha512", len(encoded))
+		}
+	}
+
+	if rounds != "" {
+		r, err := strconv.ParseUint(rounds, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("crypto: sha-crypt hash has invalid rounds parameter %q %w", rounds, err)
+		}
+		if r > shaCryptMaxRounds {
+			return nil, fmt.Errorf("crypto: sha-crypt hash has rounds=%d above the maximum of %d", r, shaCryptMaxRounds)
+		}
+
+		// crypt(3) uses the minimum for lower rounds rather than rejecting them
+		input.rounds = max(r, shaCryptMinRounds)
+	}
+
+	return input, nil
+}
+
+// ParsePhpassHash parses the portable phpass format, $P$ or $H$ followed by
+// the log2 of the iteration count, an 8 character salt and the hash.
+func ParsePhpassHash(hash string) (*PhpassHashInput, error) {
+	submatch := phpassHashRegexp.FindStringSubmatchIndex(hash)
+	if submatch == nil {
+		return nil, errors.New("crypto: incorrect phpass hash format")
+	}
+
+	alg := string(phpassHashRegexp.ExpandString(nil, "$alg", hash, submatch))
+	count := string(phpassHashRegexp.ExpandString(nil, "$count", hash, submatch))
+	salt := string(phpassHashRegexp.ExpandString(nil, "$salt", hash, submatch))
+	encoded := string(phpassHashRegexp.ExpandString(nil, "$hash", hash, submatch))
+
+	countLog2 := strings.IndexByte(itoa64, count[0])
+	if countLog2 < phpassMinCountLog2 || countLog2 > phpassMaxCountLog2 {
+		return nil, fmt.Errorf("crypto: phpass hash has iteration count 2^%d out of range 2^%d to 2^%d", countLog2, phpassMinCountLog2, phpassMaxCountLog2)
+	}
+
+	input := &PhpassHashInput{
+		alg:   alg,
+		count: uint64(1) << countLog2,
+		salt:  []byte(salt),
+		hash:  encoded,
+	}
+
+	return input, nil
+}
+
+func compareHashAndPasswordPBKDF2(ctx context.Context, hash, password string) error {
+	input, err := ParsePBKDF2Hash(hash)
+	if err != nil {
+		return err
+	}
+
+	attributes := []attribute.KeyValue{
+		attribute.String("alg", "pbkdf2_sha256"),
+		attribute.Int64("iterations", int64(input.iterations)),
+		attribute.Int("len", len(input.rawHash)),
+	} // #nosec G115
+
+	var match bool
+	compareHashAndPasswordSubmittedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	defer func() {
+		attributes = append(attributes, attribute.Bool("match", match))
+		compareHashAndPasswordCompletedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	}()
+
+	derivedKey := pbkdf2.Key([]byte(password), input.salt, int(input.iterations), len(input.rawHash), sha256.New) // #nosec G115
+
+	match = subtle.ConstantTimeCompare(derivedKey, input.rawHash) == 1
+	if !match {
+		return ErrPBKDF2MismatchedHashAndPassword
+	}
+
+	return nil
+}
+
+func compareHashAndPasswordSHACrypt(ctx context.Context, hash, password string) error {
+	input, err := ParseSHACryptHash(hash)
+	if err != nil {
+		return err
+	}
+
+	attributes := []attribute.KeyValue{
+		attribute.String("alg", "sha-crypt"),
+		attribute.String("hash", input.alg),
+		attribute.Int64("rounds", int64(input.rounds)),
+	} // #nosec G115
+
+	var match bool
+	compareHashAndPasswordSubmittedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	defer func() {
+		attributes = append(attributes, attribute.Bool("match", match))
+		compareHashAndPasswordCompletedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	}()
+
+	var derived string
+	switch input.alg {
+	case "sha256":
+		derived = shaCrypt(sha256.New, shaCrypt256Order, []byte(password), input.salt, input.rounds)
+
+	case "sha512":
+		derived = shaCrypt(sha512.New, shaCrypt512Order, []byte(password), input.salt, input.rounds)
+	}
+
+	match = subtle.ConstantTimeCompare([]byte(derived), []byte(input.hash)) == 1
+	if !match {
+		return ErrSHACryptMismatchedHashAndPassword
+	}
+
+	return nil
+}
+
+func compareHashAndPasswordPhpass(ctx context.Context, hash, password string) error {
+	input, err := ParsePhpassHash(hash)
+	if err != nil {
+		return err
+	}
+
+	attributes := []attribute.KeyValue{
+		attribute.String("alg", "phpass"),
+		attribute.Int64("count", int64(input.count)),
+	} // #nosec G115
+
+	var match bool
+	compareHashAndPasswordSubmittedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	defer func() {
+		attributes = append(attributes, attribute.Bool("match", match))
+		compareHashAndPasswordCompletedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	}()
+
+	derived := phpass([]byte(password), input.salt, input.count)
+
+	match = subtle.ConstantTimeCompare([]byte(derived), []byte(input.hash)) == 1
+	if !match {
+		return ErrPhpassMismatchedHashAndPassword
+	}
+
+	return nil
+}
+
+// shaCrypt256Order and shaCrypt512Order are the orders the digest bytes are
+// encoded in, three at a time, by SHA-crypt.
+var shaCrypt256Order = []int{
+	0, 10, 20, 21, 1, 11, 12, 22, 2, 3, 13, 23, 24, 4, 14,
+	15, 25, 5, 6, 16, 26, 27, 7, 17, 18, 28, 8, 9, 19, 29,
+	31, 30,
+}
+
+var shaCrypt512Order = []int{
+	0, 21, 42, 22, 43, 1, 44, 2, 23, 3, 24, 45, 25, 46, 4,
+	47, 5, 26, 6, 27, 48, 28, 49, 7, 50, 8, 29, 9, 30, 51,
+	31, 52, 10, 53, 11, 32, 12, 33, 54, 34, 55, 13, 56, 14, 35,
+	15, 36, 57, 37, 58, 16, 59, 17, 38, 18, 39, 60, 40, 61, 19,
+	62, 20, 41, 63,
+}
+
+// shaCrypt implements https://www.akkadia.org/drepper/SHA-crypt.txt and
+// returns the encoded hash, without the prefix, rounds and salt.
+func shaCrypt(newHash func() hash.Hash, order []int, password, salt []byte, rounds uint64) string {
+	h := newHash()
+	size := h.Size()
+
+	// digest B
+	h.Write(password)
+	h.Write(salt)
+	h.Write(password)
+	b := h.Sum(nil)
+
+	// digest A
+	h.Reset()
+	h.Write(password)
+	h.Write(salt)
+	for n := len(password); n > 0; n -= size {
+		h.Write(b[:min(n, size)])
+	}
+	for n := len(password); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(password)
+		}
+	}
+	a := h.Sum(nil)
+
+	// byte sequence P
+	h.Reset()
+	for range len(password) {
+		h.Write(password)
+	}
+	dp := h.Sum(nil)
+	p := make([]byte, 0, len(password))
+	for n := len(password); n > 0; n -= size {
+		p = append(p, dp[:min(n, size)]...)
+	}
+
+	// byte sequence S
+	h.Reset()
+	for range 16 + int(a[0]) {
+		h.Write(salt)
+	}
+	ds := h.Sum(nil)
+	s := make([]byte, 0, len(salt))
+	for n := len(salt); n > 0; n -= size {
+		s = append(s, ds[:min(n, size)]...)
+	}
+
+	c := a
+	for i := uint64(0); i < rounds; i++ {
+		h.Reset()
+
+		if i&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(c)
+		}
+
+		if i%3 != 0 {
+			h.Write(s)
+		}
+
+		if i%7 != 0 {
+			h.Write(p)
+		}
+
+		if i&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(p)
+		}
+
+		c = h.Sum(c[:0])
+	}
+
+	var out strings.Builder
+
+	i := 0
+	for ; i+3 <= len(order); i += 3 {
+		encode64(&out, uint(c[order[i]])<<16|uint(c[order[i+1]])<<8|uint(c[order[i+2]]), 4)
+	}
+
+	switch len(order) - i {
+	case 2:
+		encode64(&out, uint(c[order[i]])<<8|uint(c[order[i+1]]), 3)
+
+	case 1:
+		encode64(&out, uint(c[order[i]]), 2)
+	}
+
+	return out.String()
+}
+
+// phpass implements the portable hashes of https://www.openwall.com/phpass/
+// and returns the encoded hash, without the prefix, count and salt.
+func phpass(password, salt []byte, count uint64) string {
+	h := md5.New() // #nosec G401
+
+	h.Write(salt)
+	h.Write(password)
+	sum := h.Sum(nil)
+
+	for i := uint64(0); i < count; i++ {
+		h.Reset()
+		h.Write(sum)
+		h.Write(password)
+		sum = h.Sum(sum[:0])
+	}
+
+	var out strings.Builder
+
+	for i := 0; i < len(sum); i += 3 {
+		value := uint(sum[i])
+		n := 2
+
+		if i+1 < len(sum) {
+			value |= uint(sum[i+1]) << 8
+			n++
+		}
+
+		if i+2 < len(sum) {
+			value |= uint(sum[i+2]) << 16
+			n++
+		}
+
+		encode64(&out, value, n)
+	}
+
+	return out.String()
+}
+
+// encode64 writes n characters of the crypt(3) base64 encoding of value,
+// least significant 6 bits first.
+func encode64(out *strings.Builder, value uint, n int) {
+	for ; n > 0; n-- {
+		out.WriteByte(itoa64[value&0x3f])
+		value >>= 6
+	}
+}
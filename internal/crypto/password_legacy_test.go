@@ -0,0 +1,187 @@
+package crypto
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPBKDF2(t *testing.T) {
+	examples := []struct {
+		hash     string
+		password string
+	}{
+		{
+			hash:     "pbkdf2_sha256$600000$pepper$mv1sS9UgLylDvl2iiyvJZZkaGLIVzAVetfcYRaRVQ4I=",
+			password: "correct horse battery staple",
+		},
+		{
+			hash:     "pbkdf2_sha256$1000$seasalt$JgZryXe2Ga8ysg6XbzkLpTdyPQrHqsinbL9BnnhgX4A=",
+			password: "lètmein",
+		},
+		{
+			// Django 6.0 default
+			hash:     "pbkdf2_sha256$1200000$pepper$O1YzeXLQvBNz4fS+enX6WwBldvM9QSkq7OhJPMzNBxc=",
+			password: "correct horse battery staple",
+		},
+	}
+
+	for _, example := range examples {
+		assert.NoError(t, CompareHashAndPassword(context.Background(), example.hash, example.password))
+		assert.ErrorIs(t, CompareHashAndPassword(context.Background(), example.hash, example.password+"1"), ErrPBKDF2MismatchedHashAndPassword)
+	}
+
+	negativeExamples := []string{
+		// sha1
+		"pbkdf2_sha1$1000$seasalt$JgZryXe2Ga8ysg6XbzkLpTdyPQrHqsinbL9BnnhgX4A=",
+		// iterations=0
+		"pbkdf2_sha256$0$seasalt$JgZryXe2Ga8ysg6XbzkLpTdyPQrHqsinbL9BnnhgX4A=",
+		// iterations larger than 31 bits
+		"pbkdf2_sha256$4294967296$seasalt$JgZryXe2Ga8ysg6XbzkLpTdyPQrHqsinbL9BnnhgX4A=",
+		// iterations above the maximum
+		"pbkdf2_sha256$5000001$seasalt$JgZryXe2Ga8ysg6XbzkLpTdyPQrHqsinbL9BnnhgX4A=",
+		// salt empty
+		"pbkdf2_sha256$1000$$JgZryXe2Ga8ysg6XbzkLpTdyPQrHqsinbL9BnnhgX4A=",
+		// hash not Base64
+		"pbkdf2_sha256$1000$seasalt$JgZryXe2Ga8ysg6XbzkLpTdyPQrHqsinbL9BnnhgX4A",
+		// hash empty
+		"pbkdf2_sha256$1000$seasalt$",
+	}
+
+	for _, example := range negativeExamples {
+		_, err := ParsePBKDF2Hash(example)
+		assert.Error(t, err, example)
+	}
+}
+
+func TestSHACrypt(t *testing.T) {
+	// vectors from https://www.akkadia.org/drepper/SHA-crypt.txt
+
+	examples := []struct {
+		hash     string
+		password string
+	}{
+		{
+			hash:     "$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+			password: "Hello world!",
+		},
+		{
+			hash:     "$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA",
+			password: "Hello world!",
+		},
+		{
+			hash:     "$5$rounds=77777$short$JiO1O3ZpDAxGJeaDIuqCoEFysAe1mZNJRs3pw0KQRd/",
+			password: "we have a short salt string but not a short password",
+		},
+		{
+			hash:     "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+			password: "Hello world!",
+		},
+		{
+			hash:     "$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.",
+			password: "Hello world!",
+		},
+		{
+			hash:     "$6$rounds=1400$anotherlongsalts$5FGyu8c4BZDX4wJgs0Un26YOw2XibT5eTkHF1I1aP3QqStoJI9BHD2YPJYsAjEePVGUyBjdZxcNqMWlrrbIOC.",
+			password: "Hello world!",
+		},
+		{
+			hash:     "$6$salt$r6qPcj2UeIkfklWHvleGJk8OKTInFYR/fxyuwcC656IWiZBpIFZ9.hMRG2ZQnnyMFrKOe461f9iT9Ljn0wJ5l.",
+			password: "",
+		},
+		{
+			// rounds below the minimum use the minimum
+			hash:     "$5$rounds=10$roundstoolow$yfvwcWrQ8l/K0DAWyuPMDNHpIVlTQebY9l/gL972bIC",
+			password: "the minimum number is still observed",
+		},
+		{
+			hash:     "$6$rounds=10$roundstoolow$kUMsbe306n21p9R.FRkW3IGn.S9NPN0x50YhH1xhLsPuWGsUSklZt58jaTfF4ZEQpyUNGc0dqbpBYYBaHHrsX.",
+			password: "the minimum number is still observed",
+		},
+	}
+
+	for _, example := range examples {
+		assert.NoError(t, CompareHashAndPassword(context.Background(), example.hash, example.password), example.hash)
+		assert.ErrorIs(t, CompareHashAndPassword(context.Background(), example.hash, example.password+"1"), ErrSHACryptMismatchedHashAndPassword)
+	}
+
+	negativeExamples := []string{
+		// unknown algorithm
+		"$7$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+		// sha512 hash length with sha256
+		"$5$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+		// sha256 hash length with sha512
+		"$6$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+		// rounds above the maximum
+		"$5$rounds=1000001$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+		// rounds larger than 32 bits
+		"$5$rounds=4294967296$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+		// salt longer than 16 characters
+		"$5$saltstringsaltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+		// hash not crypt Base64
+		"$5$saltstring$5B8vYYiY+CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+	}
+
+	for _, example := range negativeExamples {
+		_, err := ParseSHACryptHash(example)
+		assert.Error(t, err, example)
+	}
+}
+
+func TestPhpass(t *testing.T) {
+	examples := []struct {
+		hash     string
+		password string
+	}{
+		{
+			// from the phpass test suite
+			hash:     "$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0",
+			password: "test12345",
+		},
+		{
+			// WordPress
+			hash:     "$P$BwrdPrs1xkn5PgQjCJXw2XIdepJWtJ0",
+			password: "correct horse battery staple",
+		},
+		{
+			// phpBB
+			hash:     "$H$9phpBBsallZlTqkfpGjzDL501Pc1xW.",
+			password: "correct horse battery staple",
+		},
+	}
+
+	for _, example := range examples {
+		assert.NoError(t, CompareHashAndPassword(context.Background(), example.hash, example.password), example.hash)
+		assert.ErrorIs(t, CompareHashAndPassword(context.Background(), example.hash, example.password+"1"), ErrPhpassMismatchedHashAndPassword)
+	}
+
+	negativeExamples := []string{
+		// iteration count below 2^7
+		"$P$4IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0",
+		// iteration count above 2^20
+		"$P$JIQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0",
+		// salt too short
+		"$P$9IQRaTwmeRo7ud9Fh4E2PdI0S3r.L0",
+		// hash too long
+		"$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0.",
+		// portable hashes only
+		"$Q$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0",
+	}
+
+	for _, example := range negativeExamples {
+		_, err := ParsePhpassHash(example)
+		assert.Error(t, err, example)
+	}
+}
+
+func TestNeedsRehashLegacy(t *testing.T) {
+	for _, hash := range []string{
+		"pbkdf2_sha256$1000$seasalt$JgZryXe2Ga8ysg6XbzkLpTdyPQrHqsinbL9BnnhgX4A=",
+		"$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+		"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+		"$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0",
+	} {
+		assert.True(t, NeedsRehash(hash), hash)
+	}
+}
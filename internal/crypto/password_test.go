@@ -176,3 +176,44 @@ func TestBcrypt(t *testing.T) {
 		assert.Error(t, CompareHashAndPassword(context.Background(), example, "test"))
 	}
 }
+
+func TestNeedsRehash(t *testing.T) {
+	defer SetPasswordHashParams(PasswordHashParams{})
+
+	bcrypt10 := "$2y$10$srNl09aPtc2qr.0Vl.NtjekJRt/NxRxYQm3qd3OvfcKsJgVnr6.Ve"
+	bcrypt04 := "$2y$04$mIJxfrCaEI3GukZe11CiXublhEFanu5.ododkll1WphfSp6pn4zIu"
+	argon2i := "$argon2i$v=19$m=16,t=2,p=1$bGJRWThNOHJJTVBSdHl2dQ$NfEnUOuUpb7F2fQkgFUG4g"
+	fbscrypt := "$fbscrypt$v=1,n=14,r=8,p=1,ss=Bw==,sk=ou9tdYTGyYm8kuR6Dt0Bp0kDuAYoXrK16mbZO4yGwAn3oLspjnN0/c41v8xZnO1n14J3MjKj1b2g6AUCAlFwMw==$C0sHCg9ek77hsg==$zKVTMvnWVw5BBOZNUdnsalx4c4c7y/w7IS5p6Ut2+CfEFFlz37J9huyQfov4iizN8dbjvEJlM5tQaJP84+hfTw=="
+
+	SetPasswordHashParams(PasswordHashParams{})
+	assert.Equal(t, "$2_$10$%", PasswordHashPattern())
+	assert.False(t, NeedsRehash(bcrypt10))
+	assert.True(t, NeedsRehash(bcrypt04))
+	assert.True(t, NeedsRehash(argon2i))
+	assert.True(t, NeedsRehash(fbscrypt))
+
+	SetPasswordHashParams(PasswordHashParams{
+		Algorithm:     PasswordHashArgon2id,
+		Argon2Memory:  16,
+		Argon2Time:    2,
+		Argon2Threads: 1,
+	})
+	assert.Equal(t, "$argon2id$v=19$m=16,t=2,p=1$%", PasswordHashPattern())
+	assert.True(t, NeedsRehash(bcrypt10))
+	assert.True(t, NeedsRehash(argon2i))
+
+	hash, err := GenerateFromPassword(context.Background(), "test")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=16,t=2,p=1$"))
+	assert.NoError(t, CompareHashAndPassword(context.Background(), hash, "test"))
+	assert.Error(t, CompareHashAndPassword(context.Background(), hash, "test1"))
+	assert.False(t, NeedsRehash(hash))
+
+	SetPasswordHashParams(PasswordHashParams{
+		Algorithm:     PasswordHashArgon2id,
+		Argon2Memory:  32,
+		Argon2Time:    2,
+		Argon2Threads: 1,
+	})
+	assert.True(t, NeedsRehash(hash))
+}
@@ -168,6 +168,9 @@ type HookRecorder struct {
 	mux                  *http.ServeMux
 	BeforeUserCreated    *Hook
 	AfterUserCreated     *Hook
+	BeforeSignIn         *Hook
+	BeforeTokenRefresh   *Hook
+	BeforeUserUpdated    *Hook
 	CustomizeAccessToken *Hook
 	MFAVerification      *Hook
 	PasswordVerification *Hook
@@ -180,6 +183,9 @@ func NewHookRecorder() *HookRecorder {
 		mux:                  http.NewServeMux(),
 		BeforeUserCreated:    NewHook(v0hooks.BeforeUserCreated),
 		AfterUserCreated:     NewHook(v0hooks.AfterUserCreated),
+		BeforeSignIn:         NewHook(v0hooks.BeforeSignIn),
+		BeforeTokenRefresh:   NewHook(v0hooks.BeforeTokenRefresh),
+		BeforeUserUpdated:    NewHook(v0hooks.BeforeUserUpdated),
 		CustomizeAccessToken: NewHook(v0hooks.CustomizeAccessToken),
 		MFAVerification:      NewHook(v0hooks.MFAVerification),
 		PasswordVerification: NewHook(v0hooks.PasswordVerification),
@@ -196,6 +202,15 @@ func NewHookRecorder() *HookRecorder {
 		case v0hooks.AfterUserCreated:
 			o.AfterUserCreated.ServeHTTP(w, r)
 
+		case v0hooks.BeforeSignIn:
+			o.BeforeSignIn.ServeHTTP(w, r)
+
+		case v0hooks.BeforeTokenRefresh:
+			o.BeforeTokenRefresh.ServeHTTP(w, r)
+
+		case v0hooks.BeforeUserUpdated:
+			o.BeforeUserUpdated.ServeHTTP(w, r)
+
 		case v0hooks.CustomizeAccessToken:
 			o.CustomizeAccessToken.ServeHTTP(w, r)
 
@@ -230,6 +245,9 @@ func (o *HookRecorder) Register(
 	}
 	set(&hookCfg.BeforeUserCreated, v0hooks.BeforeUserCreated)
 	set(&hookCfg.AfterUserCreated, v0hooks.AfterUserCreated)
+	set(&hookCfg.BeforeSignIn, v0hooks.BeforeSignIn)
+	set(&hookCfg.BeforeTokenRefresh, v0hooks.BeforeTokenRefresh)
+	set(&hookCfg.BeforeUserUpdated, v0hooks.BeforeUserUpdated)
 	set(&hookCfg.CustomAccessToken, v0hooks.CustomizeAccessToken)
 	set(&hookCfg.MFAVerificationAttempt, v0hooks.MFAVerification)
 	set(&hookCfg.PasswordVerificationAttempt, v0hooks.PasswordVerification)
@@ -0,0 +1,202 @@
+// Package events delivers the events of the outbox to the events endpoints.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/hooks/hookshttp"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// Payload is the body POSTed to the events endpoints. It's signed like the
+// payloads of HTTP hooks, with the event's ID as the webhook-id so that
+// endpoints can ignore events delivered more than once.
+type Payload struct {
+	ID        uuid.UUID        `json:"id"`
+	Type      models.EventType `json:"type"`
+	UserID    uuid.UUID        `json:"user_id"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      models.JSONMap   `json:"data"`
+}
+
+// Dispatcher polls the outbox for pending events and POSTs them to every
+// endpoint. Events are delivered at least once: a failed delivery is
+// retried on every endpoint, including those it was delivered to.
+type Dispatcher struct {
+	db     *storage.Connection
+	config atomic.Pointer[conf.EventsConfiguration]
+	client *http.Client
+	log    *logrus.Entry
+}
+
+// NewDispatcher creates a dispatcher of the events in the database.
+func NewDispatcher(db *storage.Connection, config *conf.EventsConfiguration) *Dispatcher {
+	d := &Dispatcher{
+		db:     db,
+		client: &http.Client{},
+		log:    logrus.WithField("component", "events"),
+	}
+	d.config.Store(config)
+	return d
+}
+
+// SetConfig replaces the configuration when it's reloaded.
+func (d *Dispatcher) SetConfig(config *conf.EventsConfiguration) {
+	d.config.Store(config)
+}
+
+// Run dispatches pending events every poll interval while events are
+// enabled, until the context is done. Batches are dispatched back to back
+// while the outbox has a backlog.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		config := d.config.Load()
+		wait := max(config.PollInterval, time.Second)
+
+		if config.Enabled {
+			n, err := d.Dispatch(ctx)
+			if err != nil {
+				d.log.WithError(err).Error("error dispatching events")
+			} else if n >= config.BatchSize {
+				wait = 0
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Dispatch delivers a batch of pending events, returning how many it
+// attempted to deliver.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	config := d.config.Load()
+	db := d.db.WithContext(ctx)
+
+	// the lease covers delivering the whole batch, after which events
+	// claimed by a dispatcher that stopped are delivered again
+	lease := config.Timeout * time.Duration(len(config.Endpoints)*config.BatchSize)
+
+	var events []*models.Event
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		events, terr = models.ClaimEvents(tx, time.Now(), lease, config.BatchSize)
+		return terr
+	}); err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		if ctx.Err() != nil {
+			// the events are delivered once their lease expires
+			return 0, ctx.Err()
+		}
+
+		deliveryErr := d.deliver(ctx, config, event)
+
+		now := time.Now()
+		var err error
+		if deliveryErr == nil {
+			err = event.MarkDelivered(db, now)
+		} else {
+			err = event.MarkFailed(db, deliveryErr.Error(), now.Add(Backoff(config, event.Attempts+1)), config.MaxAttempts)
+
+			log := d.log.WithError(deliveryErr).WithFields(logrus.Fields{
+				"event_id":   event.ID,
+				"event_type": event.Type,
+				"attempts":   event.Attempts,
+			})
+			if event.Status == models.EventDead {
+				log.Error("event dead-lettered after failed deliveries")
+			} else {
+				log.Warn("event delivery failed")
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(events), nil
+}
+
+// deliver POSTs the event to every endpoint, returning the errors of those
+// it couldn't be delivered to.
+func (d *Dispatcher) deliver(ctx context.Context, config *conf.EventsConfiguration, event *models.Event) error {
+	body, err := json.Marshal(&Payload{
+		ID:        event.ID,
+		Type:      event.Type,
+		UserID:    event.UserID,
+		CreatedAt: event.CreatedAt,
+		Data:      event.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, endpoint := range config.Endpoints {
+		if err := d.post(ctx, config, endpoint, event.ID, body); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) post(ctx context.Context, config *conf.EventsConfiguration, endpoint string, id uuid.UUID, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if err := hookshttp.SignRequest(req, config.Secrets, id, time.Now(), body); err != nil {
+		return err
+	}
+
+	rsp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(rsp.Body, 64*1024))
+
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", rsp.StatusCode)
+	}
+
+	return nil
+}
+
+// Backoff returns how long to wait before retrying an event that failed the
+// number of attempts, doubling from the configured backoff up to the
+// maximum.
+func Backoff(config *conf.EventsConfiguration, attempts int) time.Duration {
+	backoff := config.Backoff
+	for i := 1; i < attempts && backoff < config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, config.MaxBackoff)
+}
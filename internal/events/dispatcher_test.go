@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/stretchr/testify/require"
+
+	standardwebhooks "github.com/standard-webhooks/standard-webhooks/libraries/go"
+)
+
+const testSecret = "whsec_aWxpa2VzdXBhYmFzZXZlcnltdWNoYW5kaWhvcGV5b3Vkb3Rvbw=="
+
+func TestBackoff(t *testing.T) {
+	config := &conf.EventsConfiguration{
+		Backoff:    30 * time.Second,
+		MaxBackoff: 5 * time.Minute,
+	}
+
+	for attempts, exp := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		4:  4 * time.Minute,
+		5:  5 * time.Minute,
+		50: 5 * time.Minute,
+	} {
+		require.Equal(t, exp, Backoff(config, attempts), attempts)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	wh, err := standardwebhooks.NewWebhook(testSecret)
+	require.NoError(t, err)
+
+	received := []*Payload{}
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, wh.Verify(body, r.Header))
+
+		payload := &Payload{}
+		require.NoError(t, json.Unmarshal(body, payload))
+		require.Equal(t, payload.ID.String(), r.Header.Get("webhook-id"))
+		received = append(received, payload)
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ok.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	config := &conf.EventsConfiguration{
+		Endpoints: []string{ok.URL},
+		Secrets:   conf.HTTPHookSecrets{"v1," + testSecret},
+		Timeout:   time.Second,
+	}
+	d := NewDispatcher(nil, config)
+
+	event := &models.Event{
+		ID:        uuid.Must(uuid.NewV4()),
+		Type:      models.UserCreatedEvent,
+		UserID:    uuid.Must(uuid.NewV4()),
+		Data:      models.JSONMap{"user": map[string]interface{}{"email": "user@example.com"}},
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	require.NoError(t, d.deliver(context.Background(), config, event))
+	require.Len(t, received, 1)
+	require.Equal(t, event.ID, received[0].ID)
+	require.Equal(t, event.Type, received[0].Type)
+	require.Equal(t, event.UserID, received[0].UserID)
+	require.True(t, event.CreatedAt.Equal(received[0].CreatedAt))
+	require.Equal(t, "user@example.com", received[0].Data["user"].(map[string]interface{})["email"])
+
+	// the event is delivered to every endpoint, failing when any fails
+	config.Endpoints = []string{failing.URL, ok.URL}
+	err = d.deliver(context.Background(), config, event)
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), failing.URL))
+	require.Contains(t, err.Error(), "503")
+	require.Len(t, received, 2)
+}
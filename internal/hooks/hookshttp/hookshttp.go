@@ -132,15 +132,6 @@ func (o *Dispatcher) runHTTPHook(
 		} else {
 			hookLog.Infof("invocation attempt: %d", i)
 		}
-		msgID := uuid.Must(uuid.NewV4())
-		currentTime := time.Now()
-		signatureList, err := generateSignatures(
-			hookConfig.HTTPHookSecrets, msgID, currentTime, inputPayload)
-		if err != nil {
-			return nil, apierrors.NewInternalServerError(
-				"Error generating signatures: %v", err).WithInternalError(err)
-		}
-
 		req, err := http.NewRequestWithContext(
 			ctx, http.MethodPost, requestURL, bytes.NewBuffer(inputPayload))
 		if err != nil {
@@ -149,9 +140,10 @@ func (o *Dispatcher) runHTTPHook(
 		}
 
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("webhook-id", msgID.String())
-		req.Header.Set("webhook-timestamp", fmt.Sprintf("%d", currentTime.Unix()))
-		req.Header.Set("webhook-signature", strings.Join(signatureList, ", "))
+		if err := SignRequest(req, hookConfig.HTTPHookSecrets, uuid.Must(uuid.NewV4()), time.Now(), inputPayload); err != nil {
+			return nil, apierrors.NewInternalServerError(
+				"Error generating signatures: %v", err).WithInternalError(err)
+		}
 		// By default, Go Client sets encoding to gzip, which does not carry a content length header.
 		req.Header.Set("Accept-Encoding", "identity")
 
@@ -249,6 +241,28 @@ func (o *Dispatcher) runHTTPHook(
 		"Service currently unavailable due to hook")
 }
 
+// SignRequest signs the payload of the request with the secrets, setting the
+// webhook-id, webhook-timestamp and webhook-signature headers of the
+// Standard Webhooks scheme that HTTP hooks use.
+func SignRequest(
+	req *http.Request,
+	secrets []string,
+	msgID uuid.UUID,
+	currentTime time.Time,
+	inputPayload []byte,
+) error {
+	signatureList, err := generateSignatures(
+		secrets, msgID, currentTime, inputPayload)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("webhook-id", msgID.String())
+	req.Header.Set("webhook-timestamp", fmt.Sprintf("%d", currentTime.Unix()))
+	req.Header.Set("webhook-signature", strings.Join(signatureList, ", "))
+	return nil
+}
+
 func generateSignatures(
 	secrets []string,
 	msgID uuid.UUID,
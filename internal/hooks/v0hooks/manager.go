@@ -62,6 +62,12 @@ func configByName(
 		return &cfg.BeforeUserCreated, true
 	case AfterUserCreated:
 		return &cfg.AfterUserCreated, true
+	case BeforeSignIn:
+		return &cfg.BeforeSignIn, true
+	case BeforeTokenRefresh:
+		return &cfg.BeforeTokenRefresh, true
+	case BeforeUserUpdated:
+		return &cfg.BeforeUserUpdated, true
 	default:
 		return nil, false
 	}
@@ -146,6 +152,30 @@ func (o *Manager) invokeHook(
 		}
 		return o.dispatch(
 			r.Context(), &o.config.Hook.AfterUserCreated, conn, input, output)
+
+	case *BeforeSignInInput:
+		if _, ok := output.(*BeforeSignInOutput); !ok {
+			return apierrors.NewInternalServerError(
+				"output should be *hooks.BeforeSignInOutput")
+		}
+		return o.dispatch(
+			r.Context(), &o.config.Hook.BeforeSignIn, conn, input, output)
+
+	case *BeforeTokenRefreshInput:
+		if _, ok := output.(*BeforeTokenRefreshOutput); !ok {
+			return apierrors.NewInternalServerError(
+				"output should be *hooks.BeforeTokenRefreshOutput")
+		}
+		return o.dispatch(
+			r.Context(), &o.config.Hook.BeforeTokenRefresh, conn, input, output)
+
+	case *BeforeUserUpdatedInput:
+		if _, ok := output.(*BeforeUserUpdatedOutput); !ok {
+			return apierrors.NewInternalServerError(
+				"output should be *hooks.BeforeUserUpdatedOutput")
+		}
+		return o.dispatch(
+			r.Context(), &o.config.Hook.BeforeUserUpdated, conn, input, output)
 	}
 }
 
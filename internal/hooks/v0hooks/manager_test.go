@@ -288,6 +288,142 @@ func TestHooks(t *testing.T) {
 				end; $$ language plpgsql;`,
 		},
 
+		{
+			desc: "pass - before_sign_in",
+			setup: func() {
+				globalCfg.Hook.BeforeSignIn =
+					conf.ExtensibilityPointConfiguration{
+						URI: `pg-functions://postgres/auth/` +
+							`v0hooks_test_before_sign_in`,
+						HookName: `"auth"."v0hooks_test_before_sign_in"`,
+					}
+			},
+			req: NewBeforeSignInInput(httpReq, &models.User{}, "password"),
+			res: &BeforeSignInOutput{},
+			exp: &BeforeSignInOutput{},
+			sql: `
+				create or replace function
+					v0hooks_test_before_sign_in(input jsonb)
+				returns json as $$
+				begin
+					return '{}'::jsonb;
+				end; $$ language plpgsql;`,
+		},
+
+		{
+			desc: "pass - before_sign_in reject with message",
+			setup: func() {
+				globalCfg.Hook.BeforeSignIn =
+					conf.ExtensibilityPointConfiguration{
+						URI: `pg-functions://postgres/auth/` +
+							`v0hooks_test_before_sign_in_reject_msg`,
+						HookName: `"auth"."v0hooks_test_before_sign_in_reject_msg"`,
+					}
+			},
+			req: NewBeforeSignInInput(httpReq, &models.User{}, "password"),
+			res: &BeforeSignInOutput{},
+			exp: &BeforeSignInOutput{Decision: "reject", Message: "test case"},
+			sql: `
+				create or replace function
+					v0hooks_test_before_sign_in_reject_msg(input jsonb)
+				returns json as $$
+				begin
+					return '{"decision": "reject", "message": "test case"}'::jsonb;
+				end; $$ language plpgsql;`,
+		},
+
+		{
+			desc: "pass - before_token_refresh",
+			setup: func() {
+				globalCfg.Hook.BeforeTokenRefresh =
+					conf.ExtensibilityPointConfiguration{
+						URI: `pg-functions://postgres/auth/` +
+							`v0hooks_test_before_token_refresh`,
+						HookName: `"auth"."v0hooks_test_before_token_refresh"`,
+					}
+			},
+			req: NewBeforeTokenRefreshInput(httpReq, &models.User{}, testUUID),
+			res: &BeforeTokenRefreshOutput{},
+			exp: &BeforeTokenRefreshOutput{},
+			sql: `
+				create or replace function
+					v0hooks_test_before_token_refresh(input jsonb)
+				returns json as $$
+				begin
+					return '{}'::jsonb;
+				end; $$ language plpgsql;`,
+		},
+
+		{
+			desc: "pass - before_token_refresh reject with message",
+			setup: func() {
+				globalCfg.Hook.BeforeTokenRefresh =
+					conf.ExtensibilityPointConfiguration{
+						URI: `pg-functions://postgres/auth/` +
+							`v0hooks_test_before_token_refresh_reject_msg`,
+						HookName: `"auth"."v0hooks_test_before_token_refresh_reject_msg"`,
+					}
+			},
+			req: NewBeforeTokenRefreshInput(httpReq, &models.User{}, testUUID),
+			res: &BeforeTokenRefreshOutput{},
+			exp: &BeforeTokenRefreshOutput{Decision: "reject", Message: "test case"},
+			sql: `
+				create or replace function
+					v0hooks_test_before_token_refresh_reject_msg(input jsonb)
+				returns json as $$
+				begin
+					return '{"decision": "reject", "message": "test case"}'::jsonb;
+				end; $$ language plpgsql;`,
+		},
+
+		{
+			desc: "pass - before_user_updated",
+			setup: func() {
+				globalCfg.Hook.BeforeUserUpdated =
+					conf.ExtensibilityPointConfiguration{
+						URI: `pg-functions://postgres/auth/` +
+							`v0hooks_test_before_user_updated`,
+						HookName: `"auth"."v0hooks_test_before_user_updated"`,
+					}
+			},
+			req: NewBeforeUserUpdatedInput(httpReq, &models.User{}, &UserUpdate{
+				UserMetadata: M{"plan": "free"},
+			}),
+			res: &BeforeUserUpdatedOutput{},
+			exp: &BeforeUserUpdatedOutput{},
+			sql: `
+				create or replace function
+					v0hooks_test_before_user_updated(input jsonb)
+				returns json as $$
+				begin
+					return '{}'::jsonb;
+				end; $$ language plpgsql;`,
+		},
+
+		{
+			desc: "pass - before_user_updated reject with message",
+			setup: func() {
+				globalCfg.Hook.BeforeUserUpdated =
+					conf.ExtensibilityPointConfiguration{
+						URI: `pg-functions://postgres/auth/` +
+							`v0hooks_test_before_user_updated_reject_msg`,
+						HookName: `"auth"."v0hooks_test_before_user_updated_reject_msg"`,
+					}
+			},
+			req: NewBeforeUserUpdatedInput(httpReq, &models.User{}, &UserUpdate{
+				UserMetadata: M{"plan": "free"},
+			}),
+			res: &BeforeUserUpdatedOutput{},
+			exp: &BeforeUserUpdatedOutput{Decision: "reject", Message: "test case"},
+			sql: `
+				create or replace function
+					v0hooks_test_before_user_updated_reject_msg(input jsonb)
+				returns json as $$
+				begin
+					return '{"decision": "reject", "message": "test case"}'::jsonb;
+				end; $$ language plpgsql;`,
+		},
+
 		{
 			desc: "pass - after_user_created",
 			setup: func() {
@@ -420,6 +556,24 @@ func TestHooks(t *testing.T) {
 			res:    M{},
 			errStr: "500: output should be *hooks.AfterUserCreatedOutput",
 		},
+		{
+			desc:   "fail - before_sign_in - invalid output type",
+			req:    &BeforeSignInInput{},
+			res:    M{},
+			errStr: "500: output should be *hooks.BeforeSignInOutput",
+		},
+		{
+			desc:   "fail - before_token_refresh - invalid output type",
+			req:    &BeforeTokenRefreshInput{},
+			res:    M{},
+			errStr: "500: output should be *hooks.BeforeTokenRefreshOutput",
+		},
+		{
+			desc:   "fail - before_user_updated - invalid output type",
+			req:    &BeforeUserUpdatedInput{},
+			res:    M{},
+			errStr: "500: output should be *hooks.BeforeUserUpdatedOutput",
+		},
 
 		// fail - invalid query
 		{
@@ -543,6 +697,15 @@ func TestConfig(t *testing.T) {
 			AfterUserCreated: conf.ExtensibilityPointConfiguration{
 				URI: "http:localhost/" + string(AfterUserCreated),
 			},
+			BeforeSignIn: conf.ExtensibilityPointConfiguration{
+				URI: "http:localhost/" + string(BeforeSignIn),
+			},
+			BeforeTokenRefresh: conf.ExtensibilityPointConfiguration{
+				URI: "http:localhost/" + string(BeforeTokenRefresh),
+			},
+			BeforeUserUpdated: conf.ExtensibilityPointConfiguration{
+				URI: "http:localhost/" + string(BeforeUserUpdated),
+			},
 		},
 	}
 	cfg := &globalCfg.Hook
@@ -571,6 +734,12 @@ func TestConfig(t *testing.T) {
 			name: BeforeUserCreated, exp: &cfg.BeforeUserCreated},
 		{cfg: cfg, ok: true,
 			name: AfterUserCreated, exp: &cfg.AfterUserCreated},
+		{cfg: cfg, ok: true,
+			name: BeforeSignIn, exp: &cfg.BeforeSignIn},
+		{cfg: cfg, ok: true,
+			name: BeforeTokenRefresh, exp: &cfg.BeforeTokenRefresh},
+		{cfg: cfg, ok: true,
+			name: BeforeUserUpdated, exp: &cfg.BeforeUserUpdated},
 	}
 	for _, test := range tests {
 		t.Run(string(test.name), func(t *testing.T) {
@@ -23,6 +23,9 @@ const (
 	PasswordVerification Name = "password-verification"
 	BeforeUserCreated    Name = "before-user-created"
 	AfterUserCreated     Name = "after-user-created"
+	BeforeSignIn         Name = "before-sign-in"
+	BeforeTokenRefresh   Name = "before-token-refresh"
+	BeforeUserUpdated    Name = "before-user-updated"
 )
 
 const (
@@ -30,8 +33,11 @@ const (
 )
 
 const (
-	DefaultMFAHookRejectionMessage      = "Further MFA verification attempts will be rejected."
-	DefaultPasswordHookRejectionMessage = "Further password verification attempts will be rejected."
+	DefaultMFAHookRejectionMessage          = "Further MFA verification attempts will be rejected."
+	DefaultPasswordHookRejectionMessage     = "Further password verification attempts will be rejected."
+	DefaultSignInHookRejectionMessage       = "Sign in was rejected."
+	DefaultTokenRefreshHookRejectionMessage = "Token refresh was rejected."
+	DefaultUserUpdatedHookRejectionMessage  = "User update was rejected."
 )
 
 type Metadata struct {
@@ -91,6 +97,85 @@ func NewAfterUserCreatedInput(
 
 type AfterUserCreatedOutput struct{}
 
+type BeforeSignInInput struct {
+	Metadata             *Metadata    `json:"metadata"`
+	User                 *models.User `json:"user"`
+	AuthenticationMethod string       `json:"authentication_method"`
+}
+
+func NewBeforeSignInInput(
+	r *http.Request,
+	user *models.User,
+	authenticationMethod string,
+) *BeforeSignInInput {
+	return &BeforeSignInInput{
+		Metadata:             NewMetadata(r, BeforeSignIn),
+		User:                 user,
+		AuthenticationMethod: authenticationMethod,
+	}
+}
+
+type BeforeSignInOutput struct {
+	Decision string `json:"decision"`
+	Message  string `json:"message"`
+}
+
+type BeforeTokenRefreshInput struct {
+	Metadata  *Metadata    `json:"metadata"`
+	User      *models.User `json:"user"`
+	SessionID uuid.UUID    `json:"session_id"`
+}
+
+func NewBeforeTokenRefreshInput(
+	r *http.Request,
+	user *models.User,
+	sessionID uuid.UUID,
+) *BeforeTokenRefreshInput {
+	return &BeforeTokenRefreshInput{
+		Metadata:  NewMetadata(r, BeforeTokenRefresh),
+		User:      user,
+		SessionID: sessionID,
+	}
+}
+
+type BeforeTokenRefreshOutput struct {
+	Decision string `json:"decision"`
+	Message  string `json:"message"`
+}
+
+// UserUpdate holds the changes requested to a user. Only the fields being
+// changed are set, and the password itself is never sent to hooks.
+type UserUpdate struct {
+	Email        string                 `json:"email,omitempty"`
+	Phone        string                 `json:"phone,omitempty"`
+	Password     bool                   `json:"password,omitempty"`
+	UserMetadata map[string]interface{} `json:"user_metadata,omitempty"`
+	AppMetadata  map[string]interface{} `json:"app_metadata,omitempty"`
+}
+
+type BeforeUserUpdatedInput struct {
+	Metadata *Metadata    `json:"metadata"`
+	User     *models.User `json:"user"`
+	Update   *UserUpdate  `json:"update"`
+}
+
+func NewBeforeUserUpdatedInput(
+	r *http.Request,
+	user *models.User,
+	update *UserUpdate,
+) *BeforeUserUpdatedInput {
+	return &BeforeUserUpdatedInput{
+		Metadata: NewMetadata(r, BeforeUserUpdated),
+		User:     user,
+		Update:   update,
+	}
+}
+
+type BeforeUserUpdatedOutput struct {
+	Decision string `json:"decision"`
+	Message  string `json:"message"`
+}
+
 // TODO(joel): Move this to phone package
 type SMS struct {
 	OTP     string `json:"otp,omitempty"`
@@ -109,6 +194,14 @@ type AccessTokenClaims struct {
 	AuthenticationMethodReference []models.AMREntry      `json:"amr,omitempty"`
 	SessionId                     string                 `json:"session_id,omitempty"`
 	IsAnonymous                   bool                   `json:"is_anonymous"`
+	Restriction                   string                 `json:"restriction,omitempty"`
+	Actor                         *ActorClaims           `json:"act,omitempty"`
+}
+
+// ActorClaims identify the administrator acting as the user in an
+// impersonation session, as in RFC 8693
+type ActorClaims struct {
+	Subject string `json:"sub"`
 }
 
 type MFAVerificationAttemptInput struct {
@@ -18,6 +18,7 @@ type Mailer interface {
 	MagicLinkMail(r *http.Request, user *models.User, otp, referrerURL string, externalURL *url.URL) error
 	EmailChangeMail(r *http.Request, user *models.User, otpNew, otpCurrent, referrerURL string, externalURL *url.URL) error
 	ReauthenticateMail(r *http.Request, user *models.User, otp string) error
+	MFAEmailMail(r *http.Request, user *models.User, email, otp string) error
 	GetEmailActionLink(user *models.User, actionType, referrerURL string, externalURL *url.URL) (string, error)
 }
 
@@ -35,6 +36,9 @@ type EmailData struct {
 	SiteURL         string `json:"site_url"`
 	TokenNew        string `json:"token_new"`
 	TokenHashNew    string `json:"token_hash_new"`
+	// Email is the address to send to when it differs from the user's
+	// email, as is the case for MFA email factors.
+	Email string `json:"email,omitempty"`
 }
 
 // NewMailer returns a new gotrue mailer
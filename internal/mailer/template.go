@@ -63,6 +63,7 @@ const (
 	EmailChangeCurrentVerification = "email_change_current"
 	EmailChangeNewVerification     = "email_change_new"
 	ReauthenticationVerification   = "reauthentication"
+	MFAEmailVerification           = "mfa_email"
 )
 
 const defaultInviteMail = `<h2>You have been invited</h2>
@@ -100,6 +101,10 @@ const defaultReauthenticateMail = `<h2>Confirm reauthentication</h2>
 
 <p>Enter the code: {{ .Token }}</p>`
 
+const defaultMFAEmailMail = `<h2>Your verification code</h2>
+
+<p>Enter the code: {{ .Token }}</p>`
+
 func (m *TemplateMailer) Headers(messageType string) map[string][]string {
 	originalHeaders := m.Config.SMTP.NormalizedHeaders()
 
@@ -223,6 +228,28 @@ func (m *TemplateMailer) ReauthenticateMail(r *http.Request, user *models.User,
 	)
 }
 
+// MFAEmailMail sends an MFA challenge code to the address of an email
+// factor, which can differ from the user's email
+func (m *TemplateMailer) MFAEmailMail(r *http.Request, user *models.User, email, otp string) error {
+	data := map[string]interface{}{
+		"SiteURL": m.Config.SiteURL,
+		"Email":   email,
+		"Token":   otp,
+		"Data":    user.UserMetaData,
+	}
+
+	return m.Mailer.Mail(
+		r.Context(),
+		email,
+		withDefault(m.Config.MFA.Email.Subject, "Your verification code"),
+		m.Config.MFA.Email.Template,
+		defaultMFAEmailMail,
+		data,
+		m.Headers(MFAEmailVerification),
+		MFAEmailVerification,
+	)
+}
+
 // EmailChangeMail sends an email change confirmation mail to a user
 func (m *TemplateMailer) EmailChangeMail(r *http.Request, user *models.User, otpNew, otpCurrent, referrerURL string, externalURL *url.URL) error {
 	type Email struct {
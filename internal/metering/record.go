@@ -14,6 +14,7 @@ const (
 	LoginTypeSSO       LoginType = "sso"
 	LoginTypeOAuth     LoginType = "oauth"
 	LoginTypeWeb3      LoginType = "web3"
+	LoginTypePasskey   LoginType = "passkey"
 	LoginTypeImplicit  LoginType = "implicit"
 	LoginTypeOIDC      LoginType = "oidc"
 	LoginTypeOTP       LoginType = "otp"
@@ -30,9 +31,11 @@ const (
 	ProviderPhone = "phone"
 
 	// MFA providers
-	ProviderMFATOTP     = "totp"
-	ProviderMFAPhone    = "phone"
-	ProviderMFAWebAuthn = "webauthn"
+	ProviderMFATOTP         = "totp"
+	ProviderMFAPhone        = "phone"
+	ProviderMFAEmail        = "email"
+	ProviderMFAWebAuthn     = "webauthn"
+	ProviderMFARecoveryCode = "recovery_code"
 
 	// SSO providers
 	ProviderSAML = "saml"
@@ -0,0 +1,228 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/crypto"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// AdminAPIKeyPrefix starts every admin API key, telling them apart from
+// JWTs in the Authorization header.
+const AdminAPIKeyPrefix = "lk_admin_"
+
+// adminAPIKeySecretLength is the number of random characters in an admin
+// API key after its prefix.
+const adminAPIKeySecretLength = 40
+
+// adminAPIKeyDisplayLength is the number of characters of a key kept in
+// plain text to recognize it.
+const adminAPIKeyDisplayLength = len(AdminAPIKeyPrefix) + 4
+
+// Scopes of the admin API granted to admin API keys.
+const (
+	AdminScopeAuditRead         = "audit:read"
+	AdminScopeUsersRead         = "users:read"
+	AdminScopeUsersWrite        = "users:write"
+	AdminScopeUsersImpersonate  = "users:impersonate"
+	AdminScopeUsersRoles        = "users:roles"
+	AdminScopeUsersSecrets      = "users:secrets"
+	AdminScopeSSORead           = "sso:read"
+	AdminScopeSSOWrite          = "sso:write"
+	AdminScopeOAuthClientsRead  = "oauth_clients:read"
+	AdminScopeOAuthClientsWrite = "oauth_clients:write"
+	AdminScopeRateLimitsRead    = "rate_limits:read"
+	AdminScopeRateLimitsWrite   = "rate_limits:write"
+	AdminScopeAdminAPIKeysRead  = "api_keys:read"
+	AdminScopeAdminAPIKeysWrite = "api_keys:write"
+	AdminScopeEventsRead        = "events:read"
+	AdminScopeEventsWrite       = "events:write"
+	AdminScopeCaptchaBypass     = "captcha:bypass"
+)
+
+// AdminScopes are all the scopes of the admin API.
+var AdminScopes = []string{
+	AdminScopeAuditRead,
+	AdminScopeUsersRead,
+	AdminScopeUsersWrite,
+	AdminScopeUsersImpersonate,
+	AdminScopeUsersRoles,
+	AdminScopeUsersSecrets,
+	AdminScopeSSORead,
+	AdminScopeSSOWrite,
+	AdminScopeOAuthClientsRead,
+	AdminScopeOAuthClientsWrite,
+	AdminScopeRateLimitsRead,
+	AdminScopeRateLimitsWrite,
+	AdminScopeAdminAPIKeysRead,
+	AdminScopeAdminAPIKeysWrite,
+	AdminScopeEventsRead,
+	AdminScopeEventsWrite,
+	AdminScopeCaptchaBypass,
+}
+
+// AdminAPIKey grants access to the scopes of the admin API, optionally
+// until it expires and only from some IP ranges. Only a hash of the key is
+// stored.
+type AdminAPIKey struct {
+	ID           uuid.UUID          `json:"id" db:"id"`
+	Name         string             `json:"name" db:"name"`
+	KeyHash      string             `json:"-" db:"key_hash"`
+	KeyPrefix    string             `json:"key_prefix" db:"key_prefix"`
+	Scopes       string             `json:"-" db:"scopes"`
+	AllowedCIDRs string             `json:"-" db:"allowed_cidrs"`
+	CreatedAt    time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at" db:"updated_at"`
+	ExpiresAt    *time.Time         `json:"expires_at" db:"expires_at"`
+	LastUsedAt   *time.Time         `json:"last_used_at" db:"last_used_at"`
+	LastUsedIP   storage.NullString `json:"last_used_ip" db:"last_used_ip"`
+}
+
+type AdminAPIKeyNotFoundError struct{}
+
+func (e AdminAPIKeyNotFoundError) Error() string {
+	return "Admin API key not found"
+}
+
+func (AdminAPIKey) TableName() string {
+	tableName := "admin_api_keys"
+	return tableName
+}
+
+func hashAdminAPIKey(key string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+}
+
+// IsAdminAPIKey returns whether the bearer token is an admin API key rather
+// than a JWT.
+func IsAdminAPIKey(token string) bool {
+	return strings.HasPrefix(token, AdminAPIKeyPrefix)
+}
+
+// ValidateAdminScopes checks that the scopes are scopes of the admin API.
+func ValidateAdminScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(AdminScopes, scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+
+	return nil
+}
+
+// NewAdminAPIKey creates an admin API key with the scopes, usable from the
+// IP ranges when there are any, returning it along with the key in plain
+// text. This is the only time the key is available in plain text.
+func NewAdminAPIKey(name string, scopes, allowedCIDRs []string, expiresAt *time.Time) (*AdminAPIKey, string, error) {
+	if err := ValidateAdminScopes(scopes); err != nil {
+		return nil, "", err
+	}
+
+	var cidrs conf.CIDRList
+	if err := cidrs.Decode(strings.Join(allowedCIDRs, ",")); err != nil {
+		return nil, "", err
+	}
+
+	ranges := make([]string, 0, len(cidrs))
+	for _, prefix := range cidrs {
+		ranges = append(ranges, prefix.String())
+	}
+
+	key := AdminAPIKeyPrefix + crypto.SecureAlphanumeric(adminAPIKeySecretLength)
+	now := time.Now()
+
+	return &AdminAPIKey{
+		ID:           uuid.Must(uuid.NewV4()),
+		Name:         name,
+		KeyHash:      hashAdminAPIKey(key),
+		KeyPrefix:    key[:adminAPIKeyDisplayLength],
+		Scopes:       strings.Join(scopes, ","),
+		AllowedCIDRs: strings.Join(ranges, ","),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		ExpiresAt:    expiresAt,
+	}, key, nil
+}
+
+// GetScopes returns the scopes of the key as a slice
+func (k *AdminAPIKey) GetScopes() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// HasScope returns whether the key grants the scope.
+func (k *AdminAPIKey) HasScope(scope string) bool {
+	return slices.Contains(k.GetScopes(), scope)
+}
+
+// GetAllowedCIDRs returns the IP ranges the key can be used from, which
+// are empty when it can be used from anywhere.
+func (k *AdminAPIKey) GetAllowedCIDRs() conf.CIDRList {
+	var cidrs conf.CIDRList
+	// the ranges were validated when the key was created
+	_ = cidrs.Decode(k.AllowedCIDRs)
+	return cidrs
+}
+
+// IsExpired returns whether the key can no longer be used.
+func (k *AdminAPIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// FindAdminAPIKeyByKey finds the admin API key matching the key in plain
+// text, whether it's expired or not.
+func FindAdminAPIKeyByKey(tx *storage.Connection, key string) (*AdminAPIKey, error) {
+	return findAdminAPIKey(tx, "key_hash = ?", hashAdminAPIKey(key))
+}
+
+// FindAdminAPIKeyByID finds an admin API key by its ID.
+func FindAdminAPIKeyByID(tx *storage.Connection, id uuid.UUID) (*AdminAPIKey, error) {
+	return findAdminAPIKey(tx, "id = ?", id)
+}
+
+func findAdminAPIKey(tx *storage.Connection, query string, args ...interface{}) (*AdminAPIKey, error) {
+	key := &AdminAPIKey{}
+	if err := tx.Q().Where(query, args...).First(key); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AdminAPIKeyNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding admin API key")
+	}
+
+	return key, nil
+}
+
+// FindAdminAPIKeys returns all admin API keys, most recently created first.
+func FindAdminAPIKeys(tx *storage.Connection) ([]*AdminAPIKey, error) {
+	keys := []*AdminAPIKey{}
+	if err := tx.Q().Order("created_at desc").All(&keys); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return keys, nil
+		}
+		return nil, errors.Wrap(err, "error finding admin API keys")
+	}
+
+	return keys, nil
+}
+
+// Touch records that the key was used from the IP address.
+func (k *AdminAPIKey) Touch(tx *storage.Connection, ipAddress string) error {
+	now := time.Now()
+	k.LastUsedAt = &now
+	k.LastUsedIP = storage.NullString(ipAddress)
+	return tx.UpdateOnly(k, "last_used_at", "last_used_ip", "updated_at")
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAdminAPIKey(t *testing.T) {
+	key, plaintext, err := NewAdminAPIKey("backoffice", []string{AdminScopeUsersRead, AdminScopeAuditRead}, []string{"10.0.0.1/8", "192.0.2.7"}, nil)
+	require.NoError(t, err)
+
+	require.True(t, IsAdminAPIKey(plaintext))
+	require.True(t, strings.HasPrefix(plaintext, key.KeyPrefix))
+	require.NotContains(t, key.KeyHash, plaintext)
+	require.Equal(t, hashAdminAPIKey(plaintext), key.KeyHash)
+
+	require.Equal(t, []string{AdminScopeUsersRead, AdminScopeAuditRead}, key.GetScopes())
+	require.True(t, key.HasScope(AdminScopeUsersRead))
+	require.False(t, key.HasScope(AdminScopeUsersWrite))
+
+	require.Equal(t, "10.0.0.0/8,192.0.2.7/32", key.AllowedCIDRs)
+	require.True(t, key.GetAllowedCIDRs().Contains(netip.MustParseAddr("10.1.2.3")))
+	require.False(t, key.GetAllowedCIDRs().Contains(netip.MustParseAddr("192.0.2.8")))
+
+	require.False(t, key.IsExpired(time.Now()))
+	expiresAt := time.Now()
+	key.ExpiresAt = &expiresAt
+	require.True(t, key.IsExpired(expiresAt))
+
+	_, other, err := NewAdminAPIKey("backoffice", []string{AdminScopeUsersRead}, nil, nil)
+	require.NoError(t, err)
+	require.NotEqual(t, plaintext, other)
+
+	require.False(t, IsAdminAPIKey("eyJhbGciOiJIUzI1NiJ9.e30.sig"))
+}
+
+func TestNewAdminAPIKeyInvalid(t *testing.T) {
+	for name, c := range map[string]struct {
+		scopes, cidrs []string
+	}{
+		"no scopes":     {nil, nil},
+		"unknown scope": {[]string{"users:delete"}, nil},
+		"invalid cidr":  {[]string{AdminScopeUsersRead}, []string{"10.0.0.0/40"}},
+	} {
+		_, _, err := NewAdminAPIKey("backoffice", c.scopes, c.cidrs, nil)
+		require.Error(t, err, name)
+	}
+}
@@ -27,6 +27,8 @@ const (
 	UserSignedUpAction              AuditAction = "user_signedup"
 	UserInvitedAction               AuditAction = "user_invited"
 	UserDeletedAction               AuditAction = "user_deleted"
+	UsersImportedAction             AuditAction = "users_imported"
+	UsersExportedAction             AuditAction = "users_exported"
 	UserModifiedAction              AuditAction = "user_modified"
 	UserRecoveryRequestedAction     AuditAction = "user_recovery_requested"
 	UserReauthenticateAction        AuditAction = "user_reauthenticate_requested"
@@ -45,6 +47,17 @@ const (
 	UpdateFactorAction              AuditAction = "factor_updated"
 	MFACodeLoginAction              AuditAction = "mfa_code_login"
 	IdentityUnlinkAction            AuditAction = "identity_unlinked"
+	UserLockedAction                AuditAction = "user_locked"
+	UserUnlockedAction              AuditAction = "user_unlocked"
+	TrustDeviceAction               AuditAction = "trusted_device_created"
+	RevokeTrustedDeviceAction       AuditAction = "trusted_device_revoked"
+	RateLimitClearedAction          AuditAction = "rate_limit_cleared"
+	AdminAPIKeyCreatedAction        AuditAction = "admin_api_key_created"
+	AdminAPIKeyDeletedAction        AuditAction = "admin_api_key_deleted"
+	AdminAPIKeyUsedAction           AuditAction = "admin_api_key_used"
+	UserImpersonatedAction          AuditAction = "user_impersonated"
+	ImpersonatedLoginAction         AuditAction = "impersonated_login"
+	EventsReplayedAction            AuditAction = "events_replayed"
 
 	account       auditLogType = "account"
 	team          auditLogType = "team"
@@ -58,9 +71,18 @@ var ActionLogTypeMap = map[AuditAction]auditLogType{
 	LoginAction:                     account,
 	LogoutAction:                    account,
 	InviteAcceptedAction:            account,
+	ImpersonatedLoginAction:         account,
 	UserSignedUpAction:              team,
 	UserInvitedAction:               team,
 	UserDeletedAction:               team,
+	UsersImportedAction:             team,
+	UsersExportedAction:             team,
+	RateLimitClearedAction:          team,
+	AdminAPIKeyCreatedAction:        team,
+	AdminAPIKeyDeletedAction:        team,
+	AdminAPIKeyUsedAction:           team,
+	UserImpersonatedAction:          team,
+	EventsReplayedAction:            team,
 	TokenRevokedAction:              token,
 	TokenRefreshedAction:            token,
 	UserModifiedAction:              user,
@@ -68,6 +90,8 @@ var ActionLogTypeMap = map[AuditAction]auditLogType{
 	UserConfirmationRequestedAction: user,
 	UserRepeatedSignUpAction:        user,
 	UserUpdatePasswordAction:        user,
+	UserLockedAction:                user,
+	UserUnlockedAction:              user,
 	GenerateRecoveryCodesAction:     user,
 	EnrollFactorAction:              factor,
 	UnenrollFactorAction:            factor,
@@ -76,6 +100,8 @@ var ActionLogTypeMap = map[AuditAction]auditLogType{
 	DeleteFactorAction:              factor,
 	UpdateFactorAction:              factor,
 	MFACodeLoginAction:              factor,
+	TrustDeviceAction:               factor,
+	RevokeTrustedDeviceAction:       factor,
 	DeleteRecoveryCodesAction:       recoveryCodes,
 }
 
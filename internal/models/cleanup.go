@@ -40,6 +40,10 @@ func NewCleanup(config *conf.GlobalConfiguration) *Cleanup {
 	tableFlowStates := FlowState{}.TableName()
 	tableMFAChallenges := Challenge{}.TableName()
 	tableMFAFactors := Factor{}.TableName()
+	tableLoginLockouts := LoginLockout{}.TableName()
+	tablePasskeyChallenges := PasskeyChallenge{}.TableName()
+	tableTrustedDevices := TrustedDevice{}.TableName()
+	tableEvents := Event{}.TableName()
 
 	c := &Cleanup{}
 
@@ -58,8 +62,38 @@ func NewCleanup(config *conf.GlobalConfiguration) *Cleanup {
 		fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '24 hours' limit 100 for update skip locked);", tableFlowStates, tableFlowStates),
 		fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '24 hours' limit 100 for update skip locked);", tableMFAChallenges, tableMFAChallenges),
 		fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '24 hours' and status = 'unverified' limit 100 for update skip locked);", tableMFAFactors, tableMFAFactors),
+		fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '24 hours' limit 100 for update skip locked);", tablePasskeyChallenges, tablePasskeyChallenges),
+		fmt.Sprintf("delete from %q where id in (select id from %q where expires_at < now() - interval '24 hours' limit 100 for update skip locked);", tableTrustedDevices, tableTrustedDevices),
 	)
 
+	if config.Security.Lockout.Enabled {
+		// lockout state is only useful until the cooldown has passed
+		// since the last failed attempt and the last lockout expired
+		cooldownSeconds := int(max(config.Security.Lockout.Cooldown, config.Security.Lockout.AttemptWindow).Seconds())
+
+		c.cleanupStatements = append(c.cleanupStatements,
+			fmt.Sprintf("delete from %q where id in (select id from %q where updated_at < now() - interval '%d seconds' and (locked_until is null or locked_until < now()) limit 100 for update skip locked);", tableLoginLockouts, tableLoginLockouts, cooldownSeconds),
+		)
+	}
+
+	if config.Events.Retention > 0 {
+		// events carry a snapshot of the user, so they aren't kept once
+		// there's nothing left to deliver
+		retentionSeconds := int(config.Events.Retention.Seconds())
+
+		c.cleanupStatements = append(c.cleanupStatements,
+			fmt.Sprintf("delete from %q where id in (select id from %q where status in ('%s', '%s') and created_at < now() - interval '%d seconds' limit 100 for update skip locked);", tableEvents, tableEvents, EventDelivered, EventDead, retentionSeconds),
+		)
+	}
+
+	if config.RateLimitBackend == conf.RateLimitBackendPostgres {
+		tableRateLimits := RateLimit{}.TableName()
+
+		c.cleanupStatements = append(c.cleanupStatements,
+			fmt.Sprintf("delete from %q where key in (select key from %q where expires_at < now() limit 100 for update skip locked);", tableRateLimits, tableRateLimits),
+		)
+	}
+
 	if config.External.AnonymousUsers.Enabled {
 		// delete anonymous users older than 30 days
 		c.cleanupStatements = append(c.cleanupStatements,
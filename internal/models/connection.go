@@ -20,6 +20,8 @@ type SortDirection string
 const Ascending SortDirection = "ASC"
 const Descending SortDirection = "DESC"
 const CreatedAt = "created_at"
+const LastSignInAt = "last_sign_in_at"
+const EmailAddress = "email"
 
 type SortParams struct {
 	Fields []SortField
@@ -42,6 +44,10 @@ func TruncateAll(conn *storage.Connection) error {
 			(&pop.Model{Value: Session{}}).TableName(),
 			(&pop.Model{Value: Factor{}}).TableName(),
 			(&pop.Model{Value: Challenge{}}).TableName(),
+			(&pop.Model{Value: RecoveryCode{}}).TableName(),
+			(&pop.Model{Value: PasskeyChallenge{}}).TableName(),
+			(&pop.Model{Value: TrustedDevice{}}).TableName(),
+			(&pop.Model{Value: PasswordHistoryEntry{}}).TableName(),
 			(&pop.Model{Value: AMRClaim{}}).TableName(),
 			(&pop.Model{Value: SSOProvider{}}).TableName(),
 			(&pop.Model{Value: SSODomain{}}).TableName(),
@@ -50,6 +56,10 @@ func TruncateAll(conn *storage.Connection) error {
 			(&pop.Model{Value: FlowState{}}).TableName(),
 			(&pop.Model{Value: OneTimeToken{}}).TableName(),
 			(&pop.Model{Value: OAuthServerClient{}}).TableName(),
+			(&pop.Model{Value: LoginLockout{}}).TableName(),
+			(&pop.Model{Value: RateLimit{}}).TableName(),
+			(&pop.Model{Value: AdminAPIKey{}}).TableName(),
+			(&pop.Model{Value: Event{}}).TableName(),
 		}
 
 		for _, tableName := range tables {
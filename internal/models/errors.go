@@ -29,6 +29,18 @@ func IsNotFoundError(err error) bool {
 		return true
 	case OAuthServerClientNotFoundError, *OAuthServerClientNotFoundError:
 		return true
+	case LoginLockoutNotFoundError, *LoginLockoutNotFoundError:
+		return true
+	case RecoveryCodeNotFoundError, *RecoveryCodeNotFoundError:
+		return true
+	case PasskeyChallengeNotFoundError, *PasskeyChallengeNotFoundError:
+		return true
+	case TrustedDeviceNotFoundError, *TrustedDeviceNotFoundError:
+		return true
+	case AdminAPIKeyNotFoundError, *AdminAPIKeyNotFoundError:
+		return true
+	case EventNotFoundError, *EventNotFoundError:
+		return true
 	}
 	return false
 }
@@ -0,0 +1,220 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/pkg/errors"
+)
+
+type EventType string
+
+const (
+	UserCreatedEvent         EventType = "user.created"
+	UserConfirmedEvent       EventType = "user.confirmed"
+	UserSignedInEvent        EventType = "user.signed_in"
+	UserPasswordChangedEvent EventType = "user.password_changed"
+	UserDeletedEvent         EventType = "user.deleted"
+	FactorEnrolledEvent      EventType = "factor.enrolled"
+	IdentityLinkedEvent      EventType = "identity.linked"
+)
+
+type EventStatus string
+
+const (
+	// EventPending events are waiting to be delivered, or to be retried
+	// after a failed delivery.
+	EventPending EventStatus = "pending"
+	// EventDelivered events were delivered to every endpoint.
+	EventDelivered EventStatus = "delivered"
+	// EventDead events failed too many deliveries and are only delivered
+	// again when replayed.
+	EventDead EventStatus = "dead"
+)
+
+// Event is an event about a user in the outbox, written in the transaction
+// of the change it describes and delivered to the events endpoints by the
+// dispatcher.
+type Event struct {
+	ID            uuid.UUID          `json:"id" db:"id"`
+	Type          EventType          `json:"type" db:"type"`
+	UserID        uuid.UUID          `json:"user_id" db:"user_id"`
+	Data          JSONMap            `json:"data" db:"data"`
+	Status        EventStatus        `json:"status" db:"status"`
+	Attempts      int                `json:"attempts" db:"attempts"`
+	LastError     storage.NullString `json:"last_error" db:"last_error"`
+	NextAttemptAt time.Time          `json:"next_attempt_at" db:"next_attempt_at"`
+	DeliveredAt   *time.Time         `json:"delivered_at" db:"delivered_at"`
+	CreatedAt     time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+type EventNotFoundError struct{}
+
+func (e EventNotFoundError) Error() string {
+	return "Event not found"
+}
+
+func (Event) TableName() string {
+	tableName := "events"
+	return tableName
+}
+
+// NewEvent writes an event about the user to the outbox when events are
+// enabled. The data is delivered along with the user as they are now.
+func NewEvent(config conf.EventsConfiguration, tx *storage.Connection, eventType EventType, user *User, data map[string]interface{}) error {
+	if !config.Enabled {
+		return nil
+	}
+
+	payload := JSONMap{"user": user}
+	for k, v := range data {
+		payload[k] = v
+	}
+
+	now := time.Now()
+	event := &Event{
+		ID:            uuid.Must(uuid.NewV4()),
+		Type:          eventType,
+		UserID:        user.ID,
+		Data:          payload,
+		Status:        EventPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	if err := tx.Create(event); err != nil {
+		return errors.Wrap(err, "error creating event")
+	}
+
+	return nil
+}
+
+// ClaimEvents returns up to limit pending events due at now, oldest first,
+// and postpones their next attempt by the lease so that other dispatchers
+// don't deliver them at the same time.
+func ClaimEvents(tx *storage.Connection, now time.Time, lease time.Duration, limit int) ([]*Event, error) {
+	tableName := (&pop.Model{Value: Event{}}).TableName()
+
+	events := []*Event{}
+	if err := tx.RawQuery(
+		"update "+tableName+" set next_attempt_at = ?, updated_at = ? where id in ("+
+			"select id from "+tableName+" where status = ? and next_attempt_at <= ? "+
+			"order by next_attempt_at asc, created_at asc limit ? for update skip locked"+
+			") returning *",
+		now.Add(lease), now, EventPending, now, limit,
+	).All(&events); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return events, nil
+		}
+		return nil, errors.Wrap(err, "error claiming events")
+	}
+
+	return events, nil
+}
+
+// MarkDelivered records that the event was delivered to every endpoint.
+func (e *Event) MarkDelivered(tx *storage.Connection, now time.Time) error {
+	e.Status = EventDelivered
+	e.Attempts++
+	e.DeliveredAt = &now
+	e.LastError = ""
+	return tx.UpdateOnly(e, "status", "attempts", "delivered_at", "last_error", "updated_at")
+}
+
+// MarkFailed records a failed delivery of the event, which is retried at
+// retryAt, or dead-lettered once it failed maxAttempts times.
+func (e *Event) MarkFailed(tx *storage.Connection, deliveryErr string, retryAt time.Time, maxAttempts int) error {
+	e.Attempts++
+	e.LastError = storage.NullString(deliveryErr)
+	e.NextAttemptAt = retryAt
+	if e.Attempts >= maxAttempts {
+		e.Status = EventDead
+	}
+	return tx.UpdateOnly(e, "status", "attempts", "last_error", "next_attempt_at", "updated_at")
+}
+
+// Replay delivers the event again as soon as possible, with a fresh number
+// of attempts, whether it was delivered or dead-lettered.
+func (e *Event) Replay(tx *storage.Connection) error {
+	e.Status = EventPending
+	e.Attempts = 0
+	e.NextAttemptAt = time.Now()
+	return tx.UpdateOnly(e, "status", "attempts", "next_attempt_at", "updated_at")
+}
+
+// ReplayDeadEvents replays every dead-lettered event of the type, or of any
+// type when it's empty, returning how many were replayed.
+func ReplayDeadEvents(tx *storage.Connection, eventType EventType) (int, error) {
+	now := time.Now()
+	query := "update " + (&pop.Model{Value: Event{}}).TableName() + " set status = ?, attempts = 0, next_attempt_at = ?, updated_at = ? where status = ?"
+	args := []interface{}{EventPending, now, now, EventDead}
+
+	if eventType != "" {
+		query += " and type = ?"
+		args = append(args, eventType)
+	}
+
+	count, err := tx.RawQuery(query, args...).ExecWithCount()
+	if err != nil {
+		return 0, errors.Wrap(err, "error replaying events")
+	}
+
+	return count, nil
+}
+
+// DeleteEventsByUser deletes every event about the user, whether it was
+// delivered or not.
+func DeleteEventsByUser(tx *storage.Connection, userID uuid.UUID) error {
+	if err := tx.RawQuery("delete from "+(&pop.Model{Value: Event{}}).TableName()+" where user_id = ?", userID).Exec(); err != nil {
+		return errors.Wrap(err, "error deleting events")
+	}
+
+	return nil
+}
+
+// FindEventByID finds an event by its ID.
+func FindEventByID(tx *storage.Connection, id uuid.UUID) (*Event, error) {
+	event := &Event{}
+	if err := tx.Q().Where("id = ?", id).First(event); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, EventNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding event")
+	}
+
+	return event, nil
+}
+
+// FindEvents returns the events with the status and type when they're set,
+// most recent first.
+func FindEvents(tx *storage.Connection, status EventStatus, eventType EventType, pageParams *Pagination) ([]*Event, error) {
+	q := tx.Q().Order("created_at desc")
+
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+
+	if eventType != "" {
+		q = q.Where("type = ?", eventType)
+	}
+
+	events := []*Event{}
+	var err error
+	if pageParams != nil {
+		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).All(&events) // #nosec G115
+		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)                      // #nosec G115
+	} else {
+		err = q.All(&events)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error finding events")
+	}
+
+	return events, nil
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/linkly-id/auth/internal/storage/test"
+)
+
+type EventTestSuite struct {
+	suite.Suite
+	db     *storage.Connection
+	config conf.EventsConfiguration
+	user   *User
+}
+
+func TestEvent(t *testing.T) {
+	globalConfig, err := conf.LoadGlobal(modelsTestConfig)
+	require.NoError(t, err)
+	conn, err := test.SetupDBConnection(globalConfig)
+	require.NoError(t, err)
+	ts := &EventTestSuite{
+		db:     conn,
+		config: conf.EventsConfiguration{Enabled: true},
+	}
+	defer ts.db.Close()
+	suite.Run(t, ts)
+}
+
+func (ts *EventTestSuite) SetupTest() {
+	TruncateAll(ts.db)
+
+	user, err := NewUser("", "events@example.com", "", "authenticated", nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.db.Create(user))
+	ts.user = user
+}
+
+func (ts *EventTestSuite) TestNewEvent() {
+	require.NoError(ts.T(), NewEvent(conf.EventsConfiguration{}, ts.db, UserCreatedEvent, ts.user, nil))
+	events, err := FindEvents(ts.db, "", "", nil)
+	require.NoError(ts.T(), err)
+	require.Empty(ts.T(), events)
+
+	require.NoError(ts.T(), NewEvent(ts.config, ts.db, UserSignedInEvent, ts.user, map[string]interface{}{
+		"authentication_method": "password",
+	}))
+	events, err = FindEvents(ts.db, EventPending, UserSignedInEvent, nil)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), events, 1)
+	require.Equal(ts.T(), ts.user.ID, events[0].UserID)
+	require.Equal(ts.T(), "password", events[0].Data["authentication_method"])
+	require.Equal(ts.T(), "events@example.com", events[0].Data["user"].(map[string]interface{})["email"])
+}
+
+func (ts *EventTestSuite) TestClaimEvents() {
+	for i := 0; i < 3; i++ {
+		require.NoError(ts.T(), NewEvent(ts.config, ts.db, UserCreatedEvent, ts.user, nil))
+	}
+
+	now := time.Now().Add(time.Second)
+	claimed, err := ClaimEvents(ts.db, now, time.Minute, 2)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), claimed, 2)
+
+	// claimed events aren't claimed again until their lease expires
+	claimed, err = ClaimEvents(ts.db, now, time.Minute, 2)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), claimed, 1)
+
+	claimed, err = ClaimEvents(ts.db, now.Add(2*time.Minute), time.Minute, 10)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), claimed, 3)
+
+	require.NoError(ts.T(), claimed[0].MarkDelivered(ts.db, now))
+	require.NoError(ts.T(), claimed[1].MarkFailed(ts.db, "unavailable", now.Add(time.Hour), 3))
+	require.Equal(ts.T(), EventPending, claimed[1].Status)
+
+	claimed[2].Attempts = 2
+	require.NoError(ts.T(), claimed[2].MarkFailed(ts.db, "unavailable", now.Add(time.Hour), 3))
+	require.Equal(ts.T(), EventDead, claimed[2].Status)
+
+	claimed, err = ClaimEvents(ts.db, now.Add(10*time.Minute), time.Minute, 10)
+	require.NoError(ts.T(), err)
+	require.Empty(ts.T(), claimed)
+
+	count, err := ReplayDeadEvents(ts.db, UserSignedInEvent)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), 0, count)
+
+	count, err = ReplayDeadEvents(ts.db, "")
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), 1, count)
+
+	claimed, err = ClaimEvents(ts.db, now.Add(10*time.Minute), time.Minute, 10)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), claimed, 1)
+	require.Equal(ts.T(), 0, claimed[0].Attempts)
+	require.Equal(ts.T(), "unavailable", claimed[0].LastError.String())
+}
+
+func (ts *EventTestSuite) TestDeleteEventsByUser() {
+	other, err := NewUser("", "other@example.com", "", "authenticated", nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.db.Create(other))
+
+	require.NoError(ts.T(), NewEvent(ts.config, ts.db, UserCreatedEvent, ts.user, nil))
+	require.NoError(ts.T(), NewEvent(ts.config, ts.db, UserSignedInEvent, ts.user, nil))
+	require.NoError(ts.T(), NewEvent(ts.config, ts.db, UserCreatedEvent, other, nil))
+
+	require.NoError(ts.T(), DeleteEventsByUser(ts.db, ts.user.ID))
+
+	events, err := FindEvents(ts.db, "", "", nil)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), events, 1)
+	require.Equal(ts.T(), other.ID, events[0].UserID)
+}
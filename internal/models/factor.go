@@ -36,6 +36,8 @@ func (factorState FactorState) String() string {
 const TOTP = "totp"
 const Phone = "phone"
 const WebAuthn = "webauthn"
+const RecoveryCodes = "recovery_codes"
+const Email = "email"
 
 type AuthenticationMethod int
 
@@ -55,6 +57,11 @@ const (
 	TokenRefresh
 	Anonymous
 	Web3
+	MFARecoveryCode
+	Passkey
+	MFAEmail
+	MFATrustedDevice
+	Impersonation
 )
 
 func (authMethod AuthenticationMethod) String() string {
@@ -89,6 +96,16 @@ func (authMethod AuthenticationMethod) String() string {
 		return "mfa/webauthn"
 	case Web3:
 		return "web3"
+	case MFARecoveryCode:
+		return "mfa/recovery_code"
+	case Passkey:
+		return "passkey"
+	case MFAEmail:
+		return "mfa/email"
+	case MFATrustedDevice:
+		return "mfa/trusted_device"
+	case Impersonation:
+		return "impersonation"
 	}
 	return ""
 }
@@ -126,6 +143,16 @@ func ParseAuthenticationMethod(authMethod string) (AuthenticationMethod, error)
 		return MFAWebAuthn, nil
 	case "web3":
 		return Web3, nil
+	case "mfa/recovery_code":
+		return MFARecoveryCode, nil
+	case "passkey":
+		return Passkey, nil
+	case "mfa/email":
+		return MFAEmail, nil
+	case "mfa/trusted_device":
+		return MFATrustedDevice, nil
+	case "impersonation":
+		return Impersonation, nil
 
 	}
 	return 0, fmt.Errorf("unsupported authentication method %q", authMethod)
@@ -144,6 +171,7 @@ type Factor struct {
 	FactorType         string              `json:"factor_type" db:"factor_type"`
 	Challenge          []Challenge         `json:"-" has_many:"challenges"`
 	Phone              storage.NullString  `json:"phone" db:"phone"`
+	Email              storage.NullString  `json:"email,omitempty" db:"email"`
 	LastChallengedAt   *time.Time          `json:"last_challenged_at" db:"last_challenged_at"`
 	WebAuthnCredential *WebAuthnCredential `json:"-" db:"web_authn_credential"`
 	WebAuthnAAGUID     *uuid.UUID          `json:"web_authn_aaguid,omitempty" db:"web_authn_aaguid"`
@@ -210,11 +238,26 @@ func NewPhoneFactor(user *User, phone, friendlyName string) *Factor {
 	return factor
 }
 
+// NewEmailFactor creates an email factor. The address can differ from the
+// user's primary email.
+func NewEmailFactor(user *User, email, friendlyName string) *Factor {
+	factor := NewFactor(user, friendlyName, Email, FactorStateUnverified)
+	factor.Email = storage.NullString(email)
+	return factor
+}
+
 func NewWebAuthnFactor(user *User, friendlyName string) *Factor {
 	factor := NewFactor(user, friendlyName, WebAuthn, FactorStateUnverified)
 	return factor
 }
 
+// NewRecoveryCodesFactor creates a recovery codes factor. It is verified
+// from the start, as the codes are shown to the user only once on creation.
+func NewRecoveryCodesFactor(user *User, friendlyName string) *Factor {
+	factor := NewFactor(user, friendlyName, RecoveryCodes, FactorStateVerified)
+	return factor
+}
+
 func (f *Factor) SetSecret(secret string, encrypt bool, encryptionKeyID, encryptionKey string) error {
 	f.Secret = secret
 	if encrypt {
@@ -312,6 +355,14 @@ func (f *Factor) CreatePhoneChallenge(ipAddress string, otpCode string, encrypt
 	return phoneChallenge, nil
 }
 
+func (f *Factor) CreateEmailChallenge(ipAddress string, otpCode string, encrypt bool, encryptionKeyID, encryptionKey string) (*Challenge, error) {
+	emailChallenge := f.CreateChallenge(ipAddress)
+	if err := emailChallenge.SetOtpCode(otpCode, encrypt, encryptionKeyID, encryptionKey); err != nil {
+		return nil, err
+	}
+	return emailChallenge, nil
+}
+
 // UpdateFriendlyName changes the friendly name
 func (f *Factor) UpdateFriendlyName(tx *storage.Connection, friendlyName string) error {
 	f.FriendlyName = friendlyName
@@ -323,6 +374,11 @@ func (f *Factor) UpdatePhone(tx *storage.Connection, phone string) error {
 	return tx.UpdateOnly(f, "phone", "updated_at")
 }
 
+func (f *Factor) UpdateEmail(tx *storage.Connection, email string) error {
+	f.Email = storage.NullString(email)
+	return tx.UpdateOnly(f, "email", "updated_at")
+}
+
 // UpdateStatus modifies the factor status
 func (f *Factor) UpdateStatus(tx *storage.Connection, state FactorState) error {
 	f.Status = state.String()
@@ -354,6 +410,14 @@ func (f *Factor) IsPhoneFactor() bool {
 	return f.FactorType == Phone
 }
 
+func (f *Factor) IsEmailFactor() bool {
+	return f.FactorType == Email
+}
+
+func (f *Factor) IsRecoveryCodesFactor() bool {
+	return f.FactorType == RecoveryCodes
+}
+
 func (f *Factor) FindChallengeByID(conn *storage.Connection, challengeID uuid.UUID) (*Challenge, error) {
 	var challenge Challenge
 	err := conn.Q().Where("id = ? and factor_id = ?", challengeID, f.ID).First(&challenge)
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// LoginLockout tracks consecutive failed password sign-in attempts for a
+// lockout key. A key identifies either a user or a sign-in identifier (an
+// email address or phone number), see UserLockoutKey and
+// IdentifierLockoutKey.
+type LoginLockout struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	Key            string     `json:"key" db:"key"`
+	UserID         *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	FailedAttempts int        `json:"failed_attempts" db:"failed_attempts"`
+	LockoutCount   int        `json:"lockout_count" db:"lockout_count"`
+	LastFailedAt   *time.Time `json:"last_failed_at,omitempty" db:"last_failed_at"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type LoginLockoutNotFoundError struct{}
+
+func (e LoginLockoutNotFoundError) Error() string {
+	return "Login lockout not found"
+}
+
+func (LoginLockout) TableName() string {
+	tableName := "login_lockouts"
+	return tableName
+}
+
+// UserLockoutKey returns the lockout key used to count failed attempts
+// against a user, regardless of the identifier used to sign in.
+func UserLockoutKey(userID uuid.UUID) string {
+	return "user:" + userID.String()
+}
+
+// IdentifierLockoutKey returns the lockout key used to count failed attempts
+// against a sign-in identifier, such as an email address or phone number.
+func IdentifierLockoutKey(provider, identifier string) string {
+	return provider + ":" + strings.ToLower(strings.TrimSpace(identifier))
+}
+
+// IsLocked returns whether the key is locked out at the provided time.
+func (l *LoginLockout) IsLocked(now time.Time) bool {
+	return l.LockedUntil != nil && now.Before(*l.LockedUntil)
+}
+
+// FindLoginLockout finds the lockout state for the provided key.
+func FindLoginLockout(tx *storage.Connection, key string) (*LoginLockout, error) {
+	lockout := &LoginLockout{}
+	if err := tx.Q().Where("key = ?", key).First(lockout); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, LoginLockoutNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding login lockout")
+	}
+
+	return lockout, nil
+}
+
+// FindLockedLoginLockout returns the first of the provided keys that is
+// locked out at the provided time, or nil if none of them are.
+func FindLockedLoginLockout(tx *storage.Connection, now time.Time, keys ...string) (*LoginLockout, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	lockout := &LoginLockout{}
+	if err := tx.Q().Where("key in (?)", lockoutKeyArgs(keys)...).Where("locked_until > ?", now).Order("locked_until desc").First(lockout); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "error finding login lockout")
+	}
+
+	return lockout, nil
+}
+
+// RecordFailedLoginAttempt counts a failed sign-in attempt against the key
+// and locks it out once maxAttempts consecutive failures have been recorded
+// within the configured attempt window. Each lockout served since the last
+// cooldown doubles the length of the next one. The returned boolean reports
+// whether this attempt caused the key to become locked.
+func RecordFailedLoginAttempt(tx *storage.Connection, config *conf.LockoutConfiguration, key string, userID *uuid.UUID, maxAttempts int, now time.Time) (*LoginLockout, bool, error) {
+	id := uuid.Must(uuid.NewV4())
+
+	// make sure the row exists so that it can be locked for the rest of
+	// the transaction, serializing concurrent failed attempts on the key
+	if err := tx.RawQuery("INSERT INTO "+(&pop.Model{Value: LoginLockout{}}).TableName()+" (id, key, user_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT (key) DO NOTHING", id, key, userID, now, now).Exec(); err != nil {
+		return nil, false, errors.Wrap(err, "error creating login lockout")
+	}
+
+	lockout := &LoginLockout{}
+	if err := tx.RawQuery("SELECT * FROM "+(&pop.Model{Value: LoginLockout{}}).TableName()+" WHERE key = ? FOR UPDATE", key).First(lockout); err != nil {
+		return nil, false, errors.Wrap(err, "error finding login lockout")
+	}
+
+	if lockout.LastFailedAt != nil {
+		sinceLastFailure := now.Sub(*lockout.LastFailedAt)
+
+		if sinceLastFailure > config.AttemptWindow {
+			lockout.FailedAttempts = 0
+		}
+
+		if sinceLastFailure > config.Cooldown {
+			lockout.LockoutCount = 0
+		}
+	}
+
+	lockout.FailedAttempts += 1
+	lockout.LastFailedAt = &now
+	lockout.UpdatedAt = now
+
+	locked := false
+	if lockout.FailedAttempts >= maxAttempts {
+		lockedUntil := now.Add(config.LockoutDuration(lockout.LockoutCount))
+
+		lockout.LockedUntil = &lockedUntil
+		lockout.LockoutCount += 1
+		lockout.FailedAttempts = 0
+		locked = true
+	}
+
+	if err := tx.UpdateOnly(lockout, "failed_attempts", "lockout_count", "last_failed_at", "locked_until", "updated_at"); err != nil {
+		return nil, false, errors.Wrap(err, "error updating login lockout")
+	}
+
+	return lockout, locked, nil
+}
+
+// ClearLoginLockouts removes all failed attempts and lockouts recorded for
+// the provided keys.
+func ClearLoginLockouts(tx *storage.Connection, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if err := tx.Q().Where("key in (?)", lockoutKeyArgs(keys)...).Delete(&LoginLockout{}); err != nil {
+		return errors.Wrap(err, "error clearing login lockouts")
+	}
+
+	return nil
+}
+
+func lockoutKeyArgs(keys []string) []interface{} {
+	args := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		args = append(args, key)
+	}
+
+	return args
+}
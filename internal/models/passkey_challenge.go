@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// PasskeyChallenge is a WebAuthn challenge issued for a passkey sign-in.
+// Unlike MFA challenges it does not belong to a factor, as the user is only
+// known once the authenticator has responded to it.
+type PasskeyChallenge struct {
+	ID                  uuid.UUID            `json:"id" db:"id"`
+	IPAddress           string               `json:"ip_address" db:"ip_address"`
+	WebAuthnSessionData *WebAuthnSessionData `json:"-" db:"web_authn_session_data"`
+	CreatedAt           time.Time            `json:"created_at" db:"created_at"`
+}
+
+type PasskeyChallengeNotFoundError struct{}
+
+func (e PasskeyChallengeNotFoundError) Error() string {
+	return "Passkey challenge not found"
+}
+
+func (PasskeyChallenge) TableName() string {
+	tableName := "passkey_challenges"
+	return tableName
+}
+
+func NewPasskeyChallenge(sessionData *webauthn.SessionData, ipAddress string) *PasskeyChallenge {
+	id := uuid.Must(uuid.NewV4())
+
+	return &PasskeyChallenge{
+		ID:        id,
+		IPAddress: ipAddress,
+		WebAuthnSessionData: &WebAuthnSessionData{
+			SessionData: sessionData,
+		},
+		CreatedAt: time.Now(),
+	}
+}
+
+// FindPasskeyChallengeByID finds a passkey challenge by its ID.
+func FindPasskeyChallengeByID(tx *storage.Connection, id uuid.UUID) (*PasskeyChallenge, error) {
+	challenge := &PasskeyChallenge{}
+	if err := tx.Q().Where("id = ?", id).First(challenge); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, PasskeyChallengeNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding passkey challenge")
+	}
+
+	return challenge, nil
+}
+
+func (c *PasskeyChallenge) HasExpired(now time.Time, expiryDuration time.Duration) bool {
+	return now.After(c.GetExpiryTime(expiryDuration))
+}
+
+func (c *PasskeyChallenge) GetExpiryTime(expiryDuration time.Duration) time.Time {
+	return c.CreatedAt.Add(expiryDuration)
+}
+
+// Consume deletes the challenge so that it cannot be answered again. It
+// returns a PasskeyChallengeNotFoundError if the challenge was consumed
+// concurrently.
+func (c *PasskeyChallenge) Consume(tx *storage.Connection) error {
+	count, err := tx.RawQuery("DELETE FROM "+(&pop.Model{Value: PasskeyChallenge{}}).TableName()+" WHERE id = ?", c.ID).ExecWithCount()
+	if err != nil {
+		return errors.Wrap(err, "error consuming passkey challenge")
+	}
+
+	if count == 0 {
+		return PasskeyChallengeNotFoundError{}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/crypto"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// PasswordHistoryEntry is the hash of a password the user previously had,
+// kept to prevent its reuse.
+type PasswordHistoryEntry struct {
+	ID                uuid.UUID `json:"id" db:"id"`
+	UserID            uuid.UUID `json:"user_id" db:"user_id"`
+	EncryptedPassword string    `json:"-" db:"encrypted_password"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+}
+
+func (PasswordHistoryEntry) TableName() string {
+	tableName := "password_history"
+	return tableName
+}
+
+// FindPasswordHistory returns the last limit password hashes of the user,
+// most recent first.
+func FindPasswordHistory(tx *storage.Connection, userID uuid.UUID, limit int) ([]*PasswordHistoryEntry, error) {
+	entries := []*PasswordHistoryEntry{}
+	if limit <= 0 {
+		return entries, nil
+	}
+
+	if err := tx.Q().Where("user_id = ?", userID).Order("created_at desc").Limit(limit).All(&entries); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return entries, nil
+		}
+		return nil, errors.Wrap(err, "error finding password history")
+	}
+
+	return entries, nil
+}
+
+// AddPasswordHistory records the hash of a password the user no longer
+// has, keeping only the last limit hashes.
+func AddPasswordHistory(tx *storage.Connection, userID uuid.UUID, encryptedPassword string, limit int) error {
+	if limit <= 0 || encryptedPassword == "" {
+		return nil
+	}
+
+	entry := &PasswordHistoryEntry{
+		ID:                uuid.Must(uuid.NewV4()),
+		UserID:            userID,
+		EncryptedPassword: encryptedPassword,
+		CreatedAt:         time.Now(),
+	}
+	if err := tx.Create(entry); err != nil {
+		return errors.Wrap(err, "error adding password history")
+	}
+
+	tableName := (&pop.Model{Value: PasswordHistoryEntry{}}).TableName()
+	if err := tx.RawQuery("DELETE FROM "+tableName+" WHERE user_id = ? AND id NOT IN (SELECT id FROM "+tableName+" WHERE user_id = ? ORDER BY created_at DESC LIMIT ?)", userID, userID, limit).Exec(); err != nil {
+		return errors.Wrap(err, "error pruning password history")
+	}
+
+	return nil
+}
+
+// DeletePasswordHistoryByUser removes all previous password hashes of the
+// user.
+func DeletePasswordHistoryByUser(tx *storage.Connection, userID uuid.UUID) error {
+	if err := tx.RawQuery("DELETE FROM "+(&pop.Model{Value: PasswordHistoryEntry{}}).TableName()+" WHERE user_id = ?", userID).Exec(); err != nil {
+		return errors.Wrap(err, "error deleting password history")
+	}
+
+	return nil
+}
+
+// Matches reports whether the password matches the recorded hash.
+func (e *PasswordHistoryEntry) Matches(ctx context.Context, password string, decryptionKeys map[string]string) (bool, error) {
+	hash := e.EncryptedPassword
+
+	if es := crypto.ParseEncryptedString(hash); es != nil {
+		h, err := es.Decrypt(e.UserID.String(), decryptionKeys)
+		if err != nil {
+			return false, err
+		}
+
+		hash = string(h)
+	}
+
+	return crypto.CompareHashAndPassword(ctx, hash, password) == nil, nil
+}
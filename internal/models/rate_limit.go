@@ -0,0 +1,97 @@
+package models
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// RateLimit counts the events of a rate limited key in the current fixed
+// window and the one before it, which estimate the count in a sliding
+// window.
+type RateLimit struct {
+	Key           string    `json:"key" db:"key"`
+	WindowStart   time.Time `json:"window_start" db:"window_start"`
+	Count         int       `json:"count" db:"count"`
+	PreviousCount int       `json:"previous_count" db:"previous_count"`
+	// Allowed is whether the last event was counted.
+	Allowed   bool      `json:"allowed" db:"allowed"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+func (RateLimit) TableName() string {
+	tableName := "rate_limits"
+	return tableName
+}
+
+// TakeRateLimit counts an event of the key if fewer than limit events were
+// counted in the sliding window ending now, and returns the key's counts
+// after it. Events of the previous fixed window are weighted by how much of
+// it the sliding window still overlaps. It's a single upsert timed by the
+// database's clock, so concurrent events of the key are counted atomically
+// and in the same windows by every instance.
+func TakeRateLimit(tx *storage.Connection, key string, limit int, window time.Duration) (*RateLimit, error) {
+	seconds := window.Seconds()
+
+	windowStart := "to_timestamp(floor(extract(epoch from now()) / ?::float8) * ?::float8)"
+	previousWindowStart := "excluded.window_start - make_interval(secs => ?::float8)"
+	weight := "(1 - extract(epoch from now() - excluded.window_start) / ?::float8)"
+
+	current := "case when r.window_start = excluded.window_start then r.count else 0 end"
+	previous := "case when r.window_start = excluded.window_start then r.previous_count when r.window_start = " + previousWindowStart + " then r.count else 0 end"
+	allowed := fmt.Sprintf("floor((%s) * %s) + (%s) < ?", previous, weight, current)
+
+	rateLimit := &RateLimit{}
+	if err := tx.RawQuery(
+		"insert into "+(&pop.Model{Value: RateLimit{}}).TableName()+" as r (key, window_start, count, previous_count, allowed, expires_at) "+
+			"values (?, "+windowStart+", case when ? > 0 then 1 else 0 end, 0, ? > 0, "+windowStart+" + make_interval(secs => ?::float8)) "+
+			"on conflict (key) do update set "+
+			"previous_count = "+previous+", "+
+			"count = "+current+" + case when "+allowed+" then 1 else 0 end, "+
+			"allowed = "+allowed+", "+
+			"window_start = excluded.window_start, "+
+			"expires_at = excluded.expires_at "+
+			"returning *",
+		key, seconds, seconds, limit, limit, seconds, seconds, 2*seconds,
+		seconds,
+		seconds, seconds, limit,
+		seconds, seconds, limit,
+	).First(rateLimit); err != nil {
+		return nil, errors.Wrap(err, "error counting rate limited event")
+	}
+
+	return rateLimit, nil
+}
+
+// FindRateLimits finds the counts of the keys with the prefix that haven't
+// expired at now.
+func FindRateLimits(tx *storage.Connection, prefix string, now time.Time) ([]*RateLimit, error) {
+	rateLimits := []*RateLimit{}
+	if err := tx.Q().Where("left(key, ?) = ? and expires_at > ?", utf8.RuneCountInString(prefix), prefix, now).Order("key asc").All(&rateLimits); err != nil {
+		return nil, errors.Wrap(err, "error finding rate limits")
+	}
+
+	return rateLimits, nil
+}
+
+// ClearRateLimit forgets the counts of the key.
+func ClearRateLimit(tx *storage.Connection, key string) error {
+	if err := tx.Q().Where("key = ?", key).Delete(&RateLimit{}); err != nil {
+		return errors.Wrap(err, "error clearing rate limit")
+	}
+
+	return nil
+}
+
+// ClearRateLimits forgets the counts of the keys with the prefix.
+func ClearRateLimits(tx *storage.Connection, prefix string) error {
+	if err := tx.Q().Where("left(key, ?) = ?", utf8.RuneCountInString(prefix), prefix).Delete(&RateLimit{}); err != nil {
+		return errors.Wrap(err, "error clearing rate limits")
+	}
+
+	return nil
+}
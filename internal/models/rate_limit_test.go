@@ -0,0 +1,110 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/linkly-id/auth/internal/storage/test"
+)
+
+type RateLimitTestSuite struct {
+	suite.Suite
+	db *storage.Connection
+}
+
+func TestRateLimit(t *testing.T) {
+	globalConfig, err := conf.LoadGlobal(modelsTestConfig)
+	require.NoError(t, err)
+	conn, err := test.SetupDBConnection(globalConfig)
+	require.NoError(t, err)
+	ts := &RateLimitTestSuite{
+		db: conn,
+	}
+	defer ts.db.Close()
+	suite.Run(t, ts)
+}
+
+func (ts *RateLimitTestSuite) SetupTest() {
+	TruncateAll(ts.db)
+}
+
+func (ts *RateLimitTestSuite) take(key string, limit int) *RateLimit {
+	rateLimit, err := TakeRateLimit(ts.db, key, limit, time.Minute)
+	require.NoError(ts.T(), err)
+	return rateLimit
+}
+
+// rewind moves the counts of the key back by d, as if d had passed.
+func (ts *RateLimitTestSuite) rewind(key string, d time.Duration) {
+	require.NoError(ts.T(), ts.db.RawQuery(
+		"update "+RateLimit{}.TableName()+" set window_start = window_start - make_interval(secs => ?::float8) where key = ?",
+		d.Seconds(), key,
+	).Exec())
+}
+
+func (ts *RateLimitTestSuite) TestTakeRateLimit() {
+	for i := 0; i < 3; i++ {
+		require.True(ts.T(), ts.take("a", 3).Allowed, i)
+	}
+	require.False(ts.T(), ts.take("a", 3).Allowed)
+	require.True(ts.T(), ts.take("b", 3).Allowed, "keys are counted separately")
+
+	// windows are aligned to the window on the database's clock
+	rateLimit := ts.take("b", 3)
+	require.Zero(ts.T(), rateLimit.WindowStart.Unix()%60)
+	require.WithinDuration(ts.T(), time.Now(), rateLimit.WindowStart, time.Minute)
+	require.Equal(ts.T(), rateLimit.WindowStart.Add(2*time.Minute).Unix(), rateLimit.ExpiresAt.Unix())
+
+	// the counts of the previous window are carried over to the next one
+	ts.rewind("a", time.Minute)
+	rateLimit = ts.take("a", 3)
+	require.Equal(ts.T(), 3, rateLimit.PreviousCount)
+
+	// the previous window doesn't overlap anymore after two windows
+	ts.rewind("a", 2*time.Minute)
+	rateLimit = ts.take("a", 3)
+	require.True(ts.T(), rateLimit.Allowed)
+	require.Equal(ts.T(), 1, rateLimit.Count)
+	require.Equal(ts.T(), 0, rateLimit.PreviousCount)
+
+	require.False(ts.T(), ts.take("c", 0).Allowed, "nothing is allowed without a limit")
+}
+
+func (ts *RateLimitTestSuite) TestFindAndClearRateLimits() {
+	now := time.Now()
+	for _, key := range []string{"token:1.2.3.4", "token:5.6.7.8", "token_other:1.2.3.4", "verify:1.2.3.4"} {
+		ts.take(key, 3)
+	}
+
+	rateLimits, err := FindRateLimits(ts.db, "token:", now)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), rateLimits, 2)
+	require.Equal(ts.T(), "token:1.2.3.4", rateLimits[0].Key)
+	require.Equal(ts.T(), 1, rateLimits[0].Count)
+
+	rateLimits, err = FindRateLimits(ts.db, "token:", now.Add(3*time.Minute))
+	require.NoError(ts.T(), err)
+	require.Empty(ts.T(), rateLimits, "expired counts aren't found")
+
+	require.NoError(ts.T(), ClearRateLimit(ts.db, "token:1.2.3.4"))
+	rateLimits, err = FindRateLimits(ts.db, "token:", now)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), rateLimits, 1)
+
+	// underscores aren't wildcards
+	require.NoError(ts.T(), ClearRateLimits(ts.db, "token_"))
+	rateLimits, err = FindRateLimits(ts.db, "token:", now)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), rateLimits, 1)
+
+	require.NoError(ts.T(), ClearRateLimits(ts.db, "token:"))
+	rateLimits, err = FindRateLimits(ts.db, "", now)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), rateLimits, 1)
+	require.Equal(ts.T(), "verify:1.2.3.4", rateLimits[0].Key)
+}
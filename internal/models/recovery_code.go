@@ -0,0 +1,136 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/crypto"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// recoveryCodeLength is the number of characters in a recovery code, not
+// counting the separator added for readability.
+const recoveryCodeLength = 10
+
+// RecoveryCode is a single-use code belonging to a recovery codes factor.
+// Only a hash of the code is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	FactorID  uuid.UUID  `json:"factor_id" db:"factor_id"`
+	CodeHash  string     `json:"-" db:"code_hash"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+}
+
+type RecoveryCodeNotFoundError struct{}
+
+func (e RecoveryCodeNotFoundError) Error() string {
+	return "Recovery code not found"
+}
+
+func (RecoveryCode) TableName() string {
+	tableName := "mfa_recovery_codes"
+	return tableName
+}
+
+// NormalizeRecoveryCode removes the separators and whitespace users may
+// enter along with a recovery code.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+func hashRecoveryCode(factorID uuid.UUID, code string) string {
+	return crypto.GenerateTokenHash(factorID.String(), NormalizeRecoveryCode(code))
+}
+
+// GenerateRecoveryCodes replaces all recovery codes of the factor with count
+// new ones, returning them in plain text. This is the only time the codes
+// are available in plain text.
+func GenerateRecoveryCodes(tx *storage.Connection, factor *Factor, count int) ([]string, error) {
+	if err := DeleteRecoveryCodes(tx, factor); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, count)
+	for len(codes) < count {
+		raw := crypto.SecureAlphanumeric(recoveryCodeLength)
+		code := raw[:recoveryCodeLength/2] + "-" + raw[recoveryCodeLength/2:]
+
+		recoveryCode := &RecoveryCode{
+			ID:        uuid.Must(uuid.NewV4()),
+			FactorID:  factor.ID,
+			CodeHash:  hashRecoveryCode(factor.ID, code),
+			CreatedAt: time.Now(),
+		}
+
+		if err := tx.Create(recoveryCode); err != nil {
+			return nil, errors.Wrap(err, "error creating recovery code")
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// DeleteRecoveryCodes removes all recovery codes of the factor.
+func DeleteRecoveryCodes(tx *storage.Connection, factor *Factor) error {
+	if err := tx.RawQuery("DELETE FROM "+(&pop.Model{Value: RecoveryCode{}}).TableName()+" WHERE factor_id = ?", factor.ID).Exec(); err != nil {
+		return errors.Wrap(err, "error deleting recovery codes")
+	}
+
+	return nil
+}
+
+// FindUnusedRecoveryCode finds the recovery code of the factor matching the
+// provided code, as long as it has not been used yet.
+func FindUnusedRecoveryCode(tx *storage.Connection, factor *Factor, code string) (*RecoveryCode, error) {
+	recoveryCode := &RecoveryCode{}
+	if err := tx.Q().Where("factor_id = ? and code_hash = ? and used_at is null", factor.ID, hashRecoveryCode(factor.ID, code)).First(recoveryCode); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, RecoveryCodeNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding recovery code")
+	}
+
+	return recoveryCode, nil
+}
+
+// CountUnusedRecoveryCodes returns the number of recovery codes of the
+// factor that can still be used.
+func CountUnusedRecoveryCodes(tx *storage.Connection, factor *Factor) (int, error) {
+	count, err := tx.Q().Where("factor_id = ? and used_at is null", factor.ID).Count(&RecoveryCode{})
+	if err != nil {
+		return 0, errors.Wrap(err, "error counting recovery codes")
+	}
+
+	return count, nil
+}
+
+// Use marks the recovery code as used. It returns a RecoveryCodeNotFoundError
+// if the code was used concurrently.
+func (c *RecoveryCode) Use(tx *storage.Connection) error {
+	now := time.Now()
+
+	count, err := tx.RawQuery("UPDATE "+(&pop.Model{Value: RecoveryCode{}}).TableName()+" SET used_at = ? WHERE id = ? AND used_at IS NULL", now, c.ID).ExecWithCount()
+	if err != nil {
+		return errors.Wrap(err, "error using recovery code")
+	}
+
+	if count == 0 {
+		return RecoveryCodeNotFoundError{}
+	}
+
+	c.UsedAt = &now
+	return nil
+}
@@ -45,8 +45,16 @@ type GrantParams struct {
 	SessionNotAfter *time.Time
 	SessionTag      *string
 
+	// Impersonator is the administrator acting as the user in the
+	// session.
+	Impersonator *string
+
 	UserAgent string
 	IP        string
+
+	// TrustedDeviceToken is presented by clients on a device the user
+	// trusted earlier, to skip MFA at sign-in.
+	TrustedDeviceToken string
 }
 
 func (g *GrantParams) FillGrantParams(r *http.Request) {
@@ -149,6 +157,10 @@ func createRefreshToken(tx *storage.Connection, user *User, oldToken *RefreshTok
 			session.Tag = params.SessionTag
 		}
 
+		if params.Impersonator != nil && *params.Impersonator != "" {
+			session.Impersonator = params.Impersonator
+		}
+
 		if err := tx.Create(session); err != nil {
 			return nil, errors.Wrap(err, "error creating new session")
 		}
@@ -160,8 +172,11 @@ func createRefreshToken(tx *storage.Connection, user *User, oldToken *RefreshTok
 		return nil, errors.Wrap(err, "error creating refresh token")
 	}
 
-	if err := user.UpdateLastSignInAt(tx); err != nil {
-		return nil, errors.Wrap(err, "error update user`s last_sign_in field")
+	// an administrator impersonating the user isn't the user signing in
+	if params.Impersonator == nil || *params.Impersonator == "" {
+		if err := user.UpdateLastSignInAt(tx); err != nil {
+			return nil, errors.Wrap(err, "error update user`s last_sign_in field")
+		}
 	}
 	return token, nil
 }
@@ -89,6 +89,10 @@ type Session struct {
 	IP          *string    `json:"ip,omitempty" db:"ip"`
 
 	Tag *string `json:"tag" db:"tag"`
+
+	// Impersonator is the administrator acting as the user, set only on
+	// impersonation sessions.
+	Impersonator *string `json:"impersonator,omitempty" db:"impersonator"`
 }
 
 func (Session) TableName() string {
@@ -124,6 +128,12 @@ func (s *Session) UpdateOnlyRefreshInfo(tx *storage.Connection) error {
 	return tx.UpdateOnly(s, "refreshed_at", "user_agent", "ip")
 }
 
+// IsImpersonation returns whether an administrator is acting as the user in
+// the session.
+func (s *Session) IsImpersonation() bool {
+	return s.Impersonator != nil && *s.Impersonator != ""
+}
+
 type SessionValidityReason = int
 
 const (
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/crypto"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// trustedDeviceTokenLength is the number of characters in a trusted device
+// token.
+const trustedDeviceTokenLength = 40
+
+// TrustedDevice is a device the user chose to trust after verifying a
+// factor. Presenting its token at sign-in satisfies AAL2 without another
+// MFA challenge. Only a hash of the token is stored.
+type TrustedDevice struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"-" db:"user_id"`
+	FactorID   uuid.UUID  `json:"factor_id" db:"factor_id"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	Label      string     `json:"label" db:"label"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+}
+
+type TrustedDeviceNotFoundError struct{}
+
+func (e TrustedDeviceNotFoundError) Error() string {
+	return "Trusted device not found"
+}
+
+func (TrustedDevice) TableName() string {
+	tableName := "mfa_trusted_devices"
+	return tableName
+}
+
+func hashTrustedDeviceToken(userID uuid.UUID, token string) string {
+	return crypto.GenerateTokenHash(userID.String(), token)
+}
+
+// NewTrustedDevice creates a trusted device for the user and factor,
+// returning it along with its token in plain text. This is the only time
+// the token is available in plain text.
+func NewTrustedDevice(user *User, factor *Factor, label string, expiryDuration time.Duration) (*TrustedDevice, string) {
+	token := crypto.SecureAlphanumeric(trustedDeviceTokenLength)
+	now := time.Now()
+
+	return &TrustedDevice{
+		ID:        uuid.Must(uuid.NewV4()),
+		UserID:    user.ID,
+		FactorID:  factor.ID,
+		TokenHash: hashTrustedDeviceToken(user.ID, token),
+		Label:     label,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(expiryDuration),
+	}, token
+}
+
+// FindTrustedDeviceByToken finds the unexpired trusted device of the user
+// matching the provided token.
+func FindTrustedDeviceByToken(tx *storage.Connection, userID uuid.UUID, token string) (*TrustedDevice, error) {
+	device := &TrustedDevice{}
+	if err := tx.Q().Where("user_id = ? and token_hash = ? and expires_at > ?", userID, hashTrustedDeviceToken(userID, token), time.Now()).First(device); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, TrustedDeviceNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding trusted device")
+	}
+
+	return device, nil
+}
+
+// FindTrustedDeviceByID finds a trusted device of the user by its ID.
+func FindTrustedDeviceByID(tx *storage.Connection, userID, id uuid.UUID) (*TrustedDevice, error) {
+	device := &TrustedDevice{}
+	if err := tx.Q().Where("user_id = ? and id = ?", userID, id).First(device); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, TrustedDeviceNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding trusted device")
+	}
+
+	return device, nil
+}
+
+// FindTrustedDevicesByUser returns the unexpired trusted devices of the
+// user, most recently created first.
+func FindTrustedDevicesByUser(tx *storage.Connection, userID uuid.UUID) ([]*TrustedDevice, error) {
+	devices := []*TrustedDevice{}
+	if err := tx.Q().Where("user_id = ? and expires_at > ?", userID, time.Now()).Order("created_at desc").All(&devices); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return devices, nil
+		}
+		return nil, errors.Wrap(err, "error finding trusted devices")
+	}
+
+	return devices, nil
+}
+
+// DeleteTrustedDevicesByUser revokes all trusted devices of the user.
+func DeleteTrustedDevicesByUser(tx *storage.Connection, userID uuid.UUID) error {
+	if err := tx.RawQuery("DELETE FROM "+(&pop.Model{Value: TrustedDevice{}}).TableName()+" WHERE user_id = ?", userID).Exec(); err != nil {
+		return errors.Wrap(err, "error deleting trusted devices")
+	}
+
+	return nil
+}
+
+// Touch records that the trusted device was used to sign in.
+func (d *TrustedDevice) Touch(tx *storage.Connection) error {
+	now := time.Now()
+	d.LastUsedAt = &now
+	return tx.UpdateOnly(d, "last_used_at", "updated_at")
+}
@@ -31,6 +31,11 @@ type User struct {
 	EmailConfirmedAt  *time.Time `json:"email_confirmed_at,omitempty" db:"email_confirmed_at"`
 	InvitedAt         *time.Time `json:"invited_at,omitempty" db:"invited_at"`
 
+	// PasswordChangedAt is when the password was last changed, or nil if
+	// it hasn't been since the user was created
+	PasswordChangedAt  *time.Time `json:"password_changed_at,omitempty" db:"password_changed_at"`
+	MustChangePassword bool       `json:"must_change_password,omitempty" db:"must_change_password"`
+
 	Phone            storage.NullString `json:"phone" db:"phone"`
 	PhoneConfirmedAt *time.Time         `json:"phone_confirmed_at,omitempty" db:"phone_confirmed_at"`
 
@@ -70,27 +75,60 @@ type User struct {
 	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 	IsAnonymous bool       `json:"is_anonymous" db:"is_anonymous"`
 
+	// MFARequiredAt is when an MFA policy first required the user to
+	// enroll a factor, which starts the grace period
+	MFARequiredAt *time.Time `json:"-" db:"mfa_required_at"`
+	// MFAEnrollmentDeadline is only set while an MFA policy requires the
+	// user to enroll a factor, which they have to do before this time
+	MFAEnrollmentDeadline *time.Time `json:"mfa_enrollment_deadline,omitempty" db:"-"`
+
 	DONTUSEINSTANCEID uuid.UUID `json:"-" db:"instance_id"`
 }
 
-func NewUserWithPasswordHash(phone, email, passwordHash, aud string, userData map[string]interface{}) (*User, error) {
+// ValidatePasswordHash checks that the hash is in one of the formats
+// CompareHashAndPassword can verify.
+func ValidatePasswordHash(passwordHash string) error {
 	if strings.HasPrefix(passwordHash, crypto.Argon2Prefix) {
 		_, err := crypto.ParseArgon2Hash(passwordHash)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	} else if strings.HasPrefix(passwordHash, crypto.FirebaseScryptPrefix) {
 		_, err := crypto.ParseFirebaseScryptHash(passwordHash)
 		if err != nil {
-			return nil, err
+			return err
+		}
+	} else if strings.HasPrefix(passwordHash, crypto.PBKDF2SHA256Prefix) {
+		_, err := crypto.ParsePBKDF2Hash(passwordHash)
+		if err != nil {
+			return err
+		}
+	} else if strings.HasPrefix(passwordHash, crypto.SHA256CryptPrefix) || strings.HasPrefix(passwordHash, crypto.SHA512CryptPrefix) {
+		_, err := crypto.ParseSHACryptHash(passwordHash)
+		if err != nil {
+			return err
+		}
+	} else if strings.HasPrefix(passwordHash, crypto.PhpassPrefix) || strings.HasPrefix(passwordHash, crypto.PhpassPhpBBPrefix) {
+		_, err := crypto.ParsePhpassHash(passwordHash)
+		if err != nil {
+			return err
 		}
 	} else {
 		// verify that the hash is a bcrypt hash
 		_, err := bcrypt.Cost([]byte(passwordHash))
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
+
+	return nil
+}
+
+func NewUserWithPasswordHash(phone, email, passwordHash, aud string, userData map[string]interface{}) (*User, error) {
+	if err := ValidatePasswordHash(passwordHash); err != nil {
+		return nil, err
+	}
+
 	id := uuid.Must(uuid.NewV4())
 	user := &User{
 		ID:                id,
@@ -338,15 +376,27 @@ func (u *User) SetPassword(ctx context.Context, password string, encrypt bool, e
 
 	u.EncryptedPassword = &pw
 	if encrypt {
-		es, err := crypto.NewEncryptedString(u.ID.String(), []byte(pw), encryptionKeyID, encryptionKey)
-		if err != nil {
-			return err
-		}
+		return u.EncryptPassword(encryptionKeyID, encryptionKey)
+	}
+
+	return nil
+}
+
+// EncryptPassword encrypts the user's password hash, unless it's already
+// encrypted.
+func (u *User) EncryptPassword(encryptionKeyID, encryptionKey string) error {
+	if u.EncryptedPassword == nil || *u.EncryptedPassword == "" || crypto.ParseEncryptedString(*u.EncryptedPassword) != nil {
+		return nil
+	}
 
-		encryptedPassword := es.String()
-		u.EncryptedPassword = &encryptedPassword
+	es, err := crypto.NewEncryptedString(u.ID.String(), []byte(*u.EncryptedPassword), encryptionKeyID, encryptionKey)
+	if err != nil {
+		return err
 	}
 
+	encryptedPassword := es.String()
+	u.EncryptedPassword = &encryptedPassword
+
 	return nil
 }
 
@@ -365,7 +415,11 @@ func (u *User) UpdatePassword(tx *storage.Connection, sessionID *uuid.UUID) erro
 	u.ReauthenticationToken = ""
 	u.ReauthenticationSentAt = nil
 
-	if err := tx.UpdateOnly(u, "encrypted_password", "confirmation_token", "confirmation_sent_at", "recovery_token", "recovery_sent_at", "email_change_token_current", "email_change_token_new", "email_change_sent_at", "phone_change_token", "phone_change_sent_at", "reauthentication_token", "reauthentication_sent_at"); err != nil {
+	now := time.Now()
+	u.PasswordChangedAt = &now
+	u.MustChangePassword = false
+
+	if err := tx.UpdateOnly(u, "encrypted_password", "password_changed_at", "must_change_password", "confirmation_token", "confirmation_sent_at", "recovery_token", "recovery_sent_at", "email_change_token_current", "email_change_token_new", "email_change_sent_at", "phone_change_token", "phone_change_sent_at", "reauthentication_token", "reauthentication_sent_at"); err != nil {
 		return err
 	}
 
@@ -382,7 +436,11 @@ func (u *User) UpdatePassword(tx *storage.Connection, sessionID *uuid.UUID) erro
 	}
 }
 
-// Authenticate a user from a password
+// Authenticate a user from a password. It also reports whether the
+// password hash should be stored again, because it was rehashed with the
+// current algorithm and cost or needs encrypting with the current key. The
+// unencrypted hash is then left in EncryptedPassword, use EncryptPassword
+// before storing it.
 func (u *User) Authenticate(ctx context.Context, tx *storage.Connection, password string, decryptionKeys map[string]string, encrypt bool, encryptionKeyID string) (bool, bool, error) {
 	if u.EncryptedPassword == nil {
 		return false, false, nil
@@ -404,25 +462,27 @@ func (u *User) Authenticate(ctx context.Context, tx *storage.Connection, passwor
 		hash = string(h)
 	}
 
-	compareErr := crypto.CompareHashAndPassword(ctx, hash, password)
+	if err := crypto.CompareHashAndPassword(ctx, hash, password); err != nil {
+		return false, false, nil
+	}
 
-	if !strings.HasPrefix(hash, crypto.Argon2Prefix) && !strings.HasPrefix(hash, crypto.FirebaseScryptPrefix) {
-		// check if cost exceeds default cost or is too low
-		cost, err := bcrypt.Cost([]byte(hash))
-		if err != nil {
-			return compareErr == nil, false, err
+	if crypto.NeedsRehash(hash) {
+		// don't bother with encrypting the password in Authenticate
+		// since it's handled separately
+		if err := u.SetPassword(ctx, password, false, "", ""); err != nil {
+			return true, false, err
 		}
 
-		if cost > bcrypt.DefaultCost || cost == bcrypt.MinCost {
-			// don't bother with encrypting the password in Authenticate
-			// since it's handled separately
-			if err := u.SetPassword(ctx, password, false, "", ""); err != nil {
-				return compareErr == nil, false, err
-			}
-		}
+		return true, true, nil
 	}
 
-	return compareErr == nil, encrypt && (es == nil || es.ShouldReEncrypt(encryptionKeyID)), nil
+	if encrypt && (es == nil || es.ShouldReEncrypt(encryptionKeyID)) {
+		u.EncryptedPassword = &hash
+
+		return true, true, nil
+	}
+
+	return true, false, nil
 }
 
 // ConfirmReauthentication resets the reauthentication token
@@ -602,6 +662,14 @@ func CountOtherUsers(tx *storage.Connection, id uuid.UUID) (int, error) {
 	return userCount, errors.Wrap(err, "error finding registered users")
 }
 
+// CountLegacyPasswordHashes counts users whose password hash doesn't match
+// the SQL LIKE pattern of hashes generated with the current algorithm and
+// cost. Encrypted hashes can't be inspected and aren't counted.
+func CountLegacyPasswordHashes(tx *storage.Connection, pattern string) (int, error) {
+	count, err := tx.Q().Where("encrypted_password <> '' and encrypted_password not like '{%' and encrypted_password not like ?", pattern).Count(&User{})
+	return count, errors.Wrap(err, "error counting legacy password hashes")
+}
+
 func findUser(tx *storage.Connection, query string, args ...interface{}) (*User, error) {
 	obj := &User{}
 	if err := tx.Eager().Q().Where(query, args...).First(obj); err != nil {
@@ -690,30 +758,69 @@ func FindUserWithRefreshToken(tx *storage.Connection, token string, forUpdate bo
 
 // FindUsersInAudience finds users with the matching audience.
 func FindUsersInAudience(tx *storage.Connection, aud string, pageParams *Pagination, sortParams *SortParams, filter string) ([]*User, error) {
+	return SearchUsers(tx, aud, &UserFilter{Query: filter}, pageParams, sortParams)
+}
+
+// ExportCursor is the position of the last exported user, users are
+// exported in order of creation.
+type ExportCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// FindUsersForExport finds the next page of users in the audience after the
+// cursor, with their identities and factors. Each page is a separate query so
+// exports don't hold a transaction open on the users table. Deleted and
+// anonymous users are left out, as are users created before createdAfter or
+// without an identity of the provider when they're set.
+func FindUsersForExport(tx *storage.Connection, aud string, after *ExportCursor, createdAfter *time.Time, provider string, limit int) ([]*User, error) {
 	users := []*User{}
-	q := tx.Q().Where("instance_id = ? and aud = ?", uuid.Nil, aud)
+	q := tx.Q().Where("instance_id = ? and aud = ? and deleted_at is null and is_anonymous = false", uuid.Nil, aud)
 
-	if filter != "" {
-		lf := "%" + filter + "%"
-		// we must specify the collation in order to get case insensitive search for the JSON column
-		q = q.Where("(email LIKE ? OR raw_user_meta_data->>'full_name' ILIKE ?)", lf, lf)
+	if after != nil {
+		q = q.Where("(created_at, id) > (?, ?)", after.CreatedAt, after.ID)
 	}
 
-	if sortParams != nil && len(sortParams.Fields) > 0 {
-		for _, field := range sortParams.Fields {
-			q = q.Order(field.Name + " " + string(field.Dir))
-		}
+	if createdAfter != nil {
+		q = q.Where("created_at > ?", *createdAfter)
 	}
 
-	var err error
-	if pageParams != nil {
-		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).All(&users) // #nosec G115
-		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)                     // #nosec G115
-	} else {
-		err = q.All(&users)
+	if provider != "" {
+		q = q.Where("exists (select 1 from "+(&pop.Model{Value: Identity{}}).TableName()+" i where i.user_id = "+(&pop.Model{Value: User{}}).TableName()+".id and i.provider = ?)", provider)
+	}
+
+	if err := q.Order("created_at asc, id asc").Limit(limit).All(&users); err != nil {
+		return nil, errors.Wrap(err, "error finding users to export")
+	}
+
+	if len(users) == 0 {
+		return users, nil
 	}
 
-	return users, err
+	userIDs := make([]interface{}, 0, len(users))
+	byID := make(map[uuid.UUID]*User, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
+		byID[user.ID] = user
+	}
+
+	identities := []Identity{}
+	if err := tx.Q().Where("user_id in (?)", userIDs...).Order("created_at asc").All(&identities); err != nil {
+		return nil, errors.Wrap(err, "error finding identities to export")
+	}
+	for _, identity := range identities {
+		byID[identity.UserID].Identities = append(byID[identity.UserID].Identities, identity)
+	}
+
+	factors := []Factor{}
+	if err := tx.Q().Where("user_id in (?)", userIDs...).Order("created_at asc").All(&factors); err != nil {
+		return nil, errors.Wrap(err, "error finding factors to export")
+	}
+	for _, factor := range factors {
+		byID[factor.UserID].Factors = append(byID[factor.UserID].Factors, factor)
+	}
+
+	return users, nil
 }
 
 // IsDuplicatedEmail returns whether a user exists with a matching email and audience.
@@ -806,6 +913,36 @@ func (u *User) HasMFAEnabled() bool {
 	return false
 }
 
+// SetMustChangePassword sets whether the user has to change their password
+// before their sessions are unrestricted.
+func (u *User) SetMustChangePassword(tx *storage.Connection, mustChangePassword bool) error {
+	u.MustChangePassword = mustChangePassword
+	return tx.UpdateOnly(u, "must_change_password")
+}
+
+// PasswordExpired reports whether the user's password is older than the
+// maximum age. Passwords never changed since the user was created are as
+// old as the user.
+func (u *User) PasswordExpired(maxAge time.Duration, now time.Time) bool {
+	if maxAge <= 0 || !u.HasPassword() {
+		return false
+	}
+
+	changedAt := u.CreatedAt
+	if u.PasswordChangedAt != nil {
+		changedAt = *u.PasswordChangedAt
+	}
+
+	return now.After(changedAt.Add(maxAge))
+}
+
+// SetMFARequiredAt records when an MFA policy started requiring the user to
+// enroll a factor, or clears it with nil.
+func (u *User) SetMFARequiredAt(tx *storage.Connection, requiredAt *time.Time) error {
+	u.MFARequiredAt = requiredAt
+	return tx.UpdateOnly(u, "mfa_required_at")
+}
+
 func (u *User) UpdateBannedUntil(tx *storage.Connection) error {
 	return tx.UpdateOnly(u, "banned_until")
 }
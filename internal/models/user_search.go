@@ -0,0 +1,301 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// metaDataPathSegment is a key in a metadata path. Keys are restricted so
+// paths can be passed to Postgres as text arrays.
+var metaDataPathSegment = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
+// userSortExpressions are the expressions users are sorted by for each sort
+// field. Null values are sorted as the lowest value so they can be compared
+// in cursors.
+var userSortExpressions = map[string]string{
+	CreatedAt:    "created_at",
+	LastSignInAt: "coalesce(last_sign_in_at, to_timestamp(0))",
+	EmailAddress: "coalesce(email, '')",
+}
+
+// UserFilter narrows down user searches. Unset fields don't filter.
+type UserFilter struct {
+	// Query matches part of the email address or full name.
+	Query string
+
+	// Provider matches users with an identity of the provider.
+	Provider string
+
+	Confirmed *bool
+	Banned    *bool
+	Anonymous *bool
+	SSOUser   *bool
+	// HasMFA matches users with a verified factor.
+	HasMFA *bool
+
+	CreatedAfter     *time.Time
+	CreatedBefore    *time.Time
+	LastSignInAfter  *time.Time
+	LastSignInBefore *time.Time
+
+	// AppMetaData and UserMetaData match the values at dot separated paths
+	// of the metadata, compared as text.
+	AppMetaData  map[string]string
+	UserMetaData map[string]string
+}
+
+// ValidateMetaDataPath checks that a metadata path of a filter is keys of
+// letters, digits, underscores and dashes separated by dots.
+func ValidateMetaDataPath(path string) error {
+	for _, segment := range strings.Split(path, ".") {
+		if !metaDataPathSegment.MatchString(segment) {
+			return fmt.Errorf("invalid metadata path %q", path)
+		}
+	}
+
+	return nil
+}
+
+func (f *UserFilter) apply(q *pop.Query) (*pop.Query, error) {
+	if f.Query != "" {
+		lf := "%" + f.Query + "%"
+		// we must specify the collation in order to get case insensitive search for the JSON column
+		q = q.Where("(email LIKE ? OR raw_user_meta_data->>'full_name' ILIKE ?)", lf, lf)
+	}
+
+	if f.Provider != "" {
+		q = q.Where("exists (select 1 from "+(&pop.Model{Value: Identity{}}).TableName()+" i where i.user_id = "+(&pop.Model{Value: User{}}).TableName()+".id and i.provider = ?)", f.Provider)
+	}
+
+	if f.Confirmed != nil {
+		q = q.Where(negate("(email_confirmed_at is not null or phone_confirmed_at is not null)", *f.Confirmed))
+	}
+
+	if f.Banned != nil {
+		q = q.Where(negate("(banned_until is not null and banned_until > now())", *f.Banned))
+	}
+
+	if f.Anonymous != nil {
+		q = q.Where("is_anonymous = ?", *f.Anonymous)
+	}
+
+	if f.SSOUser != nil {
+		q = q.Where("is_sso_user = ?", *f.SSOUser)
+	}
+
+	if f.HasMFA != nil {
+		q = q.Where(negate("exists (select 1 from "+(&pop.Model{Value: Factor{}}).TableName()+" f where f.user_id = "+(&pop.Model{Value: User{}}).TableName()+".id and f.status = ?)", *f.HasMFA), FactorStateVerified.String())
+	}
+
+	if f.CreatedAfter != nil {
+		q = q.Where("created_at > ?", *f.CreatedAfter)
+	}
+
+	if f.CreatedBefore != nil {
+		q = q.Where("created_at < ?", *f.CreatedBefore)
+	}
+
+	if f.LastSignInAfter != nil {
+		q = q.Where("last_sign_in_at > ?", *f.LastSignInAfter)
+	}
+
+	if f.LastSignInBefore != nil {
+		q = q.Where("last_sign_in_at < ?", *f.LastSignInBefore)
+	}
+
+	for column, values := range map[string]map[string]string{
+		"raw_app_meta_data":  f.AppMetaData,
+		"raw_user_meta_data": f.UserMetaData,
+	} {
+		for path, value := range values {
+			if err := ValidateMetaDataPath(path); err != nil {
+				return nil, err
+			}
+
+			q = q.Where(column+" #>> ?::text[] = ?", "{"+strings.ReplaceAll(path, ".", ",")+"}", value)
+		}
+	}
+
+	return q, nil
+}
+
+func negate(condition string, match bool) string {
+	if match {
+		return condition
+	}
+
+	return "not " + condition
+}
+
+func orderUsers(q *pop.Query, sortParams *SortParams) *pop.Query {
+	if sortParams == nil || len(sortParams.Fields) == 0 {
+		return q
+	}
+
+	for _, field := range sortParams.Fields {
+		expression, ok := userSortExpressions[field.Name]
+		if !ok {
+			expression = field.Name
+		}
+		q = q.Order(expression + " " + string(field.Dir))
+	}
+
+	// ties are broken by ID so pages are stable
+	return q.Order("id " + string(sortParams.Fields[len(sortParams.Fields)-1].Dir))
+}
+
+// SearchUsers finds the users in the audience matching the filter, a page
+// at a time when pageParams are set.
+func SearchUsers(tx *storage.Connection, aud string, filter *UserFilter, pageParams *Pagination, sortParams *SortParams) ([]*User, error) {
+	users := []*User{}
+	q, err := filter.apply(tx.Q().Where("instance_id = ? and aud = ?", uuid.Nil, aud))
+	if err != nil {
+		return nil, err
+	}
+
+	q = orderUsers(q, sortParams)
+
+	if pageParams != nil {
+		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).All(&users) // #nosec G115
+		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)                     // #nosec G115
+	} else {
+		err = q.All(&users)
+	}
+
+	return users, err
+}
+
+// userCursor is the position of the last user of a page in a search,
+// encoded in the cursor of the next page.
+type userCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    uuid.UUID       `json:"id"`
+}
+
+// InvalidCursorError is a cursor that wasn't returned by a search with the
+// same sort order.
+type InvalidCursorError struct{}
+
+func (e InvalidCursorError) Error() string {
+	return "invalid cursor"
+}
+
+func sortValue(user *User, field string) interface{} {
+	switch field {
+	case LastSignInAt:
+		if user.LastSignInAt == nil {
+			return time.Unix(0, 0).UTC()
+		}
+		return *user.LastSignInAt
+
+	case EmailAddress:
+		return user.GetEmail()
+
+	default:
+		return user.CreatedAt
+	}
+}
+
+func encodeUserCursor(user *User, sort SortField) (string, error) {
+	value, err := json.Marshal(sortValue(user, sort.Name))
+	if err != nil {
+		return "", err
+	}
+
+	cursor, err := json.Marshal(userCursor{
+		Sort:  sort.Name + " " + string(sort.Dir),
+		Value: value,
+		ID:    user.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(cursor), nil
+}
+
+func decodeUserCursor(cursor string, sort SortField) (interface{}, uuid.UUID, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, uuid.Nil, InvalidCursorError{}
+	}
+
+	var c userCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort.Name+" "+string(sort.Dir) {
+		return nil, uuid.Nil, InvalidCursorError{}
+	}
+
+	if sort.Name == EmailAddress {
+		var email string
+		if err := json.Unmarshal(c.Value, &email); err != nil {
+			return nil, uuid.Nil, InvalidCursorError{}
+		}
+		return email, c.ID, nil
+	}
+
+	var t time.Time
+	if err := json.Unmarshal(c.Value, &t); err != nil {
+		return nil, uuid.Nil, InvalidCursorError{}
+	}
+
+	return t, c.ID, nil
+}
+
+// SearchUsersAfter finds up to limit users in the audience matching the
+// filter after the cursor, sorted by the field. Users are compared by the
+// field and their ID, so pages stay stable when users are created or
+// deleted. It also returns the cursor of the next page, which is empty on
+// the last page, and an InvalidCursorError when the cursor is of another
+// search.
+func SearchUsersAfter(tx *storage.Connection, aud string, filter *UserFilter, sort SortField, cursor string, limit int) ([]*User, string, error) {
+	expression, ok := userSortExpressions[sort.Name]
+	if !ok {
+		return nil, "", fmt.Errorf("users can't be sorted by %q", sort.Name)
+	}
+
+	q, err := filter.apply(tx.Q().Where("instance_id = ? and aud = ?", uuid.Nil, aud))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if cursor != "" {
+		value, id, err := decodeUserCursor(cursor, sort)
+		if err != nil {
+			return nil, "", err
+		}
+
+		comparison := ">"
+		if sort.Dir == Descending {
+			comparison = "<"
+		}
+		q = q.Where("("+expression+", id) "+comparison+" (?, ?)", value, id)
+	}
+
+	// one more user than the limit tells whether there's a next page
+	users := []*User{}
+	if err := orderUsers(q, &SortParams{Fields: []SortField{sort}}).Limit(limit + 1).All(&users); err != nil {
+		return nil, "", errors.Wrap(err, "error searching users")
+	}
+
+	if len(users) <= limit {
+		return users, "", nil
+	}
+
+	users = users[:limit]
+	next, err := encodeUserCursor(users[limit-1], sort)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return users, next, nil
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMetaDataPath(t *testing.T) {
+	for _, path := range []string{"plan", "billing.plan", "org-id", "a_b.c_d.0"} {
+		require.NoError(t, ValidateMetaDataPath(path), path)
+	}
+
+	for _, path := range []string{"", ".", "billing.", "a,b", "{a}", "a b", "a'b"} {
+		require.Error(t, ValidateMetaDataPath(path), path)
+	}
+}
+
+func TestUserCursor(t *testing.T) {
+	signedInAt := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	user := &User{
+		CreatedAt:    time.Now(),
+		LastSignInAt: &signedInAt,
+	}
+
+	sort := SortField{Name: LastSignInAt, Dir: Descending}
+	cursor, err := encodeUserCursor(user, sort)
+	require.NoError(t, err)
+
+	value, id, err := decodeUserCursor(cursor, sort)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, id)
+	require.True(t, signedInAt.Equal(value.(time.Time)))
+
+	_, _, err = decodeUserCursor(cursor, SortField{Name: LastSignInAt, Dir: Ascending})
+	require.ErrorIs(t, err, InvalidCursorError{})
+
+	_, _, err = decodeUserCursor("not a cursor", sort)
+	require.ErrorIs(t, err, InvalidCursorError{})
+}
+
+func (ts *UserTestSuite) TestSearchUsers() {
+	jane := ts.createUserWithEmail("jane@example.com")
+	john := ts.createUserWithEmail("john@example.com")
+	anna := ts.createUserWithEmail("anna@example.com")
+
+	now := time.Now()
+	jane.EmailConfirmedAt = &now
+	jane.LastSignInAt = &now
+	require.NoError(ts.T(), ts.db.UpdateOnly(jane, "email_confirmed_at", "last_sign_in_at"))
+	require.NoError(ts.T(), jane.UpdateAppMetaData(ts.db, map[string]interface{}{
+		"billing": map[string]interface{}{"plan": "pro"},
+	}))
+
+	bannedUntil := now.Add(time.Hour)
+	john.BannedUntil = &bannedUntil
+	require.NoError(ts.T(), ts.db.UpdateOnly(john, "banned_until"))
+
+	factor := NewFactor(anna, "app", TOTP, FactorStateVerified)
+	require.NoError(ts.T(), ts.db.Create(factor))
+
+	identity, err := NewIdentity(anna, "google", map[string]interface{}{"sub": "123"})
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.db.Create(identity))
+
+	confirmed, notConfirmed, banned, hasMFA := true, false, true, true
+	for name, c := range map[string]struct {
+		filter   UserFilter
+		expected []*User
+	}{
+		"provider":      {UserFilter{Provider: "google"}, []*User{anna}},
+		"confirmed":     {UserFilter{Confirmed: &confirmed}, []*User{jane}},
+		"not confirmed": {UserFilter{Confirmed: &notConfirmed}, []*User{anna, john}},
+		"banned":        {UserFilter{Banned: &banned}, []*User{john}},
+		"has mfa":       {UserFilter{HasMFA: &hasMFA}, []*User{anna}},
+		"signed in":     {UserFilter{LastSignInAfter: &time.Time{}}, []*User{jane}},
+		"app metadata":  {UserFilter{AppMetaData: map[string]string{"billing.plan": "pro"}}, []*User{jane}},
+		"no match":      {UserFilter{AppMetaData: map[string]string{"billing.plan": "free"}}, nil},
+	} {
+		users, err := SearchUsers(ts.db, "test", &c.filter, nil, &SortParams{Fields: []SortField{{Name: EmailAddress, Dir: Ascending}}})
+		require.NoError(ts.T(), err, name)
+
+		var ids []string
+		for _, user := range users {
+			ids = append(ids, user.ID.String())
+		}
+		var expected []string
+		for _, user := range c.expected {
+			expected = append(expected, user.ID.String())
+		}
+		require.Equal(ts.T(), expected, ids, name)
+	}
+
+	_, err = SearchUsers(ts.db, "test", &UserFilter{UserMetaData: map[string]string{"a,b": "c"}}, nil, nil)
+	require.Error(ts.T(), err)
+}
+
+func (ts *UserTestSuite) TestSearchUsersAfter() {
+	for _, email := range []string{"c@example.com", "a@example.com", "b@example.com"} {
+		ts.createUserWithEmail(email)
+	}
+
+	sort := SortField{Name: EmailAddress, Dir: Ascending}
+
+	users, next, err := SearchUsersAfter(ts.db, "test", &UserFilter{}, sort, "", 2)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), users, 2)
+	require.Equal(ts.T(), "a@example.com", users[0].GetEmail())
+	require.Equal(ts.T(), "b@example.com", users[1].GetEmail())
+	require.NotEmpty(ts.T(), next)
+
+	users, next, err = SearchUsersAfter(ts.db, "test", &UserFilter{}, sort, next, 2)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), users, 1)
+	require.Equal(ts.T(), "c@example.com", users[0].GetEmail())
+	require.Empty(ts.T(), next)
+
+	_, _, err = SearchUsersAfter(ts.db, "test", &UserFilter{}, SortField{Name: LastSignInAt, Dir: Descending}, "not a cursor", 2)
+	require.ErrorIs(ts.T(), err, InvalidCursorError{})
+}
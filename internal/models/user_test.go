@@ -465,3 +465,34 @@ func (ts *UserTestSuite) TestAuthenticate() {
 		})
 	}
 }
+
+func (ts *UserTestSuite) TestAuthenticateRehash() {
+	// argon2i hash of "test"
+	u, err := NewUserWithPasswordHash("", "", "$argon2i$v=19$m=16,t=2,p=1$bGJRWThNOHJJTVBSdHl2dQ$NfEnUOuUpb7F2fQkgFUG4g", "", nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.db.Create(u))
+
+	isAuthenticated, shouldUpdate, err := u.Authenticate(context.Background(), ts.db, "test1", nil, false, "")
+	require.NoError(ts.T(), err)
+	require.False(ts.T(), isAuthenticated)
+	require.False(ts.T(), shouldUpdate)
+
+	isAuthenticated, shouldUpdate, err = u.Authenticate(context.Background(), ts.db, "test", nil, false, "")
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), isAuthenticated)
+	require.True(ts.T(), shouldUpdate)
+
+	// rehashed with bcrypt, the current algorithm
+	_, err = bcrypt.Cost([]byte(*u.EncryptedPassword))
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.db.UpdateOnly(u, "encrypted_password"))
+
+	// tests hash with the minimum bcrypt cost
+	count, err := CountLegacyPasswordHashes(ts.db, "$2_$04$%")
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), 0, count)
+
+	count, err = CountLegacyPasswordHashes(ts.db, "$argon2id$v=19$m=16,t=2,p=1$%")
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), 1, count)
+}
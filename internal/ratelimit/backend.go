@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// Backend counts the events of rate limited keys outside of the process, so
+// every instance using it shares the same limits and they survive restarts.
+//
+// Implementations of Backend must be safe for concurrent use.
+type Backend interface {
+
+	// TakeAt should count an event of the key at the given time if fewer
+	// than events were counted in the window before it, and return the
+	// state of the key's limit after it.
+	TakeAt(ctx context.Context, key string, events int, window time.Duration, at time.Time) (Result, error)
+
+	// ListAt should return the state of the limits of the keys with the
+	// prefix that have events counted, at the given time and without
+	// counting an event, sorted by key.
+	ListAt(ctx context.Context, prefix string, events int, window time.Duration, at time.Time) ([]KeyResult, error)
+
+	// Clear should forget the events counted for the key.
+	Clear(ctx context.Context, key string) error
+
+	// ClearPrefix should forget the events counted for the keys with the
+	// prefix.
+	ClearPrefix(ctx context.Context, prefix string) error
+}
+
+// KeyResult is the state of the limit of a key.
+type KeyResult struct {
+	Key string
+	Result
+}
+
+// PostgresBackend counts events in sliding windows in the rate_limits table.
+type PostgresBackend struct {
+	db *storage.Connection
+}
+
+// NewPostgresBackend returns a Backend storing counts in the database.
+func NewPostgresBackend(db *storage.Connection) *PostgresBackend {
+	return &PostgresBackend{db: db}
+}
+
+// TakeAt implements Backend with an atomic upsert of the key's counts. The
+// event is counted at the database's time rather than the given one, so
+// that instances with skewed clocks count it in the same window.
+func (b *PostgresBackend) TakeAt(ctx context.Context, key string, events int, window time.Duration, at time.Time) (Result, error) {
+	rl, err := models.TakeRateLimit(b.db.WithContext(ctx), key, events, window)
+	if err != nil {
+		return Result{}, err
+	}
+
+	elapsed := min(max(at.Sub(rl.WindowStart), 0), window)
+	return slidingWindowResult(rl.Allowed, events, rl.PreviousCount, rl.Count, window, elapsed), nil
+}
+
+// ListAt implements Backend by finding the keys' counts, and sliding their
+// windows to the given time.
+func (b *PostgresBackend) ListAt(ctx context.Context, prefix string, events int, window time.Duration, at time.Time) ([]KeyResult, error) {
+	rateLimits, err := models.FindRateLimits(b.db.WithContext(ctx), prefix, at)
+	if err != nil {
+		return nil, err
+	}
+
+	windowStart := at.Truncate(window)
+
+	results := []KeyResult{}
+	for _, rl := range rateLimits {
+		var previous, current int
+		switch {
+		case rl.WindowStart.Equal(windowStart):
+			previous, current = rl.PreviousCount, rl.Count
+		case rl.WindowStart.Equal(windowStart.Add(-window)):
+			previous = rl.Count
+		}
+
+		if previous == 0 && current == 0 {
+			continue
+		}
+
+		res := slidingWindowResult(false, events, previous, current, window, at.Sub(windowStart))
+		res.Allowed = res.Remaining > 0
+		results = append(results, KeyResult{Key: rl.Key, Result: res})
+	}
+
+	return results, nil
+}
+
+// Clear implements Backend by deleting the key's counts.
+func (b *PostgresBackend) Clear(ctx context.Context, key string) error {
+	return models.ClearRateLimit(b.db.WithContext(ctx), key)
+}
+
+// ClearPrefix implements Backend by deleting the counts of the keys with the
+// prefix.
+func (b *PostgresBackend) ClearPrefix(ctx context.Context, prefix string) error {
+	return models.ClearRateLimits(b.db.WithContext(ctx), prefix)
+}
+
+// BackendLimiter is a Limiter counting the events of a single key in a
+// Backend.
+type BackendLimiter struct {
+	backend Backend
+	key     string
+	events  int
+	window  time.Duration
+}
+
+// NewBackendLimiter returns a Limiter allowing r.Events per r.OverTime
+// sliding window, counted in the backend under the key.
+func NewBackendLimiter(backend Backend, key string, r conf.Rate) *BackendLimiter {
+	window := r.OverTime
+	if window <= 0 {
+		window = defaultOverTime
+	}
+
+	return &BackendLimiter{
+		backend: backend,
+		key:     key,
+		events:  int(r.Events),
+		window:  window,
+	}
+}
+
+// Allow implements Limiter by calling AllowAt with the current time.
+func (l *BackendLimiter) Allow() bool {
+	return l.AllowAt(time.Now())
+}
+
+// AllowAt implements Limiter by calling TakeAt with the given time, outside
+// of any request.
+func (l *BackendLimiter) AllowAt(at time.Time) bool {
+	return l.TakeAt(context.Background(), at).Allowed
+}
+
+// TakeAt implements Limiter by counting the event in the backend. Events
+// are not allowed when the backend fails to count them.
+func (l *BackendLimiter) TakeAt(ctx context.Context, at time.Time) Result {
+	res, err := l.backend.TakeAt(ctx, l.key, l.events, l.window, at)
+	if err != nil {
+		logrus.WithError(err).WithField("key", l.key).Error("unable to count rate limited event")
+		return Result{Limit: l.events}
+	}
+
+	return res
+}
+
+// PeekAt implements Limiter by finding the key's limit in the backend.
+func (l *BackendLimiter) PeekAt(ctx context.Context, at time.Time) Result {
+	results, err := l.backend.ListAt(ctx, l.key, l.events, l.window, at)
+	if err != nil {
+		logrus.WithError(err).WithField("key", l.key).Error("unable to find rate limited events")
+		return Result{Limit: l.events}
+	}
+
+	for _, res := range results {
+		if res.Key == l.key {
+			return res.Result
+		}
+	}
+
+	return Result{Allowed: l.events > 0, Limit: l.events, Remaining: l.events}
+}
+
+// Reset implements Limiter by clearing the key in the backend.
+func (l *BackendLimiter) Reset(ctx context.Context) error {
+	return l.backend.Clear(ctx, l.key)
+}
@@ -0,0 +1,203 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linkly-id/auth/internal/conf"
+)
+
+type testBackend struct {
+	counts map[string]int
+	err    error
+
+	events int
+	window time.Duration
+}
+
+func (b *testBackend) TakeAt(ctx context.Context, key string, events int, window time.Duration, at time.Time) (Result, error) {
+	b.events, b.window = events, window
+	if b.err != nil {
+		return Result{}, b.err
+	}
+
+	if b.counts[key] >= events {
+		return Result{Limit: events}, nil
+	}
+	b.counts[key]++
+
+	return Result{Allowed: true, Limit: events, Remaining: events - b.counts[key]}, nil
+}
+
+func (b *testBackend) ListAt(ctx context.Context, prefix string, events int, window time.Duration, at time.Time) ([]KeyResult, error) {
+	var results []KeyResult
+	for key, count := range b.counts {
+		if strings.HasPrefix(key, prefix) {
+			results = append(results, KeyResult{Key: key, Result: Result{Allowed: count < events, Limit: events, Remaining: events - count}})
+		}
+	}
+
+	return results, b.err
+}
+
+func (b *testBackend) Clear(ctx context.Context, key string) error {
+	delete(b.counts, key)
+	return b.err
+}
+
+func (b *testBackend) ClearPrefix(ctx context.Context, prefix string) error {
+	for key := range b.counts {
+		if strings.HasPrefix(key, prefix) {
+			delete(b.counts, key)
+		}
+	}
+	return b.err
+}
+
+func TestBackendLimiter(t *testing.T) {
+	backend := &testBackend{counts: make(map[string]int)}
+
+	rl := NewBackendLimiter(backend, "email_sent", conf.Rate{Events: 2, OverTime: time.Minute})
+	for i, exp := range []bool{true, true, false} {
+		if got := rl.Allow(); exp != got {
+			t.Fatalf("exp Allow() #%d to be %v; got %v", i, exp, got)
+		}
+	}
+	if exp, got := 2, backend.events; exp != got {
+		t.Fatalf("exp %v events; got %v", exp, got)
+	}
+	if exp, got := time.Minute, backend.window; exp != got {
+		t.Fatalf("exp %v window; got %v", exp, got)
+	}
+	if exp, got := 2, backend.counts["email_sent"]; exp != got {
+		t.Fatalf("exp %v counted events; got %v", exp, got)
+	}
+	if exp, got := (Result{Limit: 2}), rl.TakeAt(context.Background(), time.Now()); exp != got {
+		t.Fatalf("exp TakeAt() to be %+v; got %+v", exp, got)
+	}
+
+	if exp, got := (Result{Limit: 2}), rl.PeekAt(context.Background(), time.Now()); exp != got {
+		t.Fatalf("exp PeekAt() to be %+v; got %+v", exp, got)
+	}
+	if err := rl.Reset(context.Background()); err != nil {
+		t.Fatalf("exp nil err; got %v", err)
+	}
+	if exp, got := (Result{Allowed: true, Limit: 2, Remaining: 2}), rl.PeekAt(context.Background(), time.Now()); exp != got {
+		t.Fatalf("exp PeekAt() after Reset() to be %+v; got %+v", exp, got)
+	}
+
+	NewBackendLimiter(backend, "sms_sent", conf.Rate{Events: 1}).Allow()
+	if exp, got := defaultOverTime, backend.window; exp != got {
+		t.Fatalf("exp default %v window; got %v", exp, got)
+	}
+
+	// events aren't allowed when the backend fails
+	backend.err = errors.New("database is down")
+	if exp, got := false, NewBackendLimiter(backend, "other", conf.Rate{Events: 2, OverTime: time.Minute}).Allow(); exp != got {
+		t.Fatalf("exp Allow() to be %v; got %v", exp, got)
+	}
+}
+
+func TestSlidingWindowResult(t *testing.T) {
+	cases := []struct {
+		desc     string
+		allowed  bool
+		limit    int
+		previous int
+		current  int
+		elapsed  time.Duration
+		exp      Result
+	}{
+		{
+			desc:    "events remain until the window ends",
+			allowed: true, limit: 3, current: 1, elapsed: 15 * time.Second,
+			exp: Result{Allowed: true, Limit: 3, Remaining: 2, Reset: 45 * time.Second},
+		},
+		{
+			desc:    "the previous window's events are weighted",
+			allowed: true, limit: 3, previous: 2, current: 1, elapsed: 30 * time.Second,
+			exp: Result{Allowed: true, Limit: 3, Remaining: 1, Reset: 30 * time.Second},
+		},
+		{
+			desc:    "the previous window's events expire as it slides on",
+			allowed: false, limit: 3, previous: 4, current: 1, elapsed: 15 * time.Second,
+			exp: Result{Allowed: false, Limit: 3, Remaining: 0, Reset: 15 * time.Second},
+		},
+		{
+			desc:    "this window's events expire in the next one",
+			allowed: false, limit: 3, previous: 0, current: 4, elapsed: 45 * time.Second,
+			exp: Result{Allowed: false, Limit: 3, Remaining: 0, Reset: 30 * time.Second},
+		},
+		{
+			desc:    "nothing is allowed without a limit",
+			allowed: false, limit: 0, elapsed: 45 * time.Second,
+			exp: Result{Allowed: false, Limit: 0, Remaining: 0, Reset: 15 * time.Second},
+		},
+	}
+
+	for _, c := range cases {
+		got := slidingWindowResult(c.allowed, c.limit, c.previous, c.current, time.Minute, c.elapsed)
+		if c.exp != got {
+			t.Fatalf("%s: exp %+v; got %+v", c.desc, c.exp, got)
+		}
+	}
+}
+
+func TestMemoryBackend(t *testing.T) {
+	ctx := context.Background()
+	now, _ := time.Parse(time.RFC3339, "2024-09-24T10:00:00.00Z")
+	backend := NewMemoryBackend()
+
+	for i := 0; i < 3; i++ {
+		res, err := backend.TakeAt(ctx, "a", 3, time.Minute, now)
+		if err != nil {
+			t.Fatalf("exp nil err; got %v", err)
+		}
+		if exp := (Result{Allowed: true, Limit: 3, Remaining: 2 - i, Reset: 20 * time.Second}); exp != res {
+			t.Fatalf("exp TakeAt() #%d to be %+v; got %+v", i, exp, res)
+		}
+	}
+
+	res, _ := backend.TakeAt(ctx, "a", 3, time.Minute, now.Add(5*time.Second))
+	if exp := (Result{Allowed: false, Limit: 3, Remaining: 0, Reset: 15 * time.Second}); exp != res {
+		t.Fatalf("exp %+v; got %+v", exp, res)
+	}
+
+	if res, _ := backend.TakeAt(ctx, "b", 3, time.Minute, now); !res.Allowed {
+		t.Fatalf("exp keys to be counted separately")
+	}
+
+	// a token is back after a third of the window
+	if res, _ := backend.TakeAt(ctx, "a", 3, time.Minute, now.Add(20*time.Second)); !res.Allowed {
+		t.Fatalf("exp event to be allowed after a token is back")
+	}
+
+	results, _ := backend.ListAt(ctx, "a", 3, time.Minute, now.Add(20*time.Second))
+	if exp := []KeyResult{{Key: "a", Result: Result{Allowed: false, Limit: 3, Remaining: 0, Reset: 20 * time.Second}}}; !reflect.DeepEqual(exp, results) {
+		t.Fatalf("exp ListAt() to be %+v; got %+v", exp, results)
+	}
+	// the bucket of b is full again
+	if results, _ := backend.ListAt(ctx, "", 3, time.Minute, now.Add(20*time.Second)); len(results) != 1 {
+		t.Fatalf("exp ListAt() to list 1 key; got %+v", results)
+	}
+
+	backend.Clear(ctx, "a")
+	if res, _ := backend.TakeAt(ctx, "a", 3, time.Minute, now.Add(20*time.Second)); res.Remaining != 2 {
+		t.Fatalf("exp cleared key to have a full bucket; got %+v", res)
+	}
+	backend.ClearPrefix(ctx, "")
+	if results, _ := backend.ListAt(ctx, "", 3, time.Minute, now.Add(20*time.Second)); len(results) != 0 {
+		t.Fatalf("exp ListAt() to list no keys; got %+v", results)
+	}
+
+	// full buckets are swept
+	backend.TakeAt(ctx, "b", 3, time.Minute, now.Add(20*time.Second))
+	backend.TakeAt(ctx, "c", 3, time.Minute, now.Add(2*time.Minute))
+	if exp, got := 1, len(backend.buckets); exp != got {
+		t.Fatalf("exp %v buckets; got %v", exp, got)
+	}
+}
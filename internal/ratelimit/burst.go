@@ -1,6 +1,8 @@
 package ratelimit
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/linkly-id/auth/internal/conf"
@@ -11,6 +13,9 @@ const defaultOverTime = time.Hour
 
 // BurstLimiter wraps the golang.org/x/time/rate package.
 type BurstLimiter struct {
+	mu sync.Mutex
+
+	// Guarded by mu, so it can be replaced by Reset.
 	rl *rate.Limiter
 }
 
@@ -56,5 +61,38 @@ func (l *BurstLimiter) Allow() bool {
 // AllowAt implements Limiter by calling the underlying x/time/rate.Limiter
 // with the given time.
 func (l *BurstLimiter) AllowAt(at time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	return l.rl.AllowN(at, 1)
 }
+
+// TakeAt implements Limiter by calling the underlying x/time/rate.Limiter
+// with the given time, and returning the tokens left in it.
+func (l *BurstLimiter) TakeAt(ctx context.Context, at time.Time) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	allowed := l.rl.AllowN(at, 1)
+	return tokenBucketResult(allowed, l.rl.Burst(), l.rl.TokensAt(at), float64(l.rl.Limit()))
+}
+
+// PeekAt implements Limiter by returning the tokens in the underlying
+// x/time/rate.Limiter at the given time.
+func (l *BurstLimiter) PeekAt(ctx context.Context, at time.Time) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tokens := l.rl.TokensAt(at)
+	return tokenBucketResult(tokens >= 1, l.rl.Burst(), tokens, float64(l.rl.Limit()))
+}
+
+// Reset implements Limiter by replacing the underlying x/time/rate.Limiter
+// with a full one.
+func (l *BurstLimiter) Reset(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rl = rate.NewLimiter(l.rl.Limit(), l.rl.Burst())
+	return nil
+}
@@ -1,6 +1,7 @@
 package ratelimit
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -60,6 +61,31 @@ func TestBurstLimiter(t *testing.T) {
 		}
 	})
 
+	t.Run("TakeAt", func(t *testing.T) {
+		now, _ := time.Parse(time.RFC3339, "2024-09-24T10:00:00.00Z")
+		rl := NewBurstLimiter(conf.Rate{Events: 2, OverTime: time.Second * 20})
+
+		for i, exp := range []Result{
+			{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second * 20},
+			{Allowed: true, Limit: 2, Remaining: 0, Reset: time.Millisecond * 17500},
+			{Allowed: false, Limit: 2, Remaining: 0, Reset: time.Second * 15},
+		} {
+			if got := rl.TakeAt(context.Background(), now.Add(time.Duration(i)*time.Second*5/2)); exp != got {
+				t.Fatalf("exp TakeAt() #%d to be %+v; got %+v", i, exp, got)
+			}
+		}
+
+		if exp, got := (Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second * 20}), rl.PeekAt(context.Background(), now.Add(time.Second*20)); exp != got {
+			t.Fatalf("exp PeekAt() to be %+v; got %+v", exp, got)
+		}
+		if err := rl.Reset(context.Background()); err != nil {
+			t.Fatalf("exp nil err; got %v", err)
+		}
+		if exp, got := 2, rl.PeekAt(context.Background(), now).Remaining; exp != got {
+			t.Fatalf("exp %v remaining after Reset(); got %v", exp, got)
+		}
+	})
+
 	t.Run("AllowAt", func(t *testing.T) {
 		now, _ := time.Parse(time.RFC3339, "2024-09-24T10:00:00.00Z")
 
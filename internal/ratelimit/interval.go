@@ -1,6 +1,7 @@
 package ratelimit
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -49,12 +50,58 @@ func (rl *IntervalLimiter) AllowAt(at time.Time) bool {
 	return rl.allowAt(at)
 }
 
-func (rl *IntervalLimiter) allowAt(at time.Time) bool {
-	since := at.Sub(rl.last)
-	if ivals := int64(since / rl.ival); ivals > 0 {
-		rl.last = rl.last.Add(time.Duration(ivals) * rl.ival)
-		rl.count = 0
+// TakeAt implements Limiter like AllowAt, and returns the events left in
+// the current interval.
+func (rl *IntervalLimiter) TakeAt(ctx context.Context, at time.Time) Result {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	allowed := rl.allowAt(at)
+	return Result{
+		Allowed:   allowed,
+		Limit:     rl.limit,
+		Remaining: max(rl.limit-rl.count, 0),
+		Reset:     rl.last.Add(rl.ival).Sub(at),
+	}
+}
+
+// PeekAt implements Limiter by returning the events left in the interval
+// of the given time.
+func (rl *IntervalLimiter) PeekAt(ctx context.Context, at time.Time) Result {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	last, count := rl.intervalAt(at)
+	return Result{
+		Allowed:   count < rl.limit,
+		Limit:     rl.limit,
+		Remaining: max(rl.limit-count, 0),
+		Reset:     last.Add(rl.ival).Sub(at),
+	}
+}
+
+// Reset implements Limiter by forgetting the events of the current
+// interval.
+func (rl *IntervalLimiter) Reset(ctx context.Context) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.count = 0
+	return nil
+}
+
+// intervalAt returns the start of the interval of the given time and the
+// events counted in it.
+func (rl *IntervalLimiter) intervalAt(at time.Time) (time.Time, int) {
+	if ivals := int64(at.Sub(rl.last) / rl.ival); ivals > 0 {
+		return rl.last.Add(time.Duration(ivals) * rl.ival), 0
 	}
+
+	return rl.last, rl.count
+}
+
+func (rl *IntervalLimiter) allowAt(at time.Time) bool {
+	rl.last, rl.count = rl.intervalAt(at)
 	if rl.count < rl.limit {
 		rl.count++
 		return true
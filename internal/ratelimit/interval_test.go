@@ -1,6 +1,7 @@
 package ratelimit
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -78,4 +79,30 @@ func TestNewIntervalLimiter(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("TakeAt", func(t *testing.T) {
+		now, _ := time.Parse(time.RFC3339, "2024-09-24T10:00:00.00Z")
+		rl := NewIntervalLimiter(conf.Rate{Events: 2, OverTime: time.Hour})
+		rl.last = now
+
+		for i, exp := range []Result{
+			{Allowed: true, Limit: 2, Remaining: 1, Reset: 50 * time.Minute},
+			{Allowed: true, Limit: 2, Remaining: 0, Reset: 50 * time.Minute},
+			{Allowed: false, Limit: 2, Remaining: 0, Reset: 50 * time.Minute},
+		} {
+			if got := rl.TakeAt(context.Background(), now.Add(10*time.Minute)); exp != got {
+				t.Fatalf("exp TakeAt() #%d to be %+v; got %+v", i, exp, got)
+			}
+		}
+
+		if exp, got := (Result{Allowed: true, Limit: 2, Remaining: 2, Reset: 50 * time.Minute}), rl.PeekAt(context.Background(), now.Add(70*time.Minute)); exp != got {
+			t.Fatalf("exp PeekAt() in the next interval to be %+v; got %+v", exp, got)
+		}
+		if err := rl.Reset(context.Background()); err != nil {
+			t.Fatalf("exp nil err; got %v", err)
+		}
+		if exp, got := true, rl.AllowAt(now.Add(10*time.Minute)); exp != got {
+			t.Fatalf("exp AllowAt() after Reset() to be %v; got %v", exp, got)
+		}
+	})
 }
@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// sweepInterval is how often expired buckets are removed from a
+// MemoryBackend.
+const sweepInterval = time.Minute
+
+// MemoryBackend is a Backend counting events in token buckets in the memory
+// of the process. A key's bucket holds events tokens and is refilled over
+// the window.
+type MemoryBackend struct {
+	mu sync.Mutex
+
+	// Guarded by mu.
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	rl *rate.Limiter
+
+	// expiresAt is when the bucket is full again.
+	expiresAt time.Time
+}
+
+// NewMemoryBackend returns an empty MemoryBackend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		buckets: make(map[string]*memoryBucket),
+	}
+}
+
+// TakeAt implements Backend by taking a token from the key's bucket.
+func (b *MemoryBackend) TakeAt(ctx context.Context, key string, events int, window time.Duration, at time.Time) (Result, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sweep(at)
+
+	perSecond := 0.0
+	if window > 0 {
+		perSecond = float64(events) / window.Seconds()
+	}
+
+	bucket, ok := b.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{
+			rl: rate.NewLimiter(rate.Limit(perSecond), events),
+		}
+		b.buckets[key] = bucket
+	}
+	bucket.expiresAt = at.Add(window)
+
+	allowed := bucket.rl.AllowN(at, 1)
+	return tokenBucketResult(allowed, events, bucket.rl.TokensAt(at), perSecond), nil
+}
+
+// ListAt implements Backend by returning the tokens in the buckets of the
+// keys with the prefix that aren't full.
+func (b *MemoryBackend) ListAt(ctx context.Context, prefix string, events int, window time.Duration, at time.Time) ([]KeyResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	results := []KeyResult{}
+	for key, bucket := range b.buckets {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		tokens := bucket.rl.TokensAt(at)
+		if tokens >= float64(bucket.rl.Burst()) {
+			continue
+		}
+
+		results = append(results, KeyResult{
+			Key:    key,
+			Result: tokenBucketResult(tokens >= 1, bucket.rl.Burst(), tokens, float64(bucket.rl.Limit())),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Key < results[j].Key
+	})
+
+	return results, nil
+}
+
+// Clear implements Backend by removing the key's bucket.
+func (b *MemoryBackend) Clear(ctx context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.buckets, key)
+	return nil
+}
+
+// ClearPrefix implements Backend by removing the buckets of the keys with the
+// prefix.
+func (b *MemoryBackend) ClearPrefix(ctx context.Context, prefix string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for key := range b.buckets {
+		if strings.HasPrefix(key, prefix) {
+			delete(b.buckets, key)
+		}
+	}
+	return nil
+}
+
+// sweep removes the buckets that are full again, as they count nothing.
+func (b *MemoryBackend) sweep(at time.Time) {
+	if at.Sub(b.lastSweep) < sweepInterval {
+		return
+	}
+
+	for key, bucket := range b.buckets {
+		if at.After(bucket.expiresAt) {
+			delete(b.buckets, key)
+		}
+	}
+	b.lastSweep = at
+}
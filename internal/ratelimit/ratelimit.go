@@ -1,6 +1,8 @@
 package ratelimit
 
 import (
+	"context"
+	"math"
 	"time"
 
 	"github.com/linkly-id/auth/internal/conf"
@@ -18,6 +20,82 @@ type Limiter interface {
 	// AllowAt should return true if an event should be allowed at the given
 	// time, or false otherwise.
 	AllowAt(at time.Time) bool
+
+	// TakeAt should count an event at the given time like AllowAt, and
+	// return the state of the limit after it. Limiters counting events
+	// outside of the process use the context of the request.
+	TakeAt(ctx context.Context, at time.Time) Result
+
+	// PeekAt should return the state of the limit at the given time
+	// without counting an event.
+	PeekAt(ctx context.Context, at time.Time) Result
+
+	// Reset should forget the counted events.
+	Reset(ctx context.Context) error
+}
+
+// Result is the state of a limit after an event was counted.
+type Result struct {
+	// Allowed is whether the event was allowed.
+	Allowed bool
+
+	// Limit is the number of events allowed at once.
+	Limit int
+
+	// Remaining is the number of events allowed after this one.
+	Remaining int
+
+	// Reset is the time until more events are allowed: until the next
+	// event is when none remain.
+	Reset time.Duration
+}
+
+// tokenBucketResult returns the result of an event taken from a token
+// bucket of limit tokens, refilled at perSecond, with tokens left in it.
+func tokenBucketResult(allowed bool, limit int, tokens, perSecond float64) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: max(int(math.Floor(tokens)), 0),
+	}
+
+	if perSecond > 0 {
+		// until the bucket holds another whole token
+		res.Reset = time.Duration((math.Floor(tokens) + 1 - tokens) / perSecond * float64(time.Second))
+	}
+
+	return res
+}
+
+// slidingWindowResult returns the result of an event counted in a sliding
+// window, of which elapsed has passed, with previous events counted in the
+// window before it and current ones in this window.
+func slidingWindowResult(allowed bool, limit, previous, current int, window, elapsed time.Duration) Result {
+	weighted := int(math.Floor(float64(previous) * (1 - float64(elapsed)/float64(window))))
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: max(limit-weighted-current, 0),
+		Reset:     window - elapsed,
+	}
+
+	switch {
+	case res.Remaining > 0 || limit <= 0:
+		// the events of this window start to expire once it ends
+
+	case current < limit:
+		// the events of the previous window expire as it slides on
+		res.Reset = time.Duration(float64(window)*(1-float64(limit-current)/float64(previous))) - elapsed
+
+	default:
+		// the events of this window expire as the next one slides on
+		res.Reset += time.Duration(float64(window) * (1 - float64(limit)/float64(current)))
+	}
+
+	res.Reset = max(res.Reset, 0)
+
+	return res
 }
 
 // New returns a new Limiter based on the given config.
@@ -0,0 +1,135 @@
+package userexport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/linkly-id/auth/internal/userimport"
+)
+
+// DefaultPageSize is the number of users read per query by default.
+const DefaultPageSize = 1000
+
+// Options configure an export.
+type Options struct {
+	// Aud is the audience of exported users.
+	Aud string
+
+	// CreatedAfter only exports users created after it.
+	CreatedAfter *time.Time
+	// Provider only exports users with an identity of the provider.
+	Provider string
+
+	// IncludeSecrets exports password hashes and TOTP secrets as they're
+	// stored, which is encrypted when database encryption is enabled.
+	// Importing them requires the same decryption keys.
+	IncludeSecrets bool
+
+	PageSize int
+}
+
+// Export writes the users as NDJSON records in the format of the import
+// file, in order of creation. Users are read a page at a time, and each
+// page is flushed when w is an http.Flusher. It returns the number of
+// exported users.
+func Export(ctx context.Context, db *storage.Connection, opts Options, w io.Writer) (int, error) {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultPageSize
+	}
+
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	var count int
+	var cursor *models.ExportCursor
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		users, err := models.FindUsersForExport(db.WithContext(ctx), opts.Aud, cursor, opts.CreatedAfter, opts.Provider, opts.PageSize)
+		if err != nil {
+			return count, err
+		}
+
+		for _, user := range users {
+			if err := encoder.Encode(NewRecord(user, opts.IncludeSecrets)); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(users) < opts.PageSize {
+			return count, nil
+		}
+
+		last := users[len(users)-1]
+		cursor = &models.ExportCursor{
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		}
+	}
+}
+
+// NewRecord returns the import record of the user. Email and phone
+// identities are left out, as importing creates them from the user's email
+// address and phone number.
+func NewRecord(user *models.User, includeSecrets bool) *userimport.Record {
+	createdAt := user.CreatedAt
+
+	record := &userimport.Record{
+		ID:               user.ID.String(),
+		Email:            user.GetEmail(),
+		Phone:            user.GetPhone(),
+		Role:             user.Role,
+		UserMetaData:     user.UserMetaData,
+		AppMetaData:      user.AppMetaData,
+		EmailConfirmedAt: user.EmailConfirmedAt,
+		PhoneConfirmedAt: user.PhoneConfirmedAt,
+		CreatedAt:        &createdAt,
+	}
+
+	if includeSecrets && user.EncryptedPassword != nil {
+		record.PasswordHash = *user.EncryptedPassword
+	}
+
+	for _, identity := range user.Identities {
+		if identity.Provider == "email" || identity.Provider == "phone" {
+			continue
+		}
+
+		record.Identities = append(record.Identities, userimport.Identity{
+			Provider:     identity.Provider,
+			ProviderID:   identity.ProviderID,
+			IdentityData: identity.IdentityData,
+		})
+	}
+
+	for _, factor := range user.Factors {
+		f := userimport.Factor{
+			ID:           factor.ID.String(),
+			FactorType:   factor.FactorType,
+			FriendlyName: factor.FriendlyName,
+			Status:       factor.Status,
+			Phone:        string(factor.Phone),
+			Email:        string(factor.Email),
+		}
+
+		if includeSecrets && factor.FactorType == models.TOTP {
+			f.Secret = factor.Secret
+		}
+
+		record.Factors = append(record.Factors, f)
+	}
+
+	return record
+}
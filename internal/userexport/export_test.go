@@ -0,0 +1,119 @@
+package userexport
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/storage"
+	"github.com/linkly-id/auth/internal/userimport"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testEncryptionKeyID = "key-id"
+	testEncryptionKey   = "pwFoiPyybQMqNmYVN0gUnpbfpGQV2sDv9vp0ZAxi_Y4"
+)
+
+func newTestUser(t *testing.T) *models.User {
+	user, err := models.NewUserWithPasswordHash("15555550100", "jane@example.com", "$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0", "authenticated", map[string]interface{}{
+		"name": "Jane",
+	})
+	require.NoError(t, err)
+	require.NoError(t, user.EncryptPassword(testEncryptionKeyID, testEncryptionKey))
+
+	confirmedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	user.EmailConfirmedAt = &confirmedAt
+	user.CreatedAt = confirmedAt
+	user.AppMetaData = map[string]interface{}{
+		"provider":  "email",
+		"providers": []interface{}{"email", "phone", "google"},
+	}
+
+	for _, provider := range []string{"email", "phone", "google"} {
+		identity, err := models.NewIdentity(user, provider, map[string]interface{}{
+			"sub": "123",
+		})
+		require.NoError(t, err)
+		user.Identities = append(user.Identities, *identity)
+	}
+
+	totp := models.NewFactor(user, "app", models.TOTP, models.FactorStateVerified)
+	require.NoError(t, totp.SetSecret("JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP", true, testEncryptionKeyID, testEncryptionKey))
+	phone := models.NewPhoneFactor(user, "15555550101", "sms")
+	phone.Status = models.FactorStateVerified.String()
+	webauthn := models.NewWebAuthnFactor(user, "key")
+	user.Factors = []models.Factor{*totp, *phone, *webauthn}
+
+	return user
+}
+
+func readRecord(t *testing.T, record *userimport.Record) *userimport.Record {
+	var buf bytes.Buffer
+	require.NoError(t, json.NewEncoder(&buf).Encode(record))
+
+	reader, err := userimport.NewReader(&buf, userimport.FormatNDJSON)
+	require.NoError(t, err)
+
+	imported, err := reader.Next()
+	require.NoError(t, err)
+
+	return imported
+}
+
+func TestNewRecord(t *testing.T) {
+	user := newTestUser(t)
+
+	record := NewRecord(user, false)
+	require.Equal(t, user.ID.String(), record.ID)
+	require.Equal(t, "jane@example.com", record.Email)
+	require.Equal(t, "15555550100", record.Phone)
+	require.Equal(t, "Jane", record.UserMetaData["name"])
+	require.Empty(t, record.PasswordHash)
+
+	require.Len(t, record.Identities, 1)
+	require.Equal(t, "google", record.Identities[0].Provider)
+
+	require.Len(t, record.Factors, 3)
+	require.Empty(t, record.Factors[0].Secret)
+	require.Equal(t, "15555550101", record.Factors[1].Phone)
+
+	imported := readRecord(t, record)
+	require.NoError(t, imported.Validate())
+}
+
+func TestNewRecordWithSecrets(t *testing.T) {
+	user := newTestUser(t)
+
+	record := NewRecord(user, true)
+	require.Equal(t, *user.EncryptedPassword, record.PasswordHash)
+	require.Equal(t, user.Factors[0].Secret, record.Factors[0].Secret)
+
+	imported := readRecord(t, record)
+	require.Error(t, imported.Validate(), "encrypted secrets are only imported with the decryption keys")
+
+	imported = readRecord(t, record)
+	require.Error(t, imported.Decrypt(map[string]string{testEncryptionKeyID: "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}))
+
+	require.NoError(t, imported.Decrypt(map[string]string{testEncryptionKeyID: testEncryptionKey}))
+	require.NoError(t, imported.Validate())
+	require.Equal(t, "$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0", imported.PasswordHash)
+	require.Equal(t, "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP", imported.Factors[0].Secret)
+}
+
+func TestNewRecordWithoutEmail(t *testing.T) {
+	user := &models.User{
+		ID:    uuid.Must(uuid.NewV4()),
+		Phone: storage.NullString("15555550100"),
+	}
+
+	record := NewRecord(user, true)
+	require.Empty(t, record.Email)
+	require.Empty(t, record.PasswordHash)
+	require.Nil(t, record.Factors)
+
+	require.NoError(t, readRecord(t, record).Validate())
+}
@@ -0,0 +1,692 @@
+package userimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/fatih/structs"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/api/provider"
+	"github.com/linkly-id/auth/internal/conf"
+	"github.com/linkly-id/auth/internal/models"
+	"github.com/linkly-id/auth/internal/storage"
+)
+
+// Policies for records of users that already exist.
+const (
+	// OnConflictSkip leaves existing users as they are.
+	OnConflictSkip = "skip"
+	// OnConflictUpdate sets the password hash, metadata and confirmation
+	// timestamps of existing users from the record, and adds the
+	// identities and factors they don't have yet. Email addresses and
+	// phone numbers aren't changed.
+	OnConflictUpdate = "update"
+)
+
+// DefaultBatchSize is the number of records inserted per transaction by
+// default.
+const DefaultBatchSize = 500
+
+// Statuses of records in the import report.
+const (
+	StatusCreated = "created"
+	StatusUpdated = "updated"
+	StatusSkipped = "skipped"
+	StatusFailed  = "failed"
+	// StatusUnprocessed records weren't imported as the import was cut
+	// short before reaching them.
+	StatusUnprocessed = "unprocessed"
+)
+
+// errDryRun rolls back batches of dry runs.
+var errDryRun = errors.New("userimport: dry run")
+
+// Options configure an import.
+type Options struct {
+	// Aud is the audience of imported users.
+	Aud string
+	// Role is the role of imported users without one.
+	Role string
+	// CheckRole, when set, refuses the roles records can't set.
+	CheckRole func(role string) error
+
+	OnConflict string
+	BatchSize  int
+
+	// DryRun validates and looks up every record, but doesn't save
+	// anything.
+	DryRun bool
+
+	// Encryption encrypts imported password hashes and factor secrets,
+	// and decrypts the ones of exported records.
+	Encryption conf.DatabaseEncryptionConfiguration
+}
+
+// Result is the outcome of importing a record.
+type Result struct {
+	Line   int    `json:"line"`
+	ID     string `json:"id,omitempty"`
+	Email  string `json:"email,omitempty"`
+	Phone  string `json:"phone,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Warnings are the parts of the record that weren't imported, like
+	// unverified factors.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Summary counts the results of an import.
+type Summary struct {
+	Created     int  `json:"created"`
+	Updated     int  `json:"updated"`
+	Skipped     int  `json:"skipped"`
+	Failed      int  `json:"failed"`
+	Unprocessed int  `json:"unprocessed,omitempty"`
+	DryRun      bool `json:"dry_run,omitempty"`
+}
+
+func (s *Summary) add(result Result) {
+	switch result.Status {
+	case StatusCreated:
+		s.Created++
+	case StatusUpdated:
+		s.Updated++
+	case StatusSkipped:
+		s.Skipped++
+	case StatusFailed:
+		s.Failed++
+	case StatusUnprocessed:
+		s.Unprocessed++
+	}
+}
+
+// Importer imports users in batched transactions.
+type Importer struct {
+	db   *storage.Connection
+	opts Options
+
+	// seen maps the IDs, email addresses and phone numbers of records
+	// to the line they were first seen on, to fail duplicates
+	seen map[string]int
+}
+
+// NewImporter returns an importer of users into db.
+func NewImporter(db *storage.Connection, opts Options) (*Importer, error) {
+	switch opts.OnConflict {
+	case "":
+		opts.OnConflict = OnConflictSkip
+
+	case OnConflictSkip, OnConflictUpdate:
+
+	default:
+		return nil, fmt.Errorf("userimport: unknown conflict policy %q, expected %s or %s", opts.OnConflict, OnConflictSkip, OnConflictUpdate)
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	return &Importer{
+		db:   db,
+		opts: opts,
+		seen: make(map[string]int),
+	}, nil
+}
+
+// Import imports all records of the reader, calling report with the result
+// of each record once its batch is committed. When the import is cut short
+// by an error, the records of the failing batch are reported as failed and
+// the ones after it as unprocessed, so that the report still accounts for
+// every record read.
+func (i *Importer) Import(ctx context.Context, reader *Reader, report func(Result) error) (*Summary, error) {
+	summary := &Summary{
+		DryRun: i.opts.DryRun,
+	}
+
+	emit := func(result Result) error {
+		summary.add(result)
+		return report(result)
+	}
+
+	batch := make([]*Record, 0, i.opts.BatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		records := batch
+		batch = batch[:0]
+
+		results, err := i.importBatch(ctx, records)
+		for _, result := range results {
+			if err := emit(result); err != nil {
+				return err
+			}
+		}
+
+		if err != nil {
+			for _, record := range records[len(results):] {
+				if err := emit(failed(record, err)); err != nil {
+					return err
+				}
+			}
+		}
+
+		return err
+	}
+
+	// abort reports the records that won't be imported after err
+	abort := func(err error) (*Summary, error) {
+		for _, record := range batch {
+			if err := emit(unprocessed(record.Line)); err != nil {
+				return summary, err
+			}
+		}
+
+		if ctx.Err() == nil {
+			for {
+				record, rerr := reader.Next()
+				var recordErr *RecordError
+				if errors.As(rerr, &recordErr) {
+					record = &Record{Line: recordErr.Line}
+				} else if rerr != nil {
+					break
+				}
+
+				if err := emit(unprocessed(record.Line)); err != nil {
+					return summary, err
+				}
+			}
+		}
+
+		return summary, err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return abort(err)
+		}
+
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var recordErr *RecordError
+		if errors.As(err, &recordErr) {
+			if err := emit(Result{Line: recordErr.Line, Status: StatusFailed, Error: recordErr.Err.Error()}); err != nil {
+				return summary, err
+			}
+			continue
+		} else if err != nil {
+			return abort(err)
+		}
+
+		if err := i.validate(record); err != nil {
+			if err := emit(failed(record, err)); err != nil {
+				return summary, err
+			}
+			continue
+		}
+
+		batch = append(batch, record)
+		if len(batch) >= i.opts.BatchSize {
+			if err := flush(); err != nil {
+				return abort(err)
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return abort(err)
+	}
+
+	return summary, nil
+}
+
+func unprocessed(line int) Result {
+	return Result{
+		Line:   line,
+		Status: StatusUnprocessed,
+	}
+}
+
+func failed(record *Record, err error) Result {
+	return Result{
+		Line:   record.Line,
+		ID:     record.ID,
+		Email:  record.Email,
+		Phone:  record.Phone,
+		Status: StatusFailed,
+		Error:  err.Error(),
+	}
+}
+
+// validate validates the record and fails records of users already in the
+// import file.
+func (i *Importer) validate(record *Record) error {
+	if err := record.Decrypt(i.opts.Encryption.DecryptionKeys); err != nil {
+		return err
+	}
+
+	if err := record.Validate(); err != nil {
+		return err
+	}
+
+	if record.Role != "" && i.opts.CheckRole != nil {
+		if err := i.opts.CheckRole(record.Role); err != nil {
+			return err
+		}
+	}
+
+	var keys []string
+	if record.userID != uuid.Nil {
+		keys = append(keys, "id:"+record.userID.String())
+	}
+	if record.Email != "" {
+		keys = append(keys, "email:"+record.Email)
+	}
+	if record.Phone != "" {
+		keys = append(keys, "phone:"+record.Phone)
+	}
+
+	for _, key := range keys {
+		if line, ok := i.seen[key]; ok {
+			return fmt.Errorf("duplicate of the user on line %d", line)
+		}
+	}
+
+	for _, key := range keys {
+		i.seen[key] = record.Line
+	}
+
+	return nil
+}
+
+// importBatch imports the records in a transaction. When a record fails in
+// the database, which aborts the transaction, the records are imported
+// again one per transaction. On errors, the results of the records already
+// committed are returned with it.
+func (i *Importer) importBatch(ctx context.Context, batch []*Record) ([]Result, error) {
+	var results []Result
+
+	err := i.db.WithContext(ctx).Transaction(func(tx *storage.Connection) error {
+		results = make([]Result, 0, len(batch))
+
+		for _, record := range batch {
+			result, terr := i.importRecord(tx, record)
+			if terr != nil {
+				return terr
+			}
+			results = append(results, result)
+		}
+
+		if i.opts.DryRun {
+			return errDryRun
+		}
+
+		return nil
+	})
+	if err == nil || errors.Is(err, errDryRun) {
+		return results, nil
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+
+	if len(batch) == 1 {
+		return []Result{failed(batch[0], err)}, nil
+	}
+
+	results = make([]Result, 0, len(batch))
+	for _, record := range batch {
+		result, err := i.importBatch(ctx, []*Record{record})
+		if err != nil {
+			// the records before it were committed
+			return results, err
+		}
+		results = append(results, result...)
+	}
+
+	return results, nil
+}
+
+// importRecord creates or updates the user of the record. Records that
+// can't be imported return a failed result, errors are only returned from
+// the database and abort the transaction.
+func (i *Importer) importRecord(tx *storage.Connection, record *Record) (Result, error) {
+	result := Result{
+		Line:     record.Line,
+		ID:       record.ID,
+		Email:    record.Email,
+		Phone:    record.Phone,
+		Warnings: record.warnings,
+	}
+
+	user, err := i.findUser(tx, record)
+	if err != nil {
+		var recordErr *RecordError
+		if errors.As(err, &recordErr) {
+			return failed(record, recordErr.Err), nil
+		}
+		return result, err
+	}
+
+	// identities the user already has
+	existingIdentities := make(map[int]bool)
+	for n, identity := range record.Identities {
+		found, err := models.FindIdentityByIdAndProvider(tx, identity.ProviderID, identity.Provider)
+		if err != nil {
+			if models.IsNotFoundError(err) {
+				continue
+			}
+			return result, err
+		}
+
+		if user == nil || found.UserID != user.ID {
+			return failed(record, fmt.Errorf("%s identity %q belongs to another user", identity.Provider, identity.ProviderID)), nil
+		}
+		existingIdentities[n] = true
+	}
+
+	if user == nil {
+		user, err = i.createUser(tx, record, existingIdentities)
+		if err != nil {
+			return result, err
+		}
+		result.ID = user.ID.String()
+		result.Status = StatusCreated
+
+		return result, nil
+	}
+
+	result.ID = user.ID.String()
+
+	if i.opts.OnConflict == OnConflictSkip {
+		result.Status = StatusSkipped
+		return result, nil
+	}
+
+	if err := i.updateUser(tx, user, record, existingIdentities); err != nil {
+		return result, err
+	}
+	result.Status = StatusUpdated
+
+	return result, nil
+}
+
+// findUser finds the existing user with the record's ID, email address or
+// phone number. It's a *RecordError when they match different users.
+func (i *Importer) findUser(tx *storage.Connection, record *Record) (*models.User, error) {
+	var found []*models.User
+
+	if record.userID != uuid.Nil {
+		user, err := models.FindUserByID(tx, record.userID)
+		if err != nil && !models.IsNotFoundError(err) {
+			return nil, err
+		}
+		if user != nil {
+			found = append(found, user)
+		}
+	}
+
+	if record.Email != "" {
+		user, err := models.IsDuplicatedEmail(tx, record.Email, i.opts.Aud, nil)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			found = append(found, user)
+		}
+	}
+
+	if record.Phone != "" {
+		user, err := models.FindUserByPhoneAndAudience(tx, record.Phone, i.opts.Aud)
+		if err != nil && !models.IsNotFoundError(err) {
+			return nil, err
+		}
+		if user != nil {
+			found = append(found, user)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	for _, user := range found[1:] {
+		if user.ID != found[0].ID {
+			return nil, &RecordError{Line: record.Line, Err: errors.New("id, email and phone belong to different existing users")}
+		}
+	}
+
+	return found[0], nil
+}
+
+func (i *Importer) createUser(tx *storage.Connection, record *Record, existingIdentities map[int]bool) (*models.User, error) {
+	userMetaData := make(map[string]interface{}, len(record.UserMetaData)+1)
+	for key, value := range record.UserMetaData {
+		userMetaData[key] = value
+	}
+
+	var user *models.User
+	var err error
+	if record.PasswordHash != "" {
+		user, err = models.NewUserWithPasswordHash(record.Phone, record.Email, record.PasswordHash, i.opts.Aud, userMetaData)
+	} else {
+		user, err = models.NewUser(record.Phone, record.Email, "", i.opts.Aud, userMetaData)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if record.userID != uuid.Nil {
+		user.ID = record.userID
+	}
+
+	if i.opts.Encryption.Encrypt {
+		if err := user.EncryptPassword(i.opts.Encryption.EncryptionKeyID, i.opts.Encryption.EncryptionKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if record.CreatedAt != nil {
+		user.CreatedAt = *record.CreatedAt
+	}
+
+	user.Role = i.opts.Role
+	if record.Role != "" {
+		user.Role = record.Role
+	}
+
+	user.EmailConfirmedAt = record.EmailConfirmedAt
+	if user.EmailConfirmedAt != nil {
+		user.UserMetaData["email_verified"] = true
+	}
+	user.PhoneConfirmedAt = record.PhoneConfirmedAt
+
+	var providers []string
+	if record.Email != "" {
+		providers = append(providers, "email")
+	}
+	if record.Phone != "" {
+		providers = append(providers, "phone")
+	}
+	for _, identity := range record.Identities {
+		if !slices.Contains(providers, identity.Provider) {
+			providers = append(providers, identity.Provider)
+		}
+	}
+
+	user.AppMetaData = map[string]interface{}{
+		"provider":  providers[0],
+		"providers": providers,
+	}
+	for key, value := range record.AppMetaData {
+		if value != nil {
+			user.AppMetaData[key] = value
+		} else {
+			delete(user.AppMetaData, key)
+		}
+	}
+
+	if err := tx.Create(user); err != nil {
+		return nil, err
+	}
+
+	if user.GetEmail() != "" {
+		if err := createIdentity(tx, user, "email", structs.Map(provider.Claims{
+			Subject: user.ID.String(),
+			Email:   user.GetEmail(),
+		})); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.GetPhone() != "" {
+		if err := createIdentity(tx, user, "phone", structs.Map(provider.Claims{
+			Subject: user.ID.String(),
+			Phone:   user.GetPhone(),
+		})); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := i.createIdentitiesAndFactors(tx, user, record, existingIdentities); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (i *Importer) updateUser(tx *storage.Connection, user *models.User, record *Record, existingIdentities map[int]bool) error {
+	if record.PasswordHash != "" {
+		passwordHash := record.PasswordHash
+		user.EncryptedPassword = &passwordHash
+		if i.opts.Encryption.Encrypt {
+			if err := user.EncryptPassword(i.opts.Encryption.EncryptionKeyID, i.opts.Encryption.EncryptionKey); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.UpdateOnly(user, "encrypted_password"); err != nil {
+			return err
+		}
+	}
+
+	if record.Role != "" {
+		if err := user.SetRole(tx, record.Role); err != nil {
+			return err
+		}
+	}
+
+	if record.EmailConfirmedAt != nil && user.EmailConfirmedAt == nil && user.GetEmail() == record.Email {
+		user.EmailConfirmedAt = record.EmailConfirmedAt
+		if err := tx.UpdateOnly(user, "email_confirmed_at"); err != nil {
+			return err
+		}
+
+		if err := user.UpdateUserMetaData(tx, map[string]interface{}{
+			"email_verified": true,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if record.PhoneConfirmedAt != nil && user.PhoneConfirmedAt == nil && user.GetPhone() == record.Phone {
+		user.PhoneConfirmedAt = record.PhoneConfirmedAt
+		if err := tx.UpdateOnly(user, "phone_confirmed_at"); err != nil {
+			return err
+		}
+	}
+
+	if record.UserMetaData != nil {
+		if err := user.UpdateUserMetaData(tx, record.UserMetaData); err != nil {
+			return err
+		}
+	}
+
+	if err := i.createIdentitiesAndFactors(tx, user, record, existingIdentities); err != nil {
+		return err
+	}
+
+	if len(record.Identities) > len(existingIdentities) {
+		if err := user.UpdateAppMetaDataProviders(tx); err != nil {
+			return err
+		}
+	}
+
+	if record.AppMetaData != nil {
+		if err := user.UpdateAppMetaData(tx, record.AppMetaData); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createIdentitiesAndFactors creates the record's identities and factors the
+// user doesn't have yet. Factors are matched by friendly name.
+func (i *Importer) createIdentitiesAndFactors(tx *storage.Connection, user *models.User, record *Record, existingIdentities map[int]bool) error {
+	for n, identity := range record.Identities {
+		if existingIdentities[n] {
+			continue
+		}
+
+		identityData := make(map[string]interface{}, len(identity.IdentityData)+1)
+		for key, value := range identity.IdentityData {
+			identityData[key] = value
+		}
+		identityData["sub"] = identity.ProviderID
+
+		if err := createIdentity(tx, user, identity.Provider, identityData); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range record.Factors {
+		if f.skip || slices.ContainsFunc(user.Factors, func(existing models.Factor) bool {
+			return existing.FriendlyName == f.FriendlyName
+		}) {
+			continue
+		}
+
+		factor := models.NewFactor(user, f.FriendlyName, f.FactorType, models.FactorStateVerified)
+		if f.factorID != uuid.Nil {
+			factor.ID = f.factorID
+		}
+
+		switch f.FactorType {
+		case models.TOTP:
+			if err := factor.SetSecret(f.Secret, i.opts.Encryption.Encrypt, i.opts.Encryption.EncryptionKeyID, i.opts.Encryption.EncryptionKey); err != nil {
+				return err
+			}
+
+		case models.Phone:
+			factor.Phone = storage.NullString(f.Phone)
+
+		case models.Email:
+			factor.Email = storage.NullString(f.Email)
+		}
+
+		if err := tx.Create(factor); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createIdentity(tx *storage.Connection, user *models.User, provider string, identityData map[string]interface{}) error {
+	identity, err := models.NewIdentity(user, provider, identityData)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(identity)
+}
@@ -0,0 +1,498 @@
+package userimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base32"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/badoux/checkmail"
+	"github.com/gofrs/uuid"
+	"github.com/linkly-id/auth/internal/crypto"
+	"github.com/linkly-id/auth/internal/models"
+)
+
+// Formats of import files.
+const (
+	// FormatNDJSON is one JSON record per line.
+	FormatNDJSON = "ndjson"
+	// FormatCSV is a header line naming the columns, followed by one
+	// record per line. Metadata, identities and factors are JSON.
+	FormatCSV = "csv"
+)
+
+// maxLineLength bounds the length of an NDJSON record.
+const maxLineLength = 1024 * 1024
+
+// minTOTPSecretLength is the minimum length of imported TOTP secrets in
+// bytes, as recommended by RFC 4226.
+const minTOTPSecretLength = 10
+
+var e164Format = regexp.MustCompile("^[1-9][0-9]{1,14}$")
+
+// Record is a user to import, or an exported user.
+type Record struct {
+	ID               string                 `json:"id,omitempty"`
+	Email            string                 `json:"email,omitempty"`
+	Phone            string                 `json:"phone,omitempty"`
+	PasswordHash     string                 `json:"password_hash,omitempty"`
+	Role             string                 `json:"role,omitempty"`
+	UserMetaData     map[string]interface{} `json:"user_metadata,omitempty"`
+	AppMetaData      map[string]interface{} `json:"app_metadata,omitempty"`
+	EmailConfirmedAt *time.Time             `json:"email_confirmed_at,omitempty"`
+	PhoneConfirmedAt *time.Time             `json:"phone_confirmed_at,omitempty"`
+	CreatedAt        *time.Time             `json:"created_at,omitempty"`
+	Identities       []Identity             `json:"identities,omitempty"`
+	Factors          []Factor               `json:"factors,omitempty"`
+
+	// Line is the line the record starts on in the import file.
+	Line int `json:"-"`
+
+	userID   uuid.UUID
+	warnings []string
+}
+
+// Identity is an external identity of an imported user, like their Google
+// account.
+type Identity struct {
+	Provider     string                 `json:"provider"`
+	ProviderID   string                 `json:"provider_id"`
+	IdentityData map[string]interface{} `json:"identity_data,omitempty"`
+}
+
+// Factor is an MFA factor of an imported user. Verified TOTP, phone and
+// email factors are imported, others are skipped with a warning.
+type Factor struct {
+	ID           string `json:"id,omitempty"`
+	FactorType   string `json:"factor_type"`
+	FriendlyName string `json:"friendly_name,omitempty"`
+	Status       string `json:"status,omitempty"`
+	Phone        string `json:"phone,omitempty"`
+	Email        string `json:"email,omitempty"`
+	// Secret is the base32 secret of TOTP factors. Secrets encrypted
+	// with the database encryption keys, as exported, need the factor ID.
+	Secret string `json:"secret,omitempty"`
+
+	factorID uuid.UUID
+	skip     bool
+}
+
+// RecordError is an invalid record. Importing continues with the next
+// record.
+type RecordError struct {
+	Line int
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks the record and normalizes its email address, phone
+// number and factors. Factors that can't be imported are marked to be
+// skipped with a warning.
+func (r *Record) Validate() error {
+	if r.ID != "" {
+		id, err := uuid.FromString(r.ID)
+		if err != nil {
+			return errors.New("id must be an UUID")
+		}
+		if id == uuid.Nil {
+			return errors.New("id cannot be a nil UUID")
+		}
+		r.userID = id
+	}
+
+	if r.Email == "" && r.Phone == "" {
+		return errors.New("either an email or phone is required")
+	}
+
+	if r.Email != "" {
+		if len(r.Email) > 255 {
+			return errors.New("email address is too long")
+		}
+		if err := checkmail.ValidateFormat(r.Email); err != nil {
+			return fmt.Errorf("invalid email address: %w", err)
+		}
+		r.Email = strings.ToLower(r.Email)
+	} else if r.EmailConfirmedAt != nil {
+		return errors.New("email_confirmed_at requires an email")
+	}
+
+	if r.Phone != "" {
+		r.Phone = strings.ReplaceAll(strings.TrimPrefix(r.Phone, "+"), " ", "")
+		if !e164Format.MatchString(r.Phone) {
+			return errors.New("invalid phone number format (E.164 required)")
+		}
+	} else if r.PhoneConfirmedAt != nil {
+		return errors.New("phone_confirmed_at requires a phone")
+	}
+
+	if r.PasswordHash != "" {
+		if crypto.ParseEncryptedString(r.PasswordHash) != nil {
+			return errors.New("encrypted password_hash must be decrypted first")
+		}
+
+		if err := models.ValidatePasswordHash(r.PasswordHash); err != nil {
+			return fmt.Errorf("invalid password_hash: %w", err)
+		}
+	}
+
+	seenIdentities := make(map[string]bool)
+	for i := range r.Identities {
+		identity := &r.Identities[i]
+
+		switch identity.Provider {
+		case "":
+			return errors.New("identities must have a provider")
+
+		case "email", "phone":
+			return fmt.Errorf("%s identities are created from the user's %s", identity.Provider, identity.Provider)
+		}
+
+		if identity.ProviderID == "" {
+			return fmt.Errorf("%s identity must have a provider_id", identity.Provider)
+		}
+
+		if seenIdentities[identity.Provider+":"+identity.ProviderID] {
+			return fmt.Errorf("duplicate %s identity %q", identity.Provider, identity.ProviderID)
+		}
+		seenIdentities[identity.Provider+":"+identity.ProviderID] = true
+
+		if email, ok := identity.IdentityData["email"]; ok {
+			if _, ok := email.(string); !ok {
+				return fmt.Errorf("%s identity email must be a string", identity.Provider)
+			}
+		}
+	}
+
+	seenFactors := make(map[string]bool)
+	for i := range r.Factors {
+		factor := &r.Factors[i]
+
+		if factor.ID != "" {
+			id, err := uuid.FromString(factor.ID)
+			if err != nil {
+				return errors.New("factor id must be an UUID")
+			}
+			factor.factorID = id
+		}
+
+		if factor.Status != "" && factor.Status != models.FactorStateVerified.String() {
+			factor.skip = true
+			r.warnings = append(r.warnings, fmt.Sprintf("skipped %s factor %q, only verified factors are imported", factor.FactorType, factor.FriendlyName))
+			continue
+		}
+
+		if factor.FactorType == models.WebAuthn || factor.FactorType == models.RecoveryCodes {
+			factor.skip = true
+			r.warnings = append(r.warnings, fmt.Sprintf("skipped %s factor %q, it can't be imported", factor.FactorType, factor.FriendlyName))
+			continue
+		}
+
+		if seenFactors[factor.FriendlyName] {
+			return fmt.Errorf("duplicate factor friendly_name %q", factor.FriendlyName)
+		}
+		seenFactors[factor.FriendlyName] = true
+
+		switch factor.FactorType {
+		case models.TOTP:
+			if factor.Secret == "" {
+				factor.skip = true
+				r.warnings = append(r.warnings, fmt.Sprintf("skipped totp factor %q without a secret", factor.FriendlyName))
+				continue
+			}
+
+			if crypto.ParseEncryptedString(factor.Secret) != nil {
+				return errors.New("encrypted totp factor secret must be decrypted first")
+			}
+
+			factor.Secret = strings.TrimRight(strings.ToUpper(strings.ReplaceAll(factor.Secret, " ", "")), "=")
+			secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(factor.Secret)
+			if err != nil {
+				return fmt.Errorf("totp factor secret must be base32: %w", err)
+			}
+			if len(secret) < minTOTPSecretLength {
+				return fmt.Errorf("totp factor secret must be at least %d bytes", minTOTPSecretLength)
+			}
+
+		case models.Phone:
+			factor.Phone = strings.ReplaceAll(strings.TrimPrefix(factor.Phone, "+"), " ", "")
+			if !e164Format.MatchString(factor.Phone) {
+				return errors.New("phone factor must have a phone number in E.164 format")
+			}
+
+		case models.Email:
+			if err := checkmail.ValidateFormat(factor.Email); err != nil {
+				return fmt.Errorf("email factor must have a valid email address: %w", err)
+			}
+			factor.Email = strings.ToLower(factor.Email)
+
+		default:
+			return fmt.Errorf("unknown factor type %q", factor.FactorType)
+		}
+	}
+
+	return nil
+}
+
+// Decrypt decrypts the password hash and TOTP secrets of records exported
+// from a database encrypted with the keys. The user and factor IDs are
+// required to decrypt them.
+func (r *Record) Decrypt(decryptionKeys map[string]string) error {
+	if es := crypto.ParseEncryptedString(r.PasswordHash); es != nil {
+		if r.ID == "" {
+			return errors.New("encrypted password_hash requires the user id")
+		}
+
+		hash, err := es.Decrypt(r.ID, decryptionKeys)
+		if err != nil {
+			return fmt.Errorf("unable to decrypt password_hash: %w", err)
+		}
+		r.PasswordHash = string(hash)
+	}
+
+	for i := range r.Factors {
+		factor := &r.Factors[i]
+
+		if es := crypto.ParseEncryptedString(factor.Secret); es != nil {
+			if factor.ID == "" {
+				return fmt.Errorf("encrypted secret of factor %q requires the factor id", factor.FriendlyName)
+			}
+
+			secret, err := es.Decrypt(factor.ID, decryptionKeys)
+			if err != nil {
+				return fmt.Errorf("unable to decrypt secret of factor %q: %w", factor.FriendlyName, err)
+			}
+			factor.Secret = string(secret)
+		}
+	}
+
+	return nil
+}
+
+// Reader reads records from an import file.
+type Reader struct {
+	format string
+
+	ndjson  *bufio.Reader
+	buf     []byte
+	csv     *csv.Reader
+	columns []string
+
+	line int
+}
+
+// NewReader returns a reader of records in the format, either FormatNDJSON
+// or FormatCSV.
+func NewReader(r io.Reader, format string) (*Reader, error) {
+	reader := &Reader{
+		format: format,
+	}
+
+	switch format {
+	case FormatNDJSON:
+		reader.ndjson = bufio.NewReaderSize(r, 64*1024)
+
+	case FormatCSV:
+		reader.csv = csv.NewReader(r)
+		reader.csv.ReuseRecord = true
+
+		header, err := reader.csv.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("userimport: csv file has no header")
+			}
+			return nil, fmt.Errorf("userimport: invalid csv header: %w", err)
+		}
+
+		for _, column := range header {
+			column = strings.TrimSpace(column)
+			if _, ok := csvColumns[column]; !ok {
+				return nil, fmt.Errorf("userimport: unknown csv column %q", column)
+			}
+			reader.columns = append(reader.columns, column)
+		}
+
+	default:
+		return nil, fmt.Errorf("userimport: unknown format %q, expected %s or %s", format, FormatNDJSON, FormatCSV)
+	}
+
+	return reader, nil
+}
+
+// Next returns the next record, or io.EOF after the last one. Records which
+// can't be parsed return a *RecordError, after which reading can continue.
+func (r *Reader) Next() (*Record, error) {
+	if r.format == FormatCSV {
+		return r.nextCSV()
+	}
+
+	return r.nextNDJSON()
+}
+
+// errLineTooLong is returned by readLine for lines over maxLineLength.
+var errLineTooLong = fmt.Errorf("record is longer than %d bytes", maxLineLength)
+
+// readLine reads the next NDJSON line. Lines over maxLineLength are skipped
+// with errLineTooLong, so reading can continue with the next line.
+func (r *Reader) readLine() ([]byte, error) {
+	r.buf = r.buf[:0]
+	tooLong := false
+
+	for {
+		chunk, err := r.ndjson.ReadSlice('\n')
+		if !tooLong {
+			if len(r.buf)+len(chunk) > maxLineLength {
+				tooLong = true
+				r.buf = r.buf[:0]
+			} else {
+				r.buf = append(r.buf, chunk...)
+			}
+		}
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if tooLong && (err == nil || errors.Is(err, io.EOF)) {
+			return nil, errLineTooLong
+		}
+
+		return r.buf, err
+	}
+}
+
+func (r *Reader) nextNDJSON() (*Record, error) {
+	for {
+		line, err := r.readLine()
+		if len(line) == 0 && errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, errLineTooLong) {
+			return nil, err
+		}
+
+		r.line++
+
+		if errors.Is(err, errLineTooLong) {
+			return nil, &RecordError{Line: r.line, Err: err}
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		record := &Record{
+			Line: r.line,
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(record); err != nil {
+			return nil, &RecordError{Line: r.line, Err: err}
+		}
+
+		return record, nil
+	}
+}
+
+// csvColumns parse CSV cells into record fields.
+var csvColumns = map[string]func(record *Record, value string) error{
+	"id": func(record *Record, value string) error {
+		record.ID = value
+		return nil
+	},
+	"email": func(record *Record, value string) error {
+		record.Email = value
+		return nil
+	},
+	"phone": func(record *Record, value string) error {
+		record.Phone = value
+		return nil
+	},
+	"password_hash": func(record *Record, value string) error {
+		record.PasswordHash = value
+		return nil
+	},
+	"role": func(record *Record, value string) error {
+		record.Role = value
+		return nil
+	},
+	"user_metadata": func(record *Record, value string) error {
+		return json.Unmarshal([]byte(value), &record.UserMetaData)
+	},
+	"app_metadata": func(record *Record, value string) error {
+		return json.Unmarshal([]byte(value), &record.AppMetaData)
+	},
+	"email_confirmed_at": func(record *Record, value string) error {
+		return parseCSVTime(value, &record.EmailConfirmedAt)
+	},
+	"phone_confirmed_at": func(record *Record, value string) error {
+		return parseCSVTime(value, &record.PhoneConfirmedAt)
+	},
+	"created_at": func(record *Record, value string) error {
+		return parseCSVTime(value, &record.CreatedAt)
+	},
+	"identities": func(record *Record, value string) error {
+		return json.Unmarshal([]byte(value), &record.Identities)
+	},
+	"factors": func(record *Record, value string) error {
+		return json.Unmarshal([]byte(value), &record.Factors)
+	},
+}
+
+func parseCSVTime(value string, t **time.Time) error {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return err
+	}
+
+	*t = &parsed
+	return nil
+}
+
+func (r *Reader) nextCSV() (*Record, error) {
+	cells, err := r.csv.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &RecordError{Line: parseErr.StartLine, Err: parseErr.Err}
+		}
+
+		return nil, err
+	}
+
+	line, _ := r.csv.FieldPos(0)
+	record := &Record{
+		Line: line,
+	}
+
+	if len(cells) != len(r.columns) {
+		return nil, &RecordError{Line: line, Err: fmt.Errorf("expected %d columns, got %d", len(r.columns), len(cells))}
+	}
+
+	for i, column := range r.columns {
+		value := strings.TrimSpace(cells[i])
+		if value == "" {
+			continue
+		}
+
+		if err := csvColumns[column](record, value); err != nil {
+			return nil, &RecordError{Line: line, Err: fmt.Errorf("invalid %s: %w", column, err)}
+		}
+	}
+
+	return record, nil
+}
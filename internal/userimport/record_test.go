@@ -0,0 +1,185 @@
+package userimport
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, reader *Reader) ([]*Record, []*RecordError) {
+	var records []*Record
+	var recordErrs []*RecordError
+
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return records, recordErrs
+		}
+
+		var recordErr *RecordError
+		if errors.As(err, &recordErr) {
+			recordErrs = append(recordErrs, recordErr)
+			continue
+		}
+		require.NoError(t, err)
+
+		records = append(records, record)
+	}
+}
+
+func TestReaderNDJSON(t *testing.T) {
+	reader, err := NewReader(strings.NewReader(`{"email":"jane@example.com","password_hash":"$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0","email_confirmed_at":"2024-01-02T03:04:05Z"}
+
+{"phone":"+1 555 555 0100","identities":[{"provider":"google","provider_id":"123","identity_data":{"email":"jane@gmail.com"}}]}
+{"email":"john@example.com","unknown":true}
+not json
+{"email":"john@example.com","factors":[{"factor_type":"totp","friendly_name":"phone","secret":"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"}]}
+`), FormatNDJSON)
+	require.NoError(t, err)
+
+	records, recordErrs := readAll(t, reader)
+	require.Len(t, records, 3)
+	require.Len(t, recordErrs, 2)
+
+	require.Equal(t, 1, records[0].Line)
+	require.Equal(t, "jane@example.com", records[0].Email)
+	require.NotNil(t, records[0].EmailConfirmedAt)
+
+	require.Equal(t, 3, records[1].Line)
+	require.Len(t, records[1].Identities, 1)
+	require.Equal(t, "google", records[1].Identities[0].Provider)
+
+	require.Equal(t, 6, records[2].Line)
+	require.Len(t, records[2].Factors, 1)
+
+	require.Equal(t, 4, recordErrs[0].Line)
+	require.Equal(t, 5, recordErrs[1].Line)
+
+	for _, record := range records {
+		require.NoError(t, record.Validate(), "line %d", record.Line)
+	}
+	require.Equal(t, "15555550100", records[1].Phone)
+}
+
+func TestReaderNDJSONLongLine(t *testing.T) {
+	long := `{"email":"jane@example.com","user_metadata":{"bio":"` + strings.Repeat("a", maxLineLength) + `"}}`
+	reader, err := NewReader(strings.NewReader(`{"email":"john@example.com"}
+`+long+`
+{"email":"joe@example.com"}
+`+long), FormatNDJSON)
+	require.NoError(t, err)
+
+	// only the records over the limit fail
+	records, recordErrs := readAll(t, reader)
+	require.Len(t, records, 2)
+	require.Equal(t, 1, records[0].Line)
+	require.Equal(t, 3, records[1].Line)
+	require.Equal(t, "joe@example.com", records[1].Email)
+
+	require.Len(t, recordErrs, 2)
+	require.Equal(t, 2, recordErrs[0].Line)
+	require.Equal(t, 4, recordErrs[1].Line)
+	require.ErrorIs(t, recordErrs[0].Err, errLineTooLong)
+}
+
+func TestReaderCSV(t *testing.T) {
+	reader, err := NewReader(strings.NewReader(`email,phone,password_hash,user_metadata,email_confirmed_at,identities,created_at
+Jane@Example.com,,pbkdf2_sha256$1000$seasalt$JgZryXe2Ga8ysg6XbzkLpTdyPQrHqsinbL9BnnhgX4A=,"{""name"":""Jane""}",2024-01-02T03:04:05Z,,2023-05-06T07:08:09Z
+,15555550100,,,,"[{""provider"":""github"",""provider_id"":""42""}]",
+john@example.com,,,not json,,,
+john@example.com,,,,yesterday,,
+john@example.com,
+`), FormatCSV)
+	require.NoError(t, err)
+
+	records, recordErrs := readAll(t, reader)
+	require.Len(t, records, 2)
+	require.Len(t, recordErrs, 3)
+
+	require.Equal(t, 2, records[0].Line)
+	require.Equal(t, "Jane", records[0].UserMetaData["name"])
+	require.NotNil(t, records[0].EmailConfirmedAt)
+	require.Equal(t, time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC), *records[0].CreatedAt)
+	require.NoError(t, records[0].Validate())
+	require.Equal(t, "jane@example.com", records[0].Email)
+
+	require.Equal(t, 3, records[1].Line)
+	require.Equal(t, "42", records[1].Identities[0].ProviderID)
+	require.NoError(t, records[1].Validate())
+
+	require.Equal(t, []int{4, 5, 6}, []int{recordErrs[0].Line, recordErrs[1].Line, recordErrs[2].Line})
+
+	_, err = NewReader(strings.NewReader("email,password\n"), FormatCSV)
+	require.Error(t, err)
+
+	_, err = NewReader(strings.NewReader(""), FormatCSV)
+	require.Error(t, err)
+
+	_, err = NewReader(strings.NewReader(""), "xml")
+	require.Error(t, err)
+}
+
+func TestRecordValidate(t *testing.T) {
+	invalid := map[string]string{
+		"no email or phone":      `{}`,
+		"invalid id":             `{"id":"123","email":"jane@example.com"}`,
+		"nil id":                 `{"id":"00000000-0000-0000-0000-000000000000","email":"jane@example.com"}`,
+		"invalid email":          `{"email":"jane"}`,
+		"invalid phone":          `{"phone":"call me"}`,
+		"confirmed no email":     `{"phone":"15555550100","email_confirmed_at":"2024-01-02T03:04:05Z"}`,
+		"confirmed no phone":     `{"email":"jane@example.com","phone_confirmed_at":"2024-01-02T03:04:05Z"}`,
+		"invalid password hash":  `{"email":"jane@example.com","password_hash":"$P$4IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0"}`,
+		"unknown password hash":  `{"email":"jane@example.com","password_hash":"5f4dcc3b5aa765d61d8327deb882cf99"}`,
+		"email identity":         `{"email":"jane@example.com","identities":[{"provider":"email","provider_id":"jane@example.com"}]}`,
+		"identity no provider":   `{"email":"jane@example.com","identities":[{"provider_id":"123"}]}`,
+		"identity no id":         `{"email":"jane@example.com","identities":[{"provider":"google"}]}`,
+		"duplicate identity":     `{"email":"jane@example.com","identities":[{"provider":"google","provider_id":"1"},{"provider":"google","provider_id":"1"}]}`,
+		"identity email":         `{"email":"jane@example.com","identities":[{"provider":"google","provider_id":"1","identity_data":{"email":42}}]}`,
+		"phone factor":           `{"email":"jane@example.com","factors":[{"factor_type":"phone","secret":"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"}]}`,
+		"factor secret base32":   `{"email":"jane@example.com","factors":[{"factor_type":"totp","secret":"not base32!"}]}`,
+		"factor secret short":    `{"email":"jane@example.com","factors":[{"factor_type":"totp","secret":"JBSWY3DP"}]}`,
+		"email factor":           `{"email":"jane@example.com","factors":[{"factor_type":"email","email":"jane"}]}`,
+		"unknown factor":         `{"email":"jane@example.com","factors":[{"factor_type":"sms"}]}`,
+		"duplicate factor names": `{"email":"jane@example.com","factors":[{"factor_type":"totp","secret":"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"},{"factor_type":"totp","secret":"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"}]}`,
+	}
+
+	for name, line := range invalid {
+		reader, err := NewReader(strings.NewReader(line), FormatNDJSON)
+		require.NoError(t, err)
+
+		record, err := reader.Next()
+		require.NoError(t, err, name)
+		require.Error(t, record.Validate(), name)
+	}
+
+	reader, err := NewReader(strings.NewReader(`{"id":"d2b1f0d6-6f5a-4c55-9a57-4a0f6c0b9a8e","email":"jane@example.com","factors":[{"factor_type":"totp","secret":"jbsw y3dp ehpk 3pxp jbsw y3dp ehpk 3pxp"}]}`), FormatNDJSON)
+	require.NoError(t, err)
+
+	record, err := reader.Next()
+	require.NoError(t, err)
+	require.NoError(t, record.Validate())
+	require.Equal(t, "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP", record.Factors[0].Secret)
+}
+
+func TestRecordValidateSkipsFactors(t *testing.T) {
+	reader, err := NewReader(strings.NewReader(`{"email":"jane@example.com","factors":[{"factor_type":"totp","friendly_name":"app"},{"factor_type":"phone","friendly_name":"sms","status":"unverified"},{"factor_type":"webauthn","friendly_name":"key","status":"verified"},{"factor_type":"phone","friendly_name":"phone","status":"verified","phone":"+1 555 555 0101"},{"factor_type":"email","friendly_name":"email","email":"Jane@Example.com"}]}`), FormatNDJSON)
+	require.NoError(t, err)
+
+	record, err := reader.Next()
+	require.NoError(t, err)
+	require.NoError(t, record.Validate())
+
+	skipped := make([]bool, len(record.Factors))
+	for i, factor := range record.Factors {
+		skipped[i] = factor.skip
+	}
+	require.Equal(t, []bool{true, true, true, false, false}, skipped)
+	require.Len(t, record.warnings, 3)
+
+	require.Equal(t, "15555550101", record.Factors[3].Phone)
+	require.Equal(t, "jane@example.com", record.Factors[4].Email)
+}
@@ -0,0 +1,278 @@
+package utilities
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1" // #nosec G505 -- the Pwned Passwords dataset is keyed by SHA-1
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/bits-and-blooms/bloom/v3"
+	"golang.org/x/crypto/md4" // #nosec G501 -- the Pwned Passwords dataset is keyed by NTLM
+)
+
+const (
+	// HIBPHashSHA1 identifies the SHA-1 version of the Pwned Passwords
+	// dataset.
+	HIBPHashSHA1 = "sha1"
+	// HIBPHashNTLM identifies the NTLM version of the Pwned Passwords
+	// dataset.
+	HIBPHashNTLM = "ntlm"
+
+	// hibpFilterMagic starts the header of filters built with
+	// BuildHIBPDatasetFilter, followed by the dataset hash and a new line.
+	hibpFilterMagic = "HIBPBLOOM1:"
+
+	// hibpMaxLineLength bounds the length of a dataset line, which is a
+	// hash followed by a count, like
+	// 000000005AD76BD555C1D6D771DE417A4B87E4B4:10
+	hibpMaxLineLength = 128
+)
+
+// HIBPHash returns the hash of the password the way it appears in the
+// Pwned Passwords dataset: upper case hex of SHA-1 or NTLM.
+func HIBPHash(hash, password string) (string, error) {
+	var sum []byte
+
+	switch hash {
+	case HIBPHashSHA1:
+		s := sha1.Sum([]byte(password)) // #nosec G401
+		sum = s[:]
+
+	case HIBPHashNTLM:
+		h := md4.New()
+		for _, c := range utf16.Encode([]rune(password)) {
+			var b [2]byte
+			binary.LittleEndian.PutUint16(b[:], c)
+			h.Write(b[:])
+		}
+		sum = h.Sum(nil)
+
+	default:
+		return "", fmt.Errorf("utilities: unknown pwned passwords hash %q", hash)
+	}
+
+	return strings.ToUpper(hex.EncodeToString(sum)), nil
+}
+
+// HIBPDatasetFile looks up passwords in a local copy of the Pwned
+// Passwords dataset, as downloaded by the official downloader: one
+// HASH:COUNT line per password, ordered by hash. The file is binary
+// searched in place, so it isn't loaded into memory.
+type HIBPDatasetFile struct {
+	file *os.File
+	size int64
+	hash string
+}
+
+// OpenHIBPDatasetFile opens a Pwned Passwords dataset hashed with hash.
+func OpenHIBPDatasetFile(path, hash string) (*HIBPDatasetFile, error) {
+	if _, err := HIBPHash(hash, ""); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path) // #nosec G304 -- path is from configuration
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &HIBPDatasetFile{
+		file: file,
+		size: info.Size(),
+		hash: hash,
+	}, nil
+}
+
+func (d *HIBPDatasetFile) Close() error {
+	return d.file.Close()
+}
+
+// lineAt returns the hash on the first line starting at or after offset,
+// or an empty string past the last line.
+func (d *HIBPDatasetFile) lineAt(offset int64) (string, error) {
+	var buf [2 * hibpMaxLineLength]byte
+
+	start := offset
+	if offset > 0 {
+		// include the previous byte to tell whether offset is the
+		// start of a line
+		start = offset - 1
+	}
+
+	n, err := d.file.ReadAt(buf[:], start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	data := buf[:n]
+
+	if offset > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return "", nil
+		}
+		data = data[i+1:]
+	}
+
+	if i := bytes.IndexAny(data, ":\r\n"); i >= 0 {
+		data = data[:i]
+	}
+
+	return strings.ToUpper(string(data)), nil
+}
+
+// Check reports whether the password is in the dataset.
+func (d *HIBPDatasetFile) Check(ctx context.Context, password string) (bool, error) {
+	target, err := HIBPHash(d.hash, password)
+	if err != nil {
+		return false, err
+	}
+
+	// find the first line whose hash isn't ordered before the target
+	lo, hi := int64(0), d.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		hash, err := d.lineAt(mid)
+		if err != nil {
+			return false, err
+		}
+
+		if hash == "" || hash >= target {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	hash, err := d.lineAt(lo)
+	if err != nil {
+		return false, err
+	}
+
+	return hash == target, nil
+}
+
+// HIBPDatasetFilter looks up passwords in a Bloom filter built from the
+// Pwned Passwords dataset with BuildHIBPDatasetFilter. It's much smaller
+// than the dataset, at the cost of some false positives.
+type HIBPDatasetFilter struct {
+	filter *bloom.BloomFilter
+	hash   string
+}
+
+// LoadHIBPDatasetFilter loads a filter built from a dataset hashed with
+// hash.
+func LoadHIBPDatasetFilter(path, hash string) (*HIBPDatasetFilter, error) {
+	if _, err := HIBPHash(hash, ""); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path) // #nosec G304 -- path is from configuration
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	header, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(header, hibpFilterMagic) {
+		return nil, errors.New("utilities: invalid pwned passwords filter")
+	}
+
+	if filterHash := strings.TrimSuffix(strings.TrimPrefix(header, hibpFilterMagic), "\n"); filterHash != hash {
+		return nil, fmt.Errorf("utilities: pwned passwords filter was built from the %s dataset, not %s", filterHash, hash)
+	}
+
+	filter := &bloom.BloomFilter{}
+	if _, err := filter.ReadFrom(reader); err != nil {
+		return nil, fmt.Errorf("utilities: invalid pwned passwords filter: %w", err)
+	}
+
+	return &HIBPDatasetFilter{
+		filter: filter,
+		hash:   hash,
+	}, nil
+}
+
+// Cap returns the size of the filter in bits.
+func (f *HIBPDatasetFilter) Cap() uint {
+	return f.filter.Cap()
+}
+
+// Check reports whether the password is likely in the dataset.
+func (f *HIBPDatasetFilter) Check(ctx context.Context, password string) (bool, error) {
+	hash, err := HIBPHash(f.hash, password)
+	if err != nil {
+		return false, err
+	}
+
+	return f.filter.TestString(hash), nil
+}
+
+// BuildHIBPDatasetFilter reads a Pwned Passwords dataset hashed with hash
+// and writes a Bloom filter of its hashes with the false positive rate to
+// w. The dataset is read twice, once to count its lines.
+func BuildHIBPDatasetFilter(dataset io.ReadSeeker, hash string, w io.Writer, falsePositives float64) (uint, error) {
+	if _, err := HIBPHash(hash, ""); err != nil {
+		return 0, err
+	}
+
+	var count uint
+
+	scanner := bufio.NewScanner(dataset)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			count++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	if _, err := dataset.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	filter := bloom.NewWithEstimates(count, falsePositives)
+
+	scanner = bufio.NewScanner(dataset)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			line = line[:i]
+		}
+
+		filter.AddString(strings.ToUpper(string(line)))
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	if _, err := io.WriteString(w, hibpFilterMagic+hash+"\n"); err != nil {
+		return 0, err
+	}
+
+	if _, err := filter.WriteTo(w); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,123 @@
+package utilities
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	tst "testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeHIBPDataset(t *tst.T, hash, newline string, passwords ...string) string {
+	lines := make([]string, 0, len(passwords))
+	for i, password := range passwords {
+		h, err := HIBPHash(hash, password)
+		require.NoError(t, err)
+
+		lines = append(lines, fmt.Sprintf("%s:%d", h, i+1))
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, newline)+newline), 0600))
+
+	return path
+}
+
+func TestHIBPHash(t *tst.T) {
+	hash, err := HIBPHash(HIBPHashSHA1, "password")
+	require.NoError(t, err)
+	require.Equal(t, "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8", hash)
+
+	hash, err = HIBPHash(HIBPHashNTLM, "password")
+	require.NoError(t, err)
+	require.Equal(t, "8846F7EAEE8FB117AD06BDD830B7586C", hash)
+
+	_, err = HIBPHash("md5", "password")
+	require.Error(t, err)
+}
+
+func TestHIBPDatasetFile(t *tst.T) {
+	var passwords []string
+	for i := 0; i < 1000; i++ {
+		passwords = append(passwords, fmt.Sprintf("password%d", i))
+	}
+
+	for _, hash := range []string{HIBPHashSHA1, HIBPHashNTLM} {
+		for _, newline := range []string{"\n", "\r\n"} {
+			dataset, err := OpenHIBPDatasetFile(writeHIBPDataset(t, hash, newline, passwords...), hash)
+			require.NoError(t, err)
+
+			for _, password := range passwords {
+				pwned, err := dataset.Check(context.Background(), password)
+				require.NoError(t, err)
+				require.True(t, pwned, "%s password %q should be pwned", hash, password)
+			}
+
+			for _, password := range []string{"", "password", "password1000", "zZgXb5gzyCNrV36qwbOSbKVQ"} {
+				pwned, err := dataset.Check(context.Background(), password)
+				require.NoError(t, err)
+				require.False(t, pwned, "%s password %q should not be pwned", hash, password)
+			}
+
+			require.NoError(t, dataset.Close())
+		}
+	}
+}
+
+func TestHIBPDatasetFileSingleLine(t *tst.T) {
+	dataset, err := OpenHIBPDatasetFile(writeHIBPDataset(t, HIBPHashSHA1, "\n", "password"), HIBPHashSHA1)
+	require.NoError(t, err)
+	defer dataset.Close()
+
+	pwned, err := dataset.Check(context.Background(), "password")
+	require.NoError(t, err)
+	require.True(t, pwned)
+
+	pwned, err = dataset.Check(context.Background(), "password1")
+	require.NoError(t, err)
+	require.False(t, pwned)
+}
+
+func TestHIBPDatasetFilter(t *tst.T) {
+	var passwords []string
+	for i := 0; i < 1000; i++ {
+		passwords = append(passwords, fmt.Sprintf("password%d", i))
+	}
+
+	dataset, err := os.Open(writeHIBPDataset(t, HIBPHashNTLM, "\r\n", passwords...))
+	require.NoError(t, err)
+	defer dataset.Close()
+
+	var buffer bytes.Buffer
+	count, err := BuildHIBPDatasetFilter(dataset, HIBPHashNTLM, &buffer, 0.0000099)
+	require.NoError(t, err)
+	require.Equal(t, uint(len(passwords)), count)
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords.bloom")
+	require.NoError(t, os.WriteFile(path, buffer.Bytes(), 0600))
+
+	filter, err := LoadHIBPDatasetFilter(path, HIBPHashNTLM)
+	require.NoError(t, err)
+
+	for _, password := range passwords {
+		pwned, err := filter.Check(context.Background(), password)
+		require.NoError(t, err)
+		require.True(t, pwned, "password %q should be pwned", password)
+	}
+
+	pwned, err := filter.Check(context.Background(), "zZgXb5gzyCNrV36qwbOSbKVQ")
+	require.NoError(t, err)
+	require.False(t, pwned)
+
+	_, err = LoadHIBPDatasetFilter(path, HIBPHashSHA1)
+	require.Error(t, err)
+
+	_, err = LoadHIBPDatasetFilter(dataset.Name(), HIBPHashNTLM)
+	require.Error(t, err)
+}
@@ -0,0 +1,22 @@
+-- Tracks failed password sign-in attempts for the built-in account lockout
+create table if not exists {{ index .Options "Namespace" }}.login_lockouts (
+    id uuid not null,
+    key text not null,
+    user_id uuid null references {{ index .Options "Namespace" }}.users(id) on delete cascade,
+    failed_attempts integer not null default 0,
+    lockout_count integer not null default 0,
+    last_failed_at timestamptz null,
+    locked_until timestamptz null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint login_lockouts_pkey primary key (id),
+    constraint login_lockouts_key_key unique (key)
+);
+
+create index if not exists login_lockouts_user_id_idx
+    on {{ index .Options "Namespace" }}.login_lockouts (user_id);
+
+create index if not exists login_lockouts_updated_at_idx
+    on {{ index .Options "Namespace" }}.login_lockouts (updated_at);
+
+comment on table {{ index .Options "Namespace" }}.login_lockouts is 'auth: stores failed password sign-in attempts and lockouts per user and per identifier';
@@ -0,0 +1,12 @@
+-- auth.passkey_challenges definition
+create table if not exists {{ index .Options "Namespace" }}.passkey_challenges (
+    id uuid not null,
+    ip_address inet not null,
+    web_authn_session_data jsonb not null,
+    created_at timestamptz not null default now(),
+    constraint passkey_challenges_pkey primary key (id)
+);
+
+create index if not exists passkey_challenges_created_at_idx on {{ index .Options "Namespace" }}.passkey_challenges (created_at desc);
+
+comment on table {{ index .Options "Namespace" }}.passkey_challenges is 'auth: stores WebAuthn challenges for passkey sign-ins, which are not tied to a user or factor';
@@ -0,0 +1,9 @@
+do $$ begin
+    alter type {{ index .Options "Namespace" }}.factor_type add value 'email';
+exception
+    when duplicate_object then null;
+end $$;
+
+alter table {{ index .Options "Namespace" }}.mfa_factors add column if not exists email text null;
+
+create unique index if not exists unique_email_factor_per_user on {{ index .Options "Namespace" }}.mfa_factors (user_id, email);
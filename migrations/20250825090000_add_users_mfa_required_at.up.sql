@@ -0,0 +1,3 @@
+alter table {{ index .Options "Namespace" }}.users add column if not exists mfa_required_at timestamptz null;
+
+comment on column {{ index .Options "Namespace" }}.users.mfa_required_at is 'auth: when an MFA policy first required the user to enroll a factor, starting the grace period';
@@ -0,0 +1,13 @@
+-- auth.password_history definition
+create table if not exists {{ index .Options "Namespace" }}.password_history (
+    id uuid not null,
+    user_id uuid not null,
+    encrypted_password text not null,
+    created_at timestamptz not null default now(),
+    constraint password_history_pkey primary key (id),
+    constraint password_history_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade
+);
+
+create index if not exists password_history_user_id_created_at_idx on {{ index .Options "Namespace" }}.password_history (user_id, created_at desc);
+
+comment on table {{ index .Options "Namespace" }}.password_history is 'auth: stores hashes of previous user passwords to prevent their reuse';
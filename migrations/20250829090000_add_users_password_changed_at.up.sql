@@ -0,0 +1,6 @@
+alter table {{ index .Options "Namespace" }}.users
+    add column if not exists password_changed_at timestamptz null,
+    add column if not exists must_change_password boolean not null default false;
+
+comment on column {{ index .Options "Namespace" }}.users.password_changed_at is 'auth: when the user last changed their password, used to expire passwords';
+comment on column {{ index .Options "Namespace" }}.users.must_change_password is 'auth: set by an administrator to require the user to change their password at next sign-in';
@@ -0,0 +1,15 @@
+-- Counts rate limited events shared by all instances, see GOTRUE_RATE_LIMIT_BACKEND
+create table if not exists {{ index .Options "Namespace" }}.rate_limits (
+    key text not null,
+    window_start timestamptz not null,
+    count integer not null default 0,
+    previous_count integer not null default 0,
+    allowed boolean not null default true,
+    expires_at timestamptz not null,
+    constraint rate_limits_pkey primary key (key)
+);
+
+create index if not exists rate_limits_expires_at_idx
+    on {{ index .Options "Namespace" }}.rate_limits (expires_at);
+
+comment on table {{ index .Options "Namespace" }}.rate_limits is 'auth: stores sliding window counts of rate limited events per key';
@@ -0,0 +1,18 @@
+-- auth.admin_api_keys definition
+create table if not exists {{ index .Options "Namespace" }}.admin_api_keys (
+    id uuid not null,
+    name text not null,
+    key_hash text not null,
+    key_prefix text not null,
+    scopes text not null,
+    allowed_cidrs text not null default '',
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    expires_at timestamptz null,
+    last_used_at timestamptz null,
+    last_used_ip text null,
+    constraint admin_api_keys_pkey primary key (id),
+    constraint admin_api_keys_key_hash_key unique (key_hash)
+);
+
+comment on table {{ index .Options "Namespace" }}.admin_api_keys is 'auth: stores hashed admin API keys and the scopes of the admin API they grant';
@@ -0,0 +1,4 @@
+alter table {{ index .Options "Namespace" }}.sessions
+    add column if not exists impersonator text null;
+
+comment on column {{ index .Options "Namespace" }}.sessions.impersonator is 'auth: the administrator acting as the user in an impersonation session, reported in the act claim of its access tokens';
@@ -0,0 +1,28 @@
+-- auth.events definition, the outbox of events delivered by the dispatcher, see GOTRUE_EVENTS_ENABLED
+create table if not exists {{ index .Options "Namespace" }}.events (
+    id uuid not null,
+    type text not null,
+    user_id uuid not null,
+    data jsonb not null default '{}'::jsonb,
+    status text not null default 'pending',
+    attempts integer not null default 0,
+    last_error text null,
+    next_attempt_at timestamptz not null default now(),
+    delivered_at timestamptz null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint events_pkey primary key (id),
+    constraint events_status_check check (status in ('pending', 'delivered', 'dead'))
+);
+
+create index if not exists events_pending_idx
+    on {{ index .Options "Namespace" }}.events (next_attempt_at)
+    where status = 'pending';
+
+create index if not exists events_status_created_at_idx
+    on {{ index .Options "Namespace" }}.events (status, created_at desc);
+
+create index if not exists events_user_id_idx
+    on {{ index .Options "Namespace" }}.events (user_id);
+
+comment on table {{ index .Options "Namespace" }}.events is 'auth: outbox of events about users written with the change, delivered to webhook endpoints';